@@ -1,21 +1,25 @@
 package main
 
 import (
+	"context"
 	"flag"
-	"fmt"
 	"log"
 	"myapp/internal/models"
+	"myapp/internal/rbac"
+	"myapp/internal/repository"
 	"myapp/internal/routes"
 	"myapp/pkg/config"
+	"myapp/pkg/database"
 	"myapp/pkg/logger"
-	"myapp/pkg/migration"
+	"net"
 	"os"
+	"os/signal"
+	"syscall"
 
 	_ "myapp/docs" // Import generated docs
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
@@ -56,6 +60,24 @@ func main() {
 	// Load configuration
 	cfg := config.Load()
 
+	// Manage the schema without starting the server, e.g. "server migrate status"
+	if flag.Arg(0) == "migrate" {
+		code := migrateCommand(cfg.Database, flag.Args()[1:], os.Stdout, os.Stderr)
+		logger.Sync()
+		os.Exit(code)
+	}
+
+	// Give a registered user the admin role, e.g. "server promote-admin admin@example.com"
+	if flag.Arg(0) == "promote-admin" {
+		code := promoteAdminCommand(cfg.Database, flag.Args()[1:], os.Stdout, os.Stderr)
+		logger.Sync()
+		os.Exit(code)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		logger.Log.Fatal("Invalid configuration", zap.Error(err))
+	}
+
 	// Log the active stage
 	activeStage := os.Getenv("APP_STAGE")
 	if activeStage == "" {
@@ -63,35 +85,74 @@ func main() {
 	}
 	logger.Log.Info("Starting server",
 		zap.String("stage", activeStage),
-		zap.String("port", cfg.Server.Port))
+		zap.String("port", cfg.Server.Port),
+		zap.String("database_driver", database.Driver(cfg.Database)))
 
 	// Connect to database
-	db, err := gorm.Open(postgres.Open(cfg.Database.URL), &gorm.Config{})
+	driver := database.Driver(cfg.Database)
+	db, err := database.Open(cfg.Database)
 	if err != nil {
-		logger.Log.Fatal("Failed to connect to database", zap.Error(err))
+		logger.Log.Fatal("Failed to connect to database", zap.String("driver", driver), zap.Error(err))
 	}
 
-	// Run migrations (recommended approach)
-	if err := migration.RunMigrations(cfg.Database.URL, logger.Log); err != nil {
-		logger.Log.Warn("Failed to run migrations, falling back to AutoMigrate", zap.Error(err))
-		// Fallback to GORM AutoMigrate for backward compatibility
-		if err := db.AutoMigrate(&models.User{}); err != nil {
+	if driver == database.DriverPostgres {
+		// Run migrations (recommended approach)
+		if err := applyMigrations(cfg.Database); err != nil {
+			if !cfg.Database.AutoMigrateFallback {
+				logger.Log.Fatal("Failed to run migrations, fix them with the migrate subcommand", zap.Error(err))
+			}
+			// Opt-in fallback to GORM AutoMigrate for backward compatibility
+			logger.Log.Warn("Failed to run migrations, falling back to AutoMigrate", zap.Error(err))
+			if err := autoMigrate(db); err != nil {
+				logger.Log.Fatal("Failed to run AutoMigrate", zap.Error(err))
+			}
+		}
+	} else {
+		// SQL migrations target PostgreSQL; other drivers derive the schema from the models
+		logger.Log.Info("Using AutoMigrate for non-PostgreSQL driver", zap.String("driver", driver))
+		if err := autoMigrate(db); err != nil {
 			logger.Log.Fatal("Failed to run AutoMigrate", zap.Error(err))
 		}
 	}
 
+	// Create built-in roles and permissions
+	if err := rbac.Seed(context.Background(), repository.NewPostgresRoleRepository(db)); err != nil {
+		logger.Log.Fatal("Failed to seed roles and permissions", zap.Error(err))
+	}
+
 	// Setup Gin
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
 
 	// Setup routes
-	routes.SetupRoutes(router, db, logger.Log, cfg.JWT.Secret)
+	readiness, closeRoutes := routes.SetupRoutes(router, db, logger.Log, cfg.JWT.Secret)
 
 	// Start server
-	addr := fmt.Sprintf(":%s", cfg.Server.Port)
-	logger.Log.Info("Server listening", zap.String("addr", addr))
-
-	if err := router.Run(addr); err != nil {
+	srv := newHTTPServer(cfg.Server, router)
+	listener, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
 		logger.Log.Fatal("Failed to start server", zap.Error(err))
 	}
+	logger.Log.Info("Server listening", zap.String("addr", srv.Addr))
+
+	// Drain in-flight requests on SIGTERM (Kubernetes) or SIGINT (Ctrl+C)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if err := serve(ctx, srv, listener, readiness, cfg.Server, logger.Log); err != nil {
+		logger.Log.Error("Server did not shut down cleanly", zap.Error(err))
+	}
+
+	// Release the rate limit stores and close the connection pool once no request can use them anymore
+	closeRoutes()
+	if sqlDB, err := db.DB(); err == nil {
+		if err := sqlDB.Close(); err != nil {
+			logger.Log.Error("Failed to close database connections", zap.Error(err))
+		}
+	}
+	logger.Log.Info("Shutdown complete")
+}
+
+// autoMigrate creates or updates all tables from the GORM models
+func autoMigrate(db *gorm.DB) error {
+	return db.AutoMigrate(&models.User{}, &models.RefreshToken{}, &models.RevokedToken{}, &models.UserTokenRevocation{}, &models.PasswordResetToken{}, &models.EmailVerificationToken{}, &models.LoginFailure{}, &models.UserTOTP{}, &models.MFARecoveryCode{}, &models.Permission{}, &models.Role{}, &models.RoleBinding{}, &models.AuditEvent{})
 }
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"myapp/pkg/config"
+	"myapp/pkg/database"
+	"myapp/pkg/logger"
+	"myapp/pkg/migration"
+	"strconv"
+)
+
+const migrateUsage = `usage: server [-stage STAGE] migrate <command>
+
+commands:
+  up         apply all pending migrations
+  down N     roll back the last N migrations
+  goto V     migrate up or down to version V
+  version    print the current version
+  force V    set the version without running migrations, e.g. after fixing a failed migration
+  status     list migrations and whether they are applied`
+
+// migrator is the part of migration.Migrator used by the migrate command
+type migrator interface {
+	Up() error
+	Down(n int) error
+	Goto(version uint) error
+	Version() (uint, bool, error)
+	Force(version int) error
+	Status() ([]migration.Status, error)
+}
+
+// migrateCommand runs the migrate subcommand against the configured database and returns the exit code
+func migrateCommand(cfg config.DatabaseConfig, args []string, stdout, stderr io.Writer) int {
+	if driver := database.Driver(cfg); driver != database.DriverPostgres {
+		fmt.Fprintf(stderr, "SQL migrations require the postgres driver, configured driver is %q\n", driver)
+		return 1
+	}
+
+	m, err := migration.Open(cfg.MigrationsPath, cfg.URL, logger.Log)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	defer m.Close()
+
+	if err := runMigrate(m, args, stdout); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// applyMigrations applies pending migrations when the server starts
+func applyMigrations(cfg config.DatabaseConfig) error {
+	m, err := migration.Open(cfg.MigrationsPath, cfg.URL, logger.Log)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	return m.Up()
+}
+
+// runMigrate executes a migrate subcommand and writes its output to out
+func runMigrate(m migrator, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	command, args := args[0], args[1:]
+	switch command {
+	case "up":
+		if err := expectArgs(args, 0); err != nil {
+			return err
+		}
+		if err := m.Up(); err != nil {
+			return err
+		}
+	case "down":
+		if err := expectArgs(args, 1); err != nil {
+			return err
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n <= 0 {
+			return fmt.Errorf("down: N must be a positive number, got %q", args[0])
+		}
+		if err := m.Down(n); err != nil {
+			return err
+		}
+	case "goto":
+		if err := expectArgs(args, 1); err != nil {
+			return err
+		}
+		version, err := strconv.ParseUint(args[0], 10, 0)
+		if err != nil {
+			return fmt.Errorf("goto: V must be a version number, got %q", args[0])
+		}
+		if err := m.Goto(uint(version)); err != nil {
+			return err
+		}
+	case "force":
+		if err := expectArgs(args, 1); err != nil {
+			return err
+		}
+		version, err := strconv.Atoi(args[0])
+		if err != nil || version < -1 {
+			return fmt.Errorf("force: V must be a version number or -1, got %q", args[0])
+		}
+		if err := m.Force(version); err != nil {
+			return err
+		}
+	case "version":
+		if err := expectArgs(args, 0); err != nil {
+			return err
+		}
+	case "status":
+		if err := expectArgs(args, 0); err != nil {
+			return err
+		}
+		statuses, err := m.Status()
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Dirty {
+				state = "dirty"
+			} else if s.Applied {
+				state = "applied"
+			}
+			fmt.Fprintf(out, "%06d  %-8s %s\n", s.Version, state, s.Name)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q\n\n%s", command, migrateUsage)
+	}
+
+	return printVersion(m, out)
+}
+
+// printVersion writes the current version, flagging a dirty database
+func printVersion(m migrator, out io.Writer) error {
+	version, dirty, err := m.Version()
+	if err != nil {
+		return err
+	}
+	if dirty {
+		fmt.Fprintf(out, "version %d (dirty: fix the failed migration, then run force %d)\n", version, version)
+		return nil
+	}
+	fmt.Fprintf(out, "version %d\n", version)
+	return nil
+}
+
+// expectArgs checks the number of arguments of a migrate command
+func expectArgs(args []string, n int) error {
+	if len(args) != n {
+		return fmt.Errorf("expected %d argument(s), got %d\n\n%s", n, len(args), migrateUsage)
+	}
+	return nil
+}
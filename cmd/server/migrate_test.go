@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"myapp/pkg/config"
+	"myapp/pkg/migration"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMigrator records calls and tracks the version like a linear migration history
+type fakeMigrator struct {
+	version uint
+	dirty   bool
+	calls   []string
+	err     error
+}
+
+func (f *fakeMigrator) Up() error {
+	f.calls = append(f.calls, "up")
+	f.version = 9
+	return f.err
+}
+
+func (f *fakeMigrator) Down(n int) error {
+	f.calls = append(f.calls, "down")
+	f.version -= uint(n)
+	return f.err
+}
+
+func (f *fakeMigrator) Goto(version uint) error {
+	f.calls = append(f.calls, "goto")
+	f.version = version
+	return f.err
+}
+
+func (f *fakeMigrator) Version() (uint, bool, error) {
+	return f.version, f.dirty, nil
+}
+
+func (f *fakeMigrator) Force(version int) error {
+	f.calls = append(f.calls, "force")
+	f.version, f.dirty = uint(version), false
+	return f.err
+}
+
+func (f *fakeMigrator) Status() ([]migration.Status, error) {
+	return []migration.Status{
+		{Version: 1, Name: "create_users_table", Applied: true},
+		{Version: 2, Name: "create_refresh_tokens_table", Applied: true, Dirty: true},
+		{Version: 3, Name: "create_token_revocations_tables"},
+	}, nil
+}
+
+func TestRunMigrate(t *testing.T) {
+	t.Run("should run commands and print the resulting version", func(t *testing.T) {
+		tests := []struct {
+			args    []string
+			version uint
+			call    string
+		}{
+			{[]string{"up"}, 9, "up"},
+			{[]string{"down", "2"}, 7, "down"},
+			{[]string{"goto", "4"}, 4, "goto"},
+			{[]string{"force", "3"}, 3, "force"},
+		}
+		for _, tt := range tests {
+			m := &fakeMigrator{version: 9}
+			var out bytes.Buffer
+
+			require.NoError(t, runMigrate(m, tt.args, &out), tt.args)
+			assert.Equal(t, []string{tt.call}, m.calls)
+			assert.Equal(t, tt.version, m.version)
+			assert.Contains(t, out.String(), "version ")
+		}
+	})
+
+	t.Run("should print the version and flag a dirty database", func(t *testing.T) {
+		m := &fakeMigrator{version: 5, dirty: true}
+		var out bytes.Buffer
+
+		require.NoError(t, runMigrate(m, []string{"version"}, &out))
+		assert.Equal(t, "version 5 (dirty: fix the failed migration, then run force 5)\n", out.String())
+		assert.Empty(t, m.calls)
+	})
+
+	t.Run("should list migration status", func(t *testing.T) {
+		var out bytes.Buffer
+
+		require.NoError(t, runMigrate(&fakeMigrator{}, []string{"status"}, &out))
+		assert.Equal(t, ""+
+			"000001  applied  create_users_table\n"+
+			"000002  dirty    create_refresh_tokens_table\n"+
+			"000003  pending  create_token_revocations_tables\n", out.String())
+	})
+
+	t.Run("should reject invalid arguments", func(t *testing.T) {
+		for _, args := range [][]string{
+			nil,
+			{"sideways"},
+			{"down"},
+			{"down", "0"},
+			{"down", "x"},
+			{"goto", "-1"},
+			{"force", "-2"},
+			{"up", "extra"},
+		} {
+			m := &fakeMigrator{}
+			assert.Error(t, runMigrate(m, args, &bytes.Buffer{}), args)
+			assert.Empty(t, m.calls, args)
+		}
+	})
+
+	t.Run("should return migration errors", func(t *testing.T) {
+		m := &fakeMigrator{err: errors.New("dirty database")}
+
+		err := runMigrate(m, []string{"up"}, &bytes.Buffer{})
+		assert.EqualError(t, err, "dirty database")
+	})
+}
+
+func TestMigrateCommand(t *testing.T) {
+	t.Run("should refuse drivers other than postgres", func(t *testing.T) {
+		var stderr bytes.Buffer
+
+		code := migrateCommand(config.DatabaseConfig{Driver: "sqlite"}, []string{"up"}, &bytes.Buffer{}, &stderr)
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr.String(), "postgres")
+	})
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"myapp/internal/audit"
+	"myapp/internal/rbac"
+	"myapp/internal/repository"
+	"myapp/pkg/config"
+	"myapp/pkg/database"
+	"myapp/pkg/logger"
+)
+
+const promoteAdminUsage = `usage: server [-stage STAGE] promote-admin <email>
+
+Gives the user registered with email the admin role. Signup always assigns the
+user role, so this creates the first admin; admins promote further users with
+PUT /v1/users/{id}/role.`
+
+// promoteAdminCommand runs the promote-admin subcommand against the configured database and returns the exit code
+func promoteAdminCommand(cfg config.DatabaseConfig, args []string, stdout, stderr io.Writer) int {
+	if len(args) != 1 {
+		fmt.Fprintln(stderr, promoteAdminUsage)
+		return 1
+	}
+	driver := database.Driver(cfg)
+	if driver == database.DriverMemory {
+		fmt.Fprintln(stderr, "promote-admin needs a persistent database, configured driver is \"memory\"")
+		return 1
+	}
+
+	db, err := database.Open(cfg)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+	users, err := repository.NewUserRepository(driver, db)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	auditor := audit.NewRecorder(repository.NewPostgresAuditRepository(db), logger.Log)
+
+	if err := promoteAdmin(context.Background(), users, auditor, args[0], stdout); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// promoteAdmin gives the user with email the admin role, records the change in
+// the audit log and writes the outcome to out
+func promoteAdmin(ctx context.Context, users repository.UserRepository, auditor audit.Auditor, email string, out io.Writer) error {
+	user, err := users.FindByEmail(ctx, email)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return fmt.Errorf("no user is registered with %s", email)
+	}
+	if err != nil {
+		return err
+	}
+	if user.Role == rbac.RoleAdmin {
+		fmt.Fprintf(out, "%s is already an admin\n", email)
+		return nil
+	}
+
+	before := *user
+	user.Role = rbac.RoleAdmin
+	if err := users.Update(ctx, user); err != nil {
+		return err
+	}
+
+	// There is no authenticated actor, so the event is attributed to nobody
+	event := audit.Event{Action: audit.ActionUserRoleChanged, Changes: audit.Diff(&before, user)}.
+		ForUser(user.ID).
+		With("source", "cli")
+	auditor.Record(ctx, event)
+
+	fmt.Fprintf(out, "%s is now an admin\n", email)
+	return nil
+}
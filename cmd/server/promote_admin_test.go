@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"myapp/internal/audit"
+	"myapp/internal/models"
+	"myapp/internal/rbac"
+	"myapp/internal/repository"
+	"myapp/pkg/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// recordingAuditor keeps the recorded audit events
+type recordingAuditor struct {
+	events []audit.Event
+}
+
+func (a *recordingAuditor) Record(ctx context.Context, event audit.Event) {
+	a.events = append(a.events, event)
+}
+
+// newTestUserRepository creates a user repository backed by a private in-memory SQLite database
+func newTestUserRepository(t *testing.T) repository.UserRepository {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	// A single connection keeps every query on the same in-memory database
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	require.NoError(t, db.AutoMigrate(&models.User{}))
+	return repository.NewSQLiteUserRepository(db)
+}
+
+func TestPromoteAdmin(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should give the user the admin role", func(t *testing.T) {
+		users := newTestUserRepository(t)
+		require.NoError(t, users.Create(ctx, &models.User{Name: "Alice", Email: "alice@example.com", Role: rbac.RoleUser}))
+		auditor := &recordingAuditor{}
+		var out bytes.Buffer
+
+		require.NoError(t, promoteAdmin(ctx, users, auditor, "alice@example.com", &out))
+
+		user, err := users.FindByEmail(ctx, "alice@example.com")
+		require.NoError(t, err)
+		assert.Equal(t, rbac.RoleAdmin, user.Role)
+		assert.Equal(t, "alice@example.com is now an admin\n", out.String())
+
+		require.Len(t, auditor.events, 1)
+		event := auditor.events[0]
+		assert.Equal(t, audit.ActionUserRoleChanged, event.Action)
+		assert.Zero(t, event.ActorID)
+		assert.Equal(t, audit.TargetUser, event.TargetType)
+		assert.Equal(t, fmt.Sprint(user.ID), event.TargetID)
+		assert.Equal(t, map[string]audit.Change{"role": {Before: rbac.RoleUser, After: rbac.RoleAdmin}}, event.Changes)
+		assert.Equal(t, map[string]any{"source": "cli"}, event.Metadata)
+	})
+
+	t.Run("should leave admins unchanged", func(t *testing.T) {
+		users := newTestUserRepository(t)
+		require.NoError(t, users.Create(ctx, &models.User{Name: "Alice", Email: "alice@example.com", Role: rbac.RoleAdmin}))
+		auditor := &recordingAuditor{}
+		var out bytes.Buffer
+
+		require.NoError(t, promoteAdmin(ctx, users, auditor, "alice@example.com", &out))
+
+		user, err := users.FindByEmail(ctx, "alice@example.com")
+		require.NoError(t, err)
+		assert.Equal(t, uint(1), user.Version)
+		assert.Empty(t, auditor.events)
+		assert.Equal(t, "alice@example.com is already an admin\n", out.String())
+	})
+
+	t.Run("should fail for unknown emails", func(t *testing.T) {
+		err := promoteAdmin(ctx, newTestUserRepository(t), &recordingAuditor{}, "nobody@example.com", &bytes.Buffer{})
+		assert.EqualError(t, err, "no user is registered with nobody@example.com")
+	})
+}
+
+func TestPromoteAdminCommand(t *testing.T) {
+	t.Run("should require exactly one email", func(t *testing.T) {
+		var stderr bytes.Buffer
+
+		code := promoteAdminCommand(config.DatabaseConfig{Driver: "sqlite"}, nil, &bytes.Buffer{}, &stderr)
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr.String(), "usage:")
+	})
+
+	t.Run("should refuse the memory driver", func(t *testing.T) {
+		var stderr bytes.Buffer
+
+		code := promoteAdminCommand(config.DatabaseConfig{Driver: "memory"}, []string{"alice@example.com"}, &bytes.Buffer{}, &stderr)
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr.String(), "memory")
+	})
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"myapp/pkg/config"
+	"myapp/pkg/health"
+	"net"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// newHTTPServer creates the HTTP server with the timeouts and header limit of cfg
+func newHTTPServer(cfg config.ServerConfig, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           handler,
+		ReadTimeout:       seconds(cfg.ReadTimeout),
+		ReadHeaderTimeout: seconds(cfg.ReadHeaderTimeout),
+		WriteTimeout:      seconds(cfg.WriteTimeout),
+		IdleTimeout:       seconds(cfg.IdleTimeout),
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+}
+
+// serve runs srv on listener until ctx is cancelled. It then reports not ready,
+// keeps serving for the shutdown delay so load balancers stop sending traffic,
+// and waits up to the shutdown timeout for in-flight requests to complete.
+func serve(ctx context.Context, srv *http.Server, listener net.Listener, readiness *health.ShutdownHealthCheckProvider, cfg config.ServerConfig, logger *zap.Logger) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		// The server stopped without a shutdown request
+		return err
+	case <-ctx.Done():
+	}
+
+	logger.Info("Shutting down, reporting not ready",
+		zap.Duration("shutdown_delay", seconds(cfg.ShutdownDelay)),
+		zap.Duration("shutdown_timeout", seconds(cfg.ShutdownTimeout)),
+	)
+	readiness.Shutdown()
+	time.Sleep(seconds(cfg.ShutdownDelay))
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), seconds(cfg.ShutdownTimeout))
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		// Grace period exceeded: drop the remaining connections
+		_ = srv.Close()
+		return err
+	}
+
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	logger.Info("Server stopped")
+	return nil
+}
+
+// seconds converts a configured number of seconds to a duration
+func seconds(n int) time.Duration {
+	return time.Duration(n) * time.Second
+}
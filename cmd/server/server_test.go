@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"io"
+	"myapp/pkg/config"
+	"myapp/pkg/health"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestNewHTTPServer(t *testing.T) {
+	t.Run("should apply timeouts and header limit", func(t *testing.T) {
+		srv := newHTTPServer(config.ServerConfig{
+			Port:              "9090",
+			ReadTimeout:       15,
+			ReadHeaderTimeout: 5,
+			WriteTimeout:      30,
+			IdleTimeout:       120,
+			MaxHeaderBytes:    4096,
+		}, http.NotFoundHandler())
+
+		assert.Equal(t, ":9090", srv.Addr)
+		assert.Equal(t, 15*time.Second, srv.ReadTimeout)
+		assert.Equal(t, 5*time.Second, srv.ReadHeaderTimeout)
+		assert.Equal(t, 30*time.Second, srv.WriteTimeout)
+		assert.Equal(t, 120*time.Second, srv.IdleTimeout)
+		assert.Equal(t, 4096, srv.MaxHeaderBytes)
+	})
+}
+
+// slowServer starts serve with a handler that blocks until release is closed
+func slowServer(t *testing.T, ctx context.Context, cfg config.ServerConfig) (string, chan struct{}, chan struct{}, *health.ShutdownHealthCheckProvider, chan error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		_, _ = io.WriteString(w, "done")
+	})
+
+	readiness := health.NewShutdownHealthCheckProvider()
+	done := make(chan error, 1)
+	go func() {
+		done <- serve(ctx, newHTTPServer(cfg, handler), listener, readiness, cfg, zap.NewNop())
+	}()
+	return "http://" + listener.Addr().String(), started, release, readiness, done
+}
+
+func TestServe(t *testing.T) {
+	t.Run("should report not ready and drain in-flight requests", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		url, started, release, readiness, done := slowServer(t, ctx, config.ServerConfig{ShutdownTimeout: 5})
+
+		response := make(chan string, 1)
+		go func() {
+			resp, err := http.Get(url)
+			if err != nil {
+				response <- err.Error()
+				return
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			response <- string(body)
+		}()
+		<-started
+
+		cancel()
+		assert.Eventually(t, func() bool {
+			result, _ := readiness.Check()
+			return result.Status == health.StatusDown
+		}, time.Second, 10*time.Millisecond)
+
+		// The server waits for the request instead of dropping it
+		select {
+		case err := <-done:
+			t.Fatalf("server stopped with a request in flight: %v", err)
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		close(release)
+		assert.Equal(t, "done", <-response)
+		assert.NoError(t, <-done)
+	})
+
+	t.Run("should give up after the grace period", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		url, started, release, _, done := slowServer(t, ctx, config.ServerConfig{ShutdownTimeout: 0})
+		defer close(release)
+
+		go func() {
+			if resp, err := http.Get(url); err == nil {
+				resp.Body.Close()
+			}
+		}()
+		<-started
+
+		cancel()
+		select {
+		case err := <-done:
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+		case <-time.After(2 * time.Second):
+			t.Fatal("server did not give up after the grace period")
+		}
+	})
+}
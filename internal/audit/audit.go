@@ -0,0 +1,192 @@
+// Package audit records security-relevant actions such as logins and changes
+// to user accounts in an append-only audit log.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"myapp/internal/models"
+	"myapp/internal/repository"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Actions recorded in the audit log
+const (
+	ActionLoginSucceeded  = "auth.login_succeeded"
+	ActionLoginFailed     = "auth.login_failed"
+	ActionLogout          = "auth.logout"
+	ActionTokensRevoked   = "auth.tokens_revoked"
+	ActionPasswordChanged = "auth.password_changed"
+	ActionPasswordReset   = "auth.password_reset"
+	ActionUserCreated     = "user.created"
+	ActionUserUpdated     = "user.updated"
+	ActionUserRoleChanged = "user.role_changed"
+	ActionUserDeleted     = "user.deleted"
+	ActionUserRoleBound   = "user.role_bound"
+	ActionUserRoleUnbound = "user.role_unbound"
+	ActionRoleCreated     = "role.created"
+	ActionRoleUpdated     = "role.updated"
+	ActionRoleDeleted     = "role.deleted"
+)
+
+// Target types of events
+const (
+	// TargetUser is the target type of events concerning a user account
+	TargetUser = "user"
+	// TargetRole is the target type of events concerning a role
+	TargetRole = "role"
+)
+
+// ignoredFields are maintained by the database and left out of diffs
+var ignoredFields = map[string]bool{"updated_at": true}
+
+// Change holds the value of a field before and after an action
+type Change struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// Event describes an action to record
+type Event struct {
+	// ActorID is the authenticated user performing the action, or 0 if anonymous
+	ActorID    uint
+	Action     string
+	TargetType string
+	TargetID   string
+	Changes    map[string]Change
+	Metadata   map[string]any
+	RequestID  string
+	ClientIP   string
+}
+
+// Auditor records events in the audit log. Recording is best effort and
+// never fails the action being audited.
+type Auditor interface {
+	Record(ctx context.Context, event Event)
+}
+
+// NewEvent starts an event for action with the actor, request ID and client IP of the request
+func NewEvent(c *gin.Context, action string) Event {
+	return Event{
+		ActorID:   c.GetUint("user_id"),
+		Action:    action,
+		RequestID: c.GetString("request_id"),
+		ClientIP:  c.ClientIP(),
+	}
+}
+
+// ForUser sets the target of the event to the user with the given ID
+func (e Event) ForUser(id uint) Event {
+	e.TargetType = TargetUser
+	e.TargetID = strconv.FormatUint(uint64(id), 10)
+	return e
+}
+
+// ForRole sets the target of the event to the role with the given ID
+func (e Event) ForRole(id uint) Event {
+	e.TargetType = TargetRole
+	e.TargetID = strconv.FormatUint(uint64(id), 10)
+	return e
+}
+
+// With adds a metadata entry to the event
+func (e Event) With(key string, value any) Event {
+	metadata := make(map[string]any, len(e.Metadata)+1)
+	for k, v := range e.Metadata {
+		metadata[k] = v
+	}
+	metadata[key] = value
+	e.Metadata = metadata
+	return e
+}
+
+// Diff compares the JSON representations of before and after and returns the
+// fields that differ. Either side may be nil, e.g. for created resources.
+// Fields hidden from JSON, such as password hashes, never appear in the diff.
+func Diff(before, after any) map[string]Change {
+	beforeFields := jsonFields(before)
+	afterFields := jsonFields(after)
+
+	changes := map[string]Change{}
+	for name, value := range beforeFields {
+		if ignoredFields[name] {
+			continue
+		}
+		if other, ok := afterFields[name]; !ok || !reflect.DeepEqual(value, other) {
+			changes[name] = Change{Before: value, After: afterFields[name]}
+		}
+	}
+	for name, value := range afterFields {
+		if _, ok := beforeFields[name]; !ok && !ignoredFields[name] {
+			changes[name] = Change{After: value}
+		}
+	}
+	return changes
+}
+
+// jsonFields returns the top-level fields of the JSON representation of v
+func jsonFields(v any) map[string]any {
+	fields := map[string]any{}
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Pointer && reflect.ValueOf(v).IsNil()) {
+		return fields
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fields
+	}
+	_ = json.Unmarshal(raw, &fields)
+	return fields
+}
+
+// Recorder is an Auditor that stores events in an AuditRepository
+type Recorder struct {
+	repo   repository.AuditRepository
+	logger *zap.Logger
+	now    func() time.Time
+}
+
+// NewRecorder creates an auditor storing events in repo
+func NewRecorder(repo repository.AuditRepository, logger *zap.Logger) *Recorder {
+	return &Recorder{repo: repo, logger: logger, now: time.Now}
+}
+
+// Record stores the event; failures are logged because the audited action has already happened
+func (r *Recorder) Record(ctx context.Context, event Event) {
+	record := &models.AuditEvent{
+		OccurredAt: r.now().UTC(),
+		Action:     event.Action,
+		TargetType: event.TargetType,
+		TargetID:   event.TargetID,
+		RequestID:  event.RequestID,
+		ClientIP:   event.ClientIP,
+	}
+	if event.ActorID != 0 {
+		actorID := event.ActorID
+		record.ActorID = &actorID
+	}
+	if len(event.Changes) > 0 {
+		raw, _ := json.Marshal(event.Changes)
+		record.Changes = string(raw)
+	}
+	if len(event.Metadata) > 0 {
+		raw, _ := json.Marshal(event.Metadata)
+		record.Metadata = string(raw)
+	}
+
+	// Record even if the request was cancelled after the action completed
+	if err := r.repo.Create(context.WithoutCancel(ctx), record); err != nil {
+		r.logger.Error("failed to record audit event",
+			zap.Error(err),
+			zap.String("action", event.Action),
+			zap.String("target_type", event.TargetType),
+			zap.String("target_id", event.TargetID),
+			zap.Uint("actor_id", event.ActorID),
+			zap.String("request_id", event.RequestID),
+		)
+	}
+}
@@ -0,0 +1,149 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"myapp/internal/models"
+	"myapp/internal/repository"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestDiff(t *testing.T) {
+	verifiedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := models.User{ID: 1, Name: "Sam", Email: "sam@example.com", PasswordHash: "old", Role: "user", EmailVerifiedAt: &verifiedAt}
+
+	t.Run("should report changed fields only", func(t *testing.T) {
+		after := before
+		after.Email = "sam@example.org"
+		after.EmailVerifiedAt = nil
+		after.PasswordHash = "new"
+		after.UpdatedAt = time.Now()
+
+		changes := Diff(&before, &after)
+		assert.Equal(t, map[string]Change{
+			"email":             {Before: "sam@example.com", After: "sam@example.org"},
+			"email_verified_at": {Before: "2024-01-01T00:00:00Z", After: nil},
+		}, changes)
+	})
+
+	t.Run("should list all fields of created resources", func(t *testing.T) {
+		changes := Diff(nil, &before)
+		assert.Equal(t, Change{After: "Sam"}, changes["name"])
+		assert.Equal(t, Change{After: "user"}, changes["role"])
+		assert.NotContains(t, changes, "password_hash")
+
+		var user *models.User
+		assert.Equal(t, changes, Diff(user, &before))
+	})
+
+	t.Run("should return no changes for equal values", func(t *testing.T) {
+		assert.Empty(t, Diff(&before, &before))
+	})
+}
+
+func TestNewEvent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("should take actor, request ID and client IP from the request", func(t *testing.T) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("DELETE", "/v1/users/7", nil)
+		c.Request.RemoteAddr = "203.0.113.7:1234"
+		c.Set("user_id", uint(3))
+		c.Set("request_id", "req-1")
+
+		event := NewEvent(c, ActionUserDeleted).ForUser(7).With("reason", "test")
+		assert.Equal(t, Event{
+			ActorID:    3,
+			Action:     ActionUserDeleted,
+			TargetType: TargetUser,
+			TargetID:   "7",
+			Metadata:   map[string]any{"reason": "test"},
+			RequestID:  "req-1",
+			ClientIP:   "203.0.113.7",
+		}, event)
+	})
+
+	t.Run("should not share metadata between copies", func(t *testing.T) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("POST", "/v1/login", nil)
+
+		base := NewEvent(c, ActionLoginFailed).With("email", "sam@example.com")
+		first := base.With("reason", "a")
+		second := base.With("reason", "b")
+		assert.Equal(t, "a", first.Metadata["reason"])
+		assert.Equal(t, "b", second.Metadata["reason"])
+		assert.NotContains(t, base.Metadata, "reason")
+	})
+}
+
+// failingAuditRepository rejects every event
+type failingAuditRepository struct{}
+
+func (failingAuditRepository) Create(context.Context, *models.AuditEvent) error {
+	return errors.New("database unavailable")
+}
+
+func (failingAuditRepository) List(context.Context, repository.AuditQueryOptions) (*repository.AuditPage, error) {
+	return nil, errors.New("database unavailable")
+}
+
+func TestRecorder(t *testing.T) {
+	t.Run("should store events with JSON changes and metadata", func(t *testing.T) {
+		db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+		require.NoError(t, err)
+		require.NoError(t, db.AutoMigrate(&models.AuditEvent{}))
+		repo := repository.NewPostgresAuditRepository(db)
+
+		recorder := NewRecorder(repo, zap.NewNop())
+		now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+		recorder.now = func() time.Time { return now }
+
+		recorder.Record(context.Background(), Event{
+			ActorID:    1,
+			Action:     ActionUserUpdated,
+			TargetType: TargetUser,
+			TargetID:   "2",
+			Changes:    map[string]Change{"name": {Before: "Sam", After: "Alex"}},
+			RequestID:  "req-1",
+			ClientIP:   "203.0.113.7",
+		})
+		recorder.Record(context.Background(), Event{Action: ActionLoginFailed, Metadata: map[string]any{"reason": "unknown_email"}})
+
+		page, err := repo.List(context.Background(), repository.AuditQueryOptions{})
+		require.NoError(t, err)
+		require.Len(t, page.Events, 2)
+
+		failed := page.Events[0]
+		assert.Equal(t, ActionLoginFailed, failed.Action)
+		assert.Nil(t, failed.ActorID)
+		assert.Empty(t, failed.Changes)
+		assert.JSONEq(t, `{"reason":"unknown_email"}`, failed.Metadata)
+
+		updated := page.Events[1]
+		require.NotNil(t, updated.ActorID)
+		assert.Equal(t, uint(1), *updated.ActorID)
+		assert.True(t, now.Equal(updated.OccurredAt))
+		assert.Equal(t, "req-1", updated.RequestID)
+		assert.Equal(t, "203.0.113.7", updated.ClientIP)
+		var changes map[string]Change
+		require.NoError(t, json.Unmarshal([]byte(updated.Changes), &changes))
+		assert.Equal(t, Change{Before: "Sam", After: "Alex"}, changes["name"])
+	})
+
+	t.Run("should not panic when storing fails", func(t *testing.T) {
+		recorder := NewRecorder(failingAuditRepository{}, zap.NewNop())
+		assert.NotPanics(t, func() {
+			recorder.Record(context.Background(), Event{Action: ActionLogout})
+		})
+	})
+}
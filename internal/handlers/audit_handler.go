@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"encoding/json"
+	"myapp/internal/models"
+	"myapp/internal/repository"
+	"myapp/pkg/problem"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// AuditHandler exposes the audit log to administrators
+type AuditHandler struct {
+	repo   repository.AuditRepository
+	logger *zap.Logger
+}
+
+// NewAuditHandler creates a new audit handler
+func NewAuditHandler(db *gorm.DB, logger *zap.Logger) *AuditHandler {
+	return &AuditHandler{repo: repository.NewPostgresAuditRepository(db), logger: logger}
+}
+
+// ListAuditEventsQuery represents the query parameters for listing audit events
+type ListAuditEventsQuery struct {
+	Limit      int    `form:"limit" binding:"omitempty,min=1,max=200"`
+	Offset     int    `form:"offset" binding:"omitempty,min=0"`
+	ActorID    *uint  `form:"actor_id"`
+	Action     string `form:"action"`
+	TargetType string `form:"target_type"`
+	TargetID   string `form:"target_id"`
+	Since      string `form:"since"`
+	Until      string `form:"until"`
+}
+
+// AuditEventResponse describes a recorded audit event
+type AuditEventResponse struct {
+	models.AuditEvent
+	Changes  json.RawMessage `json:"changes,omitempty" swaggertype:"object"`
+	Metadata json.RawMessage `json:"metadata,omitempty" swaggertype:"object"`
+}
+
+// AuditListResponse is a page of audit events with pagination details
+type AuditListResponse struct {
+	Data       []AuditEventResponse `json:"data"`
+	Pagination Pagination           `json:"pagination"`
+}
+
+// ListAuditEvents retrieves a filtered page of audit events
+// @Summary List audit events
+// @Description Get a page of audit events, most recent first, with optional filters (requires audit:read)
+// @Tags audit
+// @Produce json
+// @Security bearerauth
+// @Param limit query int false "Page size (1-200, default 50)"
+// @Param offset query int false "Number of events to skip"
+// @Param actor_id query int false "Filter by acting user"
+// @Param action query string false "Filter by action, e.g. user.updated"
+// @Param target_type query string false "Filter by target type, e.g. user"
+// @Param target_id query string false "Filter by target ID"
+// @Param since query string false "Only events at or after this RFC 3339 time"
+// @Param until query string false "Only events before this RFC 3339 time"
+// @Success 200 {object} AuditListResponse
+// @Failure 400 {object} problem.Problem "Invalid query"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Forbidden"
+// @Router /v1/audit [get]
+func (h *AuditHandler) ListAuditEvents(c *gin.Context) {
+	var query ListAuditEventsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		problem.Render(c, problem.FromBinding(err))
+		return
+	}
+
+	opts, err := query.toOptions()
+	if err != nil {
+		problem.Render(c, err)
+		return
+	}
+
+	page, err := h.repo.List(c.Request.Context(), opts)
+	if err != nil {
+		h.logger.Error("failed to fetch audit events", zap.Error(err))
+		problem.Render(c, problem.Internal("failed to fetch audit events"))
+		return
+	}
+
+	limit := opts.Limit
+	if limit == 0 {
+		limit = repository.DefaultAuditPageSize
+	}
+
+	events := make([]AuditEventResponse, len(page.Events))
+	for i, event := range page.Events {
+		events[i] = newAuditEventResponse(event)
+	}
+
+	c.JSON(http.StatusOK, AuditListResponse{
+		Data: events,
+		Pagination: Pagination{
+			Total:  page.Total,
+			Limit:  limit,
+			Offset: opts.Offset,
+		},
+	})
+}
+
+// toOptions converts query parameters to repository query options
+func (q ListAuditEventsQuery) toOptions() (repository.AuditQueryOptions, error) {
+	opts := repository.AuditQueryOptions{
+		Limit:      q.Limit,
+		Offset:     q.Offset,
+		ActorID:    q.ActorID,
+		Action:     q.Action,
+		TargetType: q.TargetType,
+		TargetID:   q.TargetID,
+	}
+
+	if q.Since != "" {
+		t, err := time.Parse(time.RFC3339, q.Since)
+		if err != nil {
+			return opts, invalidQuery("since", "must be an RFC 3339 timestamp")
+		}
+		opts.Since = &t
+	}
+	if q.Until != "" {
+		t, err := time.Parse(time.RFC3339, q.Until)
+		if err != nil {
+			return opts, invalidQuery("until", "must be an RFC 3339 timestamp")
+		}
+		opts.Until = &t
+	}
+
+	return opts, nil
+}
+
+// newAuditEventResponse converts a stored event to its API representation
+func newAuditEventResponse(event models.AuditEvent) AuditEventResponse {
+	response := AuditEventResponse{AuditEvent: event}
+	if event.Changes != "" {
+		response.Changes = json.RawMessage(event.Changes)
+	}
+	if event.Metadata != "" {
+		response.Metadata = json.RawMessage(event.Metadata)
+	}
+	return response
+}
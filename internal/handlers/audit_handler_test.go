@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"myapp/internal/audit"
+	"myapp/internal/models"
+	"myapp/internal/rbac"
+	"myapp/internal/repository"
+	"myapp/pkg/problem"
+	"myapp/pkg/utils"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupAuditTest(t *testing.T) (*gorm.DB, *gin.Engine) {
+	gin.SetMode(gin.TestMode)
+	db := setupTestDB(t)
+	logger := setupTestLogger()
+
+	hashedPassword, _ := utils.HashPassword("password123")
+	db.Create(&models.User{Name: "Test User", Email: "test@example.com", PasswordHash: hashedPassword, Role: "user"})
+
+	auditor := audit.NewRecorder(repository.NewPostgresAuditRepository(db), logger)
+	authHandler := NewAuthHandler(db, "test-secret", logger).WithAuditor(auditor)
+	userHandler := NewUserHandler(repository.NewPostgresUserRepository(db)).WithAuditor(auditor)
+	auditHandler := NewAuditHandler(db, logger)
+
+	router := gin.New()
+	router.POST("/login", authHandler.Login)
+	router.POST("/users", userHandler.CreateUser)
+
+	// Simulate the JWT middleware for an administrator
+	admin := router.Group("/")
+	admin.Use(func(c *gin.Context) {
+		c.Set("user_id", uint(42))
+		c.Set("user_role", "admin")
+		c.Set("user_permissions", []string{rbac.PermUsersUpdate})
+		c.Set("request_id", "req-42")
+		c.Next()
+	})
+	admin.PUT("/users/:id", userHandler.UpdateUser)
+	admin.PUT("/users/:id/role", userHandler.UpdateUserRole)
+	admin.DELETE("/users/:id", userHandler.DeleteUser)
+	admin.GET("/audit", auditHandler.ListAuditEvents)
+
+	return db, router
+}
+
+// listAudit fetches audit events with the given query string
+func listAudit(t *testing.T, router *gin.Engine, query string) AuditListResponse {
+	w := postJSON(router, "GET", "/audit"+query, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response AuditListResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response
+}
+
+func TestAuditLogins(t *testing.T) {
+	t.Run("should record successful and failed logins", func(t *testing.T) {
+		_, router := setupAuditTest(t)
+
+		loginTestUser(t, router, "test@example.com", "password123")
+		postJSON(router, "POST", "/login", LoginRequest{Email: "test@example.com", Password: "wrong-password"})
+		postJSON(router, "POST", "/login", LoginRequest{Email: "nobody@example.com", Password: "password123"})
+
+		response := listAudit(t, router, "?action="+audit.ActionLoginFailed)
+		require.Len(t, response.Data, 2)
+		assert.Nil(t, response.Data[0].ActorID)
+		assert.Empty(t, response.Data[0].TargetID)
+		assert.JSONEq(t, `{"email":"nobody@example.com","reason":"unknown_email"}`, string(response.Data[0].Metadata))
+		assert.Equal(t, "1", response.Data[1].TargetID)
+		assert.JSONEq(t, `{"email":"test@example.com","reason":"invalid_password"}`, string(response.Data[1].Metadata))
+
+		response = listAudit(t, router, "?action="+audit.ActionLoginSucceeded)
+		require.Len(t, response.Data, 1)
+		require.NotNil(t, response.Data[0].ActorID)
+		assert.Equal(t, uint(1), *response.Data[0].ActorID)
+	})
+}
+
+func TestAuditUserChanges(t *testing.T) {
+	t.Run("should record who changed what", func(t *testing.T) {
+		_, router := setupAuditTest(t)
+
+		w := postJSON(router, "POST", "/users", CreateUserRequest{Name: "Sam", Email: "sam@example.com", Password: "password123"})
+		require.Equal(t, http.StatusCreated, w.Code)
+		var user models.User
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &user))
+
+		w = postJSON(router, "PUT", fmt.Sprintf("/users/%d", user.ID), UpdateUserRequest{Name: "Alex"})
+		require.Equal(t, http.StatusOK, w.Code)
+		w = postJSON(router, "DELETE", fmt.Sprintf("/users/%d", user.ID), nil)
+		require.Equal(t, http.StatusNoContent, w.Code)
+
+		response := listAudit(t, router, fmt.Sprintf("?target_type=user&target_id=%d", user.ID))
+		require.Len(t, response.Data, 3)
+		assert.Equal(t, int64(3), response.Pagination.Total)
+
+		deleted, updated, created := response.Data[0], response.Data[1], response.Data[2]
+		assert.Equal(t, audit.ActionUserDeleted, deleted.Action)
+		assert.Contains(t, string(deleted.Changes), `"email":{"before":"sam@example.com","after":null}`)
+		assert.NotContains(t, string(deleted.Changes), "password")
+
+		assert.Equal(t, audit.ActionUserUpdated, updated.Action)
+		require.NotNil(t, updated.ActorID)
+		assert.Equal(t, uint(42), *updated.ActorID)
+		assert.Equal(t, "req-42", updated.RequestID)
+		assert.JSONEq(t, `{"name":{"before":"Sam","after":"Alex"}}`, string(updated.Changes))
+
+		assert.Equal(t, audit.ActionUserCreated, created.Action)
+		assert.Nil(t, created.ActorID)
+		assert.Contains(t, string(created.Changes), `"email":{"before":null,"after":"sam@example.com"}`)
+		assert.NotContains(t, string(created.Changes), "password")
+	})
+}
+
+func TestAuditRoleChanges(t *testing.T) {
+	t.Run("should record role changes separately", func(t *testing.T) {
+		_, router := setupAuditTest(t)
+
+		w := postJSON(router, "POST", "/users", CreateUserRequest{Name: "Sam", Email: "sam@example.com", Password: "password123"})
+		require.Equal(t, http.StatusCreated, w.Code)
+		var user models.User
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &user))
+
+		w = postJSON(router, "PUT", fmt.Sprintf("/users/%d/role", user.ID), UpdateUserRoleRequest{Role: rbac.RoleAdmin})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		response := listAudit(t, router, "?action="+audit.ActionUserRoleChanged)
+		require.Len(t, response.Data, 1)
+		changed := response.Data[0]
+		require.NotNil(t, changed.ActorID)
+		assert.Equal(t, uint(42), *changed.ActorID)
+		assert.Equal(t, fmt.Sprint(user.ID), changed.TargetID)
+		assert.JSONEq(t, `{"role":{"before":"user","after":"admin"}}`, string(changed.Changes))
+	})
+}
+
+func TestListAuditEvents(t *testing.T) {
+	t.Run("should filter by actor and paginate", func(t *testing.T) {
+		_, router := setupAuditTest(t)
+		for i := 0; i < 3; i++ {
+			loginTestUser(t, router, "test@example.com", "password123")
+		}
+		postJSON(router, "PUT", "/users/1", UpdateUserRequest{Name: "Renamed"})
+
+		response := listAudit(t, router, "?actor_id=1&limit=2")
+		assert.Len(t, response.Data, 2)
+		assert.Equal(t, int64(3), response.Pagination.Total)
+		assert.Equal(t, 2, response.Pagination.Limit)
+
+		response = listAudit(t, router, "?actor_id=1&limit=2&offset=2")
+		assert.Len(t, response.Data, 1)
+
+		response = listAudit(t, router, "?actor_id=42")
+		require.Len(t, response.Data, 1)
+		assert.Equal(t, audit.ActionUserUpdated, response.Data[0].Action)
+
+		response = listAudit(t, router, "?since=2999-01-01T00:00:00Z")
+		assert.Empty(t, response.Data)
+		assert.NotNil(t, response.Data)
+	})
+
+	t.Run("should reject invalid filters", func(t *testing.T) {
+		_, router := setupAuditTest(t)
+
+		for query, code := range map[string]string{
+			"?since=yesterday": problem.CodeValidationFailed,
+			"?until=soon":      problem.CodeValidationFailed,
+			"?limit=500":       problem.CodeValidationFailed,
+			"?actor_id=abc":    problem.CodeInvalidRequest,
+		} {
+			w := postJSON(router, "GET", "/audit"+query, nil)
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+			assert.Equal(t, code, problemCode(t, w), query)
+		}
+	})
+}
@@ -1,28 +1,138 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"myapp/internal/audit"
+	"myapp/internal/lockout"
+	"myapp/internal/middleware"
+	"myapp/internal/models"
+	"myapp/internal/repository"
+	"myapp/pkg/jwks"
+	"myapp/pkg/problem"
 	"myapp/pkg/utils"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
+const (
+	// DefaultAccessTokenTTL is the lifetime of access tokens issued on login and refresh
+	DefaultAccessTokenTTL = 15 * time.Minute
+	// DefaultRefreshTokenTTL is the lifetime of refresh tokens
+	DefaultRefreshTokenTTL = 7 * 24 * time.Hour
+	// DefaultMFAChallengeTTL is the time a user has to present the second factor after the password
+	DefaultMFAChallengeTTL = 5 * time.Minute
+)
+
 // AuthHandler handles authentication-related HTTP requests
 type AuthHandler struct {
-	db     *gorm.DB
-	secret string
-	logger *zap.Logger
+	db            *gorm.DB
+	secret        string
+	keys          *jwks.KeySet
+	logger        *zap.Logger
+	users         repository.UserRepository
+	refreshTokens repository.RefreshTokenRepository
+	revocations   repository.TokenRevocationRepository
+	accessTTL     time.Duration
+	refreshTTL    time.Duration
+	// requireVerifiedEmail rejects logins of users who have not confirmed their email address
+	requireVerifiedEmail bool
+	lockout              *lockout.Service
+	// mfa enables the second login step for users with a confirmed TOTP factor
+	mfa             repository.MFARepository
+	mfaChallengeTTL time.Duration
+	// permissions, if set, are embedded in access tokens
+	permissions middleware.PermissionResolver
+	auditor     audit.Auditor
 }
 
 // NewAuthHandler creates a new auth handler
 func NewAuthHandler(db *gorm.DB, secret string, logger *zap.Logger) *AuthHandler {
 	return &AuthHandler{
-		db:     db,
-		secret: secret,
-		logger: logger,
+		db:              db,
+		secret:          secret,
+		keys:            jwks.NewHMACKeySet(secret),
+		logger:          logger,
+		users:           repository.NewPostgresUserRepository(db),
+		refreshTokens:   repository.NewPostgresRefreshTokenRepository(db),
+		revocations:     repository.NewPostgresTokenRevocationRepository(db),
+		accessTTL:       DefaultAccessTokenTTL,
+		refreshTTL:      DefaultRefreshTokenTTL,
+		mfa:             repository.NewPostgresMFARepository(db),
+		mfaChallengeTTL: DefaultMFAChallengeTTL,
+		auditor:         audit.NewRecorder(repository.NewPostgresAuditRepository(db), logger),
+	}
+}
+
+// WithTokenTTLs overrides the access and refresh token lifetimes.
+// Non-positive values keep the current setting.
+func (h *AuthHandler) WithTokenTTLs(accessTTL, refreshTTL time.Duration) *AuthHandler {
+	if accessTTL > 0 {
+		h.accessTTL = accessTTL
+	}
+	if refreshTTL > 0 {
+		h.refreshTTL = refreshTTL
+	}
+	return h
+}
+
+// WithEmailVerificationRequired makes Login reject users with an unverified email address
+func (h *AuthHandler) WithEmailVerificationRequired(required bool) *AuthHandler {
+	h.requireVerifiedEmail = required
+	return h
+}
+
+// WithLockout enables throttling of failed logins per account and client IP
+func (h *AuthHandler) WithLockout(service *lockout.Service) *AuthHandler {
+	h.lockout = service
+	return h
+}
+
+// WithMFAChallengeTTL sets how long an MFA challenge token stays valid.
+// A non-positive ttl keeps the current setting.
+func (h *AuthHandler) WithMFAChallengeTTL(ttl time.Duration) *AuthHandler {
+	if ttl > 0 {
+		h.mfaChallengeTTL = ttl
 	}
+	return h
+}
+
+// WithPermissions embeds the permissions of the user in every access token
+func (h *AuthHandler) WithPermissions(resolver middleware.PermissionResolver) *AuthHandler {
+	h.permissions = resolver
+	return h
+}
+
+// WithAuditor sets where logins, logouts and token revocations are recorded
+func (h *AuthHandler) WithAuditor(auditor audit.Auditor) *AuthHandler {
+	h.auditor = auditor
+	return h
+}
+
+// WithUserRepository sets the repository used to look up users
+func (h *AuthHandler) WithUserRepository(users repository.UserRepository) *AuthHandler {
+	h.users = users
+	return h
+}
+
+// WithKeySet sets the keys used to sign access tokens, replacing the HS256 secret
+func (h *AuthHandler) WithKeySet(keys *jwks.KeySet) *AuthHandler {
+	h.keys = keys
+	return h
+}
+
+// WithRevocations sets the token revocation list. It should be the same
+// instance used by JWTAuthMiddlewareWithRevocation so cached state agrees.
+func (h *AuthHandler) WithRevocations(revocations repository.TokenRevocationRepository) *AuthHandler {
+	h.revocations = revocations
+	return h
 }
 
 // LoginRequest represents the request body for login
@@ -31,10 +141,42 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+// TokenResponse holds an access token and the refresh token that renews it
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// RefreshRequest represents the request body for refreshing a token
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// LogoutRequest represents the optional request body for logout
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// MFAChallengeResponse is returned by Login instead of tokens when the user
+// has a second factor. The mfa_token must be exchanged at /v1/login/mfa.
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required" example:"true"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int64  `json:"expires_in" example:"300"`
+}
+
+// MFALoginRequest represents the request body for completing a login with a second factor
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	SecondFactorRequest
+}
+
 // LoginResponse represents the response for successful login
 type LoginResponse struct {
-	Token string `json:"token"`
-	User  struct {
+	TokenResponse
+	User struct {
 		ID    uint   `json:"id"`
 		Name  string `json:"name"`
 		Email string `json:"email"`
@@ -44,14 +186,16 @@ type LoginResponse struct {
 
 // Login authenticates a user and returns a JWT token
 // @Summary Login user
-// @Description Authenticate user and return JWT token
+// @Description Authenticate user and return a short-lived JWT access token plus a refresh token
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param request body LoginRequest true "Login credentials"
-// @Success 200 {object} LoginResponse
-// @Failure 400 {object} map[string]string "Invalid request"
-// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Success 200 {object} LoginResponse "Tokens, or an MFAChallengeResponse if the user has a second factor"
+// @Failure 400 {object} problem.Problem "Invalid request"
+// @Failure 401 {object} problem.Problem "Invalid credentials"
+// @Failure 403 {object} problem.Problem "Email address not verified"
+// @Failure 429 {object} problem.Problem "Too many failed attempts, see Retry-After"
 // @Router /v1/login [post]
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req LoginRequest
@@ -60,7 +204,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 			zap.String("error", err.Error()),
 			zap.String("client_ip", c.ClientIP()),
 		)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		problem.Render(c, problem.FromBinding(err))
 		return
 	}
 
@@ -68,17 +212,34 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	requestID, _ := c.Get("request_id")
 	clientIP := c.ClientIP()
 
-	// Find user by email
-	var user struct {
-		ID           uint
-		Name         string
-		Email        string
-		PasswordHash string
-		Role         string
+	// Refuse attempts while the account or IP is locked, even with the right password
+	if h.lockout != nil {
+		wait, err := h.lockout.Check(c.Request.Context(), req.Email, clientIP)
+		if err != nil {
+			// Fail open so a tracking outage does not block every login
+			h.logger.Error("failed to check login lockout",
+				zap.Error(err),
+				zap.String("client_ip", clientIP),
+				zap.Any("request_id", requestID),
+			)
+		} else if wait > 0 {
+			h.logger.Warn("login attempt while locked out",
+				zap.String("email", req.Email),
+				zap.String("client_ip", clientIP),
+				zap.Any("request_id", requestID),
+				zap.Duration("retry_after", wait),
+			)
+			h.auditLoginFailure(c, req.Email, 0, "locked_out")
+			setRetryAfter(c, wait)
+			problem.Render(c, problem.TooManyRequests(problem.CodeLoginLocked, "too many failed login attempts, try again later"))
+			return
+		}
 	}
 
-	if err := h.db.WithContext(c.Request.Context()).Table("users").Where("email = ?", req.Email).First(&user).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
+	// Find user by email
+	user, err := h.users.FindByEmail(c.Request.Context(), req.Email)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
 			h.logger.Warn("login attempt with unknown email",
 				zap.String("email", req.Email),
 				zap.String("client_ip", clientIP),
@@ -92,7 +253,9 @@ func (h *AuthHandler) Login(c *gin.Context) {
 				zap.Any("request_id", requestID),
 			)
 		}
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+		h.recordLoginFailure(c, req.Email)
+		h.auditLoginFailure(c, req.Email, 0, "unknown_email")
+		problem.Render(c, problem.Unauthorized(problem.CodeInvalidCredentials, "invalid credentials"))
 		return
 	}
 
@@ -104,21 +267,62 @@ func (h *AuthHandler) Login(c *gin.Context) {
 			zap.Any("request_id", requestID),
 			zap.Uint("user_id", user.ID),
 		)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+		h.recordLoginFailure(c, req.Email)
+		h.auditLoginFailure(c, req.Email, user.ID, "invalid_password")
+		problem.Render(c, problem.Unauthorized(problem.CodeInvalidCredentials, "invalid credentials"))
+		return
+	}
+
+	if h.lockout != nil {
+		if err := h.lockout.RecordSuccess(c.Request.Context(), req.Email); err != nil {
+			h.logger.Error("failed to reset login failures",
+				zap.Error(err),
+				zap.Uint("user_id", user.ID),
+				zap.Any("request_id", requestID),
+			)
+		}
+	}
+
+	// Checked after the password so the response does not reveal unverified accounts
+	if h.requireVerifiedEmail && !user.IsEmailVerified() {
+		h.logger.Warn("login attempt with unverified email",
+			zap.String("email", req.Email),
+			zap.String("client_ip", clientIP),
+			zap.Any("request_id", requestID),
+			zap.Uint("user_id", user.ID),
+		)
+		h.auditLoginFailure(c, req.Email, user.ID, "email_not_verified")
+		problem.Render(c, problem.Forbidden(problem.CodeEmailNotVerified, "email address not verified"))
 		return
 	}
 
-	// Generate JWT
-	token, err := utils.GenerateJWT(user.ID, user.Role, h.secret)
+	// Users with a second factor get a challenge token instead of access tokens
+	factor, err := h.mfa.FindTOTP(c.Request.Context(), user.ID)
+	if err != nil && !errors.Is(err, repository.ErrTOTPNotFound) {
+		h.logger.Error("failed to look up second factor",
+			zap.Error(err),
+			zap.Uint("user_id", user.ID),
+			zap.Any("request_id", requestID),
+		)
+		problem.Render(c, problem.Internal("failed to generate token"))
+		return
+	}
+	if err == nil && factor.IsConfirmed() {
+		h.issueMFAChallenge(c, user)
+		return
+	}
+
+	// Generate access and refresh tokens, starting a new rotation family
+	tokens, err := h.issueTokens(c.Request.Context(), user.ID, user.Role, uuid.New().String(), nil)
 	if err != nil {
-		h.logger.Error("failed to generate JWT token",
+		h.logger.Error("failed to generate tokens",
 			zap.Error(err),
 			zap.Uint("user_id", user.ID),
 			zap.String("email", req.Email),
 			zap.String("client_ip", clientIP),
 			zap.Any("request_id", requestID),
 		)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		problem.Render(c, problem.Internal("failed to generate token"))
 		return
 	}
 
@@ -130,14 +334,524 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		zap.String("client_ip", clientIP),
 		zap.Any("request_id", requestID),
 	)
+	h.auditLoginSuccess(c, user, false)
+
+	c.JSON(http.StatusOK, newLoginResponse(user, tokens))
+}
+
+// LoginMFA completes a login by exchanging an MFA challenge token and a second factor for tokens
+// @Summary Complete login with second factor
+// @Description Exchange the mfa_token returned by /v1/login together with a TOTP code or an unused recovery code for a JWT access token and a refresh token
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body MFALoginRequest true "Challenge token and TOTP code or recovery code"
+// @Success 200 {object} LoginResponse
+// @Failure 400 {object} problem.Problem "Invalid request"
+// @Failure 401 {object} problem.Problem "Invalid or expired MFA token, or invalid code"
+// @Failure 429 {object} problem.Problem "Too many failed attempts, see Retry-After"
+// @Router /v1/login/mfa [post]
+func (h *AuthHandler) LoginMFA(c *gin.Context) {
+	var req MFALoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		problem.Render(c, problem.FromBinding(err))
+		return
+	}
+	if !req.valid() {
+		problem.Render(c, problem.BadRequest(problem.CodeInvalidRequest, "either code or recovery_code is required"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	requestID, _ := c.Get("request_id")
+	clientIP := c.ClientIP()
+
+	userID, jti, expiresAt, ok := h.parseMFAChallenge(ctx, req.MFAToken)
+	if !ok {
+		problem.Render(c, problem.Unauthorized(problem.CodeInvalidMFAToken, "invalid or expired MFA token"))
+		return
+	}
+
+	user, err := h.users.FindByID(ctx, userID)
+	if err != nil {
+		problem.Render(c, problem.Unauthorized(problem.CodeInvalidMFAToken, "invalid or expired MFA token"))
+		return
+	}
+
+	if h.lockout != nil {
+		wait, err := h.lockout.Check(ctx, user.Email, clientIP)
+		if err != nil {
+			h.logger.Error("failed to check login lockout",
+				zap.Error(err),
+				zap.String("client_ip", clientIP),
+				zap.Any("request_id", requestID),
+			)
+		} else if wait > 0 {
+			setRetryAfter(c, wait)
+			problem.Render(c, problem.TooManyRequests(problem.CodeLoginLocked, "too many failed login attempts, try again later"))
+			return
+		}
+	}
+
+	// The factor may have been removed since the challenge was issued
+	factor, err := h.mfa.FindTOTP(ctx, user.ID)
+	if err != nil || !factor.IsConfirmed() {
+		problem.Render(c, problem.Unauthorized(problem.CodeInvalidMFAToken, "invalid or expired MFA token"))
+		return
+	}
+
+	valid, err := verifySecondFactor(ctx, h.mfa, factor, req.SecondFactorRequest)
+	if err != nil {
+		h.logger.Error("failed to verify second factor",
+			zap.Error(err),
+			zap.Uint("user_id", user.ID),
+			zap.Any("request_id", requestID),
+		)
+		problem.Render(c, problem.Internal("failed to verify code"))
+		return
+	}
+	if !valid {
+		h.logger.Warn("login attempt with invalid second factor",
+			zap.Uint("user_id", user.ID),
+			zap.String("client_ip", clientIP),
+			zap.Any("request_id", requestID),
+		)
+		h.recordLoginFailure(c, user.Email)
+		h.auditLoginFailure(c, user.Email, user.ID, "invalid_second_factor")
+		problem.Render(c, problem.Unauthorized(problem.CodeInvalidCode, "invalid code"))
+		return
+	}
+
+	// A challenge completes a single login only
+	if err := h.revocations.RevokeToken(ctx, jti, user.ID, expiresAt); err != nil {
+		h.logger.Error("failed to revoke MFA challenge",
+			zap.Error(err),
+			zap.Uint("user_id", user.ID),
+			zap.Any("request_id", requestID),
+		)
+		problem.Render(c, problem.Internal("failed to generate token"))
+		return
+	}
+
+	tokens, err := h.issueTokens(ctx, user.ID, user.Role, uuid.New().String(), nil)
+	if err != nil {
+		h.logger.Error("failed to generate tokens",
+			zap.Error(err),
+			zap.Uint("user_id", user.ID),
+			zap.Any("request_id", requestID),
+		)
+		problem.Render(c, problem.Internal("failed to generate token"))
+		return
+	}
+
+	h.logger.Info("successful login",
+		zap.Uint("user_id", user.ID),
+		zap.String("email", user.Email),
+		zap.String("role", user.Role),
+		zap.Bool("mfa", true),
+		zap.String("client_ip", clientIP),
+		zap.Any("request_id", requestID),
+	)
+	h.auditLoginSuccess(c, user, true)
+
+	c.JSON(http.StatusOK, newLoginResponse(user, tokens))
+}
+
+// Refresh exchanges a refresh token for a new access token and a rotated refresh token
+// @Summary Refresh access token
+// @Description Rotate a refresh token and return a new access token. Reusing an already rotated refresh token revokes its whole family.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body RefreshRequest true "Refresh token"
+// @Success 200 {object} TokenResponse
+// @Failure 400 {object} problem.Problem "Invalid request"
+// @Failure 401 {object} problem.Problem "Invalid refresh token"
+// @Router /v1/token/refresh [post]
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		problem.Render(c, problem.FromBinding(err))
+		return
+	}
+
+	ctx := c.Request.Context()
+	requestID, _ := c.Get("request_id")
+	clientIP := c.ClientIP()
+
+	current, err := h.refreshTokens.FindByHash(ctx, utils.HashToken(req.RefreshToken))
+	if err != nil {
+		if !errors.Is(err, repository.ErrRefreshTokenNotFound) {
+			h.logger.Error("database error during token refresh",
+				zap.Error(err),
+				zap.String("client_ip", clientIP),
+				zap.Any("request_id", requestID),
+			)
+		}
+		problem.Render(c, problem.Unauthorized(problem.CodeInvalidRefreshToken, "invalid refresh token"))
+		return
+	}
+
+	if current.IsRevoked() {
+		h.revokeFamilyOnReuse(ctx, current, clientIP, requestID)
+		problem.Render(c, problem.Unauthorized(problem.CodeInvalidRefreshToken, "invalid refresh token"))
+		return
+	}
+
+	if current.IsExpired(time.Now()) {
+		problem.Render(c, problem.Unauthorized(problem.CodeRefreshTokenExpired, "refresh token expired"))
+		return
+	}
+
+	// Re-read the user so role changes and deletions take effect on refresh
+	user, err := h.users.FindByID(ctx, current.UserID)
+	if err != nil {
+		h.logger.Warn("token refresh for unknown user",
+			zap.Error(err),
+			zap.Uint("user_id", current.UserID),
+			zap.String("client_ip", clientIP),
+			zap.Any("request_id", requestID),
+		)
+		problem.Render(c, problem.Unauthorized(problem.CodeInvalidRefreshToken, "invalid refresh token"))
+		return
+	}
+
+	tokens, err := h.issueTokens(ctx, user.ID, user.Role, current.FamilyID, current)
+	if err != nil {
+		if errors.Is(err, repository.ErrRefreshTokenReused) {
+			h.revokeFamilyOnReuse(ctx, current, clientIP, requestID)
+			problem.Render(c, problem.Unauthorized(problem.CodeInvalidRefreshToken, "invalid refresh token"))
+			return
+		}
+		h.logger.Error("failed to rotate refresh token",
+			zap.Error(err),
+			zap.Uint("user_id", user.ID),
+			zap.String("client_ip", clientIP),
+			zap.Any("request_id", requestID),
+		)
+		problem.Render(c, problem.Internal("failed to generate token"))
+		return
+	}
+
+	h.logger.Info("token refreshed",
+		zap.Uint("user_id", user.ID),
+		zap.String("client_ip", clientIP),
+		zap.Any("request_id", requestID),
+	)
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Logout revokes the access token used for the request
+// @Summary Logout user
+// @Description Revoke the current access token and, if provided, the refresh token family it was issued with
+// @Tags auth
+// @Accept json
+// @Security bearerauth
+// @Param request body LogoutRequest false "Refresh token to revoke"
+// @Success 204 "No Content"
+// @Failure 400 {object} problem.Problem "Token cannot be revoked"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 500 {object} problem.Problem "Server error"
+// @Router /v1/logout [post]
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req LogoutRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			problem.Render(c, problem.FromBinding(err))
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	requestID, _ := c.Get("request_id")
+	userID := c.GetUint("user_id")
+	jti := c.GetString("token_jti")
+	expiresAt := c.GetTime("token_expires_at")
+
+	if jti == "" {
+		problem.Render(c, problem.BadRequest(problem.CodeTokenNotRevocable, "token does not support revocation"))
+		return
+	}
+
+	if err := h.revocations.RevokeToken(ctx, jti, userID, expiresAt); err != nil {
+		h.logger.Error("failed to revoke access token",
+			zap.Error(err),
+			zap.Uint("user_id", userID),
+			zap.Any("request_id", requestID),
+		)
+		problem.Render(c, problem.Internal("failed to logout"))
+		return
+	}
+
+	if req.RefreshToken != "" {
+		token, err := h.refreshTokens.FindByHash(ctx, utils.HashToken(req.RefreshToken))
+		if err == nil && token.UserID == userID {
+			if err := h.refreshTokens.RevokeFamily(ctx, token.FamilyID); err != nil {
+				h.logger.Error("failed to revoke refresh token family",
+					zap.Error(err),
+					zap.Uint("user_id", userID),
+					zap.Any("request_id", requestID),
+				)
+				problem.Render(c, problem.Internal("failed to logout"))
+				return
+			}
+		}
+	}
+
+	h.logger.Info("user logged out",
+		zap.Uint("user_id", userID),
+		zap.String("client_ip", c.ClientIP()),
+		zap.Any("request_id", requestID),
+	)
+	h.record(c, audit.NewEvent(c, audit.ActionLogout).ForUser(userID))
+
+	c.Status(http.StatusNoContent)
+}
+
+// RevokeUserTokens revokes every access and refresh token of a user
+// @Summary Revoke all tokens of a user
+// @Description Revoke every access token issued so far and every refresh token of a user (requires tokens:revoke)
+// @Tags auth
+// @Security bearerauth
+// @Param id path int true "User ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} problem.Problem "Invalid ID"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Forbidden"
+// @Failure 404 {object} problem.Problem "User not found"
+// @Failure 500 {object} problem.Problem "Server error"
+// @Router /v1/users/{id}/revoke-tokens [post]
+func (h *AuthHandler) RevokeUserTokens(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		problem.Render(c, problem.BadRequest(problem.CodeInvalidUserID, "invalid user ID"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	requestID, _ := c.Get("request_id")
+
+	if _, err := h.users.FindByID(ctx, uint(id)); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			problem.Render(c, problem.NotFound(problem.CodeUserNotFound, "user not found"))
+			return
+		}
+		problem.Render(c, problem.Internal("failed to fetch user"))
+		return
+	}
+
+	if err := h.revocations.RevokeAllForUser(ctx, uint(id), time.Now()); err != nil {
+		h.logger.Error("failed to revoke access tokens",
+			zap.Error(err),
+			zap.Uint64("target_user_id", id),
+			zap.Any("request_id", requestID),
+		)
+		problem.Render(c, problem.Internal("failed to revoke tokens"))
+		return
+	}
+
+	if err := h.refreshTokens.RevokeAllForUser(ctx, uint(id)); err != nil {
+		h.logger.Error("failed to revoke refresh tokens",
+			zap.Error(err),
+			zap.Uint64("target_user_id", id),
+			zap.Any("request_id", requestID),
+		)
+		problem.Render(c, problem.Internal("failed to revoke tokens"))
+		return
+	}
+
+	h.logger.Info("revoked all tokens of user",
+		zap.Uint64("target_user_id", id),
+		zap.Uint("admin_user_id", c.GetUint("user_id")),
+		zap.Any("request_id", requestID),
+	)
+	h.record(c, audit.NewEvent(c, audit.ActionTokensRevoked).ForUser(uint(id)))
 
+	c.Status(http.StatusNoContent)
+}
+
+// recordLoginFailure counts a failed login and announces a triggered lockout via Retry-After
+func (h *AuthHandler) recordLoginFailure(c *gin.Context, email string) {
+	if h.lockout == nil {
+		return
+	}
+
+	requestID, _ := c.Get("request_id")
+	wait, err := h.lockout.RecordFailure(c.Request.Context(), email, c.ClientIP())
+	if err != nil {
+		h.logger.Error("failed to record login failure",
+			zap.Error(err),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Any("request_id", requestID),
+		)
+		return
+	}
+	if wait > 0 {
+		h.logger.Warn("login locked after repeated failures",
+			zap.String("email", email),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Any("request_id", requestID),
+			zap.Duration("retry_after", wait),
+		)
+		setRetryAfter(c, wait)
+	}
+}
+
+// auditLoginFailure records a rejected login for email; userID is 0 if no account matched
+func (h *AuthHandler) auditLoginFailure(c *gin.Context, email string, userID uint, reason string) {
+	event := audit.NewEvent(c, audit.ActionLoginFailed).With("email", email).With("reason", reason)
+	if userID != 0 {
+		event = event.ForUser(userID)
+	}
+	h.record(c, event)
+}
+
+// auditLoginSuccess records a completed login, acting as the user who logged in
+func (h *AuthHandler) auditLoginSuccess(c *gin.Context, user *models.User, mfa bool) {
+	event := audit.NewEvent(c, audit.ActionLoginSucceeded).ForUser(user.ID).With("mfa", mfa)
+	event.ActorID = user.ID
+	h.record(c, event)
+}
+
+// record writes event to the audit log if an auditor is configured
+func (h *AuthHandler) record(c *gin.Context, event audit.Event) {
+	if h.auditor != nil {
+		h.auditor.Record(c.Request.Context(), event)
+	}
+}
+
+// issueMFAChallenge responds with a short-lived token that proves the password step
+func (h *AuthHandler) issueMFAChallenge(c *gin.Context, user *models.User) {
+	requestID, _ := c.Get("request_id")
+
+	token, err := utils.SignMFAChallenge(user.ID, h.keys, h.mfaChallengeTTL)
+	if err != nil {
+		h.logger.Error("failed to generate MFA challenge",
+			zap.Error(err),
+			zap.Uint("user_id", user.ID),
+			zap.Any("request_id", requestID),
+		)
+		problem.Render(c, problem.Internal("failed to generate token"))
+		return
+	}
+
+	h.logger.Info("password accepted, second factor required",
+		zap.Uint("user_id", user.ID),
+		zap.String("client_ip", c.ClientIP()),
+		zap.Any("request_id", requestID),
+	)
+
+	c.JSON(http.StatusOK, MFAChallengeResponse{
+		MFARequired: true,
+		MFAToken:    token,
+		ExpiresIn:   int64(h.mfaChallengeTTL.Seconds()),
+	})
+}
+
+// parseMFAChallenge validates an MFA challenge token that has not been used yet
+func (h *AuthHandler) parseMFAChallenge(ctx context.Context, tokenString string) (uint, string, time.Time, bool) {
+	token, err := h.keys.Parse(tokenString)
+	if err != nil || !token.Valid {
+		return 0, "", time.Time{}, false
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["token_use"] != utils.TokenUseMFAChallenge {
+		return 0, "", time.Time{}, false
+	}
+
+	userID, ok := claims["user_id"].(float64)
+	jti, _ := claims["jti"].(string)
+	exp, err := claims.GetExpirationTime()
+	if !ok || jti == "" || err != nil || exp == nil {
+		return 0, "", time.Time{}, false
+	}
+
+	revoked, err := repository.IsAccessTokenRevoked(ctx, h.revocations, jti, uint(userID), utils.IssuedAt(claims))
+	if err != nil || revoked {
+		return 0, "", time.Time{}, false
+	}
+
+	return uint(userID), jti, exp.Time, true
+}
+
+// newLoginResponse combines issued tokens with the user they belong to
+func newLoginResponse(user *models.User, tokens *TokenResponse) LoginResponse {
 	response := LoginResponse{
-		Token: token,
+		TokenResponse: *tokens,
 	}
 	response.User.ID = user.ID
 	response.User.Name = user.Name
 	response.User.Email = user.Email
 	response.User.Role = user.Role
+	return response
+}
+
+// setRetryAfter sets the Retry-After header in whole seconds, rounded up
+func setRetryAfter(c *gin.Context, wait time.Duration) {
+	seconds := int64((wait + time.Second - 1) / time.Second)
+	c.Header("Retry-After", strconv.FormatInt(seconds, 10))
+}
 
-	c.JSON(http.StatusOK, response)
+// issueTokens creates an access token and a refresh token in the given family.
+// If previous is set, it is rotated to the new refresh token atomically.
+func (h *AuthHandler) issueTokens(ctx context.Context, userID uint, role, familyID string, previous *models.RefreshToken) (*TokenResponse, error) {
+	var permissions []string
+	if h.permissions != nil {
+		var err error
+		if permissions, err = h.permissions.PermissionsForUser(ctx, userID, role); err != nil {
+			return nil, err
+		}
+	}
+
+	accessToken, err := utils.SignAccessToken(userID, role, permissions, h.keys, h.accessTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	next := &models.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: utils.HashToken(refreshToken),
+		ExpiresAt: time.Now().Add(h.refreshTTL),
+	}
+
+	if previous != nil {
+		err = h.refreshTokens.Rotate(ctx, previous, next)
+	} else {
+		err = h.refreshTokens.Create(ctx, next)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenResponse{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(h.accessTTL.Seconds()),
+	}, nil
+}
+
+// revokeFamilyOnReuse revokes every token descending from the same login
+// after a rotated refresh token was presented again, which indicates theft.
+func (h *AuthHandler) revokeFamilyOnReuse(ctx context.Context, token *models.RefreshToken, clientIP string, requestID any) {
+	h.logger.Warn("refresh token reuse detected, revoking token family",
+		zap.Uint("user_id", token.UserID),
+		zap.String("family_id", token.FamilyID),
+		zap.String("client_ip", clientIP),
+		zap.Any("request_id", requestID),
+	)
+	if err := h.refreshTokens.RevokeFamily(ctx, token.FamilyID); err != nil {
+		h.logger.Error("failed to revoke refresh token family",
+			zap.Error(err),
+			zap.String("family_id", token.FamilyID),
+			zap.Any("request_id", requestID),
+		)
+	}
 }
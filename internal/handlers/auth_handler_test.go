@@ -3,11 +3,15 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
+	"myapp/internal/middleware"
 	"myapp/internal/models"
+	"myapp/internal/repository"
 	"myapp/pkg/utils"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -23,7 +27,7 @@ func setupTestDB(t *testing.T) *gorm.DB {
 	}
 
 	// Auto-migrate the User model
-	if err := db.AutoMigrate(&models.User{}); err != nil {
+	if err := db.AutoMigrate(&models.User{}, &models.RefreshToken{}, &models.RevokedToken{}, &models.UserTokenRevocation{}, &models.PasswordResetToken{}, &models.EmailVerificationToken{}, &models.LoginFailure{}, &models.UserTOTP{}, &models.MFARecoveryCode{}, &models.Permission{}, &models.Role{}, &models.RoleBinding{}, &models.AuditEvent{}); err != nil {
 		t.Fatalf("Failed to migrate database: %v", err)
 	}
 
@@ -115,7 +119,7 @@ func TestLogin(t *testing.T) {
 
 		var response map[string]string
 		json.Unmarshal(w.Body.Bytes(), &response)
-		assert.Equal(t, "invalid credentials", response["error"])
+		assert.Equal(t, "invalid credentials", response["detail"])
 	})
 
 	t.Run("should fail with invalid password", func(t *testing.T) {
@@ -151,7 +155,7 @@ func TestLogin(t *testing.T) {
 
 		var response map[string]string
 		json.Unmarshal(w.Body.Bytes(), &response)
-		assert.Equal(t, "invalid credentials", response["error"])
+		assert.Equal(t, "invalid credentials", response["detail"])
 	})
 
 	t.Run("should reject invalid request format", func(t *testing.T) {
@@ -336,7 +340,7 @@ func TestLogin(t *testing.T) {
 
 		var response map[string]string
 		json.Unmarshal(w.Body.Bytes(), &response)
-		assert.Equal(t, "invalid credentials", response["error"])
+		assert.Equal(t, "invalid credentials", response["detail"])
 	})
 
 	t.Run("should handle JWT generation failure", func(t *testing.T) {
@@ -412,3 +416,268 @@ func TestLogin(t *testing.T) {
 		assert.Equal(t, "admin", response.User.Role)
 	})
 }
+
+func loginTestUser(t *testing.T, router *gin.Engine, email, password string) LoginResponse {
+	body, _ := json.Marshal(LoginRequest{Email: email, Password: password})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/login", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("login failed with status %d: %s", w.Code, w.Body.String())
+	}
+
+	var response LoginResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return response
+}
+
+func refreshTestToken(router *gin.Engine, refreshToken string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(RefreshRequest{RefreshToken: refreshToken})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/token/refresh", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRefresh(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	setup := func(t *testing.T) (*gorm.DB, *gin.Engine) {
+		db := setupTestDB(t)
+		hashedPassword, _ := utils.HashPassword("password123")
+		db.Create(&models.User{
+			Name:         "Test User",
+			Email:        "test@example.com",
+			PasswordHash: hashedPassword,
+			Role:         "user",
+		})
+
+		handler := NewAuthHandler(db, "test-secret", setupTestLogger())
+		router := gin.New()
+		router.POST("/login", handler.Login)
+		router.POST("/token/refresh", handler.Refresh)
+		return db, router
+	}
+
+	t.Run("login should return access and refresh tokens", func(t *testing.T) {
+		_, router := setup(t)
+
+		response := loginTestUser(t, router, "test@example.com", "password123")
+		assert.NotEmpty(t, response.Token)
+		assert.NotEmpty(t, response.RefreshToken)
+		assert.Equal(t, "Bearer", response.TokenType)
+		assert.Equal(t, int64(DefaultAccessTokenTTL.Seconds()), response.ExpiresIn)
+	})
+
+	t.Run("should persist only the refresh token hash", func(t *testing.T) {
+		db, router := setup(t)
+
+		response := loginTestUser(t, router, "test@example.com", "password123")
+
+		var stored models.RefreshToken
+		assert.NoError(t, db.First(&stored).Error)
+		assert.Equal(t, utils.HashToken(response.RefreshToken), stored.TokenHash)
+		assert.NotEqual(t, response.RefreshToken, stored.TokenHash)
+	})
+
+	t.Run("should rotate refresh token", func(t *testing.T) {
+		_, router := setup(t)
+		login := loginTestUser(t, router, "test@example.com", "password123")
+
+		w := refreshTestToken(router, login.RefreshToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response TokenResponse
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NotEmpty(t, response.Token)
+		assert.NotEmpty(t, response.RefreshToken)
+		assert.NotEqual(t, login.RefreshToken, response.RefreshToken)
+
+		// The rotated token keeps working
+		w = refreshTestToken(router, response.RefreshToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("should revoke family when a rotated token is reused", func(t *testing.T) {
+		_, router := setup(t)
+		login := loginTestUser(t, router, "test@example.com", "password123")
+
+		w := refreshTestToken(router, login.RefreshToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var rotated TokenResponse
+		json.Unmarshal(w.Body.Bytes(), &rotated)
+
+		// Replay the original token
+		w = refreshTestToken(router, login.RefreshToken)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		// The legitimate successor is revoked as well
+		w = refreshTestToken(router, rotated.RefreshToken)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("should not revoke other families on reuse", func(t *testing.T) {
+		_, router := setup(t)
+		first := loginTestUser(t, router, "test@example.com", "password123")
+		second := loginTestUser(t, router, "test@example.com", "password123")
+
+		refreshTestToken(router, first.RefreshToken)
+		w := refreshTestToken(router, first.RefreshToken)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = refreshTestToken(router, second.RefreshToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("should reject expired refresh token", func(t *testing.T) {
+		db, router := setup(t)
+		login := loginTestUser(t, router, "test@example.com", "password123")
+
+		db.Model(&models.RefreshToken{}).
+			Where("token_hash = ?", utils.HashToken(login.RefreshToken)).
+			Update("expires_at", time.Now().Add(-time.Minute))
+
+		w := refreshTestToken(router, login.RefreshToken)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		var response map[string]string
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, "refresh token expired", response["detail"])
+	})
+
+	t.Run("should reject unknown refresh token", func(t *testing.T) {
+		_, router := setup(t)
+
+		w := refreshTestToken(router, "not-a-real-token")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("should reject missing refresh token", func(t *testing.T) {
+		_, router := setup(t)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/token/refresh", bytes.NewReader([]byte(`{}`)))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("should honor configured access token ttl", func(t *testing.T) {
+		db := setupTestDB(t)
+		hashedPassword, _ := utils.HashPassword("password123")
+		db.Create(&models.User{Name: "Test", Email: "ttl@example.com", PasswordHash: hashedPassword, Role: "user"})
+
+		handler := NewAuthHandler(db, "test-secret", setupTestLogger()).WithTokenTTLs(5*time.Minute, 0)
+		router := gin.New()
+		router.POST("/login", handler.Login)
+
+		response := loginTestUser(t, router, "ttl@example.com", "password123")
+		assert.Equal(t, int64(300), response.ExpiresIn)
+		assert.Equal(t, DefaultRefreshTokenTTL, handler.refreshTTL)
+	})
+}
+
+func TestLogout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	setup := func(t *testing.T) (*gorm.DB, *gin.Engine) {
+		db := setupTestDB(t)
+		hashedPassword, _ := utils.HashPassword("password123")
+		db.Create(&models.User{Name: "Test User", Email: "test@example.com", PasswordHash: hashedPassword, Role: "user"})
+		db.Create(&models.User{Name: "Admin User", Email: "admin@example.com", PasswordHash: hashedPassword, Role: "admin"})
+
+		revocations := repository.NewCachedTokenRevocationRepository(repository.NewPostgresTokenRevocationRepository(db), time.Minute)
+		handler := NewAuthHandler(db, "test-secret", setupTestLogger()).WithRevocations(revocations)
+
+		router := gin.New()
+		router.POST("/login", handler.Login)
+		router.POST("/token/refresh", handler.Refresh)
+		protected := router.Group("/")
+		protected.Use(middleware.JWTAuthMiddlewareWithRevocation("test-secret", revocations))
+		protected.POST("/logout", handler.Logout)
+		protected.GET("/me", func(c *gin.Context) { c.Status(http.StatusOK) })
+		protected.POST("/users/:id/revoke-tokens", middleware.RequireRole("admin"), handler.RevokeUserTokens)
+		return db, router
+	}
+
+	authorized := func(router *gin.Engine, method, path, token string, body []byte) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("should reject access token after logout", func(t *testing.T) {
+		_, router := setup(t)
+		login := loginTestUser(t, router, "test@example.com", "password123")
+
+		assert.Equal(t, http.StatusOK, authorized(router, "GET", "/me", login.Token, nil).Code)
+		assert.Equal(t, http.StatusNoContent, authorized(router, "POST", "/logout", login.Token, nil).Code)
+
+		w := authorized(router, "GET", "/me", login.Token, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "token has been revoked")
+	})
+
+	t.Run("should keep other sessions valid after logout", func(t *testing.T) {
+		_, router := setup(t)
+		first := loginTestUser(t, router, "test@example.com", "password123")
+		second := loginTestUser(t, router, "test@example.com", "password123")
+
+		authorized(router, "POST", "/logout", first.Token, nil)
+
+		assert.Equal(t, http.StatusOK, authorized(router, "GET", "/me", second.Token, nil).Code)
+	})
+
+	t.Run("should revoke refresh token family on logout", func(t *testing.T) {
+		_, router := setup(t)
+		login := loginTestUser(t, router, "test@example.com", "password123")
+
+		body, _ := json.Marshal(LogoutRequest{RefreshToken: login.RefreshToken})
+		assert.Equal(t, http.StatusNoContent, authorized(router, "POST", "/logout", login.Token, body).Code)
+
+		assert.Equal(t, http.StatusUnauthorized, refreshTestToken(router, login.RefreshToken).Code)
+	})
+
+	t.Run("admin should revoke all tokens of a user", func(t *testing.T) {
+		db, router := setup(t)
+		login := loginTestUser(t, router, "test@example.com", "password123")
+		admin := loginTestUser(t, router, "admin@example.com", "password123")
+
+		var user models.User
+		db.Where("email = ?", "test@example.com").First(&user)
+
+		w := authorized(router, "POST", fmt.Sprintf("/users/%d/revoke-tokens", user.ID), admin.Token, nil)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		assert.Equal(t, http.StatusUnauthorized, authorized(router, "GET", "/me", login.Token, nil).Code)
+		assert.Equal(t, http.StatusUnauthorized, refreshTestToken(router, login.RefreshToken).Code)
+		assert.Equal(t, http.StatusOK, authorized(router, "GET", "/me", admin.Token, nil).Code)
+	})
+
+	t.Run("should return 404 when revoking tokens of unknown user", func(t *testing.T) {
+		_, router := setup(t)
+		admin := loginTestUser(t, router, "admin@example.com", "password123")
+
+		w := authorized(router, "POST", "/users/9999/revoke-tokens", admin.Token, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("non-admin should not revoke tokens of others", func(t *testing.T) {
+		_, router := setup(t)
+		login := loginTestUser(t, router, "test@example.com", "password123")
+
+		w := authorized(router, "POST", "/users/1/revoke-tokens", login.Token, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"myapp/internal/models"
+	"myapp/internal/repository"
+	"myapp/pkg/notification"
+	"myapp/pkg/problem"
+	"myapp/pkg/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultEmailVerificationTTL is the lifetime of email verification tokens
+	DefaultEmailVerificationTTL = 24 * time.Hour
+)
+
+// EmailVerificationHandler issues and redeems email verification tokens
+type EmailVerificationHandler struct {
+	users    repository.UserRepository
+	tokens   repository.EmailVerificationTokenRepository
+	notifier notification.Notifier
+	logger   *zap.Logger
+	ttl      time.Duration
+	url      string
+}
+
+// NewEmailVerificationHandler creates a new email verification handler
+func NewEmailVerificationHandler(db *gorm.DB, notifier notification.Notifier, logger *zap.Logger) *EmailVerificationHandler {
+	return &EmailVerificationHandler{
+		users:    repository.NewPostgresUserRepository(db),
+		tokens:   repository.NewPostgresEmailVerificationTokenRepository(db),
+		notifier: notifier,
+		logger:   logger,
+		ttl:      DefaultEmailVerificationTTL,
+	}
+}
+
+// WithUserRepository sets the repository used to look up and update users
+func (h *EmailVerificationHandler) WithUserRepository(users repository.UserRepository) *EmailVerificationHandler {
+	h.users = users
+	return h
+}
+
+// WithVerificationToken configures the token lifetime and the page the token is sent to.
+// A non-positive ttl keeps the current setting; an empty verifyURL sends the bare token.
+func (h *EmailVerificationHandler) WithVerificationToken(ttl time.Duration, verifyURL string) *EmailVerificationHandler {
+	if ttl > 0 {
+		h.ttl = ttl
+	}
+	h.url = verifyURL
+	return h
+}
+
+// VerifyEmailRequest represents the request body for confirming an email address
+type VerifyEmailRequest struct {
+	Token string `json:"token" form:"token" binding:"required"`
+}
+
+// ResendVerificationRequest represents the request body for resending a verification link
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// SendVerification replaces any outstanding verification token of the user
+// with a new one and sends it to the user's current email address
+func (h *EmailVerificationHandler) SendVerification(ctx context.Context, user *models.User) error {
+	err := h.sendVerification(ctx, user)
+	if err != nil {
+		h.logger.Error("failed to send verification email",
+			zap.Error(err),
+			zap.Uint("user_id", user.ID),
+		)
+	}
+	return err
+}
+
+// VerifyEmail confirms an email address with a verification token
+// @Summary Verify email address
+// @Description Confirm the email address of an account with the token from the verification link. Accepts the token as query parameter (GET) or JSON body (POST).
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param token query string false "Verification token (GET)"
+// @Param request body VerifyEmailRequest false "Verification token (POST)"
+// @Success 200 {object} map[string]string "Email verified"
+// @Failure 400 {object} problem.Problem "Invalid or expired token"
+// @Router /v1/verify-email [get]
+// @Router /v1/verify-email [post]
+func (h *EmailVerificationHandler) VerifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	var err error
+	if c.Request.Method == http.MethodGet {
+		err = c.ShouldBindQuery(&req)
+	} else {
+		err = c.ShouldBindJSON(&req)
+	}
+	if err != nil {
+		problem.Render(c, problem.FromBinding(err))
+		return
+	}
+
+	ctx := c.Request.Context()
+	requestID, _ := c.Get("request_id")
+	clientIP := c.ClientIP()
+	invalidToken := problem.BadRequest(problem.CodeInvalidVerificationToken, "invalid or expired verification token")
+
+	token, err := h.tokens.FindByHash(ctx, utils.HashToken(req.Token))
+	if err != nil {
+		if !errors.Is(err, repository.ErrEmailVerificationTokenNotFound) {
+			h.logger.Error("database error during email verification",
+				zap.Error(err),
+				zap.String("client_ip", clientIP),
+				zap.Any("request_id", requestID),
+			)
+		}
+		problem.Render(c, invalidToken)
+		return
+	}
+
+	if token.IsUsed() || token.IsExpired(time.Now()) {
+		problem.Render(c, invalidToken)
+		return
+	}
+
+	// A token only proves ownership of the address it was sent to
+	user, err := h.users.FindByID(ctx, token.UserID)
+	if err != nil || user.Email != token.Email {
+		problem.Render(c, invalidToken)
+		return
+	}
+
+	if err := h.tokens.MarkUsed(ctx, token.ID); err != nil {
+		if errors.Is(err, repository.ErrEmailVerificationTokenUsed) {
+			problem.Render(c, invalidToken)
+			return
+		}
+		problem.Render(c, problem.Internal("failed to verify email"))
+		return
+	}
+
+	now := time.Now()
+	if err := h.users.SetEmailVerifiedAt(ctx, user.ID, &now); err != nil {
+		h.logger.Error("failed to mark email as verified",
+			zap.Error(err),
+			zap.Uint("user_id", user.ID),
+			zap.Any("request_id", requestID),
+		)
+		problem.Render(c, problem.Internal("failed to verify email"))
+		return
+	}
+
+	h.logger.Info("email verified",
+		zap.Uint("user_id", user.ID),
+		zap.String("client_ip", clientIP),
+		zap.Any("request_id", requestID),
+	)
+
+	c.JSON(http.StatusOK, gin.H{"message": "email verified"})
+}
+
+// ResendVerification sends a new verification link
+// @Summary Resend verification email
+// @Description Send a new email verification link. The response is the same whether or not the account exists or is already verified.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body ResendVerificationRequest true "Account email"
+// @Success 202 {object} map[string]string "Accepted"
+// @Failure 400 {object} problem.Problem "Invalid request"
+// @Router /v1/verify-email/resend [post]
+func (h *EmailVerificationHandler) ResendVerification(c *gin.Context) {
+	var req ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		problem.Render(c, problem.FromBinding(err))
+		return
+	}
+
+	ctx := c.Request.Context()
+	requestID, _ := c.Get("request_id")
+
+	// Respond identically in every case so accounts cannot be enumerated
+	accepted := gin.H{"message": "if the account exists and is unverified, a verification link has been sent"}
+
+	user, err := h.users.FindByEmail(ctx, req.Email)
+	if err != nil || user.IsEmailVerified() {
+		c.JSON(http.StatusAccepted, accepted)
+		return
+	}
+
+	if err := h.SendVerification(ctx, user); err == nil {
+		h.logger.Info("verification email resent",
+			zap.Uint("user_id", user.ID),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Any("request_id", requestID),
+		)
+	}
+
+	c.JSON(http.StatusAccepted, accepted)
+}
+
+// sendVerification creates a token for the user's current address and delivers it
+func (h *EmailVerificationHandler) sendVerification(ctx context.Context, user *models.User) error {
+	token, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	if err := h.tokens.InvalidateAllForUser(ctx, user.ID); err != nil {
+		return err
+	}
+	if err := h.tokens.Create(ctx, &models.EmailVerificationToken{
+		UserID:    user.ID,
+		Email:     user.Email,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(h.ttl),
+	}); err != nil {
+		return err
+	}
+
+	link, err := tokenLink(h.url, token)
+	if err != nil {
+		return err
+	}
+
+	return h.notifier.Send(ctx, notification.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: "Hi " + user.Name + ",\n\n" +
+			"Please confirm your email address by opening the following link. It expires in " + h.ttl.String() + ".\n\n" +
+			link + "\n\n" +
+			"If you did not create an account, you can ignore this message.",
+	})
+}
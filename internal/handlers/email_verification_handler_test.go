@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"myapp/internal/models"
+	"myapp/internal/repository"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupVerificationTest(t *testing.T) (*gorm.DB, *gin.Engine, *recordingNotifier) {
+	gin.SetMode(gin.TestMode)
+	db := setupTestDB(t)
+	logger := setupTestLogger()
+	users := repository.NewSQLiteUserRepository(db)
+
+	notifier := &recordingNotifier{}
+	verification := NewEmailVerificationHandler(db, notifier, logger).
+		WithVerificationToken(time.Hour, "http://localhost:8080/v1/verify-email").
+		WithUserRepository(users)
+	userHandler := NewUserHandler(users).WithEmailVerifier(verification)
+	authHandler := NewAuthHandler(db, "test-secret", logger).
+		WithUserRepository(users).
+		WithEmailVerificationRequired(true)
+
+	router := gin.New()
+	router.POST("/users", userHandler.CreateUser)
+	router.POST("/login", authHandler.Login)
+	router.GET("/verify-email", verification.VerifyEmail)
+	router.POST("/verify-email", verification.VerifyEmail)
+	router.POST("/verify-email/resend", verification.ResendVerification)
+
+	w := postJSON(router, "POST", "/users", CreateUserRequest{
+		Name:     "Test User",
+		Email:    "test@example.com",
+		Password: "password123",
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	return db, router, notifier
+}
+
+func verifyByGet(router *gin.Engine, token string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/verify-email?token="+token, nil)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestEmailVerificationOnSignup(t *testing.T) {
+	t.Run("should send a verification link on signup", func(t *testing.T) {
+		_, router, notifier := setupVerificationTest(t)
+
+		sent := notifier.sent()
+		require.Len(t, sent, 1)
+		assert.Equal(t, "test@example.com", sent[0].To)
+		assert.Contains(t, sent[0].Body, "http://localhost:8080/v1/verify-email?token=")
+
+		w := postJSON(router, "POST", "/login", LoginRequest{Email: "test@example.com", Password: "password123"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "email address not verified")
+	})
+
+	t.Run("should verify with GET and allow login", func(t *testing.T) {
+		db, router, notifier := setupVerificationTest(t)
+		token := tokenFromLink(t, notifier.sent()[0])
+
+		w := verifyByGet(router, token)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var user models.User
+		require.NoError(t, db.First(&user).Error)
+		assert.True(t, user.IsEmailVerified())
+		loginTestUser(t, router, "test@example.com", "password123")
+
+		w = verifyByGet(router, token)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("should verify with POST", func(t *testing.T) {
+		_, router, notifier := setupVerificationTest(t)
+		token := tokenFromLink(t, notifier.sent()[0])
+
+		w := postJSON(router, "POST", "/verify-email", VerifyEmailRequest{Token: token})
+		assert.Equal(t, http.StatusOK, w.Code)
+		loginTestUser(t, router, "test@example.com", "password123")
+	})
+
+	t.Run("should reject expired and unknown tokens", func(t *testing.T) {
+		db, router, notifier := setupVerificationTest(t)
+		token := tokenFromLink(t, notifier.sent()[0])
+		db.Model(&models.EmailVerificationToken{}).Where("1 = 1").Update("expires_at", time.Now().Add(-time.Minute))
+
+		assert.Equal(t, http.StatusBadRequest, verifyByGet(router, token).Code)
+		assert.Equal(t, http.StatusBadRequest, verifyByGet(router, "unknown").Code)
+		assert.Equal(t, http.StatusBadRequest, verifyByGet(router, "").Code)
+	})
+
+	t.Run("should reject token issued for a previous address", func(t *testing.T) {
+		db, router, notifier := setupVerificationTest(t)
+		token := tokenFromLink(t, notifier.sent()[0])
+		db.Model(&models.User{}).Where("email = ?", "test@example.com").Update("email", "new@example.com")
+
+		assert.Equal(t, http.StatusBadRequest, verifyByGet(router, token).Code)
+	})
+}
+
+func TestResendVerification(t *testing.T) {
+	t.Run("should send a new link and invalidate the previous one", func(t *testing.T) {
+		_, router, notifier := setupVerificationTest(t)
+		first := tokenFromLink(t, notifier.sent()[0])
+
+		w := postJSON(router, "POST", "/verify-email/resend", ResendVerificationRequest{Email: "test@example.com"})
+		assert.Equal(t, http.StatusAccepted, w.Code)
+
+		sent := notifier.sent()
+		require.Len(t, sent, 2)
+		second := tokenFromLink(t, sent[1])
+
+		assert.Equal(t, http.StatusBadRequest, verifyByGet(router, first).Code)
+		assert.Equal(t, http.StatusOK, verifyByGet(router, second).Code)
+	})
+
+	t.Run("should respond identically without sending for unknown or verified accounts", func(t *testing.T) {
+		_, router, notifier := setupVerificationTest(t)
+		require.Equal(t, http.StatusOK, verifyByGet(router, tokenFromLink(t, notifier.sent()[0])).Code)
+
+		verified := postJSON(router, "POST", "/verify-email/resend", ResendVerificationRequest{Email: "test@example.com"})
+		unknown := postJSON(router, "POST", "/verify-email/resend", ResendVerificationRequest{Email: "nobody@example.com"})
+
+		assert.Equal(t, http.StatusAccepted, verified.Code)
+		assert.Equal(t, verified.Body.String(), unknown.Body.String())
+		assert.Len(t, notifier.sent(), 1)
+	})
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"myapp/pkg/jwks"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKSHandler publishes the public keys used to sign access tokens
+type JWKSHandler struct {
+	keys *jwks.KeySet
+}
+
+// NewJWKSHandler creates a new JWKS handler
+func NewJWKSHandler(keys *jwks.KeySet) *JWKSHandler {
+	return &JWKSHandler{keys: keys}
+}
+
+// GetJWKS returns the JSON Web Key Set of all active and retired signing keys
+// @Summary Get JSON Web Key Set
+// @Description Public keys that downstream services use to verify access tokens, selected by the kid header
+// @Tags auth
+// @Produce json
+// @Success 200 {object} jwks.JSONWebKeySet
+// @Router /.well-known/jwks.json [get]
+func (h *JWKSHandler) GetJWKS(c *gin.Context) {
+	// Allow verifiers to cache keys, but pick up rotations reasonably quickly
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.keys.JWKS())
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"myapp/internal/middleware"
+	"myapp/internal/models"
+	"myapp/pkg/config"
+	"myapp/pkg/jwks"
+	"myapp/pkg/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupEd25519KeySet(t *testing.T) *jwks.KeySet {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	require.NoError(t, err)
+
+	keys, err := jwks.LoadKeySet(config.JWTConfig{
+		ActiveKeyID: "test-key",
+		SigningKeys: []config.SigningKeyConfig{{
+			ID:         "test-key",
+			PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		}},
+	})
+	require.NoError(t, err)
+	return keys
+}
+
+func TestGetJWKS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("should publish public signing keys", func(t *testing.T) {
+		handler := NewJWKSHandler(setupEd25519KeySet(t))
+		router := gin.New()
+		router.GET("/.well-known/jwks.json", handler.GetJWKS)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/.well-known/jwks.json", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Cache-Control"), "max-age")
+
+		var response jwks.JSONWebKeySet
+		json.Unmarshal(w.Body.Bytes(), &response)
+		require.Len(t, response.Keys, 1)
+		assert.Equal(t, "test-key", response.Keys[0].Kid)
+		assert.Equal(t, "EdDSA", response.Keys[0].Alg)
+		assert.NotContains(t, w.Body.String(), "\"d\"")
+	})
+
+	t.Run("should not publish the HMAC secret", func(t *testing.T) {
+		handler := NewJWKSHandler(jwks.NewHMACKeySet("test-secret"))
+		router := gin.New()
+		router.GET("/.well-known/jwks.json", handler.GetJWKS)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/.well-known/jwks.json", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"keys":[]}`, w.Body.String())
+	})
+
+	t.Run("tokens from login should verify with the published key set", func(t *testing.T) {
+		db := setupTestDB(t)
+		hashedPassword, _ := utils.HashPassword("password123")
+		db.Create(&models.User{Name: "Test User", Email: "test@example.com", PasswordHash: hashedPassword, Role: "user"})
+
+		keys := setupEd25519KeySet(t)
+		handler := NewAuthHandler(db, "test-secret", setupTestLogger()).WithKeySet(keys)
+		router := gin.New()
+		router.POST("/login", handler.Login)
+		router.GET("/me", middleware.JWTAuthMiddlewareWithKeySet(keys, nil), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		login := loginTestUser(t, router, "test@example.com", "password123")
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+login.Token)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		// The HMAC secret no longer verifies the token
+		w = httptest.NewRecorder()
+		hmacRouter := gin.New()
+		hmacRouter.GET("/me", middleware.JWTAuthMiddleware("test-secret"), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+		hmacRouter.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"myapp/internal/lockout"
+	"myapp/internal/models"
+	"myapp/internal/repository"
+	"myapp/pkg/problem"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// LockoutHandler exposes failed login tracking to administrators
+type LockoutHandler struct {
+	service *lockout.Service
+	logger  *zap.Logger
+}
+
+// NewLockoutHandler creates a new lockout handler
+func NewLockoutHandler(service *lockout.Service, logger *zap.Logger) *LockoutHandler {
+	return &LockoutHandler{service: service, logger: logger}
+}
+
+// LockoutResponse describes the failed logins tracked for an account or IP
+type LockoutResponse struct {
+	models.LoginFailure
+	Locked bool `json:"locked"`
+}
+
+// ListLockouts lists accounts and IPs with recent failed logins
+// @Summary List login lockouts
+// @Description List accounts and client IPs with recent failed logins or an active lockout (requires lockouts:manage)
+// @Tags auth
+// @Produce json
+// @Security bearerauth
+// @Param scope query string false "Filter by scope (account or ip)"
+// @Success 200 {array} LockoutResponse
+// @Failure 400 {object} problem.Problem "Invalid scope"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Forbidden"
+// @Router /v1/lockouts [get]
+func (h *LockoutHandler) ListLockouts(c *gin.Context) {
+	scope := c.Query("scope")
+	if scope != "" && !isLockoutScope(scope) {
+		problem.Render(c, problem.BadRequest(problem.CodeInvalidLockoutScope, "scope must be account or ip"))
+		return
+	}
+
+	failures, err := h.service.List(c.Request.Context(), scope)
+	if err != nil {
+		problem.Render(c, problem.Internal("failed to fetch lockouts"))
+		return
+	}
+
+	now := time.Now()
+	response := make([]LockoutResponse, 0, len(failures))
+	for _, f := range failures {
+		response = append(response, LockoutResponse{LoginFailure: f, Locked: f.IsLocked(now)})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ClearLockout removes the failed logins and lockout of an account or IP
+// @Summary Clear login lockout
+// @Description Reset failed logins and lift the lockout of an account (email) or client IP (requires lockouts:manage)
+// @Tags auth
+// @Security bearerauth
+// @Param scope path string true "account or ip"
+// @Param identifier path string true "Email address or IP"
+// @Success 204 "No Content"
+// @Failure 400 {object} problem.Problem "Invalid scope"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Forbidden"
+// @Failure 404 {object} problem.Problem "No failures tracked"
+// @Router /v1/lockouts/{scope}/{identifier} [delete]
+func (h *LockoutHandler) ClearLockout(c *gin.Context) {
+	scope := c.Param("scope")
+	identifier := c.Param("identifier")
+	if !isLockoutScope(scope) {
+		problem.Render(c, problem.BadRequest(problem.CodeInvalidLockoutScope, "scope must be account or ip"))
+		return
+	}
+
+	if err := h.service.Clear(c.Request.Context(), scope, identifier); err != nil {
+		if errors.Is(err, repository.ErrLoginFailureNotFound) {
+			problem.Render(c, problem.NotFound(problem.CodeLockoutNotFound, "no failed logins tracked"))
+			return
+		}
+		problem.Render(c, problem.Internal("failed to clear lockout"))
+		return
+	}
+
+	requestID, _ := c.Get("request_id")
+	h.logger.Info("login lockout cleared",
+		zap.String("scope", scope),
+		zap.String("identifier", identifier),
+		zap.Uint("admin_user_id", c.GetUint("user_id")),
+		zap.Any("request_id", requestID),
+	)
+
+	c.Status(http.StatusNoContent)
+}
+
+// isLockoutScope reports whether scope names a tracked scope
+func isLockoutScope(scope string) bool {
+	return scope == models.LoginFailureScopeAccount || scope == models.LoginFailureScopeIP
+}
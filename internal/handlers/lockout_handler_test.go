@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"myapp/internal/lockout"
+	"myapp/internal/models"
+	"myapp/internal/repository"
+	"myapp/pkg/problem"
+	"myapp/pkg/utils"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupLockoutTest(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	db := setupTestDB(t)
+	logger := setupTestLogger()
+
+	hashedPassword, _ := utils.HashPassword("password123")
+	db.Create(&models.User{
+		Name:         "Test User",
+		Email:        "test@example.com",
+		PasswordHash: hashedPassword,
+		Role:         "user",
+	})
+
+	service := lockout.NewService(repository.NewPostgresLoginFailureRepository(db), lockout.Policy{
+		AccountThreshold: 3,
+		IPThreshold:      10,
+		BaseDelay:        30 * time.Second,
+		MaxDelay:         time.Hour,
+		Window:           15 * time.Minute,
+	})
+	authHandler := NewAuthHandler(db, "test-secret", logger).WithLockout(service)
+	lockoutHandler := NewLockoutHandler(service, logger)
+
+	router := gin.New()
+	router.POST("/login", authHandler.Login)
+	router.GET("/lockouts", lockoutHandler.ListLockouts)
+	router.DELETE("/lockouts/:scope/:identifier", lockoutHandler.ClearLockout)
+	return router
+}
+
+func failLogin(router *gin.Engine) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(LoginRequest{Email: "test@example.com", Password: "wrong"})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/login", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = "192.0.2.10:1234"
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestLoginLockout(t *testing.T) {
+	t.Run("should lock account after threshold and return Retry-After", func(t *testing.T) {
+		router := setupLockoutTest(t)
+
+		assert.Equal(t, http.StatusUnauthorized, failLogin(router).Code)
+		assert.Equal(t, http.StatusUnauthorized, failLogin(router).Code)
+
+		w := failLogin(router)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, "30", w.Header().Get("Retry-After"))
+
+		// Even the right password is refused while locked
+		w = postJSON(router, "POST", "/login", LoginRequest{Email: "test@example.com", Password: "password123"})
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+		require.NoError(t, err)
+		assert.True(t, retryAfter > 0 && retryAfter <= 30)
+	})
+
+	t.Run("should track unknown accounts like existing ones", func(t *testing.T) {
+		router := setupLockoutTest(t)
+
+		for i := 0; i < 3; i++ {
+			postJSON(router, "POST", "/login", LoginRequest{Email: "nobody@example.com", Password: "wrong"})
+		}
+
+		w := postJSON(router, "POST", "/login", LoginRequest{Email: "nobody@example.com", Password: "wrong"})
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	})
+
+	t.Run("should reset account failures on successful login", func(t *testing.T) {
+		router := setupLockoutTest(t)
+
+		failLogin(router)
+		failLogin(router)
+		loginTestUser(t, router, "test@example.com", "password123")
+		failLogin(router)
+		failLogin(router)
+
+		loginTestUser(t, router, "test@example.com", "password123")
+	})
+}
+
+func TestLockoutAdminEndpoints(t *testing.T) {
+	t.Run("should list tracked accounts and IPs", func(t *testing.T) {
+		router := setupLockoutTest(t)
+		for i := 0; i < 3; i++ {
+			failLogin(router)
+		}
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/lockouts?scope=account", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response []LockoutResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Len(t, response, 1)
+		assert.Equal(t, "test@example.com", response[0].Identifier)
+		assert.Equal(t, 3, response[0].Failures)
+		assert.True(t, response[0].Locked)
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/lockouts?scope=ip", nil)
+		router.ServeHTTP(w, req)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Len(t, response, 1)
+		assert.Equal(t, "192.0.2.10", response[0].Identifier)
+		assert.False(t, response[0].Locked)
+	})
+
+	t.Run("should clear a lockout", func(t *testing.T) {
+		router := setupLockoutTest(t)
+		for i := 0; i < 3; i++ {
+			failLogin(router)
+		}
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("DELETE", "/lockouts/account/test@example.com", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		loginTestUser(t, router, "test@example.com", "password123")
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("DELETE", "/lockouts/account/test@example.com", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("should reject unknown scope", func(t *testing.T) {
+		router := setupLockoutTest(t)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/lockouts?scope=user", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, problem.CodeInvalidLockoutScope, problemCode(t, w))
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("DELETE", "/lockouts/user/1", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
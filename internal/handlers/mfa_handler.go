@@ -0,0 +1,372 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"myapp/internal/models"
+	"myapp/internal/repository"
+	"myapp/pkg/problem"
+	"myapp/pkg/totp"
+	"myapp/pkg/utils"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultMFAIssuer is the issuer shown in authenticator apps
+	DefaultMFAIssuer = "myapp"
+	// RecoveryCodeCount is the number of recovery codes issued on enrollment
+	RecoveryCodeCount = 10
+	// recoveryCodeBytes is the amount of randomness in a recovery code
+	recoveryCodeBytes = 8
+	// totpSkew is the number of time steps a code may be off to tolerate clock drift
+	totpSkew = 1
+)
+
+// MFAHandler handles enrollment and removal of second factors
+type MFAHandler struct {
+	users  repository.UserRepository
+	mfa    repository.MFARepository
+	logger *zap.Logger
+	issuer string
+}
+
+// NewMFAHandler creates a new MFA handler
+func NewMFAHandler(db *gorm.DB, logger *zap.Logger) *MFAHandler {
+	return &MFAHandler{
+		users:  repository.NewPostgresUserRepository(db),
+		mfa:    repository.NewPostgresMFARepository(db),
+		logger: logger,
+		issuer: DefaultMFAIssuer,
+	}
+}
+
+// WithUserRepository sets the repository used to look up users
+func (h *MFAHandler) WithUserRepository(users repository.UserRepository) *MFAHandler {
+	h.users = users
+	return h
+}
+
+// WithIssuer sets the issuer shown in authenticator apps. An empty issuer keeps the current setting.
+func (h *MFAHandler) WithIssuer(issuer string) *MFAHandler {
+	if issuer != "" {
+		h.issuer = issuer
+	}
+	return h
+}
+
+// MFAStatusResponse describes the second factors of the authenticated user
+type MFAStatusResponse struct {
+	TOTPEnabled            bool  `json:"totp_enabled"`
+	RecoveryCodesRemaining int64 `json:"recovery_codes_remaining"`
+}
+
+// TOTPEnrollmentResponse holds the shared secret of a pending TOTP enrollment
+type TOTPEnrollmentResponse struct {
+	Secret     string `json:"secret" example:"JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"`
+	OTPAuthURI string `json:"otpauth_uri" example:"otpauth://totp/myapp:john@example.com?secret=JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP&issuer=myapp"`
+}
+
+// ConfirmTOTPRequest represents the request body for confirming a TOTP enrollment
+type ConfirmTOTPRequest struct {
+	Code string `json:"code" binding:"required,len=6,numeric"`
+}
+
+// RecoveryCodesResponse holds recovery codes, which are only shown once
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// SecondFactorRequest carries either a TOTP code or a recovery code
+type SecondFactorRequest struct {
+	Code         string `json:"code" binding:"omitempty,len=6,numeric"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// GetMFAStatus returns the second factors of the authenticated user
+// @Summary Get MFA status
+// @Description Report whether TOTP is enabled for the caller and how many recovery codes are left
+// @Tags mfa
+// @Produce json
+// @Security bearerauth
+// @Success 200 {object} MFAStatusResponse
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Router /v1/mfa [get]
+func (h *MFAHandler) GetMFAStatus(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := c.GetUint("user_id")
+
+	var response MFAStatusResponse
+	factor, err := h.mfa.FindTOTP(ctx, userID)
+	if err != nil && !errors.Is(err, repository.ErrTOTPNotFound) {
+		problem.Render(c, problem.Internal("failed to fetch MFA status"))
+		return
+	}
+	if err == nil && factor.IsConfirmed() {
+		response.TOTPEnabled = true
+		if response.RecoveryCodesRemaining, err = h.mfa.CountRecoveryCodes(ctx, userID); err != nil {
+			problem.Render(c, problem.Internal("failed to fetch MFA status"))
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// EnrollTOTP starts a TOTP enrollment for the authenticated user
+// @Summary Start TOTP enrollment
+// @Description Generate a new TOTP secret and its otpauth:// URI. The factor is enforced only after it is confirmed with a code. Restarting an unconfirmed enrollment replaces the secret.
+// @Tags mfa
+// @Produce json
+// @Security bearerauth
+// @Success 200 {object} TOTPEnrollmentResponse
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 409 {object} problem.Problem "TOTP already enabled"
+// @Router /v1/mfa/totp/enroll [post]
+func (h *MFAHandler) EnrollTOTP(c *gin.Context) {
+	ctx := c.Request.Context()
+	requestID, _ := c.Get("request_id")
+	userID := c.GetUint("user_id")
+
+	user, err := h.users.FindByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			problem.Render(c, problem.NotFound(problem.CodeUserNotFound, "user not found"))
+			return
+		}
+		problem.Render(c, problem.Internal("failed to fetch user"))
+		return
+	}
+
+	existing, err := h.mfa.FindTOTP(ctx, userID)
+	if err != nil && !errors.Is(err, repository.ErrTOTPNotFound) {
+		problem.Render(c, problem.Internal("failed to start enrollment"))
+		return
+	}
+	if err == nil && existing.IsConfirmed() {
+		problem.Render(c, problem.Conflict(problem.CodeMFAAlreadyEnabled, "two-factor authentication is already enabled"))
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		problem.Render(c, problem.Internal("failed to start enrollment"))
+		return
+	}
+
+	if err := h.mfa.SaveTOTP(ctx, &models.UserTOTP{UserID: userID, Secret: secret}); err != nil {
+		h.logger.Error("failed to store TOTP secret",
+			zap.Error(err),
+			zap.Uint("user_id", userID),
+			zap.Any("request_id", requestID),
+		)
+		problem.Render(c, problem.Internal("failed to start enrollment"))
+		return
+	}
+
+	h.logger.Info("TOTP enrollment started",
+		zap.Uint("user_id", userID),
+		zap.String("client_ip", c.ClientIP()),
+		zap.Any("request_id", requestID),
+	)
+
+	c.JSON(http.StatusOK, TOTPEnrollmentResponse{
+		Secret:     secret,
+		OTPAuthURI: totp.URI(h.issuer, user.Email, secret),
+	})
+}
+
+// ConfirmTOTP completes a TOTP enrollment and issues recovery codes
+// @Summary Confirm TOTP enrollment
+// @Description Enable TOTP by presenting a code from the authenticator app. Returns recovery codes, which are not shown again.
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Security bearerauth
+// @Param request body ConfirmTOTPRequest true "Current TOTP code"
+// @Success 200 {object} RecoveryCodesResponse
+// @Failure 400 {object} problem.Problem "Invalid code or no pending enrollment"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 409 {object} problem.Problem "TOTP already enabled"
+// @Router /v1/mfa/totp/confirm [post]
+func (h *MFAHandler) ConfirmTOTP(c *gin.Context) {
+	var req ConfirmTOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		problem.Render(c, problem.FromBinding(err))
+		return
+	}
+
+	ctx := c.Request.Context()
+	requestID, _ := c.Get("request_id")
+	userID := c.GetUint("user_id")
+
+	factor, err := h.mfa.FindTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrTOTPNotFound) {
+			problem.Render(c, problem.BadRequest(problem.CodeNoPendingEnrollment, "no pending TOTP enrollment"))
+			return
+		}
+		problem.Render(c, problem.Internal("failed to confirm enrollment"))
+		return
+	}
+	if factor.IsConfirmed() {
+		problem.Render(c, problem.Conflict(problem.CodeMFAAlreadyEnabled, "two-factor authentication is already enabled"))
+		return
+	}
+
+	step, ok := totp.Validate(factor.Secret, req.Code, time.Now(), totpSkew)
+	if !ok {
+		problem.Render(c, problem.BadRequest(problem.CodeInvalidCode, "invalid code"))
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		problem.Render(c, problem.Internal("failed to confirm enrollment"))
+		return
+	}
+
+	if err := h.mfa.ConfirmTOTP(ctx, userID, step, hashes); err != nil {
+		if errors.Is(err, repository.ErrTOTPCodeReused) {
+			problem.Render(c, problem.BadRequest(problem.CodeInvalidCode, "invalid code"))
+			return
+		}
+		h.logger.Error("failed to confirm TOTP enrollment",
+			zap.Error(err),
+			zap.Uint("user_id", userID),
+			zap.Any("request_id", requestID),
+		)
+		problem.Render(c, problem.Internal("failed to confirm enrollment"))
+		return
+	}
+
+	h.logger.Info("TOTP enabled",
+		zap.Uint("user_id", userID),
+		zap.String("client_ip", c.ClientIP()),
+		zap.Any("request_id", requestID),
+	)
+
+	c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTOTP removes TOTP and the recovery codes of the authenticated user
+// @Summary Disable TOTP
+// @Description Turn off two-factor authentication. Requires a current TOTP code or an unused recovery code.
+// @Tags mfa
+// @Accept json
+// @Security bearerauth
+// @Param request body SecondFactorRequest true "TOTP code or recovery code"
+// @Success 204 "No Content"
+// @Failure 400 {object} problem.Problem "Invalid request or code"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 404 {object} problem.Problem "TOTP not enabled"
+// @Router /v1/mfa/totp [delete]
+func (h *MFAHandler) DisableTOTP(c *gin.Context) {
+	var req SecondFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		problem.Render(c, problem.FromBinding(err))
+		return
+	}
+	if !req.valid() {
+		problem.Render(c, problem.BadRequest(problem.CodeInvalidRequest, "either code or recovery_code is required"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	requestID, _ := c.Get("request_id")
+	userID := c.GetUint("user_id")
+
+	factor, err := h.mfa.FindTOTP(ctx, userID)
+	if err != nil || !factor.IsConfirmed() {
+		if err == nil || errors.Is(err, repository.ErrTOTPNotFound) {
+			problem.Render(c, problem.NotFound(problem.CodeMFANotEnabled, "two-factor authentication is not enabled"))
+			return
+		}
+		problem.Render(c, problem.Internal("failed to disable two-factor authentication"))
+		return
+	}
+
+	ok, err := verifySecondFactor(ctx, h.mfa, factor, req)
+	if err != nil {
+		problem.Render(c, problem.Internal("failed to disable two-factor authentication"))
+		return
+	}
+	if !ok {
+		h.logger.Warn("invalid second factor when disabling TOTP",
+			zap.Uint("user_id", userID),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Any("request_id", requestID),
+		)
+		problem.Render(c, problem.BadRequest(problem.CodeInvalidCode, "invalid code"))
+		return
+	}
+
+	if err := h.mfa.DeleteTOTP(ctx, userID); err != nil && !errors.Is(err, repository.ErrTOTPNotFound) {
+		problem.Render(c, problem.Internal("failed to disable two-factor authentication"))
+		return
+	}
+
+	h.logger.Info("TOTP disabled",
+		zap.Uint("user_id", userID),
+		zap.String("client_ip", c.ClientIP()),
+		zap.Any("request_id", requestID),
+	)
+
+	c.Status(http.StatusNoContent)
+}
+
+// valid reports whether exactly one kind of second factor was provided
+func (r SecondFactorRequest) valid() bool {
+	return (r.Code == "") != (r.RecoveryCode == "")
+}
+
+// verifySecondFactor checks a TOTP code or redeems a recovery code of a
+// confirmed factor. Each TOTP code and recovery code is accepted only once.
+func verifySecondFactor(ctx context.Context, repo repository.MFARepository, factor *models.UserTOTP, req SecondFactorRequest) (bool, error) {
+	if req.Code != "" {
+		step, ok := totp.Validate(factor.Secret, req.Code, time.Now(), totpSkew)
+		if !ok {
+			return false, nil
+		}
+		err := repo.UseTOTPStep(ctx, factor.UserID, step)
+		if errors.Is(err, repository.ErrTOTPCodeReused) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	err := repo.UseRecoveryCode(ctx, factor.UserID, utils.HashToken(normalizeRecoveryCode(req.RecoveryCode)))
+	if errors.Is(err, repository.ErrRecoveryCodeInvalid) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// generateRecoveryCodes creates recovery codes formatted as xxxx-xxxx-xxxx-xxxx
+// together with the hashes that are stored
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	hashes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		raw := hex.EncodeToString(b)
+		codes[i] = raw[0:4] + "-" + raw[4:8] + "-" + raw[8:12] + "-" + raw[12:16]
+		hashes[i] = utils.HashToken(raw)
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode accepts recovery codes with or without separators and in any case
+func normalizeRecoveryCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(strings.TrimSpace(code)))
+}
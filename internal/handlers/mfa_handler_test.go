@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"encoding/json"
+	"myapp/internal/models"
+	"myapp/internal/repository"
+	"myapp/pkg/jwks"
+	"myapp/pkg/problem"
+	"myapp/pkg/totp"
+	"myapp/pkg/utils"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupMFATest(t *testing.T) (*gorm.DB, *gin.Engine) {
+	gin.SetMode(gin.TestMode)
+	db := setupTestDB(t)
+	logger := setupTestLogger()
+
+	hashedPassword, _ := utils.HashPassword("password123")
+	user := &models.User{
+		Name:         "Test User",
+		Email:        "test@example.com",
+		PasswordHash: hashedPassword,
+		Role:         "user",
+	}
+	db.Create(user)
+
+	authHandler := NewAuthHandler(db, "test-secret", logger).WithMFAChallengeTTL(time.Minute)
+	mfaHandler := NewMFAHandler(db, logger).WithIssuer("Test App")
+
+	router := gin.New()
+	router.POST("/login", authHandler.Login)
+	router.POST("/login/mfa", authHandler.LoginMFA)
+
+	// Simulate the JWT middleware
+	authenticated := router.Group("/")
+	authenticated.Use(func(c *gin.Context) {
+		c.Set("user_id", user.ID)
+		c.Next()
+	})
+	authenticated.GET("/mfa", mfaHandler.GetMFAStatus)
+	authenticated.POST("/mfa/totp/enroll", mfaHandler.EnrollTOTP)
+	authenticated.POST("/mfa/totp/confirm", mfaHandler.ConfirmTOTP)
+	authenticated.DELETE("/mfa/totp", mfaHandler.DisableTOTP)
+
+	return db, router
+}
+
+// enableTOTP enrolls and confirms TOTP and returns the secret and recovery codes
+func enableTOTP(t *testing.T, router *gin.Engine) (string, []string) {
+	w := postJSON(router, "POST", "/mfa/totp/enroll", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var enrollment TOTPEnrollmentResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &enrollment))
+
+	code, err := totp.Code(enrollment.Secret, time.Now())
+	require.NoError(t, err)
+	w = postJSON(router, "POST", "/mfa/totp/confirm", ConfirmTOTPRequest{Code: code})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var recovery RecoveryCodesResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &recovery))
+
+	return enrollment.Secret, recovery.RecoveryCodes
+}
+
+// mfaChallenge logs in with the password and returns the challenge token
+func mfaChallenge(t *testing.T, router *gin.Engine) string {
+	w := postJSON(router, "POST", "/login", LoginRequest{Email: "test@example.com", Password: "password123"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var challenge MFAChallengeResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &challenge))
+	require.True(t, challenge.MFARequired)
+	require.NotEmpty(t, challenge.MFAToken)
+	return challenge.MFAToken
+}
+
+// nextCode returns the code of the following time step, which has not been used yet
+func nextCode(t *testing.T, secret string) string {
+	code, err := totp.Code(secret, time.Now().Add(totp.Period))
+	require.NoError(t, err)
+	return code
+}
+
+func TestEnrollTOTP(t *testing.T) {
+	t.Run("should return secret and otpauth URI", func(t *testing.T) {
+		db, router := setupMFATest(t)
+
+		w := postJSON(router, "POST", "/mfa/totp/enroll", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response TOTPEnrollmentResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.NotEmpty(t, response.Secret)
+		assert.True(t, strings.HasPrefix(response.OTPAuthURI, "otpauth://totp/Test%20App:test@example.com?"))
+		assert.Contains(t, response.OTPAuthURI, "secret="+response.Secret)
+
+		// Pending enrollment does not enable the factor yet
+		var stored models.UserTOTP
+		require.NoError(t, db.First(&stored).Error)
+		assert.False(t, stored.IsConfirmed())
+		login := loginTestUser(t, router, "test@example.com", "password123")
+		assert.NotEmpty(t, login.Token)
+	})
+
+	t.Run("should reject enrollment when already enabled", func(t *testing.T) {
+		_, router := setupMFATest(t)
+		enableTOTP(t, router)
+
+		w := postJSON(router, "POST", "/mfa/totp/enroll", nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, problem.CodeMFAAlreadyEnabled, problemCode(t, w))
+	})
+}
+
+func TestConfirmTOTP(t *testing.T) {
+	t.Run("should enable TOTP and store hashed recovery codes", func(t *testing.T) {
+		db, router := setupMFATest(t)
+		_, codes := enableTOTP(t, router)
+
+		assert.Len(t, codes, RecoveryCodeCount)
+		var stored []models.MFARecoveryCode
+		db.Find(&stored)
+		assert.Len(t, stored, RecoveryCodeCount)
+		for _, code := range stored {
+			assert.NotContains(t, codes, code.CodeHash)
+			assert.Len(t, code.CodeHash, 64)
+		}
+
+		w := postJSON(router, "GET", "/mfa", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"totp_enabled":true,"recovery_codes_remaining":10}`, w.Body.String())
+	})
+
+	t.Run("should reject invalid code", func(t *testing.T) {
+		_, router := setupMFATest(t)
+		postJSON(router, "POST", "/mfa/totp/enroll", nil)
+
+		w := postJSON(router, "POST", "/mfa/totp/confirm", ConfirmTOTPRequest{Code: "000000"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("should reject confirmation without enrollment", func(t *testing.T) {
+		_, router := setupMFATest(t)
+
+		w := postJSON(router, "POST", "/mfa/totp/confirm", ConfirmTOTPRequest{Code: "123456"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestLoginMFA(t *testing.T) {
+	t.Run("should require second factor and issue tokens for valid code", func(t *testing.T) {
+		_, router := setupMFATest(t)
+		secret, _ := enableTOTP(t, router)
+
+		token := mfaChallenge(t, router)
+		w := postJSON(router, "POST", "/login/mfa", MFALoginRequest{
+			MFAToken:            token,
+			SecondFactorRequest: SecondFactorRequest{Code: nextCode(t, secret)},
+		})
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response LoginResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.NotEmpty(t, response.Token)
+		assert.NotEmpty(t, response.RefreshToken)
+		assert.Equal(t, "test@example.com", response.User.Email)
+	})
+
+	t.Run("should reject reused code and reused challenge", func(t *testing.T) {
+		_, router := setupMFATest(t)
+		secret, _ := enableTOTP(t, router)
+		code := nextCode(t, secret)
+
+		token := mfaChallenge(t, router)
+		request := MFALoginRequest{MFAToken: token, SecondFactorRequest: SecondFactorRequest{Code: code}}
+		require.Equal(t, http.StatusOK, postJSON(router, "POST", "/login/mfa", request).Code)
+
+		// Same challenge again
+		w := postJSON(router, "POST", "/login/mfa", request)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		// Same code with a new challenge
+		request.MFAToken = mfaChallenge(t, router)
+		w = postJSON(router, "POST", "/login/mfa", request)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid code")
+	})
+
+	t.Run("should accept each recovery code once", func(t *testing.T) {
+		_, router := setupMFATest(t)
+		_, codes := enableTOTP(t, router)
+
+		// Recovery codes are accepted without separators and in upper case
+		request := MFALoginRequest{
+			MFAToken:            mfaChallenge(t, router),
+			SecondFactorRequest: SecondFactorRequest{RecoveryCode: strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))},
+		}
+		assert.Equal(t, http.StatusOK, postJSON(router, "POST", "/login/mfa", request).Code)
+
+		request.MFAToken = mfaChallenge(t, router)
+		assert.Equal(t, http.StatusUnauthorized, postJSON(router, "POST", "/login/mfa", request).Code)
+
+		w := postJSON(router, "GET", "/mfa", nil)
+		assert.JSONEq(t, `{"totp_enabled":true,"recovery_codes_remaining":9}`, w.Body.String())
+	})
+
+	t.Run("should reject access tokens and expired challenges", func(t *testing.T) {
+		_, router := setupMFATest(t)
+		secret, _ := enableTOTP(t, router)
+		keys := jwks.NewHMACKeySet("test-secret")
+
+		access, err := utils.SignJWT(1, "user", keys, time.Minute)
+		require.NoError(t, err)
+		expired, err := utils.SignMFAChallenge(1, keys, -time.Minute)
+		require.NoError(t, err)
+
+		for _, token := range []string{access, expired, "not-a-token"} {
+			w := postJSON(router, "POST", "/login/mfa", MFALoginRequest{
+				MFAToken:            token,
+				SecondFactorRequest: SecondFactorRequest{Code: nextCode(t, secret)},
+			})
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+			assert.Contains(t, w.Body.String(), "invalid or expired MFA token")
+		}
+	})
+
+	t.Run("should require exactly one second factor", func(t *testing.T) {
+		_, router := setupMFATest(t)
+		enableTOTP(t, router)
+		token := mfaChallenge(t, router)
+
+		for _, factor := range []SecondFactorRequest{{}, {Code: "123456", RecoveryCode: "abcd"}} {
+			w := postJSON(router, "POST", "/login/mfa", MFALoginRequest{MFAToken: token, SecondFactorRequest: factor})
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		}
+	})
+}
+
+func TestDisableTOTP(t *testing.T) {
+	t.Run("should disable TOTP with valid code", func(t *testing.T) {
+		db, router := setupMFATest(t)
+		secret, _ := enableTOTP(t, router)
+
+		w := postJSON(router, "DELETE", "/mfa/totp", SecondFactorRequest{Code: nextCode(t, secret)})
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		var count int64
+		db.Model(&models.MFARecoveryCode{}).Count(&count)
+		assert.Zero(t, count)
+		_, err := repository.NewPostgresMFARepository(db).FindTOTP(t.Context(), 1)
+		assert.ErrorIs(t, err, repository.ErrTOTPNotFound)
+
+		login := loginTestUser(t, router, "test@example.com", "password123")
+		assert.NotEmpty(t, login.Token)
+	})
+
+	t.Run("should reject invalid code", func(t *testing.T) {
+		_, router := setupMFATest(t)
+		enableTOTP(t, router)
+
+		w := postJSON(router, "DELETE", "/mfa/totp", SecondFactorRequest{RecoveryCode: "0000-0000-0000-0000"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("should return 404 when not enabled", func(t *testing.T) {
+		_, router := setupMFATest(t)
+
+		w := postJSON(router, "DELETE", "/mfa/totp", SecondFactorRequest{Code: "123456"})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,388 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"myapp/internal/audit"
+	"myapp/internal/models"
+	"myapp/internal/repository"
+	"myapp/pkg/notification"
+	"myapp/pkg/problem"
+	"myapp/pkg/utils"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultPasswordResetTTL is the lifetime of password reset tokens
+	DefaultPasswordResetTTL = time.Hour
+)
+
+// PasswordHandler handles password change and password reset requests
+type PasswordHandler struct {
+	users         repository.UserRepository
+	resetTokens   repository.PasswordResetTokenRepository
+	refreshTokens repository.RefreshTokenRepository
+	revocations   repository.TokenRevocationRepository
+	notifier      notification.Notifier
+	auditor       audit.Auditor
+	logger        *zap.Logger
+	resetTTL      time.Duration
+	resetURL      string
+}
+
+// NewPasswordHandler creates a new password handler
+func NewPasswordHandler(db *gorm.DB, notifier notification.Notifier, logger *zap.Logger) *PasswordHandler {
+	return &PasswordHandler{
+		users:         repository.NewPostgresUserRepository(db),
+		resetTokens:   repository.NewPostgresPasswordResetTokenRepository(db),
+		refreshTokens: repository.NewPostgresRefreshTokenRepository(db),
+		revocations:   repository.NewPostgresTokenRevocationRepository(db),
+		notifier:      notifier,
+		logger:        logger,
+		resetTTL:      DefaultPasswordResetTTL,
+	}
+}
+
+// WithUserRepository sets the repository used to look up and update users
+func (h *PasswordHandler) WithUserRepository(users repository.UserRepository) *PasswordHandler {
+	h.users = users
+	return h
+}
+
+// WithRevocations sets the token revocation list used to end sessions after a password change
+func (h *PasswordHandler) WithRevocations(revocations repository.TokenRevocationRepository) *PasswordHandler {
+	h.revocations = revocations
+	return h
+}
+
+// WithAuditor records password changes and resets in the audit log
+func (h *PasswordHandler) WithAuditor(auditor audit.Auditor) *PasswordHandler {
+	h.auditor = auditor
+	return h
+}
+
+// WithResetToken configures the reset token lifetime and the page the token is sent to.
+// A non-positive ttl keeps the current setting; an empty resetURL sends the bare token.
+func (h *PasswordHandler) WithResetToken(ttl time.Duration, resetURL string) *PasswordHandler {
+	if ttl > 0 {
+		h.resetTTL = ttl
+	}
+	h.resetURL = resetURL
+	return h
+}
+
+// ChangePasswordRequest represents the request body for changing a password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+}
+
+// ForgotPasswordRequest represents the request body for requesting a password reset
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest represents the request body for resetting a password with a token
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=6"`
+}
+
+// ChangePassword changes the password of the authenticated user
+// @Summary Change password
+// @Description Change the caller's own password. Requires the current password and signs out every session.
+// @Tags users
+// @Accept json
+// @Security bearerauth
+// @Param id path int true "User ID"
+// @Param request body ChangePasswordRequest true "Current and new password"
+// @Success 204 "No Content"
+// @Failure 400 {object} problem.Problem "Invalid request or wrong current password"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Forbidden"
+// @Failure 404 {object} problem.Problem "User not found"
+// @Router /v1/users/{id}/password [put]
+func (h *PasswordHandler) ChangePassword(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		problem.Render(c, problem.BadRequest(problem.CodeInvalidUserID, "invalid user ID"))
+		return
+	}
+
+	// Only the owner knows the current password, so admins cannot use this endpoint for others
+	if c.GetUint("user_id") != uint(id) {
+		problem.Render(c, problem.Forbidden(problem.CodeForbidden, "insufficient permissions"))
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		problem.Render(c, problem.FromBinding(err))
+		return
+	}
+
+	ctx := c.Request.Context()
+	requestID, _ := c.Get("request_id")
+	clientIP := c.ClientIP()
+
+	user, err := h.users.FindByID(ctx, uint(id))
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			problem.Render(c, problem.NotFound(problem.CodeUserNotFound, "user not found"))
+			return
+		}
+		problem.Render(c, problem.Internal("failed to fetch user"))
+		return
+	}
+
+	if !utils.CheckPasswordHash(req.CurrentPassword, user.PasswordHash) {
+		h.logger.Warn("password change with invalid current password",
+			zap.Uint("user_id", user.ID),
+			zap.String("client_ip", clientIP),
+			zap.Any("request_id", requestID),
+		)
+		problem.Render(c, problem.BadRequest(problem.CodeIncorrectPassword, "current password is incorrect"))
+		return
+	}
+
+	if err := h.setPassword(ctx, user, req.NewPassword); err != nil {
+		h.logger.Error("failed to change password",
+			zap.Error(err),
+			zap.Uint("user_id", user.ID),
+			zap.Any("request_id", requestID),
+		)
+		problem.Render(c, problem.Internal("failed to change password"))
+		return
+	}
+
+	h.logger.Info("password changed",
+		zap.Uint("user_id", user.ID),
+		zap.String("client_ip", clientIP),
+		zap.Any("request_id", requestID),
+	)
+	h.record(c, audit.NewEvent(c, audit.ActionPasswordChanged).ForUser(user.ID))
+
+	c.Status(http.StatusNoContent)
+}
+
+// ForgotPassword sends a password reset token to the user's email address
+// @Summary Request password reset
+// @Description Send a single-use password reset token to the given address. The response is the same whether or not the account exists.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body ForgotPasswordRequest true "Account email"
+// @Success 202 {object} map[string]string "Accepted"
+// @Failure 400 {object} problem.Problem "Invalid request"
+// @Router /v1/password/forgot [post]
+func (h *PasswordHandler) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		problem.Render(c, problem.FromBinding(err))
+		return
+	}
+
+	ctx := c.Request.Context()
+	requestID, _ := c.Get("request_id")
+	clientIP := c.ClientIP()
+
+	// Respond identically for unknown addresses so accounts cannot be enumerated
+	accepted := gin.H{"message": "if the account exists, a password reset link has been sent"}
+
+	user, err := h.users.FindByEmail(ctx, req.Email)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			h.logger.Info("password reset requested for unknown email",
+				zap.String("email", req.Email),
+				zap.String("client_ip", clientIP),
+				zap.Any("request_id", requestID),
+			)
+		} else {
+			h.logger.Error("database error during password reset request",
+				zap.Error(err),
+				zap.String("client_ip", clientIP),
+				zap.Any("request_id", requestID),
+			)
+		}
+		c.JSON(http.StatusAccepted, accepted)
+		return
+	}
+
+	if err := h.sendResetToken(ctx, user); err != nil {
+		h.logger.Error("failed to send password reset token",
+			zap.Error(err),
+			zap.Uint("user_id", user.ID),
+			zap.Any("request_id", requestID),
+		)
+		c.JSON(http.StatusAccepted, accepted)
+		return
+	}
+
+	h.logger.Info("password reset token sent",
+		zap.Uint("user_id", user.ID),
+		zap.String("client_ip", clientIP),
+		zap.Any("request_id", requestID),
+	)
+
+	c.JSON(http.StatusAccepted, accepted)
+}
+
+// ResetPassword sets a new password using a password reset token
+// @Summary Reset password
+// @Description Set a new password with a token from the reset email. The token can be used once and every session is signed out.
+// @Tags auth
+// @Accept json
+// @Param request body ResetPasswordRequest true "Reset token and new password"
+// @Success 204 "No Content"
+// @Failure 400 {object} problem.Problem "Invalid request or invalid token"
+// @Router /v1/password/reset [post]
+func (h *PasswordHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		problem.Render(c, problem.FromBinding(err))
+		return
+	}
+
+	ctx := c.Request.Context()
+	requestID, _ := c.Get("request_id")
+	clientIP := c.ClientIP()
+	invalidToken := problem.BadRequest(problem.CodeInvalidResetToken, "invalid or expired reset token")
+
+	token, err := h.resetTokens.FindByHash(ctx, utils.HashToken(req.Token))
+	if err != nil {
+		if !errors.Is(err, repository.ErrPasswordResetTokenNotFound) {
+			h.logger.Error("database error during password reset",
+				zap.Error(err),
+				zap.String("client_ip", clientIP),
+				zap.Any("request_id", requestID),
+			)
+		}
+		problem.Render(c, invalidToken)
+		return
+	}
+
+	if token.IsUsed() || token.IsExpired(time.Now()) {
+		problem.Render(c, invalidToken)
+		return
+	}
+
+	user, err := h.users.FindByID(ctx, token.UserID)
+	if err != nil {
+		problem.Render(c, invalidToken)
+		return
+	}
+
+	// setPassword redeems the token only after the new password is stored, so a
+	// failure leaves it usable. A concurrent reset that read the same user
+	// version fails the versioned update.
+	if err := h.setPassword(ctx, user, req.NewPassword); err != nil {
+		if errors.Is(err, repository.ErrVersionConflict) {
+			problem.Render(c, invalidToken)
+			return
+		}
+		h.logger.Error("failed to reset password",
+			zap.Error(err),
+			zap.Uint("user_id", user.ID),
+			zap.Any("request_id", requestID),
+		)
+		problem.Render(c, problem.Internal("failed to reset password"))
+		return
+	}
+
+	h.logger.Info("password reset",
+		zap.Uint("user_id", user.ID),
+		zap.String("client_ip", clientIP),
+		zap.Any("request_id", requestID),
+	)
+	// The caller is anonymous, so the event is attributed to the account owner
+	event := audit.NewEvent(c, audit.ActionPasswordReset).ForUser(user.ID)
+	event.ActorID = user.ID
+	h.record(c, event)
+
+	c.Status(http.StatusNoContent)
+}
+
+// setPassword stores a new password hash, invalidates outstanding reset
+// tokens and revokes every access and refresh token of the user.
+func (h *PasswordHandler) setPassword(ctx context.Context, user *models.User, password string) error {
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	user.PasswordHash = hash
+	if err := h.users.Update(ctx, user); err != nil {
+		return err
+	}
+
+	if err := h.resetTokens.InvalidateAllForUser(ctx, user.ID); err != nil {
+		return err
+	}
+	if err := h.revocations.RevokeAllForUser(ctx, user.ID, time.Now()); err != nil {
+		return err
+	}
+	return h.refreshTokens.RevokeAllForUser(ctx, user.ID)
+}
+
+// record passes an event to the auditor if one is configured
+func (h *PasswordHandler) record(c *gin.Context, event audit.Event) {
+	if h.auditor != nil {
+		h.auditor.Record(c.Request.Context(), event)
+	}
+}
+
+// sendResetToken replaces any outstanding reset token of the user with a new one and sends it
+func (h *PasswordHandler) sendResetToken(ctx context.Context, user *models.User) error {
+	token, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	if err := h.resetTokens.InvalidateAllForUser(ctx, user.ID); err != nil {
+		return err
+	}
+	if err := h.resetTokens.Create(ctx, &models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(h.resetTTL),
+	}); err != nil {
+		return err
+	}
+
+	link, err := tokenLink(h.resetURL, token)
+	if err != nil {
+		return err
+	}
+
+	return h.notifier.Send(ctx, notification.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: "Hi " + user.Name + ",\n\n" +
+			"Use the following link to choose a new password. It expires in " + h.resetTTL.String() + " and can be used once.\n\n" +
+			link + "\n\n" +
+			"If you did not request a password reset, you can ignore this message.",
+	})
+}
+
+// tokenLink appends token as the token query parameter of base, or returns
+// the bare token if no base URL is configured
+func tokenLink(base, token string) (string, error) {
+	if base == "" {
+		return token, nil
+	}
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
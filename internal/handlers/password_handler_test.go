@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"myapp/internal/audit"
+	"myapp/internal/models"
+	"myapp/internal/repository"
+	"myapp/pkg/notification"
+	"myapp/pkg/problem"
+	"myapp/pkg/utils"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// recordingNotifier keeps sent messages in memory
+type recordingNotifier struct {
+	mu       sync.Mutex
+	messages []notification.Message
+}
+
+func (n *recordingNotifier) Send(ctx context.Context, msg notification.Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.messages = append(n.messages, msg)
+	return nil
+}
+
+func (n *recordingNotifier) sent() []notification.Message {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]notification.Message(nil), n.messages...)
+}
+
+// tokenFromLink extracts the token from the link in a message
+func tokenFromLink(t *testing.T, msg notification.Message) string {
+	for _, line := range strings.Split(msg.Body, "\n") {
+		if strings.HasPrefix(line, "http") {
+			u, err := url.Parse(line)
+			require.NoError(t, err)
+			return u.Query().Get("token")
+		}
+	}
+	t.Fatalf("no reset link in message: %s", msg.Body)
+	return ""
+}
+
+func postJSON(router *gin.Engine, method, path string, payload any) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(payload)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// problemCode returns the error code of a problem details response
+func problemCode(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	var response problem.Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+	return response.Code
+}
+
+func setupPasswordTest(t *testing.T) (*gorm.DB, *gin.Engine, *recordingNotifier, *models.User) {
+	gin.SetMode(gin.TestMode)
+	db := setupTestDB(t)
+	logger := setupTestLogger()
+
+	hashedPassword, _ := utils.HashPassword("password123")
+	user := &models.User{
+		Name:         "Test User",
+		Email:        "test@example.com",
+		PasswordHash: hashedPassword,
+		Role:         "user",
+	}
+	db.Create(user)
+
+	notifier := &recordingNotifier{}
+	handler := NewPasswordHandler(db, notifier, logger).
+		WithResetToken(time.Hour, "http://localhost:3000/reset-password").
+		WithAuditor(audit.NewRecorder(repository.NewPostgresAuditRepository(db), logger))
+	authHandler := NewAuthHandler(db, "test-secret", logger)
+
+	router := gin.New()
+	router.POST("/login", authHandler.Login)
+	router.POST("/token/refresh", authHandler.Refresh)
+	router.POST("/password/forgot", handler.ForgotPassword)
+	router.POST("/password/reset", handler.ResetPassword)
+
+	// Simulate the JWT middleware; X-Other-User authenticates as a different user
+	authenticated := router.Group("/")
+	authenticated.Use(func(c *gin.Context) {
+		c.Set("user_id", user.ID)
+		if c.GetHeader("X-Other-User") != "" {
+			c.Set("user_id", user.ID+1)
+		}
+		c.Next()
+	})
+	authenticated.PUT("/users/:id/password", handler.ChangePassword)
+
+	return db, router, notifier, user
+}
+
+// failingUpdates is a user repository whose updates fail
+type failingUpdates struct {
+	repository.UserRepository
+}
+
+func (failingUpdates) Update(ctx context.Context, user *models.User) error {
+	return errors.New("database unavailable")
+}
+
+// passwordAuditActions returns the password actions recorded for the user in order
+func passwordAuditActions(t *testing.T, db *gorm.DB, userID uint) []string {
+	var actions []string
+	require.NoError(t, db.Model(&models.AuditEvent{}).
+		Where("target_id = ? AND action IN ?", strconv.FormatUint(uint64(userID), 10),
+			[]string{audit.ActionPasswordChanged, audit.ActionPasswordReset}).
+		Order("id").Pluck("action", &actions).Error)
+	return actions
+}
+
+func TestChangePassword(t *testing.T) {
+	t.Run("should change password and revoke sessions", func(t *testing.T) {
+		db, router, _, user := setupPasswordTest(t)
+		login := loginTestUser(t, router, "test@example.com", "password123")
+
+		w := postJSON(router, "PUT", "/users/1/password", ChangePasswordRequest{
+			CurrentPassword: "password123",
+			NewPassword:     "newpassword456",
+		})
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		w = postJSON(router, "POST", "/login", LoginRequest{Email: "test@example.com", Password: "password123"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		loginTestUser(t, router, "test@example.com", "newpassword456")
+
+		w = refreshTestToken(router, login.RefreshToken)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, []string{audit.ActionPasswordChanged}, passwordAuditActions(t, db, user.ID))
+	})
+
+	t.Run("should reject wrong current password", func(t *testing.T) {
+		_, router, _, _ := setupPasswordTest(t)
+
+		w := postJSON(router, "PUT", "/users/1/password", ChangePasswordRequest{
+			CurrentPassword: "wrong",
+			NewPassword:     "newpassword456",
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, problem.CodeIncorrectPassword, problemCode(t, w))
+		loginTestUser(t, router, "test@example.com", "password123")
+	})
+
+	t.Run("should reject changing another user's password", func(t *testing.T) {
+		_, router, _, _ := setupPasswordTest(t)
+
+		body, _ := json.Marshal(ChangePasswordRequest{CurrentPassword: "password123", NewPassword: "newpassword456"})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/users/1/password", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Other-User", "1")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("should reject short new password", func(t *testing.T) {
+		_, router, _, _ := setupPasswordTest(t)
+
+		w := postJSON(router, "PUT", "/users/1/password", ChangePasswordRequest{
+			CurrentPassword: "password123",
+			NewPassword:     "short",
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestForgotPassword(t *testing.T) {
+	t.Run("should send a reset link and store only the token hash", func(t *testing.T) {
+		db, router, notifier, user := setupPasswordTest(t)
+
+		w := postJSON(router, "POST", "/password/forgot", ForgotPasswordRequest{Email: "test@example.com"})
+		assert.Equal(t, http.StatusAccepted, w.Code)
+
+		sent := notifier.sent()
+		require.Len(t, sent, 1)
+		assert.Equal(t, "test@example.com", sent[0].To)
+		assert.Contains(t, sent[0].Body, "http://localhost:3000/reset-password?token=")
+
+		token := tokenFromLink(t, sent[0])
+		var stored models.PasswordResetToken
+		require.NoError(t, db.Where("user_id = ?", user.ID).First(&stored).Error)
+		assert.Equal(t, utils.HashToken(token), stored.TokenHash)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), stored.ExpiresAt, time.Minute)
+	})
+
+	t.Run("should respond identically for unknown email", func(t *testing.T) {
+		_, router, notifier, _ := setupPasswordTest(t)
+
+		known := postJSON(router, "POST", "/password/forgot", ForgotPasswordRequest{Email: "test@example.com"})
+		unknown := postJSON(router, "POST", "/password/forgot", ForgotPasswordRequest{Email: "nobody@example.com"})
+
+		assert.Equal(t, known.Code, unknown.Code)
+		assert.Equal(t, known.Body.String(), unknown.Body.String())
+		assert.Len(t, notifier.sent(), 1)
+	})
+}
+
+func TestResetPassword(t *testing.T) {
+	requestToken := func(t *testing.T, router *gin.Engine, notifier *recordingNotifier) string {
+		w := postJSON(router, "POST", "/password/forgot", ForgotPasswordRequest{Email: "test@example.com"})
+		require.Equal(t, http.StatusAccepted, w.Code)
+		sent := notifier.sent()
+		return tokenFromLink(t, sent[len(sent)-1])
+	}
+
+	t.Run("should reset password once", func(t *testing.T) {
+		db, router, notifier, user := setupPasswordTest(t)
+		login := loginTestUser(t, router, "test@example.com", "password123")
+		token := requestToken(t, router, notifier)
+
+		w := postJSON(router, "POST", "/password/reset", ResetPasswordRequest{Token: token, NewPassword: "newpassword456"})
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		loginTestUser(t, router, "test@example.com", "newpassword456")
+		assert.Equal(t, http.StatusUnauthorized, refreshTestToken(router, login.RefreshToken).Code)
+
+		w = postJSON(router, "POST", "/password/reset", ResetPasswordRequest{Token: token, NewPassword: "another789"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		loginTestUser(t, router, "test@example.com", "newpassword456")
+		assert.Equal(t, []string{audit.ActionPasswordReset}, passwordAuditActions(t, db, user.ID))
+	})
+
+	t.Run("should keep the token usable when the password cannot be stored", func(t *testing.T) {
+		db, router, notifier, _ := setupPasswordTest(t)
+		token := requestToken(t, router, notifier)
+
+		failing := NewPasswordHandler(db, notifier, setupTestLogger()).
+			WithUserRepository(failingUpdates{repository.NewPostgresUserRepository(db)})
+		router.POST("/password/reset-failing", failing.ResetPassword)
+
+		w := postJSON(router, "POST", "/password/reset-failing", ResetPasswordRequest{Token: token, NewPassword: "newpassword456"})
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+		w = postJSON(router, "POST", "/password/reset", ResetPasswordRequest{Token: token, NewPassword: "newpassword456"})
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		loginTestUser(t, router, "test@example.com", "newpassword456")
+	})
+
+	t.Run("should reject expired token", func(t *testing.T) {
+		db, router, notifier, _ := setupPasswordTest(t)
+		token := requestToken(t, router, notifier)
+		db.Model(&models.PasswordResetToken{}).Where("1 = 1").Update("expires_at", time.Now().Add(-time.Minute))
+
+		w := postJSON(router, "POST", "/password/reset", ResetPasswordRequest{Token: token, NewPassword: "newpassword456"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, problem.CodeInvalidResetToken, problemCode(t, w))
+	})
+
+	t.Run("should reject unknown token", func(t *testing.T) {
+		_, router, _, _ := setupPasswordTest(t)
+
+		w := postJSON(router, "POST", "/password/reset", ResetPasswordRequest{Token: "unknown", NewPassword: "newpassword456"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("should invalidate earlier tokens when a new one is requested", func(t *testing.T) {
+		_, router, notifier, _ := setupPasswordTest(t)
+		first := requestToken(t, router, notifier)
+		second := requestToken(t, router, notifier)
+
+		w := postJSON(router, "POST", "/password/reset", ResetPasswordRequest{Token: first, NewPassword: "newpassword456"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = postJSON(router, "POST", "/password/reset", ResetPasswordRequest{Token: second, NewPassword: "newpassword456"})
+		assert.Equal(t, http.StatusNoContent, w.Code)
+	})
+}
@@ -0,0 +1,493 @@
+package handlers
+
+import (
+	"errors"
+	"myapp/internal/audit"
+	"myapp/internal/models"
+	"myapp/internal/rbac"
+	"myapp/internal/repository"
+	"myapp/pkg/problem"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// roleNamePattern restricts role names to lowercase identifiers
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// RoleHandler manages roles, their permissions and role bindings of users
+type RoleHandler struct {
+	roles   repository.RoleRepository
+	users   repository.UserRepository
+	auditor audit.Auditor
+	logger  *zap.Logger
+}
+
+// NewRoleHandler creates a new role handler
+func NewRoleHandler(db *gorm.DB, logger *zap.Logger) *RoleHandler {
+	return &RoleHandler{
+		roles:  repository.NewPostgresRoleRepository(db),
+		users:  repository.NewPostgresUserRepository(db),
+		logger: logger,
+	}
+}
+
+// WithUserRepository sets the repository used to look up users
+func (h *RoleHandler) WithUserRepository(users repository.UserRepository) *RoleHandler {
+	h.users = users
+	return h
+}
+
+// WithAuditor records changes to roles and role bindings in the audit log
+func (h *RoleHandler) WithAuditor(auditor audit.Auditor) *RoleHandler {
+	h.auditor = auditor
+	return h
+}
+
+// RoleResponse describes a role and the permissions it grants
+type RoleResponse struct {
+	ID          uint      `json:"id" example:"3"`
+	Name        string    `json:"name" example:"support"`
+	Description string    `json:"description" example:"Helpdesk staff"`
+	Builtin     bool      `json:"builtin" example:"false"`
+	Permissions []string  `json:"permissions" example:"users:list,users:read"`
+	CreatedAt   time.Time `json:"created_at" example:"2024-01-01T00:00:00Z"`
+	UpdatedAt   time.Time `json:"updated_at" example:"2024-01-01T00:00:00Z"`
+}
+
+// CreateRoleRequest represents the request body for creating a role
+type CreateRoleRequest struct {
+	Name        string   `json:"name" binding:"required,max=50"`
+	Description string   `json:"description" binding:"max=255"`
+	Permissions []string `json:"permissions"`
+}
+
+// UpdateRoleRequest represents the request body for updating a role
+type UpdateRoleRequest struct {
+	Description string   `json:"description" binding:"max=255"`
+	Permissions []string `json:"permissions"`
+}
+
+// ListPermissions lists every permission that can be granted
+// @Summary List permissions
+// @Description List every permission that can be granted to roles (requires roles:read)
+// @Tags roles
+// @Produce json
+// @Security bearerauth
+// @Success 200 {array} models.Permission
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Forbidden"
+// @Router /v1/permissions [get]
+func (h *RoleHandler) ListPermissions(c *gin.Context) {
+	permissions, err := h.roles.ListPermissions(c.Request.Context())
+	if err != nil {
+		problem.Render(c, problem.Internal("failed to fetch permissions"))
+		return
+	}
+	c.JSON(http.StatusOK, permissions)
+}
+
+// ListRoles lists all roles
+// @Summary List roles
+// @Description List all roles with their permissions (requires roles:read)
+// @Tags roles
+// @Produce json
+// @Security bearerauth
+// @Success 200 {array} RoleResponse
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Forbidden"
+// @Router /v1/roles [get]
+func (h *RoleHandler) ListRoles(c *gin.Context) {
+	roles, err := h.roles.ListRoles(c.Request.Context())
+	if err != nil {
+		problem.Render(c, problem.Internal("failed to fetch roles"))
+		return
+	}
+	c.JSON(http.StatusOK, newRoleResponses(roles))
+}
+
+// GetRole retrieves a role by ID
+// @Summary Get role
+// @Description Get a role and its permissions (requires roles:read)
+// @Tags roles
+// @Produce json
+// @Security bearerauth
+// @Param id path int true "Role ID"
+// @Success 200 {object} RoleResponse
+// @Failure 400 {object} problem.Problem "Invalid ID"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Forbidden"
+// @Failure 404 {object} problem.Problem "Role not found"
+// @Router /v1/roles/{id} [get]
+func (h *RoleHandler) GetRole(c *gin.Context) {
+	role, ok := h.findRole(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, newRoleResponse(role))
+}
+
+// CreateRole creates a role
+// @Summary Create role
+// @Description Create a role granting the given permissions (requires roles:write)
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Security bearerauth
+// @Param request body CreateRoleRequest true "Role"
+// @Success 201 {object} RoleResponse
+// @Failure 400 {object} problem.Problem "Invalid request or unknown permission"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Forbidden"
+// @Failure 409 {object} problem.Problem "Role already exists"
+// @Router /v1/roles [post]
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	var req CreateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		problem.Render(c, problem.FromBinding(err))
+		return
+	}
+	if !roleNamePattern.MatchString(req.Name) {
+		err := problem.BadRequest(problem.CodeValidationFailed, "request validation failed")
+		err.Fields = []problem.FieldError{{Field: "name", Code: "format", Detail: "must start with a lowercase letter and contain only lowercase letters, digits, - and _"}}
+		problem.Render(c, err)
+		return
+	}
+
+	role := &models.Role{Name: req.Name, Description: req.Description}
+	if err := h.roles.CreateRole(c.Request.Context(), role, req.Permissions); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRoleExists):
+			problem.Render(c, problem.Conflict(problem.CodeRoleExists, "role already exists"))
+		case errors.Is(err, repository.ErrUnknownPermission):
+			problem.Render(c, problem.BadRequest(problem.CodeUnknownPermission, "unknown permission"))
+		default:
+			problem.Render(c, problem.Internal("failed to create role"))
+		}
+		return
+	}
+
+	requestID, _ := c.Get("request_id")
+	h.logger.Info("role created",
+		zap.String("role", role.Name),
+		zap.Strings("permissions", role.PermissionNames()),
+		zap.Uint("admin_user_id", c.GetUint("user_id")),
+		zap.Any("request_id", requestID),
+	)
+
+	response := newRoleResponse(role)
+	event := audit.NewEvent(c, audit.ActionRoleCreated).ForRole(role.ID)
+	event.Changes = audit.Diff(nil, response)
+	h.record(c, event)
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// UpdateRole replaces the description and permissions of a role
+// @Summary Update role
+// @Description Replace the description and permissions of a role. The admin role always has every permission and cannot be changed. (requires roles:write)
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Security bearerauth
+// @Param id path int true "Role ID"
+// @Param request body UpdateRoleRequest true "Role"
+// @Success 200 {object} RoleResponse
+// @Failure 400 {object} problem.Problem "Invalid request or unknown permission"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Forbidden"
+// @Failure 404 {object} problem.Problem "Role not found"
+// @Failure 409 {object} problem.Problem "Role cannot be changed"
+// @Router /v1/roles/{id} [put]
+func (h *RoleHandler) UpdateRole(c *gin.Context) {
+	role, ok := h.findRole(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var req UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		problem.Render(c, problem.FromBinding(err))
+		return
+	}
+
+	if role.Name == rbac.RoleAdmin {
+		problem.Render(c, problem.Conflict(problem.CodeBuiltinRole, "the admin role always has every permission"))
+		return
+	}
+
+	before := newRoleResponse(role)
+	role.Description = req.Description
+	if err := h.roles.UpdateRole(c.Request.Context(), role, req.Permissions); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrUnknownPermission):
+			problem.Render(c, problem.BadRequest(problem.CodeUnknownPermission, "unknown permission"))
+		case errors.Is(err, repository.ErrRoleNotFound):
+			problem.Render(c, problem.NotFound(problem.CodeRoleNotFound, "role not found"))
+		default:
+			problem.Render(c, problem.Internal("failed to update role"))
+		}
+		return
+	}
+
+	requestID, _ := c.Get("request_id")
+	h.logger.Info("role updated",
+		zap.String("role", role.Name),
+		zap.Strings("permissions", role.PermissionNames()),
+		zap.Uint("admin_user_id", c.GetUint("user_id")),
+		zap.Any("request_id", requestID),
+	)
+
+	response := newRoleResponse(role)
+	event := audit.NewEvent(c, audit.ActionRoleUpdated).ForRole(role.ID)
+	event.Changes = audit.Diff(before, response)
+	h.record(c, event)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteRole deletes a role
+// @Summary Delete role
+// @Description Delete a role and remove it from every user it is bound to. Built-in roles and roles that are still the primary role of a user cannot be deleted. (requires roles:write)
+// @Tags roles
+// @Security bearerauth
+// @Param id path int true "Role ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} problem.Problem "Invalid ID"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Forbidden"
+// @Failure 404 {object} problem.Problem "Role not found"
+// @Failure 409 {object} problem.Problem "Role is built in or in use"
+// @Router /v1/roles/{id} [delete]
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	role, ok := h.findRole(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	if role.Builtin {
+		problem.Render(c, problem.Conflict(problem.CodeBuiltinRole, "built-in roles cannot be deleted"))
+		return
+	}
+
+	if err := h.roles.DeleteRole(c.Request.Context(), role.ID); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRoleInUse):
+			problem.Render(c, problem.Conflict(problem.CodeRoleInUse, "role is still assigned to users"))
+		case errors.Is(err, repository.ErrRoleNotFound):
+			problem.Render(c, problem.NotFound(problem.CodeRoleNotFound, "role not found"))
+		default:
+			problem.Render(c, problem.Internal("failed to delete role"))
+		}
+		return
+	}
+
+	requestID, _ := c.Get("request_id")
+	h.logger.Info("role deleted",
+		zap.String("role", role.Name),
+		zap.Uint("admin_user_id", c.GetUint("user_id")),
+		zap.Any("request_id", requestID),
+	)
+
+	event := audit.NewEvent(c, audit.ActionRoleDeleted).ForRole(role.ID)
+	event.Changes = audit.Diff(newRoleResponse(role), nil)
+	h.record(c, event)
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListUserRoles lists the roles bound to a user
+// @Summary List role bindings of a user
+// @Description List the roles granted to a user in addition to their primary role (requires roles:read)
+// @Tags roles
+// @Produce json
+// @Security bearerauth
+// @Param id path int true "User ID"
+// @Success 200 {array} RoleResponse
+// @Failure 400 {object} problem.Problem "Invalid ID"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Forbidden"
+// @Failure 404 {object} problem.Problem "User not found"
+// @Router /v1/users/{id}/roles [get]
+func (h *RoleHandler) ListUserRoles(c *gin.Context) {
+	userID, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+
+	roles, err := h.roles.ListUserRoles(c.Request.Context(), userID)
+	if err != nil {
+		problem.Render(c, problem.Internal("failed to fetch roles"))
+		return
+	}
+	c.JSON(http.StatusOK, newRoleResponses(roles))
+}
+
+// BindRole grants a role to a user
+// @Summary Bind role to user
+// @Description Grant a role to a user in addition to their primary role. Takes effect with the next access token. The admin role cannot be bound; it is only granted as a primary role. (requires roles:write)
+// @Tags roles
+// @Security bearerauth
+// @Param id path int true "User ID"
+// @Param role_id path int true "Role ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} problem.Problem "Invalid ID"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Forbidden"
+// @Failure 404 {object} problem.Problem "User or role not found"
+// @Failure 409 {object} problem.Problem "The admin role cannot be bound"
+// @Router /v1/users/{id}/roles/{role_id} [put]
+func (h *RoleHandler) BindRole(c *gin.Context) {
+	userID, ok := h.findUser(c)
+	if !ok {
+		return
+	}
+	role, ok := h.findRole(c, c.Param("role_id"))
+	if !ok {
+		return
+	}
+
+	if role.Name == rbac.RoleAdmin {
+		problem.Render(c, problem.Conflict(problem.CodeBuiltinRole, "the admin role cannot be bound; assign it as the primary role of the user instead"))
+		return
+	}
+
+	if err := h.roles.BindRole(c.Request.Context(), userID, role.ID); err != nil {
+		problem.Render(c, problem.Internal("failed to bind role"))
+		return
+	}
+
+	requestID, _ := c.Get("request_id")
+	h.logger.Info("role bound to user",
+		zap.String("role", role.Name),
+		zap.Uint("target_user_id", userID),
+		zap.Uint("admin_user_id", c.GetUint("user_id")),
+		zap.Any("request_id", requestID),
+	)
+
+	h.record(c, audit.NewEvent(c, audit.ActionUserRoleBound).ForUser(userID).
+		With("role_id", role.ID).With("role", role.Name))
+
+	c.Status(http.StatusNoContent)
+}
+
+// UnbindRole removes a role from a user
+// @Summary Unbind role from user
+// @Description Remove a role binding of a user. Takes effect with the next access token. (requires roles:write)
+// @Tags roles
+// @Security bearerauth
+// @Param id path int true "User ID"
+// @Param role_id path int true "Role ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} problem.Problem "Invalid ID"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Forbidden"
+// @Failure 404 {object} problem.Problem "Role not bound to user"
+// @Router /v1/users/{id}/roles/{role_id} [delete]
+func (h *RoleHandler) UnbindRole(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		problem.Render(c, problem.BadRequest(problem.CodeInvalidUserID, "invalid user ID"))
+		return
+	}
+	roleID, err := strconv.ParseUint(c.Param("role_id"), 10, 32)
+	if err != nil {
+		problem.Render(c, problem.BadRequest(problem.CodeInvalidRoleID, "invalid role ID"))
+		return
+	}
+
+	if err := h.roles.UnbindRole(c.Request.Context(), uint(userID), uint(roleID)); err != nil {
+		if errors.Is(err, repository.ErrRoleNotFound) {
+			problem.Render(c, problem.NotFound(problem.CodeRoleNotBound, "role not bound to user"))
+			return
+		}
+		problem.Render(c, problem.Internal("failed to unbind role"))
+		return
+	}
+
+	requestID, _ := c.Get("request_id")
+	h.logger.Info("role unbound from user",
+		zap.Uint64("role_id", roleID),
+		zap.Uint64("target_user_id", userID),
+		zap.Uint("admin_user_id", c.GetUint("user_id")),
+		zap.Any("request_id", requestID),
+	)
+
+	h.record(c, audit.NewEvent(c, audit.ActionUserRoleUnbound).ForUser(uint(userID)).
+		With("role_id", uint(roleID)))
+
+	c.Status(http.StatusNoContent)
+}
+
+// record writes event to the audit log if an auditor is configured
+func (h *RoleHandler) record(c *gin.Context, event audit.Event) {
+	if h.auditor != nil {
+		h.auditor.Record(c.Request.Context(), event)
+	}
+}
+
+// findRole loads the role with the given ID and writes an error response if it fails
+func (h *RoleHandler) findRole(c *gin.Context, idParam string) (*models.Role, bool) {
+	id, err := strconv.ParseUint(idParam, 10, 32)
+	if err != nil {
+		problem.Render(c, problem.BadRequest(problem.CodeInvalidRoleID, "invalid role ID"))
+		return nil, false
+	}
+
+	role, err := h.roles.FindRoleByID(c.Request.Context(), uint(id))
+	if err != nil {
+		if errors.Is(err, repository.ErrRoleNotFound) {
+			problem.Render(c, problem.NotFound(problem.CodeRoleNotFound, "role not found"))
+			return nil, false
+		}
+		problem.Render(c, problem.Internal("failed to fetch role"))
+		return nil, false
+	}
+	return role, true
+}
+
+// findUser checks that the user in the id path parameter exists and writes an error response if not
+func (h *RoleHandler) findUser(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		problem.Render(c, problem.BadRequest(problem.CodeInvalidUserID, "invalid user ID"))
+		return 0, false
+	}
+
+	if _, err := h.users.FindByID(c.Request.Context(), uint(id)); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			problem.Render(c, problem.NotFound(problem.CodeUserNotFound, "user not found"))
+			return 0, false
+		}
+		problem.Render(c, problem.Internal("failed to fetch user"))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// newRoleResponse converts a role to its API representation
+func newRoleResponse(role *models.Role) RoleResponse {
+	return RoleResponse{
+		ID:          role.ID,
+		Name:        role.Name,
+		Description: role.Description,
+		Builtin:     role.Builtin,
+		Permissions: role.PermissionNames(),
+		CreatedAt:   role.CreatedAt,
+		UpdatedAt:   role.UpdatedAt,
+	}
+}
+
+// newRoleResponses converts roles to their API representation
+func newRoleResponses(roles []models.Role) []RoleResponse {
+	response := make([]RoleResponse, len(roles))
+	for i := range roles {
+		response[i] = newRoleResponse(&roles[i])
+	}
+	return response
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"myapp/internal/audit"
+	"myapp/internal/models"
+	"myapp/internal/rbac"
+	"myapp/internal/repository"
+	"myapp/pkg/problem"
+	"myapp/pkg/utils"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupRoleTest(t *testing.T) (*gorm.DB, *gin.Engine) {
+	gin.SetMode(gin.TestMode)
+	db := setupTestDB(t)
+	require.NoError(t, rbac.Seed(t.Context(), repository.NewPostgresRoleRepository(db)))
+
+	hashedPassword, _ := utils.HashPassword("password123")
+	db.Create(&models.User{Name: "Test User", Email: "test@example.com", PasswordHash: hashedPassword, Role: "user"})
+
+	logger := setupTestLogger()
+	roleHandler := NewRoleHandler(db, logger).WithAuditor(audit.NewRecorder(repository.NewPostgresAuditRepository(db), logger))
+
+	router := gin.New()
+	// Simulate the JWT and permission middleware
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", uint(99))
+		c.Set("user_role", "admin")
+		c.Next()
+	})
+	router.GET("/permissions", roleHandler.ListPermissions)
+	router.GET("/roles", roleHandler.ListRoles)
+	router.GET("/roles/:id", roleHandler.GetRole)
+	router.POST("/roles", roleHandler.CreateRole)
+	router.PUT("/roles/:id", roleHandler.UpdateRole)
+	router.DELETE("/roles/:id", roleHandler.DeleteRole)
+	router.GET("/users/:id/roles", roleHandler.ListUserRoles)
+	router.PUT("/users/:id/roles/:role_id", roleHandler.BindRole)
+	router.DELETE("/users/:id/roles/:role_id", roleHandler.UnbindRole)
+	router.GET("/audit", NewAuditHandler(db, logger).ListAuditEvents)
+
+	return db, router
+}
+
+// createTestRole creates a role through the API and returns it
+func createTestRole(t *testing.T, router *gin.Engine, name string, permissions ...string) RoleResponse {
+	w := postJSON(router, "POST", "/roles", CreateRoleRequest{Name: name, Permissions: permissions})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var role RoleResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &role))
+	return role
+}
+
+func TestCreateRole(t *testing.T) {
+	t.Run("should create role with permissions", func(t *testing.T) {
+		_, router := setupRoleTest(t)
+
+		role := createTestRole(t, router, "support", rbac.PermUsersRead, rbac.PermUsersList)
+		assert.Equal(t, "support", role.Name)
+		assert.False(t, role.Builtin)
+		assert.Equal(t, []string{rbac.PermUsersList, rbac.PermUsersRead}, role.Permissions)
+
+		w := postJSON(router, "GET", fmt.Sprintf("/roles/%d", role.ID), nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"permissions":["users:list","users:read"]`)
+
+		w = postJSON(router, "GET", "/roles", nil)
+		var roles []RoleResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &roles))
+		assert.Len(t, roles, 3)
+	})
+
+	t.Run("should reject invalid requests", func(t *testing.T) {
+		_, router := setupRoleTest(t)
+
+		w := postJSON(router, "POST", "/roles", CreateRoleRequest{Name: "Support Staff"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = postJSON(router, "POST", "/roles", CreateRoleRequest{Name: "support", Permissions: []string{"users:fly"}})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "unknown permission")
+
+		w = postJSON(router, "POST", "/roles", CreateRoleRequest{Name: rbac.RoleUser})
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+}
+
+func TestUpdateRole(t *testing.T) {
+	t.Run("should replace permissions", func(t *testing.T) {
+		_, router := setupRoleTest(t)
+		role := createTestRole(t, router, "support", rbac.PermUsersRead)
+
+		w := postJSON(router, "PUT", fmt.Sprintf("/roles/%d", role.ID), UpdateRoleRequest{
+			Description: "Helpdesk",
+			Permissions: []string{rbac.PermLockoutsManage},
+		})
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var updated RoleResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+		assert.Equal(t, "Helpdesk", updated.Description)
+		assert.Equal(t, []string{rbac.PermLockoutsManage}, updated.Permissions)
+	})
+
+	t.Run("should refuse to change the admin role", func(t *testing.T) {
+		db, router := setupRoleTest(t)
+		admin, err := repository.NewPostgresRoleRepository(db).FindRoleByName(t.Context(), rbac.RoleAdmin)
+		require.NoError(t, err)
+
+		w := postJSON(router, "PUT", fmt.Sprintf("/roles/%d", admin.ID), UpdateRoleRequest{})
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("should return 404 for unknown role", func(t *testing.T) {
+		_, router := setupRoleTest(t)
+
+		w := postJSON(router, "PUT", "/roles/999", UpdateRoleRequest{})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestDeleteRole(t *testing.T) {
+	t.Run("should delete custom role", func(t *testing.T) {
+		_, router := setupRoleTest(t)
+		role := createTestRole(t, router, "support")
+
+		w := postJSON(router, "DELETE", fmt.Sprintf("/roles/%d", role.ID), nil)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		w = postJSON(router, "GET", fmt.Sprintf("/roles/%d", role.ID), nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("should refuse to delete built-in and assigned roles", func(t *testing.T) {
+		db, router := setupRoleTest(t)
+		builtin, err := repository.NewPostgresRoleRepository(db).FindRoleByName(t.Context(), rbac.RoleUser)
+		require.NoError(t, err)
+
+		w := postJSON(router, "DELETE", fmt.Sprintf("/roles/%d", builtin.ID), nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		role := createTestRole(t, router, "support")
+		db.Model(&models.User{}).Where("email = ?", "test@example.com").Update("role", "support")
+		w = postJSON(router, "DELETE", fmt.Sprintf("/roles/%d", role.ID), nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, problem.CodeRoleInUse, problemCode(t, w))
+	})
+}
+
+func TestRoleBindings(t *testing.T) {
+	t.Run("should bind and unbind roles", func(t *testing.T) {
+		_, router := setupRoleTest(t)
+		role := createTestRole(t, router, "support", rbac.PermUsersRead)
+
+		w := postJSON(router, "PUT", fmt.Sprintf("/users/1/roles/%d", role.ID), nil)
+		assert.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+
+		w = postJSON(router, "GET", "/users/1/roles", nil)
+		var roles []RoleResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &roles))
+		require.Len(t, roles, 1)
+		assert.Equal(t, "support", roles[0].Name)
+
+		w = postJSON(router, "DELETE", fmt.Sprintf("/users/1/roles/%d", role.ID), nil)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		w = postJSON(router, "DELETE", fmt.Sprintf("/users/1/roles/%d", role.ID), nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("should refuse to bind the admin role", func(t *testing.T) {
+		db, router := setupRoleTest(t)
+		var admin models.Role
+		require.NoError(t, db.Where("name = ?", rbac.RoleAdmin).First(&admin).Error)
+
+		w := postJSON(router, "PUT", fmt.Sprintf("/users/1/roles/%d", admin.ID), nil)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, problem.CodeBuiltinRole, problemCode(t, w))
+		var bindings int64
+		db.Table("user_roles").Where("user_id = ?", 1).Count(&bindings)
+		assert.Zero(t, bindings)
+	})
+
+	t.Run("should return 404 for unknown user or role", func(t *testing.T) {
+		_, router := setupRoleTest(t)
+		role := createTestRole(t, router, "support")
+
+		w := postJSON(router, "PUT", fmt.Sprintf("/users/999/roles/%d", role.ID), nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), "user not found")
+
+		w = postJSON(router, "PUT", "/users/1/roles/999", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), "role not found")
+	})
+}
+
+func TestLoginEmbedsPermissions(t *testing.T) {
+	t.Run("should include permissions of bound roles in the access token", func(t *testing.T) {
+		db, router := setupRoleTest(t)
+		roles := repository.NewPostgresRoleRepository(db)
+		role := createTestRole(t, router, "support", rbac.PermUsersRead)
+		require.NoError(t, roles.BindRole(t.Context(), 1, role.ID))
+
+		authHandler := NewAuthHandler(db, "test-secret", setupTestLogger()).WithPermissions(roles)
+		router.POST("/login", authHandler.Login)
+		login := loginTestUser(t, router, "test@example.com", "password123")
+
+		claims := jwt.MapClaims{}
+		_, _, err := jwt.NewParser().ParseUnverified(login.Token, claims)
+		require.NoError(t, err)
+		assert.Equal(t, []any{rbac.PermUsersRead}, claims["permissions"])
+	})
+}
+
+func TestRoleAudit(t *testing.T) {
+	t.Run("should record role changes and bindings", func(t *testing.T) {
+		_, router := setupRoleTest(t)
+		role := createTestRole(t, router, "support", rbac.PermUsersRead)
+
+		w := postJSON(router, "PUT", fmt.Sprintf("/roles/%d", role.ID), UpdateRoleRequest{Description: "Helpdesk", Permissions: []string{rbac.PermUsersList}})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		w = postJSON(router, "PUT", fmt.Sprintf("/users/1/roles/%d", role.ID), nil)
+		require.Equal(t, http.StatusNoContent, w.Code)
+		w = postJSON(router, "DELETE", fmt.Sprintf("/users/1/roles/%d", role.ID), nil)
+		require.Equal(t, http.StatusNoContent, w.Code)
+		w = postJSON(router, "DELETE", fmt.Sprintf("/roles/%d", role.ID), nil)
+		require.Equal(t, http.StatusNoContent, w.Code)
+
+		response := listAudit(t, router, "?target_type=role")
+		require.Len(t, response.Data, 3)
+		deleted, updated, created := response.Data[0], response.Data[1], response.Data[2]
+		assert.Equal(t, audit.ActionRoleDeleted, deleted.Action)
+		assert.Contains(t, string(deleted.Changes), `"name":{"before":"support","after":null}`)
+		assert.Equal(t, audit.ActionRoleUpdated, updated.Action)
+		assert.Equal(t, fmt.Sprint(role.ID), updated.TargetID)
+		require.NotNil(t, updated.ActorID)
+		assert.Equal(t, uint(99), *updated.ActorID)
+		assert.JSONEq(t, `{"description":{"before":"","after":"Helpdesk"},"permissions":{"before":["users:read"],"after":["users:list"]}}`, string(updated.Changes))
+		assert.Equal(t, audit.ActionRoleCreated, created.Action)
+		assert.Contains(t, string(created.Changes), `"name":{"before":null,"after":"support"}`)
+
+		response = listAudit(t, router, "?target_type=user&target_id=1")
+		require.Len(t, response.Data, 2)
+		assert.Equal(t, audit.ActionUserRoleUnbound, response.Data[0].Action)
+		assert.JSONEq(t, fmt.Sprintf(`{"role_id":%d}`, role.ID), string(response.Data[0].Metadata))
+		assert.Equal(t, audit.ActionUserRoleBound, response.Data[1].Action)
+		assert.JSONEq(t, fmt.Sprintf(`{"role_id":%d,"role":"support"}`, role.ID), string(response.Data[1].Metadata))
+	})
+}
@@ -1,33 +1,91 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"myapp/internal/audit"
 	"myapp/internal/middleware"
 	"myapp/internal/models"
+	"myapp/internal/rbac"
 	"myapp/internal/repository"
+	"myapp/pkg/jsonpatch"
+	"myapp/pkg/problem"
 	"myapp/pkg/utils"
 	"net/http"
+	"reflect"
+	"slices"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"go.uber.org/zap"
 )
 
+// EmailVerifier sends a verification link to a user's current email address
+type EmailVerifier interface {
+	SendVerification(ctx context.Context, user *models.User) error
+}
+
 // UserHandler handles user-related HTTP requests
 type UserHandler struct {
-	repo repository.UserRepository
+	repo          repository.UserRepository
+	verifier      EmailVerifier
+	roles         repository.RoleRepository
+	auditor       audit.Auditor
+	revocations   repository.TokenRevocationRepository
+	refreshTokens repository.RefreshTokenRepository
+	logger        *zap.Logger
 }
 
 // NewUserHandler creates a new user handler
 func NewUserHandler(repo repository.UserRepository) *UserHandler {
-	return &UserHandler{repo: repo}
+	return &UserHandler{repo: repo, logger: zap.NewNop()}
+}
+
+// WithLogger logs failures that do not fail the request, e.g. session revocation after a role change
+func (h *UserHandler) WithLogger(logger *zap.Logger) *UserHandler {
+	h.logger = logger
+	return h
+}
+
+// WithEmailVerifier sends verification links on signup and email changes
+func (h *UserHandler) WithEmailVerifier(verifier EmailVerifier) *UserHandler {
+	h.verifier = verifier
+	return h
+}
+
+// WithRoles validates roles against the given repository instead of only the built-in roles
+func (h *UserHandler) WithRoles(roles repository.RoleRepository) *UserHandler {
+	h.roles = roles
+	return h
+}
+
+// WithAuditor records account changes in the audit log
+func (h *UserHandler) WithAuditor(auditor audit.Auditor) *UserHandler {
+	h.auditor = auditor
+	return h
 }
 
-// CreateUserRequest represents the request body for creating a user
+// WithSessionRevocation signs users out after a role change so that no token
+// keeps the permissions of the previous role
+func (h *UserHandler) WithSessionRevocation(revocations repository.TokenRevocationRepository, refreshTokens repository.RefreshTokenRepository) *UserHandler {
+	h.revocations = revocations
+	h.refreshTokens = refreshTokens
+	return h
+}
+
+// CreateUserRequest represents the request body for creating a user. Signup
+// always assigns rbac.RoleUser; admins change roles with UpdateUserRole.
 type CreateUserRequest struct {
 	Name     string `json:"name" binding:"required"`
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=6"`
-	Role     string `json:"role" binding:"omitempty,oneof=user admin"`
+	Role     string `json:"role" binding:"omitempty,oneof=user"`
 }
 
 // UpdateUserRequest represents the request body for updating a user
@@ -36,106 +94,247 @@ type UpdateUserRequest struct {
 	Email string `json:"email" binding:"omitempty,email"`
 }
 
-// GetUsers retrieves all users
-// @Summary Get all users
-// @Description Get list of all users (Admin only)
+// UpdateUserRoleRequest represents the request body for changing a user's role
+type UpdateUserRoleRequest struct {
+	Role string `json:"role" binding:"required,max=50"`
+}
+
+// UserPatchDocument is the representation of a user that PatchUser applies
+// patches to. Fields missing after patching are rejected as required.
+type UserPatchDocument struct {
+	Name  string `json:"name" binding:"required"`
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required,max=50"`
+}
+
+// fields returns the document as decoded JSON, the form patches are applied to
+func (d UserPatchDocument) fields() map[string]any {
+	return map[string]any{"name": d.Name, "email": d.Email, "role": d.Role}
+}
+
+// patchableFields lists the fields of UserPatchDocument each role may change;
+// roles not listed may change those of rbac.RoleUser
+var patchableFields = map[string][]string{
+	rbac.RoleAdmin: {"name", "email", "role"},
+	rbac.RoleUser:  {"name", "email"},
+}
+
+// ListUsersQuery represents the query parameters for listing users
+type ListUsersQuery struct {
+	Limit         int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	Offset        int    `form:"offset" binding:"omitempty,min=0"`
+	Cursor        string `form:"cursor"`
+	Role          string `form:"role"`
+	Email         string `form:"email"`
+	CreatedAfter  string `form:"created_after"`
+	CreatedBefore string `form:"created_before"`
+	Sort          string `form:"sort"`
+}
+
+// Pagination describes the position of a page within the full result
+type Pagination struct {
+	Total      int64  `json:"total"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// UserListResponse is a page of users with pagination details
+type UserListResponse struct {
+	Data       []models.User `json:"data"`
+	Pagination Pagination    `json:"pagination"`
+}
+
+// GetUsers retrieves a filtered, sorted page of users
+// @Summary List users
+// @Description Get a page of users with optional filters and sorting (requires users:list)
 // @Tags users
 // @Produce json
 // @Security bearerauth
-// @Success 200 {array} models.User
-// @Failure 401 {object} map[string]string "Unauthorized"
-// @Failure 403 {object} map[string]string "Forbidden"
+// @Param limit query int false "Page size (1-100, default 20)"
+// @Param offset query int false "Number of users to skip, cannot be combined with cursor"
+// @Param cursor query string false "Cursor from a previous page's next_cursor"
+// @Param role query string false "Filter by role"
+// @Param email query string false "Filter by email substring (case-insensitive)"
+// @Param created_after query string false "Only users created at or after this RFC 3339 time"
+// @Param created_before query string false "Only users created before this RFC 3339 time"
+// @Param sort query string false "Sort field (id, name, email, created_at), prefix with - for descending"
+// @Success 200 {object} UserListResponse
+// @Failure 400 {object} problem.Problem "Invalid query"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Forbidden"
 // @Router /v1/users [get]
 func (h *UserHandler) GetUsers(c *gin.Context) {
-	users, err := h.repo.FindAll(c.Request.Context())
+	var query ListUsersQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		problem.Render(c, problem.FromBinding(err))
+		return
+	}
+
+	opts, err := query.toOptions()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch users"})
+		problem.Render(c, err)
+		return
+	}
+
+	page, err := h.repo.FindPage(c.Request.Context(), opts)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			problem.Render(c, invalidQuery("cursor", "is invalid"))
+			return
+		}
+		if errors.Is(err, repository.ErrInvalidSortField) {
+			problem.Render(c, invalidQuery("sort", "must be one of: "+strings.Join(repository.UserSortFields, ", ")))
+			return
+		}
+		problem.Render(c, problem.Internal("failed to fetch users"))
 		return
 	}
 
-	c.JSON(http.StatusOK, users)
+	limit := opts.Limit
+	if limit == 0 {
+		limit = repository.DefaultUserPageSize
+	}
+
+	c.JSON(http.StatusOK, UserListResponse{
+		Data: page.Users,
+		Pagination: Pagination{
+			Total:      page.Total,
+			Limit:      limit,
+			Offset:     opts.Offset,
+			NextCursor: page.NextCursor,
+		},
+	})
+}
+
+// toOptions converts query parameters to repository query options
+func (q ListUsersQuery) toOptions() (repository.UserQueryOptions, error) {
+	opts := repository.UserQueryOptions{
+		Limit:         q.Limit,
+		Offset:        q.Offset,
+		Cursor:        q.Cursor,
+		Role:          q.Role,
+		EmailContains: q.Email,
+	}
+
+	if q.Cursor != "" && q.Offset > 0 {
+		return opts, problem.BadRequest(problem.CodeInvalidRequest, "cursor and offset cannot be combined")
+	}
+
+	if q.CreatedAfter != "" {
+		t, err := time.Parse(time.RFC3339, q.CreatedAfter)
+		if err != nil {
+			return opts, invalidQuery("created_after", "must be an RFC 3339 timestamp")
+		}
+		opts.CreatedAfter = &t
+	}
+	if q.CreatedBefore != "" {
+		t, err := time.Parse(time.RFC3339, q.CreatedBefore)
+		if err != nil {
+			return opts, invalidQuery("created_before", "must be an RFC 3339 timestamp")
+		}
+		opts.CreatedBefore = &t
+	}
+
+	opts.SortBy = strings.TrimPrefix(q.Sort, "-")
+	opts.SortDesc = strings.HasPrefix(q.Sort, "-")
+
+	return opts, nil
 }
 
 // CreateUser creates a new user
 // @Summary Create a new user
-// @Description Register a new user account
+// @Description Register a new user account with the user role
 // @Tags users
 // @Accept json
 // @Produce json
 // @Param request body CreateUserRequest true "User information"
 // @Success 201 {object} models.User
-// @Failure 400 {object} map[string]string "Invalid request"
-// @Failure 500 {object} map[string]string "Server error"
+// @Failure 400 {object} problem.Problem "Invalid request"
+// @Failure 409 {object} problem.Problem "Email already registered"
+// @Failure 500 {object} problem.Problem "Server error"
 // @Router /v1/users [post]
 func (h *UserHandler) CreateUser(c *gin.Context) {
 	var req CreateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		problem.Render(c, problem.FromBinding(err))
 		return
 	}
 
 	// Hash password
 	hashedPassword, err := utils.HashPassword(req.Password)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to hash password"})
+		problem.Render(c, problem.Internal("failed to hash password"))
 		return
 	}
 
-	// Set default role if not provided
-	role := req.Role
-	if role == "" {
-		role = "user"
-	}
-
 	user := &models.User{
 		Name:         req.Name,
 		Email:        req.Email,
 		PasswordHash: hashedPassword,
-		Role:         role,
+		Role:         rbac.RoleUser,
 	}
 
 	if err := h.repo.Create(c.Request.Context(), user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create user"})
+		problem.Render(c, writeError(err, "failed to create user"))
 		return
 	}
 
+	// Signup succeeds even if delivery fails; the user can request a new link
+	if h.verifier != nil {
+		_ = h.verifier.SendVerification(c.Request.Context(), user)
+	}
+
+	event := audit.NewEvent(c, audit.ActionUserCreated).ForUser(user.ID)
+	event.Changes = audit.Diff(nil, user)
+	h.record(c, event)
+
 	c.JSON(http.StatusCreated, user)
 }
 
 // GetUserByID retrieves a user by ID
 // @Summary Get user by ID
-// @Description Get user details by ID (Owner or Admin)
+// @Description Get user details by ID (Owner or users:read). The ETag header identifies the version of the user.
 // @Tags users
 // @Produce json
 // @Security bearerauth
 // @Param id path int true "User ID"
+// @Param If-None-Match header string false "ETag of a cached copy"
 // @Success 200 {object} models.User
-// @Failure 400 {object} map[string]string "Invalid ID"
-// @Failure 401 {object} map[string]string "Unauthorized"
-// @Failure 403 {object} map[string]string "Forbidden"
-// @Failure 404 {object} map[string]string "User not found"
+// @Success 304 "Not Modified"
+// @Failure 400 {object} problem.Problem "Invalid ID"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Forbidden"
+// @Failure 404 {object} problem.Problem "User not found"
 // @Router /v1/users/{id} [get]
 func (h *UserHandler) GetUserByID(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.ParseUint(idStr, 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		problem.Render(c, problem.BadRequest(problem.CodeInvalidUserID, "invalid user ID"))
 		return
 	}
 
-	// Check if user is owner or admin
-	if !middleware.IsOwnerOrAdmin(c, uint(id)) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "insufficient permissions"})
+	// Check if user is owner or may view other users
+	if !middleware.IsOwnerOrPermitted(c, uint(id), rbac.PermUsersRead) {
+		problem.Render(c, problem.Forbidden(problem.CodeForbidden, "insufficient permissions"))
 		return
 	}
 
 	user, err := h.repo.FindByID(c.Request.Context(), uint(id))
 	if err != nil {
 		if errors.Is(err, repository.ErrUserNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			problem.Render(c, problem.NotFound(problem.CodeUserNotFound, "user not found"))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch user"})
+		problem.Render(c, problem.Internal("failed to fetch user"))
+		return
+	}
+
+	etag := userETag(user)
+	c.Header("ETag", etag)
+	if etagListMatches(c.GetHeader("If-None-Match"), etag, true) {
+		c.Status(http.StatusNotModified)
 		return
 	}
 
@@ -144,94 +343,479 @@ func (h *UserHandler) GetUserByID(c *gin.Context) {
 
 // UpdateUser updates a user by ID
 // @Summary Update user
-// @Description Update user details (Owner or Admin)
+// @Description Update user details (Owner or users:update). Send the ETag of GetUserByID as If-Match to avoid overwriting concurrent changes.
 // @Tags users
 // @Accept json
 // @Produce json
 // @Security bearerauth
 // @Param id path int true "User ID"
+// @Param If-Match header string false "ETag the update is based on"
 // @Param request body UpdateUserRequest true "User update information"
 // @Success 200 {object} models.User
-// @Failure 400 {object} map[string]string "Invalid request"
-// @Failure 401 {object} map[string]string "Unauthorized"
-// @Failure 403 {object} map[string]string "Forbidden"
-// @Failure 404 {object} map[string]string "User not found"
+// @Failure 400 {object} problem.Problem "Invalid request"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Forbidden"
+// @Failure 404 {object} problem.Problem "User not found"
+// @Failure 409 {object} problem.Problem "Email already registered or concurrent update"
+// @Failure 412 {object} problem.Problem "User changed since the If-Match ETag"
 // @Router /v1/users/{id} [put]
 func (h *UserHandler) UpdateUser(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
+	user, ok := h.userForUpdate(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		problem.Render(c, problem.FromBinding(err))
+		return
+	}
+
+	// Update fields if provided
+	before := *user
+	if req.Name != "" {
+		user.Name = req.Name
+	}
+	if req.Email != "" {
+		user.Email = req.Email
+	}
+
+	h.saveUpdate(c, &before, user)
+}
+
+// PatchUser partially updates a user by ID
+// @Summary Patch user
+// @Description Change user fields with a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902) applied to the user document (Owner or users:update). Admins may change name, email and role, other users name and email; role changes follow the rules of UpdateUserRole. Send the ETag of GetUserByID as If-Match to avoid overwriting concurrent changes.
+// @Tags users
+// @Accept application/merge-patch+json
+// @Accept application/json-patch+json
+// @Produce json
+// @Security bearerauth
+// @Param id path int true "User ID"
+// @Param If-Match header string false "ETag the patch is based on"
+// @Param request body UserPatchDocument true "Merge patch, or JSON Patch operations on this document"
+// @Success 200 {object} models.User
+// @Failure 400 {object} problem.Problem "Invalid patch or patched user"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Forbidden, field not patchable by the caller's role, or demoting yourself"
+// @Failure 404 {object} problem.Problem "User not found"
+// @Failure 409 {object} problem.Problem "Email already registered, last admin, concurrent update or failed test operation"
+// @Failure 412 {object} problem.Problem "User changed since the If-Match ETag"
+// @Failure 415 {object} problem.Problem "Unsupported patch format"
+// @Router /v1/users/{id} [patch]
+func (h *UserHandler) PatchUser(c *gin.Context) {
+	contentType := c.ContentType()
+	if contentType != jsonpatch.MergePatchContentType && contentType != jsonpatch.JSONPatchContentType {
+		c.Header("Accept-Patch", jsonpatch.MergePatchContentType+", "+jsonpatch.JSONPatchContentType)
+		problem.Render(c, problem.UnsupportedMediaType(problem.CodeUnsupportedMediaType,
+			"patches must be sent as "+jsonpatch.MergePatchContentType+" or "+jsonpatch.JSONPatchContentType))
+		return
+	}
+
+	user, ok := h.userForUpdate(c)
+	if !ok {
+		return
+	}
+
+	body, err := c.GetRawData()
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		problem.Render(c, problem.BadRequest(problem.CodeInvalidRequest, "request body could not be read"))
+		return
+	}
+	if len(body) == 0 {
+		problem.Render(c, problem.BadRequest(problem.CodeInvalidRequest, "request body is required"))
 		return
 	}
 
-	// Check if user is owner or admin
-	if !middleware.IsOwnerOrAdmin(c, uint(id)) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "insufficient permissions"})
+	current := UserPatchDocument{Name: user.Name, Email: user.Email, Role: user.Role}
+	patched, perr := applyUserPatch(contentType, current.fields(), body)
+	if perr != nil {
+		problem.Render(c, perr)
 		return
 	}
 
-	// Check if user exists
-	user, err := h.repo.FindByID(c.Request.Context(), uint(id))
+	if perr := checkPatchedFields(current.fields(), patched, c.GetString("user_role")); perr != nil {
+		problem.Render(c, perr)
+		return
+	}
+
+	// Decode and validate the result like a request body
+	var doc UserPatchDocument
+	data, err := json.Marshal(patched)
 	if err != nil {
-		if errors.Is(err, repository.ErrUserNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		problem.Render(c, problem.Internal("failed to update user"))
+		return
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		problem.Render(c, problem.FromBinding(err))
+		return
+	}
+	if err := binding.Validator.ValidateStruct(&doc); err != nil {
+		problem.Render(c, problem.FromBinding(err))
+		return
+	}
+
+	if doc.Role != user.Role {
+		if ok, err := h.roleExists(c.Request.Context(), doc.Role); err != nil {
+			problem.Render(c, problem.Internal("failed to update user"))
+			return
+		} else if !ok {
+			problem.Render(c, problem.BadRequest(problem.CodeUnknownRole, "unknown role"))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch user"})
+	}
+
+	before := *user
+	user.Name = doc.Name
+	user.Email = doc.Email
+	user.Role = doc.Role
+
+	h.saveUpdate(c, &before, user)
+}
+
+// UpdateUserRole changes the role of a user
+// @Summary Change user role
+// @Description Promote or demote a user (admin only). Admins cannot demote themselves and the last admin cannot be demoted. The user is signed out of every session. Send the ETag of GetUserByID as If-Match to avoid overwriting concurrent changes.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security bearerauth
+// @Param id path int true "User ID"
+// @Param If-Match header string false "ETag the change is based on"
+// @Param request body UpdateUserRoleRequest true "New role"
+// @Success 200 {object} models.User
+// @Failure 400 {object} problem.Problem "Invalid request or unknown role"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Not an admin, or demoting yourself"
+// @Failure 404 {object} problem.Problem "User not found"
+// @Failure 409 {object} problem.Problem "Last admin or concurrent update"
+// @Failure 412 {object} problem.Problem "User changed since the If-Match ETag"
+// @Router /v1/users/{id}/role [put]
+func (h *UserHandler) UpdateUserRole(c *gin.Context) {
+	user, ok := h.userForUpdate(c)
+	if !ok {
 		return
 	}
 
-	var req UpdateUserRequest
+	var req UpdateUserRoleRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		problem.Render(c, problem.FromBinding(err))
 		return
 	}
 
-	// Update fields if provided
-	if req.Name != "" {
-		user.Name = req.Name
+	if req.Role != user.Role {
+		if ok, err := h.roleExists(c.Request.Context(), req.Role); err != nil {
+			problem.Render(c, problem.Internal("failed to update user"))
+			return
+		} else if !ok {
+			problem.Render(c, problem.BadRequest(problem.CodeUnknownRole, "unknown role"))
+			return
+		}
 	}
-	if req.Email != "" {
-		user.Email = req.Email
+
+	before := *user
+	user.Role = req.Role
+
+	h.saveUpdate(c, &before, user)
+}
+
+// userForUpdate loads the user named by the id parameter if the caller may
+// update it and the If-Match header, if any, matches. Otherwise it renders the
+// error and returns false.
+func (h *UserHandler) userForUpdate(c *gin.Context) (*models.User, bool) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		problem.Render(c, problem.BadRequest(problem.CodeInvalidUserID, "invalid user ID"))
+		return nil, false
+	}
+
+	// Check if user is owner or may update other users
+	if !middleware.IsOwnerOrPermitted(c, uint(id), rbac.PermUsersUpdate) {
+		problem.Render(c, problem.Forbidden(problem.CodeForbidden, "insufficient permissions"))
+		return nil, false
 	}
 
-	if err := h.repo.Update(c.Request.Context(), user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update user"})
+	// Check if user exists
+	user, err := h.repo.FindByID(c.Request.Context(), uint(id))
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			problem.Render(c, problem.NotFound(problem.CodeUserNotFound, "user not found"))
+			return nil, false
+		}
+		problem.Render(c, problem.Internal("failed to fetch user"))
+		return nil, false
+	}
+
+	if ifMatch := c.GetHeader("If-Match"); ifMatch != "" && !etagListMatches(ifMatch, userETag(user), false) {
+		problem.Render(c, userChangedError())
+		return nil, false
+	}
+	return user, true
+}
+
+// saveUpdate stores the changes made to user, which looked like before when it
+// was loaded, and responds with the updated user. Role changes must not demote
+// the caller or the last admin, and sign the user out.
+func (h *UserHandler) saveUpdate(c *gin.Context, before, user *models.User) {
+	roleChanged := user.Role != before.Role
+	if roleChanged && before.Role == rbac.RoleAdmin {
+		if callerID, ok := c.Get("user_id"); ok && callerID == user.ID {
+			problem.Render(c, problem.Forbidden(problem.CodeSelfDemotion, "admins cannot change their own role, ask another admin"))
+			return
+		}
+	}
+
+	// A new address must be verified again
+	emailChanged := user.Email != before.Email
+	if emailChanged && h.verifier != nil {
+		user.EmailVerifiedAt = nil
+	}
+
+	var err error
+	if roleChanged {
+		err = h.repo.UpdateKeepingRole(c.Request.Context(), user, rbac.RoleAdmin)
+	} else {
+		err = h.repo.Update(c.Request.Context(), user)
+	}
+	if err != nil {
+		if c.GetHeader("If-Match") != "" && errors.Is(err, repository.ErrVersionConflict) {
+			problem.Render(c, userChangedError())
+			return
+		}
+		problem.Render(c, writeError(err, "failed to update user"))
 		return
 	}
 
+	if emailChanged && h.verifier != nil {
+		_ = h.verifier.SendVerification(c.Request.Context(), user)
+	}
+
+	action := audit.ActionUserUpdated
+	if roleChanged {
+		action = audit.ActionUserRoleChanged
+	}
+	event := audit.NewEvent(c, action).ForUser(user.ID)
+	event.Changes = audit.Diff(before, user)
+	h.record(c, event)
+
+	if roleChanged {
+		// The role is already saved, so the update succeeds; a failed revocation
+		// is logged so that an operator can revoke the tokens with revoke-tokens
+		if err := h.revokeSessions(c.Request.Context(), user.ID); err != nil {
+			h.logger.Error("role changed, but failed to revoke tokens",
+				zap.Uint("user_id", user.ID),
+				zap.String("request_id", c.GetString("request_id")),
+				zap.Error(err),
+			)
+		}
+	}
+
+	c.Header("ETag", userETag(user))
 	c.JSON(http.StatusOK, user)
 }
 
 // DeleteUser deletes a user by ID
 // @Summary Delete user
-// @Description Delete user by ID (Admin only)
+// @Description Delete user by ID (requires users:delete). The last admin cannot be deleted.
 // @Tags users
 // @Security bearerauth
 // @Param id path int true "User ID"
 // @Success 204 "No Content"
-// @Failure 400 {object} map[string]string "Invalid ID"
-// @Failure 401 {object} map[string]string "Unauthorized"
-// @Failure 403 {object} map[string]string "Forbidden"
-// @Failure 404 {object} map[string]string "User not found"
+// @Failure 400 {object} problem.Problem "Invalid ID"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Forbidden"
+// @Failure 404 {object} problem.Problem "User not found"
+// @Failure 409 {object} problem.Problem "Last admin"
 // @Router /v1/users/{id} [delete]
 func (h *UserHandler) DeleteUser(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.ParseUint(idStr, 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		problem.Render(c, problem.BadRequest(problem.CodeInvalidUserID, "invalid user ID"))
 		return
 	}
 
-	if err := h.repo.Delete(c.Request.Context(), uint(id)); err != nil {
-		if errors.Is(err, repository.ErrUserNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete user"})
+	ctx := c.Request.Context()
+	user, err := h.repo.FindByID(ctx, uint(id))
+	if err != nil {
+		problem.Render(c, writeError(err, "failed to fetch user"))
+		return
+	}
+
+	if err := h.repo.DeleteKeepingRole(ctx, user.ID, rbac.RoleAdmin); err != nil {
+		problem.Render(c, writeError(err, "failed to delete user"))
 		return
 	}
 
+	event := audit.NewEvent(c, audit.ActionUserDeleted).ForUser(user.ID)
+	event.Changes = audit.Diff(user, nil)
+	h.record(c, event)
+
 	c.JSON(http.StatusNoContent, nil)
 }
+
+// writeError maps an error of a user write to 404, 409 or, with detail, 500
+func writeError(err error, detail string) *problem.Error {
+	switch {
+	case errors.Is(err, repository.ErrVersionConflict):
+		return problem.Conflict(problem.CodeConflict, "user was modified concurrently, fetch it and try again")
+	case errors.Is(err, repository.ErrEmailTaken):
+		return problem.Conflict(problem.CodeEmailTaken, "email already registered")
+	case errors.Is(err, repository.ErrConflict):
+		return problem.Conflict(problem.CodeConflict, "user conflicts with an existing user")
+	case errors.Is(err, repository.ErrLastRoleHolder):
+		return problem.Conflict(problem.CodeLastAdmin, "the last admin cannot be demoted or deleted, promote another user first")
+	case errors.Is(err, repository.ErrUserNotFound):
+		return problem.NotFound(problem.CodeUserNotFound, "user not found")
+	default:
+		return problem.Internal(detail)
+	}
+}
+
+// applyUserPatch applies body, a patch of the given content type, to doc
+func applyUserPatch(contentType string, doc map[string]any, body []byte) (map[string]any, *problem.Error) {
+	var patched any
+	if contentType == jsonpatch.MergePatchContentType {
+		var patch any
+		if err := json.Unmarshal(body, &patch); err != nil {
+			return nil, problem.FromBinding(err)
+		}
+		if _, ok := patch.(map[string]any); !ok {
+			return nil, problem.BadRequest(problem.CodeInvalidPatch, "merge patch must be a JSON object")
+		}
+		patched = jsonpatch.MergePatch(doc, patch)
+	} else {
+		ops, err := jsonpatch.DecodePatch(body)
+		if err != nil {
+			return nil, problem.BadRequest(problem.CodeInvalidPatch, err.Error())
+		}
+		if patched, err = jsonpatch.Apply(doc, ops); err != nil {
+			if errors.Is(err, jsonpatch.ErrTestFailed) {
+				return nil, problem.Conflict(problem.CodePatchTestFailed, err.Error())
+			}
+			return nil, problem.BadRequest(problem.CodeInvalidPatch, err.Error())
+		}
+	}
+
+	result, ok := patched.(map[string]any)
+	if !ok {
+		return nil, problem.BadRequest(problem.CodeInvalidPatch, "patched user must be a JSON object")
+	}
+	return result, nil
+}
+
+// checkPatchedFields rejects members patched adds to the user document and
+// changes to fields that role may not patch
+func checkPatchedFields(original, patched map[string]any, role string) *problem.Error {
+	var unknown []string
+	for name := range patched {
+		if _, ok := original[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		err := problem.BadRequest(problem.CodeValidationFailed, "request validation failed")
+		for _, name := range unknown {
+			err.Fields = append(err.Fields, problem.FieldError{Field: name, Code: "unknown", Detail: "is not a user field"})
+		}
+		return err
+	}
+
+	allowed, ok := patchableFields[role]
+	if !ok {
+		allowed = patchableFields[rbac.RoleUser]
+	}
+	var denied []problem.FieldError
+	for _, name := range []string{"name", "email", "role"} {
+		value, ok := patched[name]
+		if ok && reflect.DeepEqual(value, original[name]) {
+			continue
+		}
+		if !slices.Contains(allowed, name) {
+			denied = append(denied, problem.FieldError{Field: name, Code: "not_patchable", Detail: "cannot be changed by your role"})
+		}
+	}
+	if len(denied) > 0 {
+		err := problem.Forbidden(problem.CodeForbidden, "patch changes fields your role cannot change")
+		err.Fields = denied
+		return err
+	}
+	return nil
+}
+
+// userChangedError rejects a conditional update based on an outdated ETag
+func userChangedError() *problem.Error {
+	return problem.PreconditionFailed(problem.CodePreconditionFailed, "user was modified since the If-Match ETag, fetch it and try again")
+}
+
+// userETag is the strong entity tag of the user's current version
+func userETag(user *models.User) string {
+	return `"` + strconv.FormatUint(uint64(user.Version), 10) + `"`
+}
+
+// etagListMatches reports whether an If-Match or If-None-Match header value
+// lists etag or is "*". Weak tags only match with weak comparison (RFC 9110 8.8.3.2).
+func etagListMatches(header, etag string, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if strings.HasPrefix(tag, "W/") {
+			if !weak {
+				continue
+			}
+			tag = tag[2:]
+		}
+		if tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// invalidQuery rejects the query parameter field
+func invalidQuery(field, detail string) *problem.Error {
+	err := problem.BadRequest(problem.CodeValidationFailed, "query validation failed")
+	err.Fields = []problem.FieldError{{Field: field, Code: "invalid", Detail: detail}}
+	return err
+}
+
+// revokeSessions revokes every access and refresh token of the user if
+// session revocation is configured. A failure to revoke one kind of token does
+// not keep the other from being revoked.
+func (h *UserHandler) revokeSessions(ctx context.Context, userID uint) error {
+	var errs []error
+	if h.revocations != nil {
+		if err := h.revocations.RevokeAllForUser(ctx, userID, time.Now()); err != nil {
+			errs = append(errs, fmt.Errorf("access tokens: %w", err))
+		}
+	}
+	if h.refreshTokens != nil {
+		if err := h.refreshTokens.RevokeAllForUser(ctx, userID); err != nil {
+			errs = append(errs, fmt.Errorf("refresh tokens: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// record writes event to the audit log if an auditor is configured
+func (h *UserHandler) record(c *gin.Context, event audit.Event) {
+	if h.auditor != nil {
+		h.auditor.Record(c.Request.Context(), event)
+	}
+}
+
+// roleExists reports whether role can be assigned to users
+func (h *UserHandler) roleExists(ctx context.Context, role string) (bool, error) {
+	if h.roles == nil {
+		return role == rbac.RoleUser || role == rbac.RoleAdmin, nil
+	}
+	_, err := h.roles.FindRoleByName(ctx, role)
+	if errors.Is(err, repository.ErrRoleNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
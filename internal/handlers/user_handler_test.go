@@ -5,17 +5,33 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"myapp/internal/models"
+	"myapp/internal/rbac"
 	"myapp/internal/repository"
+	"myapp/pkg/problem"
+	"myapp/pkg/utils"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 )
 
+// stubEmailVerifier records the addresses verification links were sent to
+type stubEmailVerifier struct {
+	sentTo []string
+}
+
+func (v *stubEmailVerifier) SendVerification(ctx context.Context, user *models.User) error {
+	v.sentTo = append(v.sentTo, user.Email)
+	return nil
+}
+
 func TestGetUsers(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	ctrl := gomock.NewController(t)
@@ -23,40 +39,106 @@ func TestGetUsers(t *testing.T) {
 
 	mockRepo := repository.NewMockUserRepository(ctrl)
 
-	t.Run("should return all users", func(t *testing.T) {
+	newRouter := func() *gin.Engine {
+		handler := NewUserHandler(mockRepo)
+		router := gin.New()
+		router.GET("/users", handler.GetUsers)
+		return router
+	}
+
+	t.Run("should return a page of users", func(t *testing.T) {
 		users := []models.User{
 			{ID: 1, Name: "Alice", Email: "alice@example.com", Role: "user"},
 			{ID: 2, Name: "Bob", Email: "bob@example.com", Role: "admin"},
 		}
 
-		mockRepo.EXPECT().FindAll(gomock.Any()).Return(users, nil)
-
-		handler := NewUserHandler(mockRepo)
-		router := gin.New()
-		router.GET("/users", handler.GetUsers)
+		mockRepo.EXPECT().FindPage(gomock.Any(), repository.UserQueryOptions{}).
+			Return(&repository.UserPage{Users: users, Total: 7, NextCursor: "next"}, nil)
 
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest("GET", "/users", nil)
-		router.ServeHTTP(w, req)
+		newRouter().ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusOK, w.Code)
 
-		var response []models.User
+		var response UserListResponse
 		json.Unmarshal(w.Body.Bytes(), &response)
-		assert.Len(t, response, 2)
-		assert.Equal(t, "Alice", response[0].Name)
+		assert.Len(t, response.Data, 2)
+		assert.Equal(t, "Alice", response.Data[0].Name)
+		assert.Equal(t, int64(7), response.Pagination.Total)
+		assert.Equal(t, repository.DefaultUserPageSize, response.Pagination.Limit)
+		assert.Equal(t, "next", response.Pagination.NextCursor)
 	})
 
-	t.Run("should handle database error", func(t *testing.T) {
-		mockRepo.EXPECT().FindAll(gomock.Any()).Return(nil, errors.New("database error"))
+	t.Run("should pass filters, sorting and pagination to the repository", func(t *testing.T) {
+		after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		before := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+		expected := repository.UserQueryOptions{
+			Limit:         5,
+			Offset:        10,
+			Role:          "admin",
+			EmailContains: "example",
+			CreatedAfter:  &after,
+			CreatedBefore: &before,
+			SortBy:        "created_at",
+			SortDesc:      true,
+		}
 
-		handler := NewUserHandler(mockRepo)
-		router := gin.New()
-		router.GET("/users", handler.GetUsers)
+		mockRepo.EXPECT().FindPage(gomock.Any(), expected).
+			Return(&repository.UserPage{Users: []models.User{}, Total: 0}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/users?limit=5&offset=10&role=admin&email=example"+
+			"&created_after=2024-01-01T00:00:00Z&created_before=2024-02-01T00:00:00Z&sort=-created_at", nil)
+		newRouter().ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"offset":10`)
+	})
+
+	t.Run("should reject invalid query parameters", func(t *testing.T) {
+		for _, query := range []string{
+			"limit=101",
+			"offset=-1",
+			"created_after=yesterday",
+			"created_before=2024-01-01",
+			"cursor=abc&offset=5",
+		} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/users?"+query, nil)
+			newRouter().ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		}
+	})
+
+	t.Run("should reject invalid cursor or sort field", func(t *testing.T) {
+		mockRepo.EXPECT().FindPage(gomock.Any(), gomock.Any()).Return(nil, repository.ErrInvalidCursor)
+		mockRepo.EXPECT().FindPage(gomock.Any(), gomock.Any()).Return(nil, repository.ErrInvalidSortField)
+
+		for _, tc := range []struct{ query, field string }{
+			{"cursor=bogus", "cursor"},
+			{"sort=password_hash", "sort"},
+		} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/users?"+tc.query, nil)
+			newRouter().ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, tc.query)
+			var response problem.Problem
+			json.Unmarshal(w.Body.Bytes(), &response)
+			if assert.Len(t, response.Errors, 1, tc.query) {
+				assert.Equal(t, tc.field, response.Errors[0].Field)
+			}
+		}
+	})
+
+	t.Run("should handle database error", func(t *testing.T) {
+		mockRepo.EXPECT().FindPage(gomock.Any(), gomock.Any()).Return(nil, errors.New("database error"))
 
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest("GET", "/users", nil)
-		router.ServeHTTP(w, req)
+		newRouter().ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusInternalServerError, w.Code)
 	})
@@ -163,7 +245,33 @@ func TestCreateUser(t *testing.T) {
 
 		var response map[string]string
 		json.Unmarshal(w.Body.Bytes(), &response)
-		assert.Equal(t, "failed to create user", response["error"])
+		assert.Equal(t, "failed to create user", response["detail"])
+	})
+
+	t.Run("should return 409 when the email is already registered", func(t *testing.T) {
+		userInput := CreateUserRequest{
+			Name:     "Duplicate",
+			Email:    "taken@example.com",
+			Password: "password123",
+		}
+
+		mockRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(repository.ErrEmailTaken)
+
+		handler := NewUserHandler(mockRepo)
+		router := gin.New()
+		router.POST("/users", handler.CreateUser)
+
+		body, _ := json.Marshal(userInput)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/users", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		var response problem.Problem
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, problem.CodeEmailTaken, response.Code)
 	})
 
 	t.Run("should reject missing required fields", func(t *testing.T) {
@@ -202,6 +310,14 @@ func TestCreateUser(t *testing.T) {
 		router.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+
+		var response problem.Problem
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, problem.CodeValidationFailed, response.Code)
+		assert.Equal(t, []problem.FieldError{
+			{Field: "email", Code: "email", Detail: "must be a valid email address"},
+		}, response.Errors)
 	})
 
 	t.Run("should reject short password", func(t *testing.T) {
@@ -221,6 +337,13 @@ func TestCreateUser(t *testing.T) {
 		router.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		var response problem.Problem
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, []problem.FieldError{
+			{Field: "password", Code: "min", Detail: "must be at least 6 characters"},
+		}, response.Errors)
+		assert.NotContains(t, w.Body.String(), "CreateUserRequest")
 	})
 
 	t.Run("should reject invalid role", func(t *testing.T) {
@@ -242,6 +365,30 @@ func TestCreateUser(t *testing.T) {
 
 		assert.Equal(t, http.StatusBadRequest, w.Code)
 	})
+
+	t.Run("should not let signups choose the admin role", func(t *testing.T) {
+		handler := NewUserHandler(mockRepo)
+		router := gin.New()
+		router.POST("/users", handler.CreateUser)
+
+		body, _ := json.Marshal(CreateUserRequest{
+			Name:     "Mallory",
+			Email:    "mallory@example.com",
+			Password: "password123",
+			Role:     rbac.RoleAdmin,
+		})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/users", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		var response problem.Problem
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, []problem.FieldError{
+			{Field: "role", Code: "oneof", Detail: "must be one of: user"},
+		}, response.Errors)
+	})
 }
 
 func TestGetUserByID(t *testing.T) {
@@ -301,6 +448,7 @@ func TestGetUserByID(t *testing.T) {
 		router.Use(func(c *gin.Context) {
 			c.Set("user_id", uint(1))
 			c.Set("user_role", "admin")
+			c.Set("user_permissions", []string{rbac.PermUsersRead})
 		})
 		router.GET("/users/:id", handler.GetUserByID)
 
@@ -355,7 +503,7 @@ func TestGetUserByID(t *testing.T) {
 
 		var response map[string]string
 		json.Unmarshal(w.Body.Bytes(), &response)
-		assert.Equal(t, "insufficient permissions", response["error"])
+		assert.Equal(t, "insufficient permissions", response["detail"])
 	})
 
 	t.Run("should reject invalid user ID format", func(t *testing.T) {
@@ -364,6 +512,7 @@ func TestGetUserByID(t *testing.T) {
 		router.Use(func(c *gin.Context) {
 			c.Set("user_id", uint(1))
 			c.Set("user_role", "admin")
+			c.Set("user_permissions", []string{rbac.PermUsersRead})
 		})
 		router.GET("/users/:id", handler.GetUserByID)
 
@@ -375,7 +524,7 @@ func TestGetUserByID(t *testing.T) {
 
 		var response map[string]string
 		json.Unmarshal(w.Body.Bytes(), &response)
-		assert.Equal(t, "invalid user ID", response["error"])
+		assert.Equal(t, "invalid user ID", response["detail"])
 	})
 
 	t.Run("should handle database error when fetching user", func(t *testing.T) {
@@ -386,6 +535,7 @@ func TestGetUserByID(t *testing.T) {
 		router.Use(func(c *gin.Context) {
 			c.Set("user_id", uint(1))
 			c.Set("user_role", "admin")
+			c.Set("user_permissions", []string{rbac.PermUsersRead})
 		})
 		router.GET("/users/:id", handler.GetUserByID)
 
@@ -397,7 +547,7 @@ func TestGetUserByID(t *testing.T) {
 
 		var response map[string]string
 		json.Unmarshal(w.Body.Bytes(), &response)
-		assert.Equal(t, "failed to fetch user", response["error"])
+		assert.Equal(t, "failed to fetch user", response["detail"])
 	})
 }
 
@@ -435,6 +585,41 @@ func TestUpdateUser(t *testing.T) {
 		assert.Equal(t, http.StatusOK, w.Code)
 	})
 
+	t.Run("should require verification again after email change", func(t *testing.T) {
+		verifiedAt := time.Now()
+		existingUser := &models.User{ID: 1, Name: "Old Name", Email: "old@example.com", EmailVerifiedAt: &verifiedAt}
+		mockRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(existingUser, nil)
+		mockRepo.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, user *models.User) error {
+				// Cleared in the same write as the new address
+				assert.Nil(t, user.EmailVerifiedAt)
+				return nil
+			},
+		)
+
+		verifier := &stubEmailVerifier{}
+		handler := NewUserHandler(mockRepo).WithEmailVerifier(verifier)
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Set("user_id", uint(1))
+			c.Set("user_role", "user")
+		})
+		router.PUT("/users/:id", handler.UpdateUser)
+
+		body, _ := json.Marshal(UpdateUserRequest{Email: "new@example.com"})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/users/1", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []string{"new@example.com"}, verifier.sentTo)
+
+		var response models.User
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Nil(t, response.EmailVerifiedAt)
+	})
+
 	t.Run("should allow admin to update any user", func(t *testing.T) {
 		updateInput := UpdateUserRequest{
 			Name:  "Admin Updated",
@@ -450,6 +635,7 @@ func TestUpdateUser(t *testing.T) {
 		router.Use(func(c *gin.Context) {
 			c.Set("user_id", uint(1))
 			c.Set("user_role", "admin")
+			c.Set("user_permissions", []string{rbac.PermUsersUpdate})
 		})
 		router.PUT("/users/:id", handler.UpdateUser)
 
@@ -513,7 +699,7 @@ func TestUpdateUser(t *testing.T) {
 
 		var response map[string]string
 		json.Unmarshal(w.Body.Bytes(), &response)
-		assert.Equal(t, "insufficient permissions", response["error"])
+		assert.Equal(t, "insufficient permissions", response["detail"])
 	})
 
 	t.Run("should reject invalid user ID format", func(t *testing.T) {
@@ -522,6 +708,7 @@ func TestUpdateUser(t *testing.T) {
 		router.Use(func(c *gin.Context) {
 			c.Set("user_id", uint(1))
 			c.Set("user_role", "admin")
+			c.Set("user_permissions", []string{rbac.PermUsersUpdate})
 		})
 		router.PUT("/users/:id", handler.UpdateUser)
 
@@ -536,7 +723,7 @@ func TestUpdateUser(t *testing.T) {
 
 		var response map[string]string
 		json.Unmarshal(w.Body.Bytes(), &response)
-		assert.Equal(t, "invalid user ID", response["error"])
+		assert.Equal(t, "invalid user ID", response["detail"])
 	})
 
 	t.Run("should return 404 when user not found", func(t *testing.T) {
@@ -547,6 +734,7 @@ func TestUpdateUser(t *testing.T) {
 		router.Use(func(c *gin.Context) {
 			c.Set("user_id", uint(1))
 			c.Set("user_role", "admin")
+			c.Set("user_permissions", []string{rbac.PermUsersUpdate})
 		})
 		router.PUT("/users/:id", handler.UpdateUser)
 
@@ -561,7 +749,7 @@ func TestUpdateUser(t *testing.T) {
 
 		var response map[string]string
 		json.Unmarshal(w.Body.Bytes(), &response)
-		assert.Equal(t, "user not found", response["error"])
+		assert.Equal(t, "user not found", response["detail"])
 	})
 
 	t.Run("should handle database error when finding user", func(t *testing.T) {
@@ -572,6 +760,7 @@ func TestUpdateUser(t *testing.T) {
 		router.Use(func(c *gin.Context) {
 			c.Set("user_id", uint(1))
 			c.Set("user_role", "admin")
+			c.Set("user_permissions", []string{rbac.PermUsersUpdate})
 		})
 		router.PUT("/users/:id", handler.UpdateUser)
 
@@ -586,7 +775,7 @@ func TestUpdateUser(t *testing.T) {
 
 		var response map[string]string
 		json.Unmarshal(w.Body.Bytes(), &response)
-		assert.Equal(t, "failed to fetch user", response["error"])
+		assert.Equal(t, "failed to fetch user", response["detail"])
 	})
 
 	t.Run("should reject invalid JSON input", func(t *testing.T) {
@@ -633,7 +822,61 @@ func TestUpdateUser(t *testing.T) {
 
 		var response map[string]string
 		json.Unmarshal(w.Body.Bytes(), &response)
-		assert.Equal(t, "failed to update user", response["error"])
+		assert.Equal(t, "failed to update user", response["detail"])
+	})
+
+	t.Run("should return 409 when another user has the email", func(t *testing.T) {
+		existingUser := &models.User{ID: 1, Name: "Original", Email: "original@example.com"}
+		mockRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(existingUser, nil)
+		mockRepo.EXPECT().Update(gomock.Any(), gomock.Any()).Return(repository.ErrEmailTaken)
+
+		handler := NewUserHandler(mockRepo)
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Set("user_id", uint(1))
+			c.Set("user_role", "user")
+		})
+		router.PUT("/users/:id", handler.UpdateUser)
+
+		body, _ := json.Marshal(UpdateUserRequest{Email: "taken@example.com"})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/users/1", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		var response problem.Problem
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, problem.CodeEmailTaken, response.Code)
+	})
+
+	t.Run("should return 409 for other unique violations", func(t *testing.T) {
+		existingUser := &models.User{ID: 1, Name: "Original", Email: "original@example.com"}
+		mockRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(existingUser, nil)
+		mockRepo.EXPECT().Update(gomock.Any(), gomock.Any()).
+			Return(fmt.Errorf("%w: users_pkey", repository.ErrConflict))
+
+		handler := NewUserHandler(mockRepo)
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Set("user_id", uint(1))
+			c.Set("user_role", "user")
+		})
+		router.PUT("/users/:id", handler.UpdateUser)
+
+		body, _ := json.Marshal(UpdateUserRequest{Name: "Updated"})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/users/1", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		var response problem.Problem
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, problem.CodeConflict, response.Code)
+		assert.NotContains(t, w.Body.String(), "users_pkey")
 	})
 
 	t.Run("should update only provided fields", func(t *testing.T) {
@@ -698,7 +941,8 @@ func TestDeleteUser(t *testing.T) {
 	mockRepo := repository.NewMockUserRepository(ctrl)
 
 	t.Run("should delete user successfully", func(t *testing.T) {
-		mockRepo.EXPECT().Delete(gomock.Any(), uint(1)).Return(nil)
+		mockRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(&models.User{ID: 1, Role: "user"}, nil)
+		mockRepo.EXPECT().DeleteKeepingRole(gomock.Any(), uint(1), "admin").Return(nil)
 
 		handler := NewUserHandler(mockRepo)
 		router := gin.New()
@@ -712,7 +956,7 @@ func TestDeleteUser(t *testing.T) {
 	})
 
 	t.Run("should return 404 when user not found", func(t *testing.T) {
-		mockRepo.EXPECT().Delete(gomock.Any(), uint(999)).Return(repository.ErrUserNotFound)
+		mockRepo.EXPECT().FindByID(gomock.Any(), uint(999)).Return(nil, repository.ErrUserNotFound)
 
 		handler := NewUserHandler(mockRepo)
 		router := gin.New()
@@ -738,11 +982,12 @@ func TestDeleteUser(t *testing.T) {
 
 		var response map[string]string
 		json.Unmarshal(w.Body.Bytes(), &response)
-		assert.Equal(t, "invalid user ID", response["error"])
+		assert.Equal(t, "invalid user ID", response["detail"])
 	})
 
 	t.Run("should handle repository delete error", func(t *testing.T) {
-		mockRepo.EXPECT().Delete(gomock.Any(), uint(5)).Return(errors.New("database error"))
+		mockRepo.EXPECT().FindByID(gomock.Any(), uint(5)).Return(&models.User{ID: 5, Role: "user"}, nil)
+		mockRepo.EXPECT().DeleteKeepingRole(gomock.Any(), uint(5), "admin").Return(errors.New("database error"))
 
 		handler := NewUserHandler(mockRepo)
 		router := gin.New()
@@ -756,6 +1001,626 @@ func TestDeleteUser(t *testing.T) {
 
 		var response map[string]string
 		json.Unmarshal(w.Body.Bytes(), &response)
-		assert.Equal(t, "failed to delete user", response["error"])
+		assert.Equal(t, "failed to delete user", response["detail"])
+	})
+
+	t.Run("should not delete the last admin", func(t *testing.T) {
+		mockRepo.EXPECT().FindByID(gomock.Any(), uint(7)).Return(&models.User{ID: 7, Role: "admin"}, nil)
+		mockRepo.EXPECT().DeleteKeepingRole(gomock.Any(), uint(7), "admin").Return(repository.ErrLastRoleHolder)
+
+		handler := NewUserHandler(mockRepo)
+		router := gin.New()
+		router.DELETE("/users/:id", handler.DeleteUser)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("DELETE", "/users/7", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		var response map[string]any
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, "last_admin", response["code"])
+	})
+}
+
+func TestUserConditionalRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepository(ctrl)
+
+	newRouter := func() *gin.Engine {
+		handler := NewUserHandler(mockRepo)
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Set("user_id", uint(1))
+			c.Set("user_role", "user")
+		})
+		router.GET("/users/:id", handler.GetUserByID)
+		router.PUT("/users/:id", handler.UpdateUser)
+		return router
+	}
+
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/users/1", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		newRouter().ServeHTTP(w, req)
+		return w
+	}
+
+	put := func(ifMatch string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(UpdateUserRequest{Name: "Updated"})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/users/1", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		newRouter().ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("should return the version as ETag", func(t *testing.T) {
+		mockRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(&models.User{ID: 1, Name: "Alice", Version: 3}, nil)
+
+		w := get("")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+	})
+
+	t.Run("should return 304 when If-None-Match lists the current ETag", func(t *testing.T) {
+		for _, header := range []string{`"3"`, `W/"3"`, `"1", "3"`, `*`} {
+			mockRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(&models.User{ID: 1, Name: "Alice", Version: 3}, nil)
+
+			w := get(header)
+
+			assert.Equal(t, http.StatusNotModified, w.Code, header)
+			assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+			assert.Empty(t, w.Body.String())
+		}
+	})
+
+	t.Run("should return the user when If-None-Match is outdated", func(t *testing.T) {
+		mockRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(&models.User{ID: 1, Name: "Alice", Version: 4}, nil)
+
+		w := get(`"3"`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"4"`, w.Header().Get("ETag"))
+	})
+
+	t.Run("should update and return the new ETag when If-Match matches", func(t *testing.T) {
+		mockRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(&models.User{ID: 1, Name: "Alice", Version: 3}, nil)
+		mockRepo.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, user *models.User) error {
+				assert.Equal(t, uint(3), user.Version)
+				user.Version++
+				return nil
+			},
+		)
+
+		w := put(`"3"`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"4"`, w.Header().Get("ETag"))
+	})
+
+	t.Run("should return 412 without writing when If-Match is outdated", func(t *testing.T) {
+		for _, header := range []string{`"2"`, `W/"3"`} {
+			mockRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(&models.User{ID: 1, Name: "Alice", Version: 3}, nil)
+
+			w := put(header)
+
+			assert.Equal(t, http.StatusPreconditionFailed, w.Code, header)
+			var response problem.Problem
+			json.Unmarshal(w.Body.Bytes(), &response)
+			assert.Equal(t, problem.CodePreconditionFailed, response.Code)
+		}
+	})
+
+	t.Run("should return 412 when the user changes during a conditional update", func(t *testing.T) {
+		mockRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(&models.User{ID: 1, Name: "Alice", Version: 3}, nil)
+		mockRepo.EXPECT().Update(gomock.Any(), gomock.Any()).Return(repository.ErrVersionConflict)
+
+		assert.Equal(t, http.StatusPreconditionFailed, put(`"3"`).Code)
+	})
+
+	t.Run("should return 409 when the user changes during an unconditional update", func(t *testing.T) {
+		mockRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(&models.User{ID: 1, Name: "Alice", Version: 3}, nil)
+		mockRepo.EXPECT().Update(gomock.Any(), gomock.Any()).Return(repository.ErrVersionConflict)
+
+		w := put("")
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		var response problem.Problem
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, problem.CodeConflict, response.Code)
+	})
+}
+
+func TestPatchUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepository(ctrl)
+
+	// patch sends body to PATCH /users/1 as a caller with role, who is user 1 unless admin
+	patch := func(role, contentType, body string, headers ...string) *httptest.ResponseRecorder {
+		handler := NewUserHandler(mockRepo)
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Set("user_role", role)
+			if role == rbac.RoleAdmin {
+				c.Set("user_id", uint(2))
+				c.Set("user_permissions", []string{rbac.PermUsersUpdate})
+			} else {
+				c.Set("user_id", uint(1))
+			}
+		})
+		router.PATCH("/users/:id", handler.PatchUser)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PATCH", "/users/1", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", contentType)
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	existing := func() *models.User {
+		return &models.User{ID: 1, Name: "Alice", Email: "alice@example.com", Role: rbac.RoleUser, Version: 3}
+	}
+
+	decodeProblem := func(w *httptest.ResponseRecorder) problem.Problem {
+		var response problem.Problem
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response
+	}
+
+	t.Run("should apply a merge patch", func(t *testing.T) {
+		mockRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(existing(), nil)
+		mockRepo.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, user *models.User) error {
+			assert.Equal(t, "Alicia", user.Name)
+			assert.Equal(t, "alice@example.com", user.Email)
+			user.Version++
+			return nil
+		})
+
+		w := patch(rbac.RoleUser, "application/merge-patch+json", `{"name":"Alicia"}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"4"`, w.Header().Get("ETag"))
+		var response models.User
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, "Alicia", response.Name)
+	})
+
+	t.Run("should apply a JSON patch", func(t *testing.T) {
+		mockRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(existing(), nil)
+		mockRepo.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, user *models.User) error {
+			assert.Equal(t, "Alice", user.Name)
+			assert.Equal(t, "new@example.com", user.Email)
+			return nil
+		})
+
+		w := patch(rbac.RoleUser, "application/json-patch+json",
+			`[{"op":"test","path":"/email","value":"alice@example.com"},{"op":"replace","path":"/email","value":"new@example.com"}]`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("should let admins change the role", func(t *testing.T) {
+		mockRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(existing(), nil)
+		mockRepo.EXPECT().UpdateKeepingRole(gomock.Any(), gomock.Any(), rbac.RoleAdmin).DoAndReturn(func(ctx context.Context, user *models.User, role string) error {
+			assert.Equal(t, rbac.RoleAdmin, user.Role)
+			return nil
+		})
+
+		w := patch(rbac.RoleAdmin, "application/merge-patch+json", `{"role":"admin"}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("should forbid users to change their role", func(t *testing.T) {
+		mockRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(existing(), nil)
+
+		w := patch(rbac.RoleUser, "application/merge-patch+json", `{"name":"Alicia","role":"admin"}`)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		response := decodeProblem(w)
+		assert.Equal(t, problem.CodeForbidden, response.Code)
+		assert.Equal(t, []problem.FieldError{{Field: "role", Code: "not_patchable", Detail: "cannot be changed by your role"}}, response.Errors)
+	})
+
+	t.Run("should allow fields the role cannot change when they keep their value", func(t *testing.T) {
+		mockRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(existing(), nil)
+		mockRepo.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil)
+
+		w := patch(rbac.RoleUser, "application/merge-patch+json", `{"name":"Alicia","role":"user"}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("should reject unknown admin-assigned roles", func(t *testing.T) {
+		mockRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(existing(), nil)
+
+		w := patch(rbac.RoleAdmin, "application/merge-patch+json", `{"role":"superuser"}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, problem.CodeUnknownRole, decodeProblem(w).Code)
+	})
+
+	t.Run("should reject fields that are not part of the user", func(t *testing.T) {
+		mockRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(existing(), nil)
+
+		w := patch(rbac.RoleAdmin, "application/merge-patch+json", `{"password_hash":"x","id":5}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		response := decodeProblem(w)
+		assert.Equal(t, problem.CodeValidationFailed, response.Code)
+		if assert.Len(t, response.Errors, 2) {
+			assert.Equal(t, "id", response.Errors[0].Field)
+			assert.Equal(t, "password_hash", response.Errors[1].Field)
+		}
+	})
+
+	t.Run("should validate the patched user", func(t *testing.T) {
+		tests := []struct {
+			contentType, body, field, code string
+		}{
+			{"application/merge-patch+json", `{"name":null}`, "name", "required"},
+			{"application/merge-patch+json", `{"email":"not-an-email"}`, "email", "email"},
+			{"application/merge-patch+json", `{"name":42}`, "name", "type"},
+			{"application/json-patch+json", `[{"op":"remove","path":"/email"}]`, "email", "required"},
+		}
+		for _, tt := range tests {
+			mockRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(existing(), nil)
+
+			w := patch(rbac.RoleUser, tt.contentType, tt.body)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, tt.body)
+			response := decodeProblem(w)
+			assert.Equal(t, problem.CodeValidationFailed, response.Code, tt.body)
+			if assert.Len(t, response.Errors, 1, tt.body) {
+				assert.Equal(t, tt.field, response.Errors[0].Field)
+				assert.Equal(t, tt.code, response.Errors[0].Code)
+			}
+		}
+	})
+
+	t.Run("should reject invalid patches", func(t *testing.T) {
+		tests := []struct {
+			contentType, body, code string
+		}{
+			{"application/merge-patch+json", `["name"]`, problem.CodeInvalidPatch},
+			{"application/merge-patch+json", `{"name":`, problem.CodeInvalidRequest},
+			{"application/json-patch+json", `{"op":"remove","path":"/name"}`, problem.CodeInvalidPatch},
+			{"application/json-patch+json", `[{"op":"replace","path":"/phone","value":"1"}]`, problem.CodeInvalidPatch},
+			{"application/json-patch+json", `[{"op":"replace","path":"","value":"Alice"}]`, problem.CodeInvalidPatch},
+			{"application/json-patch+json", ``, problem.CodeInvalidRequest},
+		}
+		for _, tt := range tests {
+			mockRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(existing(), nil)
+
+			w := patch(rbac.RoleUser, tt.contentType, tt.body)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, tt.body)
+			assert.Equal(t, tt.code, decodeProblem(w).Code, tt.body)
+		}
+	})
+
+	t.Run("should return 409 when a test operation fails", func(t *testing.T) {
+		mockRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(existing(), nil)
+
+		w := patch(rbac.RoleUser, "application/json-patch+json",
+			`[{"op":"test","path":"/name","value":"Bob"},{"op":"replace","path":"/name","value":"Robert"}]`)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, problem.CodePatchTestFailed, decodeProblem(w).Code)
+	})
+
+	t.Run("should return 415 for other content types", func(t *testing.T) {
+		w := patch(rbac.RoleUser, "application/json", `{"name":"Alicia"}`)
+
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+		assert.Equal(t, problem.CodeUnsupportedMediaType, decodeProblem(w).Code)
+		assert.Equal(t, "application/merge-patch+json, application/json-patch+json", w.Header().Get("Accept-Patch"))
+	})
+
+	t.Run("should forbid patching other users without users:update", func(t *testing.T) {
+		handler := NewUserHandler(mockRepo)
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Set("user_id", uint(2))
+			c.Set("user_role", rbac.RoleUser)
+		})
+		router.PATCH("/users/:id", handler.PatchUser)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PATCH", "/users/1", bytes.NewBufferString(`{"name":"Mallory"}`))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("should return 412 when If-Match does not match", func(t *testing.T) {
+		mockRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(existing(), nil)
+
+		w := patch(rbac.RoleUser, "application/merge-patch+json", `{"name":"Alicia"}`, "If-Match", `"2"`)
+
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	})
+
+	t.Run("should require verifying a patched email again", func(t *testing.T) {
+		verifier := &stubEmailVerifier{}
+		verifiedAt := time.Now()
+		user := existing()
+		user.EmailVerifiedAt = &verifiedAt
+		mockRepo.EXPECT().FindByID(gomock.Any(), uint(1)).Return(user, nil)
+		mockRepo.EXPECT().Update(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, user *models.User) error {
+			assert.Nil(t, user.EmailVerifiedAt)
+			return nil
+		})
+
+		handler := NewUserHandler(mockRepo).WithEmailVerifier(verifier)
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Set("user_id", uint(1))
+			c.Set("user_role", rbac.RoleUser)
+		})
+		router.PATCH("/users/:id", handler.PatchUser)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PATCH", "/users/1", bytes.NewBufferString(`{"email":"new@example.com"}`))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []string{"new@example.com"}, verifier.sentTo)
+	})
+}
+
+func TestUpdateUserRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockUserRepository(ctrl)
+
+	// send changes the role of user id as admin 2
+	send := func(id uint, body string) *httptest.ResponseRecorder {
+		handler := NewUserHandler(mockRepo)
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Set("user_id", uint(2))
+			c.Set("user_role", rbac.RoleAdmin)
+			c.Set("user_permissions", []string{rbac.PermUsersUpdate})
+		})
+		router.PUT("/users/:id/role", handler.UpdateUserRole)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", fmt.Sprintf("/users/%d/role", id), bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	decodeProblem := func(w *httptest.ResponseRecorder) problem.Problem {
+		var response problem.Problem
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response
+	}
+
+	t.Run("should promote a user", func(t *testing.T) {
+		mockRepo.EXPECT().FindByID(gomock.Any(), uint(3)).Return(&models.User{ID: 3, Name: "Carol", Role: rbac.RoleUser, Version: 1}, nil)
+		mockRepo.EXPECT().UpdateKeepingRole(gomock.Any(), gomock.Any(), rbac.RoleAdmin).DoAndReturn(func(ctx context.Context, user *models.User, role string) error {
+			assert.Equal(t, rbac.RoleAdmin, user.Role)
+			user.Version++
+			return nil
+		})
+
+		w := send(3, `{"role":"admin"}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+		var response models.User
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, rbac.RoleAdmin, response.Role)
+	})
+
+	t.Run("should demote another admin", func(t *testing.T) {
+		mockRepo.EXPECT().FindByID(gomock.Any(), uint(3)).Return(&models.User{ID: 3, Name: "Carol", Role: rbac.RoleAdmin, Version: 1}, nil)
+		mockRepo.EXPECT().UpdateKeepingRole(gomock.Any(), gomock.Any(), rbac.RoleAdmin).Return(nil)
+
+		assert.Equal(t, http.StatusOK, send(3, `{"role":"user"}`).Code)
+	})
+
+	t.Run("should not demote the last admin", func(t *testing.T) {
+		mockRepo.EXPECT().FindByID(gomock.Any(), uint(3)).Return(&models.User{ID: 3, Name: "Carol", Role: rbac.RoleAdmin, Version: 1}, nil)
+		mockRepo.EXPECT().UpdateKeepingRole(gomock.Any(), gomock.Any(), rbac.RoleAdmin).Return(repository.ErrLastRoleHolder)
+
+		w := send(3, `{"role":"user"}`)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, problem.CodeLastAdmin, decodeProblem(w).Code)
+	})
+
+	t.Run("should not let admins demote themselves", func(t *testing.T) {
+		mockRepo.EXPECT().FindByID(gomock.Any(), uint(2)).Return(&models.User{ID: 2, Name: "Bob", Role: rbac.RoleAdmin, Version: 1}, nil)
+
+		w := send(2, `{"role":"user"}`)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, problem.CodeSelfDemotion, decodeProblem(w).Code)
+	})
+
+	t.Run("should not let admins demote themselves with a patch", func(t *testing.T) {
+		mockRepo.EXPECT().FindByID(gomock.Any(), uint(2)).Return(&models.User{ID: 2, Name: "Bob", Email: "bob@example.com", Role: rbac.RoleAdmin, Version: 1}, nil)
+
+		handler := NewUserHandler(mockRepo)
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Set("user_id", uint(2))
+			c.Set("user_role", rbac.RoleAdmin)
+		})
+		router.PATCH("/users/:id", handler.PatchUser)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PATCH", "/users/2", bytes.NewBufferString(`{"role":"user"}`))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, problem.CodeSelfDemotion, decodeProblem(w).Code)
+	})
+
+	t.Run("should keep the role of an admin setting their own role again", func(t *testing.T) {
+		mockRepo.EXPECT().FindByID(gomock.Any(), uint(2)).Return(&models.User{ID: 2, Name: "Bob", Role: rbac.RoleAdmin, Version: 1}, nil)
+		mockRepo.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil)
+
+		assert.Equal(t, http.StatusOK, send(2, `{"role":"admin"}`).Code)
+	})
+
+	t.Run("should reject unknown roles", func(t *testing.T) {
+		mockRepo.EXPECT().FindByID(gomock.Any(), uint(3)).Return(&models.User{ID: 3, Name: "Carol", Role: rbac.RoleUser, Version: 1}, nil)
+
+		w := send(3, `{"role":"superuser"}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, problem.CodeUnknownRole, decodeProblem(w).Code)
+	})
+
+	t.Run("should require a role", func(t *testing.T) {
+		mockRepo.EXPECT().FindByID(gomock.Any(), uint(3)).Return(&models.User{ID: 3, Name: "Carol", Role: rbac.RoleUser, Version: 1}, nil)
+
+		w := send(3, `{}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, problem.CodeValidationFailed, decodeProblem(w).Code)
+	})
+
+	t.Run("should return 404 for unknown users", func(t *testing.T) {
+		mockRepo.EXPECT().FindByID(gomock.Any(), uint(9)).Return(nil, repository.ErrUserNotFound)
+
+		assert.Equal(t, http.StatusNotFound, send(9, `{"role":"admin"}`).Code)
+	})
+}
+
+// failingTokenRevocations is a revocation list whose writes always fail
+type failingTokenRevocations struct {
+	repository.TokenRevocationRepository
+}
+
+func (failingTokenRevocations) RevokeAllForUser(ctx context.Context, userID uint, before time.Time) error {
+	return errors.New("database unavailable")
+}
+
+func TestRoleChangeRevokesSessions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// setup signs in a user and routes role changes by admin 42
+	setup := func(t *testing.T) (*gin.Engine, repository.TokenRevocationRepository, LoginResponse) {
+		db := setupTestDB(t)
+		hashedPassword, _ := utils.HashPassword("password123")
+		db.Create(&models.User{Name: "Test User", Email: "test@example.com", PasswordHash: hashedPassword, Role: rbac.RoleUser})
+
+		revocations := repository.NewPostgresTokenRevocationRepository(db)
+		authHandler := NewAuthHandler(db, "test-secret", setupTestLogger()).WithRevocations(revocations)
+		userHandler := NewUserHandler(repository.NewPostgresUserRepository(db)).
+			WithSessionRevocation(revocations, repository.NewPostgresRefreshTokenRepository(db))
+
+		router := gin.New()
+		router.POST("/login", authHandler.Login)
+		router.POST("/token/refresh", authHandler.Refresh)
+		admin := router.Group("/")
+		admin.Use(func(c *gin.Context) {
+			c.Set("user_id", uint(42))
+			c.Set("user_role", rbac.RoleAdmin)
+			c.Set("user_permissions", []string{rbac.PermUsersUpdate})
+			c.Next()
+		})
+		admin.PUT("/users/:id", userHandler.UpdateUser)
+		admin.PUT("/users/:id/role", userHandler.UpdateUserRole)
+		admin.PATCH("/users/:id", userHandler.PatchUser)
+
+		return router, revocations, loginTestUser(t, router, "test@example.com", "password123")
+	}
+
+	send := func(router *gin.Engine, method, path, contentType, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", contentType)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("should revoke access and refresh tokens when the role changes", func(t *testing.T) {
+		for _, change := range []struct{ method, contentType string }{
+			{"PUT", "application/json"},
+			{"PATCH", "application/merge-patch+json"},
+		} {
+			router, revocations, login := setup(t)
+			path := "/users/1"
+			if change.method == "PUT" {
+				path += "/role"
+			}
+
+			w := send(router, change.method, path, change.contentType, `{"role":"admin"}`)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+			cutoff, err := revocations.RevokedBefore(t.Context(), 1)
+			require.NoError(t, err)
+			assert.False(t, cutoff.IsZero(), change.method)
+			assert.Equal(t, http.StatusUnauthorized, refreshTestToken(router, login.RefreshToken).Code, change.method)
+		}
+	})
+
+	t.Run("should save the role and revoke refresh tokens when access token revocation fails", func(t *testing.T) {
+		db := setupTestDB(t)
+		hashedPassword, _ := utils.HashPassword("password123")
+		db.Create(&models.User{Name: "Test User", Email: "test@example.com", PasswordHash: hashedPassword, Role: rbac.RoleUser})
+		authHandler := NewAuthHandler(db, "test-secret", setupTestLogger())
+		userHandler := NewUserHandler(repository.NewPostgresUserRepository(db)).
+			WithSessionRevocation(failingTokenRevocations{}, repository.NewPostgresRefreshTokenRepository(db))
+		router := gin.New()
+		router.POST("/login", authHandler.Login)
+		router.POST("/token/refresh", authHandler.Refresh)
+		router.PUT("/users/:id/role", func(c *gin.Context) {
+			c.Set("user_id", uint(42))
+			c.Set("user_role", rbac.RoleAdmin)
+			c.Set("user_permissions", []string{rbac.PermUsersUpdate})
+		}, userHandler.UpdateUserRole)
+		login := loginTestUser(t, router, "test@example.com", "password123")
+
+		w := send(router, "PUT", "/users/1/role", "application/json", `{"role":"admin"}`)
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.NotEmpty(t, w.Header().Get("ETag"))
+		var user models.User
+		require.NoError(t, db.First(&user, 1).Error)
+		assert.Equal(t, rbac.RoleAdmin, user.Role)
+		assert.Equal(t, http.StatusUnauthorized, refreshTestToken(router, login.RefreshToken).Code)
+	})
+
+	t.Run("should keep sessions when the role stays the same", func(t *testing.T) {
+		router, revocations, login := setup(t)
+
+		w := send(router, "PUT", "/users/1", "application/json", `{"name":"Renamed"}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		cutoff, err := revocations.RevokedBefore(t.Context(), 1)
+		require.NoError(t, err)
+		assert.True(t, cutoff.IsZero())
+		assert.Equal(t, http.StatusOK, refreshTestToken(router, login.RefreshToken).Code)
 	})
 }
@@ -0,0 +1,94 @@
+// Package janitor periodically removes rows that are no longer needed, such as
+// expired entries of the token revocation list.
+package janitor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefaultInterval is how often the tasks run when no interval is given
+const DefaultInterval = time.Hour
+
+// Task removes stale rows as of now and returns how many it removed
+type Task func(ctx context.Context, now time.Time) (int64, error)
+
+type namedTask struct {
+	name string
+	run  Task
+}
+
+// Janitor runs its tasks once on Start and then every interval until Close is called
+type Janitor struct {
+	interval time.Duration
+	logger   *zap.Logger
+	tasks    []namedTask
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// New creates a janitor that runs its tasks every interval
+func New(interval time.Duration, logger *zap.Logger) *Janitor {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Janitor{interval: interval, logger: logger}
+}
+
+// Add registers a task; name identifies it in the logs
+func (j *Janitor) Add(name string, task Task) *Janitor {
+	j.tasks = append(j.tasks, namedTask{name: name, run: task})
+	return j
+}
+
+// Start runs the tasks in the background, once right away and then every interval
+func (j *Janitor) Start() *Janitor {
+	if j.stop != nil {
+		return j
+	}
+	j.stop = make(chan struct{})
+	j.done = make(chan struct{})
+	go func() {
+		defer close(j.done)
+		ticker := time.NewTicker(j.interval)
+		defer ticker.Stop()
+		for {
+			j.RunOnce(context.Background(), time.Now())
+			select {
+			case <-ticker.C:
+			case <-j.stop:
+				return
+			}
+		}
+	}()
+	return j
+}
+
+// RunOnce runs every task once. A failing task is logged and does not keep
+// the others from running.
+func (j *Janitor) RunOnce(ctx context.Context, now time.Time) {
+	for _, task := range j.tasks {
+		removed, err := task.run(ctx, now)
+		if err != nil {
+			j.logger.Warn("janitor task failed", zap.String("task", task.name), zap.Error(err))
+			continue
+		}
+		if removed > 0 {
+			j.logger.Info("janitor removed stale rows", zap.String("task", task.name), zap.Int64("removed", removed))
+		}
+	}
+}
+
+// Close stops the janitor and waits for a running task to finish
+func (j *Janitor) Close() {
+	j.stopOnce.Do(func() {
+		if j.stop != nil {
+			close(j.stop)
+			<-j.done
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package janitor
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestJanitor(t *testing.T) {
+	t.Run("should run every task and continue after a failure", func(t *testing.T) {
+		now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+		var seen []time.Time
+		j := New(time.Minute, zap.NewNop()).
+			Add("failing", func(ctx context.Context, at time.Time) (int64, error) {
+				return 0, errors.New("database unavailable")
+			}).
+			Add("working", func(ctx context.Context, at time.Time) (int64, error) {
+				seen = append(seen, at)
+				return 3, nil
+			})
+
+		j.RunOnce(context.Background(), now)
+
+		assert.Equal(t, []time.Time{now}, seen)
+	})
+
+	t.Run("should run right away and then every interval until closed", func(t *testing.T) {
+		var runs atomic.Int32
+		j := New(10*time.Millisecond, zap.NewNop()).
+			Add("count", func(ctx context.Context, at time.Time) (int64, error) {
+				runs.Add(1)
+				return 0, nil
+			}).
+			Start()
+
+		assert.Eventually(t, func() bool { return runs.Load() >= 3 }, time.Second, 5*time.Millisecond)
+		j.Close()
+		stopped := runs.Load()
+		time.Sleep(30 * time.Millisecond)
+		assert.Equal(t, stopped, runs.Load())
+	})
+
+	t.Run("should close a janitor that was never started", func(t *testing.T) {
+		j := New(0, zap.NewNop())
+
+		assert.NotPanics(t, j.Close)
+		assert.Equal(t, DefaultInterval, j.interval)
+	})
+}
@@ -0,0 +1,192 @@
+// Package lockout throttles password guessing by tracking failed logins per
+// account and per client IP and refusing attempts with exponential backoff.
+package lockout
+
+import (
+	"context"
+	"errors"
+	"myapp/internal/models"
+	"myapp/internal/repository"
+	"strings"
+	"time"
+)
+
+// Policy controls when and for how long login attempts are refused
+type Policy struct {
+	// AccountThreshold is the number of failures per email address before it is locked
+	AccountThreshold int
+	// IPThreshold is the number of failures per client IP before it is locked
+	IPThreshold int
+	// BaseDelay is the lockout after reaching a threshold; it doubles with every further failure
+	BaseDelay time.Duration
+	// MaxDelay caps the lockout duration
+	MaxDelay time.Duration
+	// Window is how long failures are remembered once no lockout is active
+	Window time.Duration
+	// MaxTrackedAccounts caps the number of tracked email addresses, 0 for no
+	// limit. Failures for unknown emails are tracked like any other so that a
+	// lockout does not reveal which addresses are registered; the cap keeps
+	// them from filling the table.
+	MaxTrackedAccounts int
+}
+
+// DefaultPolicy returns the policy used when no configuration is given
+func DefaultPolicy() Policy {
+	return Policy{
+		AccountThreshold:   5,
+		IPThreshold:        20,
+		BaseDelay:          30 * time.Second,
+		MaxDelay:           time.Hour,
+		Window:             15 * time.Minute,
+		MaxTrackedAccounts: 100000,
+	}
+}
+
+// Service records failed logins and decides whether attempts are allowed
+type Service struct {
+	repo   repository.LoginFailureRepository
+	policy Policy
+	now    func() time.Time
+}
+
+// NewService creates a lockout service
+func NewService(repo repository.LoginFailureRepository, policy Policy) *Service {
+	return &Service{repo: repo, policy: policy, now: time.Now}
+}
+
+// Check returns how long the caller must wait before trying to log in as
+// email from ip, or zero if the attempt is allowed
+func (s *Service) Check(ctx context.Context, email, ip string) (time.Duration, error) {
+	now := s.now()
+	var wait time.Duration
+	for _, k := range s.keys(email, ip) {
+		failure, err := s.repo.Find(ctx, k.scope, k.identifier)
+		if err != nil {
+			if errors.Is(err, repository.ErrLoginFailureNotFound) {
+				continue
+			}
+			return 0, err
+		}
+		if failure.IsLocked(now) {
+			wait = max(wait, failure.LockedUntil.Sub(now))
+		}
+	}
+	return wait, nil
+}
+
+// RecordFailure counts a failed login for the account and the IP. It returns
+// how long further attempts are refused, or zero if no lockout was triggered.
+func (s *Service) RecordFailure(ctx context.Context, email, ip string) (time.Duration, error) {
+	now := s.now()
+	var wait time.Duration
+	for _, k := range s.keys(email, ip) {
+		if k.scope == models.LoginFailureScopeAccount {
+			full, err := s.accountsFull(ctx, k.identifier)
+			if err != nil {
+				return 0, err
+			}
+			if full {
+				// Only the IP is throttled until stale accounts are pruned
+				continue
+			}
+		}
+		failure, err := s.repo.Update(ctx, k.scope, k.identifier, func(f *models.LoginFailure) {
+			// Start counting afresh once old failures have aged out
+			if !f.IsLocked(now) && now.Sub(f.LastFailedAt) > s.policy.Window {
+				f.Failures = 0
+				f.LockedUntil = nil
+			}
+			f.Failures++
+			f.LastFailedAt = now
+			if delay := s.delay(f.Failures, k.threshold); delay > 0 {
+				lockedUntil := now.Add(delay)
+				f.LockedUntil = &lockedUntil
+			}
+		})
+		if err != nil {
+			return 0, err
+		}
+		if failure.IsLocked(now) {
+			wait = max(wait, failure.LockedUntil.Sub(now))
+		}
+	}
+	return wait, nil
+}
+
+// accountsFull reports whether email is not tracked yet and MaxTrackedAccounts is reached
+func (s *Service) accountsFull(ctx context.Context, email string) (bool, error) {
+	if s.policy.MaxTrackedAccounts <= 0 {
+		return false, nil
+	}
+	if _, err := s.repo.Find(ctx, models.LoginFailureScopeAccount, email); !errors.Is(err, repository.ErrLoginFailureNotFound) {
+		return false, err
+	}
+	count, err := s.repo.Count(ctx, models.LoginFailureScopeAccount)
+	if err != nil {
+		return false, err
+	}
+	return count >= int64(s.policy.MaxTrackedAccounts), nil
+}
+
+// Prune removes accounts and IPs whose failures have aged out and whose
+// lockout has ended; they no longer affect logins
+func (s *Service) Prune(ctx context.Context, now time.Time) (int64, error) {
+	return s.repo.DeleteStale(ctx, now.Add(-s.policy.Window), now)
+}
+
+// RecordSuccess forgets the failures of an account after a successful login.
+// IP failures are kept so one valid account cannot reset an IP guessing others.
+func (s *Service) RecordSuccess(ctx context.Context, email string) error {
+	err := s.repo.Delete(ctx, models.LoginFailureScopeAccount, normalizeEmail(email))
+	if errors.Is(err, repository.ErrLoginFailureNotFound) {
+		return nil
+	}
+	return err
+}
+
+// List returns tracked accounts and IPs with recent failures or an active lockout
+func (s *Service) List(ctx context.Context, scope string) ([]models.LoginFailure, error) {
+	now := s.now()
+	return s.repo.List(ctx, scope, now.Add(-s.policy.Window), now)
+}
+
+// Clear removes the failures and lockout of an account or IP
+func (s *Service) Clear(ctx context.Context, scope, identifier string) error {
+	if scope == models.LoginFailureScopeAccount {
+		identifier = normalizeEmail(identifier)
+	}
+	return s.repo.Delete(ctx, scope, identifier)
+}
+
+// delay returns the lockout for the given number of failures: BaseDelay at
+// the threshold, doubling with every further failure up to MaxDelay
+func (s *Service) delay(failures, threshold int) time.Duration {
+	if threshold <= 0 || failures < threshold {
+		return 0
+	}
+	delay := s.policy.BaseDelay
+	for i := threshold; i < failures && delay < s.policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, s.policy.MaxDelay)
+}
+
+type trackingKey struct {
+	scope      string
+	identifier string
+	threshold  int
+}
+
+// keys returns the account and IP records affected by a login attempt
+func (s *Service) keys(email, ip string) []trackingKey {
+	keys := []trackingKey{{models.LoginFailureScopeAccount, normalizeEmail(email), s.policy.AccountThreshold}}
+	if ip != "" {
+		keys = append(keys, trackingKey{models.LoginFailureScopeIP, ip, s.policy.IPThreshold})
+	}
+	return keys
+}
+
+// normalizeEmail makes lookups independent of case and surrounding whitespace
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,231 @@
+package lockout
+
+import (
+	"context"
+	"myapp/internal/models"
+	"myapp/internal/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupService creates a service on an in-memory database with a controllable clock
+func setupService(t *testing.T, policy Policy) (*Service, *time.Time) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	require.NoError(t, db.AutoMigrate(&models.LoginFailure{}))
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := NewService(repository.NewPostgresLoginFailureRepository(db), policy)
+	s.now = func() time.Time { return now }
+	return s, &now
+}
+
+func testPolicy() Policy {
+	return Policy{
+		AccountThreshold: 3,
+		IPThreshold:      5,
+		BaseDelay:        10 * time.Second,
+		MaxDelay:         time.Minute,
+		Window:           15 * time.Minute,
+	}
+}
+
+func TestService(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should allow attempts below the threshold", func(t *testing.T) {
+		s, _ := setupService(t, testPolicy())
+
+		for i := 0; i < 2; i++ {
+			wait, err := s.RecordFailure(ctx, "alice@example.com", "10.0.0.1")
+			require.NoError(t, err)
+			assert.Zero(t, wait)
+		}
+
+		wait, err := s.Check(ctx, "alice@example.com", "10.0.0.1")
+		require.NoError(t, err)
+		assert.Zero(t, wait)
+	})
+
+	t.Run("should lock account with exponential backoff", func(t *testing.T) {
+		s, now := setupService(t, testPolicy())
+
+		var waits []time.Duration
+		for i := 0; i < 6; i++ {
+			wait, err := s.RecordFailure(ctx, "alice@example.com", "")
+			require.NoError(t, err)
+			waits = append(waits, wait)
+		}
+		assert.Equal(t, []time.Duration{0, 0, 10 * time.Second, 20 * time.Second, 40 * time.Second, time.Minute}, waits)
+
+		wait, err := s.Check(ctx, "ALICE@example.com ", "10.0.0.9")
+		require.NoError(t, err)
+		assert.Equal(t, time.Minute, wait)
+
+		*now = now.Add(time.Minute)
+		wait, err = s.Check(ctx, "alice@example.com", "")
+		require.NoError(t, err)
+		assert.Zero(t, wait)
+	})
+
+	t.Run("should lock IP across accounts", func(t *testing.T) {
+		s, _ := setupService(t, testPolicy())
+
+		for _, email := range []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com"} {
+			_, err := s.RecordFailure(ctx, email, "10.0.0.1")
+			require.NoError(t, err)
+		}
+
+		wait, err := s.Check(ctx, "f@example.com", "10.0.0.1")
+		require.NoError(t, err)
+		assert.Equal(t, 10*time.Second, wait)
+
+		wait, err = s.Check(ctx, "f@example.com", "10.0.0.2")
+		require.NoError(t, err)
+		assert.Zero(t, wait)
+	})
+
+	t.Run("should forget failures after the window", func(t *testing.T) {
+		s, now := setupService(t, testPolicy())
+
+		for i := 0; i < 2; i++ {
+			_, err := s.RecordFailure(ctx, "alice@example.com", "")
+			require.NoError(t, err)
+		}
+		*now = now.Add(16 * time.Minute)
+
+		wait, err := s.RecordFailure(ctx, "alice@example.com", "")
+		require.NoError(t, err)
+		assert.Zero(t, wait)
+	})
+
+	t.Run("should reset account but not IP on success", func(t *testing.T) {
+		s, _ := setupService(t, testPolicy())
+
+		for i := 0; i < 4; i++ {
+			_, err := s.RecordFailure(ctx, "alice@example.com", "10.0.0.1")
+			require.NoError(t, err)
+		}
+		require.NoError(t, s.RecordSuccess(ctx, "alice@example.com"))
+		require.NoError(t, s.RecordSuccess(ctx, "unknown@example.com"))
+
+		failures, err := s.List(ctx, "")
+		require.NoError(t, err)
+		require.Len(t, failures, 1)
+		assert.Equal(t, models.LoginFailureScopeIP, failures[0].Scope)
+		assert.Equal(t, 4, failures[0].Failures)
+	})
+
+	t.Run("should list and clear lockouts", func(t *testing.T) {
+		s, now := setupService(t, testPolicy())
+
+		for i := 0; i < 3; i++ {
+			_, err := s.RecordFailure(ctx, "alice@example.com", "10.0.0.1")
+			require.NoError(t, err)
+		}
+
+		accounts, err := s.List(ctx, models.LoginFailureScopeAccount)
+		require.NoError(t, err)
+		require.Len(t, accounts, 1)
+		assert.Equal(t, "alice@example.com", accounts[0].Identifier)
+		assert.True(t, accounts[0].IsLocked(*now))
+
+		require.NoError(t, s.Clear(ctx, models.LoginFailureScopeAccount, "Alice@Example.com"))
+		wait, err := s.Check(ctx, "alice@example.com", "")
+		require.NoError(t, err)
+		assert.Zero(t, wait)
+
+		err = s.Clear(ctx, models.LoginFailureScopeAccount, "alice@example.com")
+		assert.ErrorIs(t, err, repository.ErrLoginFailureNotFound)
+
+		*now = now.Add(time.Hour)
+		all, err := s.List(ctx, "")
+		require.NoError(t, err)
+		assert.Empty(t, all)
+	})
+}
+
+func TestServicePrune(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should remove failures that aged out once their lockout ended", func(t *testing.T) {
+		s, now := setupService(t, testPolicy())
+
+		_, err := s.RecordFailure(ctx, "stale@example.com", "")
+		require.NoError(t, err)
+		for i := 0; i < 6; i++ {
+			_, err := s.RecordFailure(ctx, "locked@example.com", "")
+			require.NoError(t, err)
+		}
+		*now = now.Add(16 * time.Minute)
+		_, err = s.RecordFailure(ctx, "recent@example.com", "")
+		require.NoError(t, err)
+
+		removed, err := s.Prune(ctx, *now)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), removed)
+
+		_, err = s.repo.Find(ctx, models.LoginFailureScopeAccount, "recent@example.com")
+		assert.NoError(t, err)
+		_, err = s.repo.Find(ctx, models.LoginFailureScopeAccount, "stale@example.com")
+		assert.ErrorIs(t, err, repository.ErrLoginFailureNotFound)
+	})
+
+	t.Run("should keep lockouts that have not ended yet", func(t *testing.T) {
+		policy := testPolicy()
+		policy.MaxDelay = time.Hour
+		s, now := setupService(t, policy)
+
+		for i := 0; i < 10; i++ {
+			_, err := s.RecordFailure(ctx, "locked@example.com", "")
+			require.NoError(t, err)
+		}
+		*now = now.Add(16 * time.Minute)
+
+		removed, err := s.Prune(ctx, *now)
+		require.NoError(t, err)
+		assert.Zero(t, removed)
+
+		wait, err := s.Check(ctx, "locked@example.com", "")
+		require.NoError(t, err)
+		assert.Positive(t, wait)
+	})
+}
+
+func TestServiceMaxTrackedAccounts(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should stop tracking new accounts at the cap but keep counting the IP", func(t *testing.T) {
+		policy := testPolicy()
+		policy.MaxTrackedAccounts = 2
+		s, _ := setupService(t, policy)
+
+		for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+			_, err := s.RecordFailure(ctx, email, "10.0.0.1")
+			require.NoError(t, err)
+		}
+		_, err := s.RecordFailure(ctx, "a@example.com", "10.0.0.1")
+		require.NoError(t, err)
+
+		count, err := s.repo.Count(ctx, models.LoginFailureScopeAccount)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+		_, err = s.repo.Find(ctx, models.LoginFailureScopeAccount, "c@example.com")
+		assert.ErrorIs(t, err, repository.ErrLoginFailureNotFound)
+
+		tracked, err := s.repo.Find(ctx, models.LoginFailureScopeAccount, "a@example.com")
+		require.NoError(t, err)
+		assert.Equal(t, 2, tracked.Failures)
+		ip, err := s.repo.Find(ctx, models.LoginFailureScopeIP, "10.0.0.1")
+		require.NoError(t, err)
+		assert.Equal(t, 4, ip.Failures)
+	})
+}
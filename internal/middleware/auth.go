@@ -1,7 +1,11 @@
 package middleware
 
 import (
-	"net/http"
+	"context"
+	"myapp/internal/repository"
+	"myapp/pkg/jwks"
+	"myapp/pkg/problem"
+	"myapp/pkg/utils"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -10,45 +14,103 @@ import (
 
 // JWTAuthMiddleware validates JWT tokens
 func JWTAuthMiddleware(secret string) gin.HandlerFunc {
+	return JWTAuthMiddlewareWithKeySet(jwks.NewHMACKeySet(secret), nil)
+}
+
+// JWTAuthMiddlewareWithRevocation validates JWT tokens and rejects tokens that
+// are on the revocation list. A nil revocations repository disables the check.
+func JWTAuthMiddlewareWithRevocation(secret string, revocations repository.TokenRevocationRepository) gin.HandlerFunc {
+	return JWTAuthMiddlewareWithKeySet(jwks.NewHMACKeySet(secret), revocations)
+}
+
+// JWTAuthMiddlewareWithKeySet validates JWT tokens against every key in keys,
+// selected by the kid header, and rejects revoked tokens if revocations is set.
+func JWTAuthMiddlewareWithKeySet(keys *jwks.KeySet, revocations repository.TokenRevocationRepository) gin.HandlerFunc {
+	return JWTAuthMiddlewareWithPermissions(keys, revocations, nil)
+}
+
+// PermissionResolver looks up the permissions of a user
+type PermissionResolver interface {
+	PermissionsForUser(ctx context.Context, userID uint, role string) ([]string, error)
+}
+
+// JWTAuthMiddlewareWithPermissions validates JWT tokens like
+// JWTAuthMiddlewareWithKeySet and stores the permissions of the caller for
+// RequirePermission. Permissions are taken from the token's permissions claim;
+// for tokens without one they are looked up with resolver if it is set.
+func JWTAuthMiddlewareWithPermissions(keys *jwks.KeySet, revocations repository.TokenRevocationRepository, resolver PermissionResolver) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authorization header required"})
+			problem.Render(c, problem.Unauthorized(problem.CodeAuthorizationRequired, "authorization header required"))
 			return
 		}
 
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid authorization header format"})
+			problem.Render(c, problem.Unauthorized(problem.CodeAuthorizationRequired, "invalid authorization header format"))
 			return
 		}
 
 		tokenString := parts[1]
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return []byte(secret), nil
-		})
+		token, err := keys.Parse(tokenString)
 
 		if err != nil || !token.Valid {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			problem.Render(c, problem.Unauthorized(problem.CodeInvalidToken, "invalid token"))
 			return
 		}
 
 		claims, ok := token.Claims.(jwt.MapClaims)
 		if !ok {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token claims"})
+			problem.Render(c, problem.Unauthorized(problem.CodeInvalidToken, "invalid token claims"))
+			return
+		}
+
+		// Tokens issued before token_use was introduced carry no claim and are access tokens
+		if use, ok := claims["token_use"]; ok && use != utils.TokenUseAccess {
+			problem.Render(c, problem.Unauthorized(problem.CodeInvalidToken, "invalid token"))
 			return
 		}
 
+		userIDClaim, hasUserID := claims["user_id"].(float64)
+		jti, _ := claims["jti"].(string)
+
+		if revocations != nil && hasUserID {
+			revoked, err := repository.IsAccessTokenRevoked(c.Request.Context(), revocations, jti, uint(userIDClaim), utils.IssuedAt(claims))
+			if err != nil {
+				problem.Render(c, problem.Unavailable("unable to verify token"))
+				return
+			}
+			if revoked {
+				problem.Render(c, problem.Unauthorized(problem.CodeTokenRevoked, "token has been revoked"))
+				return
+			}
+		}
+
 		// Store user info in context
-		if userID, ok := claims["user_id"].(float64); ok {
-			c.Set("user_id", uint(userID))
+		if hasUserID {
+			c.Set("user_id", uint(userIDClaim))
 		}
 		if role, ok := claims["role"].(string); ok {
 			c.Set("user_role", role)
 		}
+		if jti != "" {
+			c.Set("token_jti", jti)
+		}
+		if permissions, ok := permissionsClaim(claims); ok {
+			c.Set("user_permissions", permissions)
+		} else if resolver != nil && hasUserID {
+			role, _ := claims["role"].(string)
+			permissions, err := resolver.PermissionsForUser(c.Request.Context(), uint(userIDClaim), role)
+			if err != nil {
+				problem.Render(c, problem.Unavailable("unable to verify token"))
+				return
+			}
+			c.Set("user_permissions", permissions)
+		}
+		if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+			c.Set("token_expires_at", exp.Time)
+		}
 
 		c.Next()
 	}
@@ -59,12 +121,24 @@ func RequireRole(role string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userRole, exists := c.Get("user_role")
 		if !exists {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "role not found in token"})
+			problem.Render(c, problem.Forbidden(problem.CodeForbidden, "role not found in token"))
 			return
 		}
 
 		if userRole != role {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "insufficient permissions"})
+			problem.Render(c, problem.Forbidden(problem.CodeForbidden, "insufficient permissions"))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequirePermission checks if the user has been granted permission
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasPermission(c, permission) {
+			problem.Render(c, problem.Forbidden(problem.CodeForbidden, "insufficient permissions"))
 			return
 		}
 
@@ -72,8 +146,33 @@ func RequireRole(role string) gin.HandlerFunc {
 	}
 }
 
+// HasPermission reports whether the authenticated user has been granted permission
+func HasPermission(c *gin.Context, permission string) bool {
+	permissions, _ := c.Get("user_permissions")
+	granted, _ := permissions.([]string)
+	for _, p := range granted {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// IsOwnerOrPermitted checks if the authenticated user is either the resource
+// owner or has been granted permission to act on resources of other users
+func IsOwnerOrPermitted(c *gin.Context, resourceUserID uint, permission string) bool {
+	if HasPermission(c, permission) {
+		return true
+	}
+
+	userID, idExists := c.Get("user_id")
+	return idExists && userID == resourceUserID
+}
+
 // IsOwnerOrAdmin checks if the authenticated user is either the resource owner or an admin
 // resourceUserID is the ID of the user resource being accessed
+//
+// Deprecated: use IsOwnerOrPermitted, which does not depend on the role name.
 func IsOwnerOrAdmin(c *gin.Context, resourceUserID uint) bool {
 	// Get the authenticated user's role
 	userRole, roleExists := c.Get("user_role")
@@ -95,3 +194,18 @@ func IsOwnerOrAdmin(c *gin.Context, resourceUserID uint) bool {
 	// Regular users can only access their own resources
 	return userID == resourceUserID
 }
+
+// permissionsClaim extracts the permissions claim of a token, if present
+func permissionsClaim(claims jwt.MapClaims) ([]string, bool) {
+	raw, ok := claims["permissions"].([]any)
+	if !ok {
+		return nil, false
+	}
+	permissions := make([]string, 0, len(raw))
+	for _, p := range raw {
+		if name, ok := p.(string); ok {
+			permissions = append(permissions, name)
+		}
+	}
+	return permissions, true
+}
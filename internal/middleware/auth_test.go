@@ -1,6 +1,12 @@
 package middleware
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"myapp/pkg/jwks"
+	"myapp/pkg/problem"
+	"myapp/pkg/utils"
 	"testing"
 	"time"
 
@@ -26,7 +32,13 @@ func TestJWTAuthMiddleware(t *testing.T) {
 		router.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusUnauthorized, w.Code)
-		assert.Contains(t, w.Body.String(), "authorization header required")
+		assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+
+		var response problem.Problem
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, problem.CodeAuthorizationRequired, response.Code)
+		assert.Equal(t, "authorization header required", response.Detail)
+		assert.Equal(t, "/protected", response.Instance)
 	})
 
 	t.Run("should reject request with invalid token", func(t *testing.T) {
@@ -274,6 +286,44 @@ func TestJWTAuthMiddleware(t *testing.T) {
 
 		assert.Equal(t, http.StatusOK, w.Code)
 	})
+
+	t.Run("should reject MFA challenge token", func(t *testing.T) {
+		secret := "test-secret"
+		router := gin.New()
+		router.Use(JWTAuthMiddleware(secret))
+		router.GET("/protected", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"message": "success"})
+		})
+
+		tokenString, err := utils.SignMFAChallenge(123, jwks.NewHMACKeySet(secret), time.Minute)
+		assert.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/protected", nil)
+		req.Header.Set("Authorization", "Bearer "+tokenString)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("should accept access token with token_use claim", func(t *testing.T) {
+		secret := "test-secret"
+		router := gin.New()
+		router.Use(JWTAuthMiddleware(secret))
+		router.GET("/protected", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"message": "success"})
+		})
+
+		tokenString, err := utils.GenerateJWTWithTTL(123, "user", secret, time.Minute)
+		assert.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/protected", nil)
+		req.Header.Set("Authorization", "Bearer "+tokenString)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
 }
 
 func TestRequireRole(t *testing.T) {
@@ -360,3 +410,201 @@ func TestIsOwnerOrAdmin(t *testing.T) {
 		assert.False(t, result)
 	})
 }
+
+// stubRevocations is a TokenRevocationRepository backed by plain maps
+type stubRevocations struct {
+	revoked map[string]bool
+	cutoffs map[uint]time.Time
+	err     error
+}
+
+func (s *stubRevocations) RevokeToken(ctx context.Context, jti string, userID uint, expiresAt time.Time) error {
+	s.revoked[jti] = true
+	return s.err
+}
+
+func (s *stubRevocations) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	return s.revoked[jti], s.err
+}
+
+func (s *stubRevocations) RevokeAllForUser(ctx context.Context, userID uint, before time.Time) error {
+	s.cutoffs[userID] = before
+	return s.err
+}
+
+func (s *stubRevocations) RevokedBefore(ctx context.Context, userID uint) (time.Time, error) {
+	return s.cutoffs[userID], s.err
+}
+
+func (s *stubRevocations) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	return 0, s.err
+}
+
+func TestJWTAuthMiddlewareWithRevocation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	secret := "test-secret"
+
+	signToken := func(jti string, issuedAt time.Time) string {
+		claims := jwt.MapClaims{
+			"jti":     jti,
+			"user_id": float64(123),
+			"role":    "user",
+			"iat":     issuedAt.Unix(),
+			"exp":     time.Now().Add(time.Hour).Unix(),
+		}
+		token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		return token
+	}
+
+	setup := func(revocations *stubRevocations) *gin.Engine {
+		router := gin.New()
+		router.Use(JWTAuthMiddlewareWithRevocation(secret, revocations))
+		router.GET("/protected", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"jti": c.GetString("token_jti")})
+		})
+		return router
+	}
+
+	request := func(router *gin.Engine, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/protected", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("should accept token that is not revoked", func(t *testing.T) {
+		router := setup(&stubRevocations{revoked: map[string]bool{}, cutoffs: map[uint]time.Time{}})
+
+		w := request(router, signToken("jti-1", time.Now()))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "jti-1")
+	})
+
+	t.Run("should reject revoked jti", func(t *testing.T) {
+		router := setup(&stubRevocations{revoked: map[string]bool{"jti-1": true}, cutoffs: map[uint]time.Time{}})
+
+		w := request(router, signToken("jti-1", time.Now()))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "token has been revoked")
+	})
+
+	t.Run("should reject token issued before user revocation", func(t *testing.T) {
+		router := setup(&stubRevocations{revoked: map[string]bool{}, cutoffs: map[uint]time.Time{123: time.Now()}})
+
+		w := request(router, signToken("jti-1", time.Now().Add(-time.Minute)))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("should fail closed when revocation list is unavailable", func(t *testing.T) {
+		router := setup(&stubRevocations{
+			revoked: map[string]bool{},
+			cutoffs: map[uint]time.Time{},
+			err:     errors.New("database unavailable"),
+		})
+
+		w := request(router, signToken("jti-1", time.Now()))
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	})
+}
+
+// stubResolver is a PermissionResolver returning fixed permissions per role
+type stubResolver struct {
+	permissions map[string][]string
+	err         error
+	calls       int
+}
+
+func (s *stubResolver) PermissionsForUser(ctx context.Context, userID uint, role string) ([]string, error) {
+	s.calls++
+	return s.permissions[role], s.err
+}
+
+func TestJWTAuthMiddlewareWithPermissions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	keys := jwks.NewHMACKeySet("test-secret")
+
+	setup := func(resolver PermissionResolver) *gin.Engine {
+		router := gin.New()
+		router.Use(JWTAuthMiddlewareWithPermissions(keys, nil, resolver))
+		router.GET("/users", RequirePermission("users:list"), func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"message": "success"})
+		})
+		return router
+	}
+
+	request := func(router *gin.Engine, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/users", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("should use permissions embedded in the token", func(t *testing.T) {
+		resolver := &stubResolver{}
+		router := setup(resolver)
+
+		granted, _ := utils.SignAccessToken(1, "support", []string{"users:list"}, keys, time.Minute)
+		denied, _ := utils.SignAccessToken(1, "admin", []string{"users:read"}, keys, time.Minute)
+
+		assert.Equal(t, http.StatusOK, request(router, granted).Code)
+		assert.Equal(t, http.StatusForbidden, request(router, denied).Code)
+		assert.Zero(t, resolver.calls)
+	})
+
+	t.Run("should resolve permissions for tokens without the claim", func(t *testing.T) {
+		resolver := &stubResolver{permissions: map[string][]string{"admin": {"users:list"}}}
+		router := setup(resolver)
+
+		admin, _ := utils.SignJWT(1, "admin", keys, time.Minute)
+		user, _ := utils.SignJWT(2, "user", keys, time.Minute)
+
+		assert.Equal(t, http.StatusOK, request(router, admin).Code)
+		assert.Equal(t, http.StatusForbidden, request(router, user).Code)
+		assert.Equal(t, 2, resolver.calls)
+	})
+
+	t.Run("should fail closed when permissions cannot be resolved", func(t *testing.T) {
+		router := setup(&stubResolver{err: errors.New("database unavailable")})
+
+		token, _ := utils.SignJWT(1, "admin", keys, time.Minute)
+		assert.Equal(t, http.StatusServiceUnavailable, request(router, token).Code)
+	})
+
+	t.Run("should grant nothing without claim or resolver", func(t *testing.T) {
+		router := setup(nil)
+
+		token, _ := utils.SignJWT(1, "admin", keys, time.Minute)
+		assert.Equal(t, http.StatusForbidden, request(router, token).Code)
+	})
+}
+
+func TestIsOwnerOrPermitted(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("should allow user with permission to access any resource", func(t *testing.T) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Set("user_id", uint(1))
+		c.Set("user_permissions", []string{"users:read"})
+
+		assert.True(t, IsOwnerOrPermitted(c, uint(999), "users:read"))
+		assert.False(t, IsOwnerOrPermitted(c, uint(999), "users:update"))
+	})
+
+	t.Run("should allow owner without permission", func(t *testing.T) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Set("user_id", uint(5))
+
+		assert.True(t, IsOwnerOrPermitted(c, uint(5), "users:read"))
+		assert.False(t, IsOwnerOrPermitted(c, uint(10), "users:read"))
+	})
+
+	t.Run("should not grant permissions based on the role name", func(t *testing.T) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Set("user_id", uint(1))
+		c.Set("user_role", "admin")
+
+		assert.False(t, IsOwnerOrPermitted(c, uint(999), "users:read"))
+	})
+}
@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"myapp/pkg/config"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// originMatcher matches an Origin header against one allowed origin
+type originMatcher struct {
+	scheme string
+	host   string
+	port   string
+	// subdomains matches any subdomain of host instead of host itself
+	subdomains bool
+}
+
+func (m originMatcher) matches(origin string) bool {
+	u, err := url.Parse(strings.ToLower(origin))
+	if err != nil || u.Scheme != m.scheme || u.Port() != m.port || u.Path != "" {
+		return false
+	}
+	host := u.Hostname()
+	if !m.subdomains {
+		return host == m.host
+	}
+	sub, ok := strings.CutSuffix(host, "."+m.host)
+	if !ok || sub == "" {
+		return false
+	}
+	for _, label := range strings.Split(sub, ".") {
+		if !isHostLabel(label) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseOrigin parses "scheme://host[:port]" where host may start with "*." to allow its subdomains
+func parseOrigin(origin string) (originMatcher, error) {
+	u, err := url.Parse(strings.ToLower(origin))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return originMatcher{}, fmt.Errorf("origin %q must look like https://example.com", origin)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return originMatcher{}, fmt.Errorf("origin %q must use http or https", origin)
+	}
+	if (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return originMatcher{}, fmt.Errorf("origin %q must not contain a path, query or credentials", origin)
+	}
+
+	m := originMatcher{scheme: u.Scheme, host: u.Hostname(), port: u.Port()}
+	if rest, ok := strings.CutPrefix(m.host, "*."); ok {
+		m.host, m.subdomains = rest, true
+	}
+	if strings.Contains(m.host, "*") {
+		return originMatcher{}, fmt.Errorf("origin %q: a wildcard is only allowed as the first label, e.g. https://*.example.com", origin)
+	}
+	if m.subdomains && !strings.Contains(m.host, ".") {
+		return originMatcher{}, fmt.Errorf("origin %q: wildcard subdomains need a registrable domain such as example.com", origin)
+	}
+	return m, nil
+}
+
+func isHostLabel(label string) bool {
+	if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+		return false
+	}
+	for _, r := range label {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// CORSMiddleware applies the configured cross-origin policy. It fails for
+// malformed origins and for credentials combined with the "*" origin, which
+// would let every website send authenticated requests. With no allowed origins
+// it does nothing and browsers only allow same-origin requests.
+func CORSMiddleware(cfg config.CORSConfig) (gin.HandlerFunc, error) {
+	if len(cfg.AllowedOrigins) == 0 {
+		return func(c *gin.Context) { c.Next() }, nil
+	}
+	if cfg.MaxAge < 0 {
+		return nil, errors.New("cors.max_age must not be negative")
+	}
+
+	corsConfig := cors.Config{
+		AllowMethods:     cfg.AllowedMethods,
+		AllowHeaders:     cfg.AllowedHeaders,
+		ExposeHeaders:    cfg.ExposedHeaders,
+		AllowCredentials: cfg.AllowCredentials,
+		MaxAge:           time.Duration(cfg.MaxAge) * time.Second,
+	}
+
+	var matchers []originMatcher
+	for _, origin := range cfg.AllowedOrigins {
+		if origin == "*" {
+			if cfg.AllowCredentials {
+				return nil, errors.New(`cors.allow_credentials cannot be combined with the "*" origin; list the allowed origins instead`)
+			}
+			corsConfig.AllowAllOrigins = true
+			continue
+		}
+		m, err := parseOrigin(origin)
+		if err != nil {
+			return nil, fmt.Errorf("cors.allowed_origins: %w", err)
+		}
+		matchers = append(matchers, m)
+	}
+
+	if !corsConfig.AllowAllOrigins {
+		corsConfig.AllowOriginFunc = func(origin string) bool {
+			for _, m := range matchers {
+				if m.matches(origin) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	if err := corsConfig.Validate(); err != nil {
+		return nil, fmt.Errorf("cors: %w", err)
+	}
+	return cors.New(corsConfig), nil
+}
@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"myapp/pkg/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func corsTestConfig(origins ...string) config.CORSConfig {
+	return config.CORSConfig{
+		AllowedOrigins: origins,
+		AllowedMethods: []string{"GET", "POST", "PATCH"},
+		AllowedHeaders: []string{"Content-Type", "Authorization"},
+		ExposedHeaders: []string{"RateLimit-Remaining"},
+		MaxAge:         600,
+	}
+}
+
+func corsRouter(t *testing.T, cfg config.CORSConfig) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	handler, err := CORSMiddleware(cfg)
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.Use(handler)
+	router.GET("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+	})
+	return router
+}
+
+func corsRequest(router *gin.Engine, method, origin string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, "/test", nil)
+	req.Header.Set("Origin", origin)
+	if method == http.MethodOptions {
+		req.Header.Set("Access-Control-Request-Method", "GET")
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	t.Run("should allow listed origins and expose configured headers", func(t *testing.T) {
+		router := corsRouter(t, corsTestConfig("https://app.example.com"))
+
+		w := corsRequest(router, http.MethodGet, "https://app.example.com")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "Ratelimit-Remaining", w.Header().Get("Access-Control-Expose-Headers"))
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+	})
+
+	t.Run("should reject unlisted origins", func(t *testing.T) {
+		router := corsRouter(t, corsTestConfig("https://app.example.com"))
+
+		for _, origin := range []string{"https://evil.com", "http://app.example.com", "https://app.example.com:8443"} {
+			w := corsRequest(router, http.MethodGet, origin)
+			assert.Equal(t, http.StatusForbidden, w.Code, origin)
+			assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"), origin)
+		}
+	})
+
+	t.Run("should answer preflight requests with methods, headers and max-age", func(t *testing.T) {
+		router := corsRouter(t, corsTestConfig("https://app.example.com"))
+
+		w := corsRequest(router, http.MethodOptions, "https://app.example.com")
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, "GET,POST,PATCH", w.Header().Get("Access-Control-Allow-Methods"))
+		assert.Equal(t, "Content-Type,Authorization", w.Header().Get("Access-Control-Allow-Headers"))
+		assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+	})
+
+	t.Run("should match wildcard subdomains only", func(t *testing.T) {
+		router := corsRouter(t, corsTestConfig("https://*.example.com"))
+
+		for _, origin := range []string{"https://app.example.com", "https://eu.app.example.com", "https://APP.example.com"} {
+			assert.Equal(t, http.StatusOK, corsRequest(router, http.MethodGet, origin).Code, origin)
+		}
+		for _, origin := range []string{
+			"https://example.com",
+			"https://evilexample.com",
+			"https://example.com.evil.com",
+			"https://evil.com/.example.com",
+			"http://app.example.com",
+			"https://app.example.com:8443",
+			"https://-bad.example.com",
+		} {
+			assert.Equal(t, http.StatusForbidden, corsRequest(router, http.MethodGet, origin).Code, origin)
+		}
+	})
+
+	t.Run("should allow any origin with *", func(t *testing.T) {
+		router := corsRouter(t, corsTestConfig("*"))
+
+		w := corsRequest(router, http.MethodGet, "https://anything.test")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("should reflect the origin when credentials are allowed", func(t *testing.T) {
+		cfg := corsTestConfig("https://*.example.com")
+		cfg.AllowCredentials = true
+		router := corsRouter(t, cfg)
+
+		w := corsRequest(router, http.MethodGet, "https://app.example.com")
+		assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	})
+
+	t.Run("should not add CORS headers without allowed origins", func(t *testing.T) {
+		router := corsRouter(t, corsTestConfig())
+
+		w := corsRequest(router, http.MethodGet, "https://app.example.com")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("should reject unsafe or malformed configuration", func(t *testing.T) {
+		tests := []struct {
+			name string
+			cfg  func() config.CORSConfig
+			want string
+		}{
+			{"credentials with *", func() config.CORSConfig {
+				cfg := corsTestConfig("https://app.example.com", "*")
+				cfg.AllowCredentials = true
+				return cfg
+			}, "cannot be combined"},
+			{"origin without scheme", func() config.CORSConfig { return corsTestConfig("app.example.com") }, "must look like"},
+			{"unsupported scheme", func() config.CORSConfig { return corsTestConfig("ftp://app.example.com") }, "http or https"},
+			{"origin with path", func() config.CORSConfig { return corsTestConfig("https://app.example.com/login") }, "must not contain a path"},
+			{"wildcard inside a label", func() config.CORSConfig { return corsTestConfig("https://app-*.example.com") }, "first label"},
+			{"wildcard top-level domain", func() config.CORSConfig { return corsTestConfig("https://*.com") }, "registrable domain"},
+			{"negative max age", func() config.CORSConfig {
+				cfg := corsTestConfig("https://app.example.com")
+				cfg.MaxAge = -1
+				return cfg
+			}, "max_age"},
+		}
+		for _, tt := range tests {
+			_, err := CORSMiddleware(tt.cfg())
+			assert.ErrorContains(t, err, tt.want, tt.name)
+		}
+	})
+}
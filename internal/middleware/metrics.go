@@ -29,6 +29,22 @@ var (
 		[]string{"method", "path"},
 	)
 
+	rateLimitRejectionsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rate_limit_rejections_total",
+			Help: "Total number of requests rejected by rate limiting",
+		},
+		[]string{"policy"},
+	)
+
+	rateLimitTrackedKeys = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "rate_limit_tracked_keys",
+			Help: "Number of keys with an in-memory rate limit bucket",
+		},
+		[]string{"policy"},
+	)
+
 	userCountOnce sync.Once
 )
 
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// ForwardedHeaderXForwardedFor is the de facto standard client, proxy1, proxy2 list
+	ForwardedHeaderXForwardedFor = "X-Forwarded-For"
+	// ForwardedHeaderXRealIP carries only the client address, as set by e.g. nginx
+	ForwardedHeaderXRealIP = "X-Real-IP"
+	// ForwardedHeaderForwarded is the RFC 7239 header: for=client;proto=https, for=proxy1
+	ForwardedHeaderForwarded = "Forwarded"
+
+	// forwardedForHeader carries the for= addresses parsed from Forwarded, which
+	// gin cannot read itself. Incoming copies are always dropped.
+	forwardedForHeader = "X-Forwarded-For-Parsed"
+)
+
+// ConfigureTrustedProxies makes c.ClientIP() honor header only on requests
+// arriving from one of the trusted proxies (IPs or CIDRs). Addresses in the
+// header are read right to left, skipping trusted proxies, so a client cannot
+// spoof its address by sending the header itself. With no trusted proxies the
+// header is ignored and the peer address is used.
+func ConfigureTrustedProxies(router *gin.Engine, proxies []string, header string) error {
+	if err := router.SetTrustedProxies(proxies); err != nil {
+		return fmt.Errorf("invalid trusted proxy: %w", err)
+	}
+	router.ForwardedByClientIP = true
+	router.TrustedPlatform = ""
+
+	switch http.CanonicalHeaderKey(header) {
+	case ForwardedHeaderXForwardedFor, "":
+		router.RemoteIPHeaders = []string{ForwardedHeaderXForwardedFor}
+	case http.CanonicalHeaderKey(ForwardedHeaderXRealIP):
+		router.RemoteIPHeaders = []string{ForwardedHeaderXRealIP}
+	case ForwardedHeaderForwarded:
+		router.RemoteIPHeaders = []string{forwardedForHeader}
+		router.Use(forwardedHeaderMiddleware())
+	default:
+		return fmt.Errorf("unsupported forwarded header %q", header)
+	}
+	return nil
+}
+
+// forwardedHeaderMiddleware exposes the for= addresses of the Forwarded header
+// to gin as a comma-separated list. It must run before anything reads ClientIP.
+func forwardedHeaderMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request.Header.Del(forwardedForHeader)
+		if values := c.Request.Header.Values(ForwardedHeaderForwarded); len(values) > 0 {
+			if addrs := parseForwardedFor(strings.Join(values, ",")); len(addrs) > 0 {
+				c.Request.Header.Set(forwardedForHeader, strings.Join(addrs, ", "))
+			}
+		}
+		c.Next()
+	}
+}
+
+// parseForwardedFor returns the for= node of every Forwarded element in order.
+// Ports, brackets and quotes are removed; obfuscated or unknown nodes are kept
+// as they are so that they stop the search for the client address.
+func parseForwardedFor(value string) []string {
+	var addrs []string
+	for _, element := range strings.Split(value, ",") {
+		for _, pair := range strings.Split(element, ";") {
+			name, node, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok || !strings.EqualFold(name, "for") {
+				continue
+			}
+			addrs = append(addrs, forwardedNodeIP(strings.Trim(node, `"`)))
+		}
+	}
+	return addrs
+}
+
+// forwardedNodeIP strips the port from "192.0.2.1:4711" or "[2001:db8::1]:4711"
+func forwardedNodeIP(node string) string {
+	if strings.HasPrefix(node, "[") {
+		if end := strings.Index(node, "]"); end > 0 {
+			return node[1:end]
+		}
+		return node
+	}
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		return host
+	}
+	return node
+}
@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clientIPRouter echoes c.ClientIP() as resolved by ConfigureTrustedProxies
+func clientIPRouter(t *testing.T, proxies []string, header string) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	require.NoError(t, ConfigureTrustedProxies(router, proxies, header))
+	router.GET("/ip", func(c *gin.Context) {
+		c.String(http.StatusOK, c.ClientIP())
+	})
+	return router
+}
+
+func clientIP(router *gin.Engine, remoteAddr string, headers map[string]string) string {
+	req, _ := http.NewRequest("GET", "/ip", nil)
+	req.RemoteAddr = remoteAddr
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Body.String()
+}
+
+func TestConfigureTrustedProxies(t *testing.T) {
+	proxies := []string{"10.0.0.0/8", "192.168.1.10"}
+
+	t.Run("should ignore forwarded headers without trusted proxies", func(t *testing.T) {
+		router := clientIPRouter(t, nil, ForwardedHeaderXForwardedFor)
+
+		assert.Equal(t, "10.0.0.5", clientIP(router, "10.0.0.5:1234", map[string]string{
+			"X-Forwarded-For": "1.2.3.4",
+		}))
+	})
+
+	t.Run("should ignore X-Forwarded-For sent by an untrusted client", func(t *testing.T) {
+		router := clientIPRouter(t, proxies, ForwardedHeaderXForwardedFor)
+
+		assert.Equal(t, "203.0.113.7", clientIP(router, "203.0.113.7:1234", map[string]string{
+			"X-Forwarded-For": "1.2.3.4",
+		}))
+	})
+
+	t.Run("should use X-Forwarded-For from a trusted proxy", func(t *testing.T) {
+		router := clientIPRouter(t, proxies, ForwardedHeaderXForwardedFor)
+
+		assert.Equal(t, "198.51.100.1", clientIP(router, "10.0.0.5:1234", map[string]string{
+			"X-Forwarded-For": "198.51.100.1",
+		}))
+		assert.Equal(t, "198.51.100.1", clientIP(router, "192.168.1.10:1234", map[string]string{
+			"X-Forwarded-For": "198.51.100.1",
+		}))
+	})
+
+	t.Run("should skip trusted hops and ignore addresses prepended by the client", func(t *testing.T) {
+		router := clientIPRouter(t, proxies, ForwardedHeaderXForwardedFor)
+
+		// The client sent 1.2.3.4; the edge proxy appended the real address, then an internal hop
+		assert.Equal(t, "198.51.100.1", clientIP(router, "10.0.0.5:1234", map[string]string{
+			"X-Forwarded-For": "1.2.3.4, 198.51.100.1, 10.0.0.9",
+		}))
+	})
+
+	t.Run("should fall back to the peer when the header is malformed", func(t *testing.T) {
+		router := clientIPRouter(t, proxies, ForwardedHeaderXForwardedFor)
+
+		assert.Equal(t, "10.0.0.5", clientIP(router, "10.0.0.5:1234", map[string]string{
+			"X-Forwarded-For": "not-an-ip",
+		}))
+	})
+
+	t.Run("should only honor the configured header", func(t *testing.T) {
+		router := clientIPRouter(t, proxies, ForwardedHeaderXRealIP)
+
+		assert.Equal(t, "10.0.0.5", clientIP(router, "10.0.0.5:1234", map[string]string{
+			"X-Forwarded-For": "1.2.3.4",
+		}))
+		assert.Equal(t, "198.51.100.1", clientIP(router, "10.0.0.5:1234", map[string]string{
+			"X-Forwarded-For": "1.2.3.4",
+			"X-Real-IP":       "198.51.100.1",
+		}))
+	})
+
+	t.Run("should ignore X-Real-IP sent by an untrusted client", func(t *testing.T) {
+		router := clientIPRouter(t, proxies, ForwardedHeaderXRealIP)
+
+		assert.Equal(t, "203.0.113.7", clientIP(router, "203.0.113.7:1234", map[string]string{
+			"X-Real-IP": "1.2.3.4",
+		}))
+	})
+
+	t.Run("should read the for= parameters of Forwarded", func(t *testing.T) {
+		router := clientIPRouter(t, proxies, ForwardedHeaderForwarded)
+
+		assert.Equal(t, "198.51.100.1", clientIP(router, "10.0.0.5:1234", map[string]string{
+			"Forwarded": "for=1.2.3.4, for=198.51.100.1;proto=https;by=10.0.0.9",
+		}))
+		assert.Equal(t, "2001:db8:cafe::17", clientIP(router, "10.0.0.5:1234", map[string]string{
+			"Forwarded": `for="[2001:db8:cafe::17]:4711"`,
+		}))
+		assert.Equal(t, "198.51.100.1", clientIP(router, "10.0.0.5:1234", map[string]string{
+			"Forwarded": `For="198.51.100.1:8443"`,
+		}))
+	})
+
+	t.Run("should ignore Forwarded sent by an untrusted client", func(t *testing.T) {
+		router := clientIPRouter(t, proxies, ForwardedHeaderForwarded)
+
+		assert.Equal(t, "203.0.113.7", clientIP(router, "203.0.113.7:1234", map[string]string{
+			"Forwarded": "for=1.2.3.4",
+		}))
+	})
+
+	t.Run("should not trust the parsed header when sent by a client", func(t *testing.T) {
+		router := clientIPRouter(t, proxies, ForwardedHeaderForwarded)
+
+		assert.Equal(t, "10.0.0.5", clientIP(router, "10.0.0.5:1234", map[string]string{
+			forwardedForHeader: "1.2.3.4",
+		}))
+		assert.Equal(t, "198.51.100.1", clientIP(router, "10.0.0.5:1234", map[string]string{
+			forwardedForHeader: "1.2.3.4",
+			"Forwarded":        "for=198.51.100.1",
+		}))
+	})
+
+	t.Run("should stop at obfuscated Forwarded nodes", func(t *testing.T) {
+		router := clientIPRouter(t, proxies, ForwardedHeaderForwarded)
+
+		assert.Equal(t, "10.0.0.5", clientIP(router, "10.0.0.5:1234", map[string]string{
+			"Forwarded": "for=_hidden",
+		}))
+	})
+
+	t.Run("should reject invalid configuration", func(t *testing.T) {
+		err := ConfigureTrustedProxies(gin.New(), []string{"10.0.0.0/33"}, ForwardedHeaderXForwardedFor)
+		assert.ErrorContains(t, err, "invalid trusted proxy")
+
+		err = ConfigureTrustedProxies(gin.New(), nil, "CF-Connecting-IP")
+		assert.ErrorContains(t, err, "unsupported forwarded header")
+	})
+}
+
+func TestParseForwardedFor(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"for=192.0.2.60;proto=http;by=203.0.113.43", []string{"192.0.2.60"}},
+		{`for=192.0.2.43, for="[2001:db8:cafe::17]"`, []string{"192.0.2.43", "2001:db8:cafe::17"}},
+		{`for="192.0.2.43:47011"`, []string{"192.0.2.43"}},
+		{"proto=https;by=203.0.113.43", nil},
+		{"for=unknown", []string{"unknown"}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, parseForwardedFor(tt.value), tt.value)
+	}
+}
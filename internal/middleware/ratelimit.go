@@ -1,58 +1,92 @@
 package middleware
 
 import (
-	"net/http"
-	"sync"
+	"context"
+	"fmt"
+	"io"
+	"myapp/pkg/config"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/time/rate"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
 )
 
-// RateLimiter holds rate limiters per IP
-type RateLimiter struct {
-	limiters map[string]*rate.Limiter
-	mu       sync.RWMutex
-	r        rate.Limit
-	b        int
+const (
+	// LimiterStoreMemory keeps limiter state in each replica's memory
+	LimiterStoreMemory = "memory"
+	// LimiterStoreRedis shares limiter state between replicas through a Redis-protocol server
+	LimiterStoreRedis = "redis"
+)
+
+// Decision is the outcome of a LimiterStore check
+type Decision struct {
+	Allowed bool
+	// Limit is the number of requests admitted at once
+	Limit int
+	// Remaining is the number of requests admitted right now
+	Remaining int
+	// Reset is the time until the full limit is available again
+	Reset time.Duration
+	// RetryAfter is the time until the next request is admitted; zero when allowed
+	RetryAfter time.Duration
 }
 
-// NewRateLimiter creates a new rate limiter
-func NewRateLimiter(requestsPerSecond float64, burst int) *RateLimiter {
-	return &RateLimiter{
-		limiters: make(map[string]*rate.Limiter),
-		r:        rate.Limit(requestsPerSecond),
-		b:        burst,
-	}
+// LimiterStore decides whether a request identified by key may proceed
+type LimiterStore interface {
+	// Allow consumes one request for key and reports whether it is within the limit
+	Allow(ctx context.Context, key string) (Decision, error)
 }
 
-// GetLimiter returns a rate limiter for a given IP
-func (rl *RateLimiter) GetLimiter(ip string) *rate.Limiter {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
+// storeFactory creates the store of one policy
+type storeFactory func(policy string, requestsPerSecond float64, burst int) LimiterStore
 
-	limiter, exists := rl.limiters[ip]
-	if !exists {
-		limiter = rate.NewLimiter(rl.r, rl.b)
-		rl.limiters[ip] = limiter
+// newStoreFactory returns the factory for the configured store, defaulting to
+// memory, and the shared connection to close on shutdown, if any. Redis stores
+// share one client and keep each policy under its own key prefix.
+func newStoreFactory(cfg config.RateLimitConfig) (storeFactory, io.Closer, error) {
+	switch cfg.Store {
+	case LimiterStoreMemory, "":
+		return func(policy string, requestsPerSecond float64, burst int) LimiterStore {
+			return NewRateLimiter(requestsPerSecond, burst).
+				WithName(policy).
+				WithMaxKeys(cfg.MaxKeys).
+				WithIdleTTL(time.Duration(cfg.IdleTTL) * time.Second).
+				StartJanitor()
+		}, nil, nil
+	case LimiterStoreRedis:
+		if cfg.Redis.Addr == "" {
+			return nil, nil, fmt.Errorf("rate_limit.redis.addr is required for the %q store", LimiterStoreRedis)
+		}
+		timeout := time.Duration(cfg.Redis.Timeout) * time.Millisecond
+		client := redis.NewClient(&redis.Options{
+			Addr:         cfg.Redis.Addr,
+			Password:     cfg.Redis.Password,
+			DB:           cfg.Redis.DB,
+			DialTimeout:  timeout,
+			ReadTimeout:  timeout,
+			WriteTimeout: timeout,
+			// Fail fast and let the request through instead of retrying
+			MaxRetries: -1,
+		})
+		return func(policy string, requestsPerSecond float64, burst int) LimiterStore {
+			return NewRedisLimiterStore(client, cfg.Redis.KeyPrefix+policy+":", requestsPerSecond, burst)
+		}, client, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported rate limit store %q", cfg.Store)
 	}
-
-	return limiter
 }
 
 // RateLimitMiddleware limits requests per IP address
 func RateLimitMiddleware(requestsPerSecond float64, burst int) gin.HandlerFunc {
-	limiter := NewRateLimiter(requestsPerSecond, burst)
-
-	return func(c *gin.Context) {
-		ip := c.ClientIP()
-
-		if !limiter.GetLimiter(ip).Allow() {
-			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
-				"error": "rate limit exceeded",
-			})
-			return
-		}
+	return RateLimitMiddlewareWithStore(NewRateLimiter(requestsPerSecond, burst), zap.NewNop())
+}
 
-		c.Next()
+// RateLimitMiddlewareWithStore limits requests per IP address using the given store
+func RateLimitMiddlewareWithStore(store LimiterStore, logger *zap.Logger) gin.HandlerFunc {
+	policies := &RateLimitPolicies{
+		fallback: &RateLimitPolicy{Name: DefaultRateLimitPolicy, key: RateLimitKeyIP, store: store},
+		logger:   logger,
 	}
+	return policies.Middleware()
 }
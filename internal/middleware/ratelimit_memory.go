@@ -0,0 +1,230 @@
+package middleware
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// rateLimiterShards is the number of independently locked maps buckets are spread over
+const rateLimiterShards = 32
+
+// RateLimiter holds a token bucket per key in memory. Keys are spread over
+// shards with their own lock; buckets idle for the idle TTL are evicted by a
+// janitor and, when a maximum is set, the least recently used bucket of a
+// full shard makes room for a new key.
+type RateLimiter struct {
+	limiters []*limiterShard
+	r        rate.Limit
+	b        int
+	name     string
+	maxKeys  int
+	idleTTL  time.Duration
+	keys     atomic.Int64
+	now      func() time.Time
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+type limiterShard struct {
+	mu       sync.RWMutex
+	limiters map[string]*limiterEntry
+	capacity int
+}
+
+type limiterEntry struct {
+	limiter *rate.Limiter
+	// lastSeen is the last use in Unix nanoseconds
+	lastSeen atomic.Int64
+}
+
+// NewRateLimiter creates a new rate limiter
+func NewRateLimiter(requestsPerSecond float64, burst int) *RateLimiter {
+	rl := &RateLimiter{
+		r:   rate.Limit(requestsPerSecond),
+		b:   burst,
+		now: time.Now,
+	}
+	rl.resetShards(rateLimiterShards, 0)
+	return rl
+}
+
+// WithName labels the tracked keys gauge with the policy name
+func (rl *RateLimiter) WithName(policy string) *RateLimiter {
+	rl.name = policy
+	return rl
+}
+
+// WithMaxKeys bounds the number of tracked keys; zero means unbounded. Call it before first use.
+func (rl *RateLimiter) WithMaxKeys(maxKeys int) *RateLimiter {
+	rl.maxKeys = max(maxKeys, 0)
+	shards := rateLimiterShards
+	if rl.maxKeys > 0 {
+		// Every shard gets an equal share so the total never exceeds maxKeys
+		shards = min(shards, rl.maxKeys)
+		rl.resetShards(shards, rl.maxKeys/shards)
+	} else {
+		rl.resetShards(shards, 0)
+	}
+	return rl
+}
+
+// WithIdleTTL sets how long an unused bucket is kept. Buckets are kept at
+// least until they have refilled, so evicting one never hands out extra tokens.
+func (rl *RateLimiter) WithIdleTTL(ttl time.Duration) *RateLimiter {
+	if ttl > 0 && rl.r > 0 && rl.r != rate.Inf {
+		ttl = max(ttl, tokenDuration(float64(rl.b), rl.r))
+	}
+	rl.idleTTL = ttl
+	return rl
+}
+
+// StartJanitor evicts idle buckets in the background until Close is called.
+// It does nothing without an idle TTL.
+func (rl *RateLimiter) StartJanitor() *RateLimiter {
+	if rl.idleTTL <= 0 || rl.stop != nil {
+		return rl
+	}
+	rl.stop = make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(max(rl.idleTTL/2, time.Second))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				rl.evictIdle()
+			case <-rl.stop:
+				return
+			}
+		}
+	}()
+	return rl
+}
+
+// Close stops the janitor
+func (rl *RateLimiter) Close() error {
+	rl.stopOnce.Do(func() {
+		if rl.stop != nil {
+			close(rl.stop)
+		}
+	})
+	return nil
+}
+
+// Len returns the number of tracked keys
+func (rl *RateLimiter) Len() int {
+	return int(rl.keys.Load())
+}
+
+func (rl *RateLimiter) resetShards(n, capacity int) {
+	rl.limiters = make([]*limiterShard, n)
+	for i := range rl.limiters {
+		rl.limiters[i] = &limiterShard{limiters: make(map[string]*limiterEntry), capacity: capacity}
+	}
+}
+
+func (rl *RateLimiter) shard(key string) *limiterShard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return rl.limiters[h.Sum32()%uint32(len(rl.limiters))]
+}
+
+// GetLimiter returns a rate limiter for a given IP
+func (rl *RateLimiter) GetLimiter(ip string) *rate.Limiter {
+	now := rl.now().UnixNano()
+	shard := rl.shard(ip)
+
+	shard.mu.RLock()
+	entry, exists := shard.limiters[ip]
+	shard.mu.RUnlock()
+	if exists {
+		entry.lastSeen.Store(now)
+		return entry.limiter
+	}
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if entry, exists = shard.limiters[ip]; exists {
+		entry.lastSeen.Store(now)
+		return entry.limiter
+	}
+	if shard.capacity > 0 && len(shard.limiters) >= shard.capacity {
+		shard.evictOldest()
+		rl.keys.Add(-1)
+	}
+	entry = &limiterEntry{limiter: rate.NewLimiter(rl.r, rl.b)}
+	entry.lastSeen.Store(now)
+	shard.limiters[ip] = entry
+	rl.keys.Add(1)
+	rl.reportKeys()
+
+	return entry.limiter
+}
+
+// evictOldest removes the least recently used bucket; the caller holds s.mu
+func (s *limiterShard) evictOldest() {
+	var oldestKey string
+	oldest := int64(math.MaxInt64)
+	for key, entry := range s.limiters {
+		if seen := entry.lastSeen.Load(); seen < oldest {
+			oldestKey, oldest = key, seen
+		}
+	}
+	delete(s.limiters, oldestKey)
+}
+
+// evictIdle removes the buckets unused for the idle TTL
+func (rl *RateLimiter) evictIdle() {
+	cutoff := rl.now().Add(-rl.idleTTL).UnixNano()
+	for _, shard := range rl.limiters {
+		shard.mu.Lock()
+		for key, entry := range shard.limiters {
+			if entry.lastSeen.Load() <= cutoff {
+				delete(shard.limiters, key)
+				rl.keys.Add(-1)
+			}
+		}
+		shard.mu.Unlock()
+	}
+	rl.reportKeys()
+}
+
+func (rl *RateLimiter) reportKeys() {
+	if rl.name != "" {
+		rateLimitTrackedKeys.WithLabelValues(rl.name).Set(float64(rl.keys.Load()))
+	}
+}
+
+// Allow implements LimiterStore with a token bucket per key
+func (rl *RateLimiter) Allow(_ context.Context, key string) (Decision, error) {
+	limiter := rl.GetLimiter(key)
+	now := rl.now()
+	allowed := limiter.AllowN(now, 1)
+	tokens := limiter.TokensAt(now)
+
+	d := Decision{
+		Allowed:   allowed,
+		Limit:     rl.b,
+		Remaining: max(int(math.Floor(tokens)), 0),
+	}
+	if rl.r > 0 && rl.r != rate.Inf {
+		d.Reset = tokenDuration(float64(rl.b)-tokens, rl.r)
+		if !allowed {
+			d.RetryAfter = tokenDuration(1-tokens, rl.r)
+		}
+	}
+	return d, nil
+}
+
+// tokenDuration is the time the bucket needs to refill n tokens
+func tokenDuration(n float64, r rate.Limit) time.Duration {
+	if n <= 0 {
+		return 0
+	}
+	return time.Duration(n / float64(r) * float64(time.Second))
+}
@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock lets tests move the limiter's time forward
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestRateLimiterEviction(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should evict buckets idle for the TTL", func(t *testing.T) {
+		clock := &fakeClock{now: time.Now()}
+		rl := NewRateLimiter(10, 5).WithIdleTTL(time.Minute)
+		rl.now = clock.Now
+
+		rl.GetLimiter("192.168.1.1")
+		clock.Advance(30 * time.Second)
+		rl.GetLimiter("192.168.1.2")
+		assert.Equal(t, 2, rl.Len())
+
+		clock.Advance(45 * time.Second)
+		rl.evictIdle()
+
+		assert.Equal(t, 1, rl.Len())
+		d, err := rl.Allow(ctx, "192.168.1.2")
+		require.NoError(t, err)
+		assert.Equal(t, 4, d.Remaining, "the recently used bucket is kept")
+	})
+
+	t.Run("should keep buckets until they have refilled", func(t *testing.T) {
+		clock := &fakeClock{now: time.Now()}
+		// A bucket needs 100 seconds to refill, longer than the TTL
+		rl := NewRateLimiter(0.1, 10).WithIdleTTL(time.Second)
+		rl.now = clock.Now
+
+		for range 10 {
+			rl.Allow(ctx, "192.168.1.1")
+		}
+		clock.Advance(time.Minute)
+		rl.evictIdle()
+
+		// 6 tokens refilled; a new bucket would have started with 10
+		d, _ := rl.Allow(ctx, "192.168.1.1")
+		assert.Equal(t, 5, d.Remaining, "eviction must not hand out a fresh burst")
+	})
+
+	t.Run("should evict idle buckets in the background", func(t *testing.T) {
+		rl := NewRateLimiter(1000, 1).WithIdleTTL(time.Millisecond).StartJanitor()
+		defer rl.Close()
+
+		rl.GetLimiter("192.168.1.1")
+		assert.Eventually(t, func() bool { return rl.Len() == 0 }, 3*time.Second, 50*time.Millisecond)
+	})
+
+	t.Run("should stop the janitor on close", func(t *testing.T) {
+		rl := NewRateLimiter(10, 5).WithIdleTTL(time.Minute).StartJanitor()
+		require.NoError(t, rl.Close())
+		require.NoError(t, rl.Close())
+	})
+}
+
+func TestRateLimiterMaxKeys(t *testing.T) {
+	t.Run("should never track more than the maximum", func(t *testing.T) {
+		rl := NewRateLimiter(10, 5).WithMaxKeys(100)
+
+		for i := range 1000 {
+			rl.GetLimiter(fmt.Sprintf("10.0.%d.%d", i/256, i%256))
+		}
+
+		assert.LessOrEqual(t, rl.Len(), 100)
+		tracked := 0
+		for _, shard := range rl.limiters {
+			tracked += len(shard.limiters)
+		}
+		assert.Equal(t, rl.Len(), tracked)
+	})
+
+	t.Run("should evict the least recently used bucket", func(t *testing.T) {
+		clock := &fakeClock{now: time.Now()}
+		// 32 shards with room for two buckets each
+		rl := NewRateLimiter(10, 5).WithMaxKeys(64)
+		rl.now = clock.Now
+		require.Len(t, rl.limiters, 32)
+
+		// Find three keys that land in the same shard
+		var keys []string
+		target := rl.shard("seed")
+		for i := 0; len(keys) < 3; i++ {
+			if key := fmt.Sprintf("10.0.0.%d", i); rl.shard(key) == target {
+				keys = append(keys, key)
+			}
+		}
+
+		first := rl.GetLimiter(keys[0])
+		clock.Advance(time.Second)
+		rl.GetLimiter(keys[1])
+		clock.Advance(time.Second)
+		assert.Same(t, first, rl.GetLimiter(keys[0]), "touching a key keeps it")
+		clock.Advance(time.Second)
+		rl.GetLimiter(keys[2])
+
+		_, kept := target.limiters[keys[0]]
+		_, evicted := target.limiters[keys[1]]
+		assert.True(t, kept)
+		assert.False(t, evicted)
+	})
+
+	t.Run("should be unbounded by default", func(t *testing.T) {
+		rl := NewRateLimiter(10, 5)
+		for i := range 1000 {
+			rl.GetLimiter(fmt.Sprintf("key-%d", i))
+		}
+		assert.Equal(t, 1000, rl.Len())
+	})
+}
+
+func TestRateLimiterTrackedKeysGauge(t *testing.T) {
+	t.Run("should report tracked keys per policy", func(t *testing.T) {
+		clock := &fakeClock{now: time.Now()}
+		rl := NewRateLimiter(10, 5).WithName("gauge-test").WithIdleTTL(time.Minute)
+		rl.now = clock.Now
+
+		rl.GetLimiter("192.168.1.1")
+		rl.GetLimiter("192.168.1.2")
+		assert.Equal(t, 2.0, testutil.ToFloat64(rateLimitTrackedKeys.WithLabelValues("gauge-test")))
+
+		clock.Advance(2 * time.Minute)
+		rl.evictIdle()
+		assert.Equal(t, 0.0, testutil.ToFloat64(rateLimitTrackedKeys.WithLabelValues("gauge-test")))
+	})
+}
+
+func TestRateLimiterConcurrency(t *testing.T) {
+	t.Run("should stay consistent under concurrent use and eviction", func(t *testing.T) {
+		rl := NewRateLimiter(1000, 10).WithMaxKeys(64)
+		var wg sync.WaitGroup
+		for g := range 16 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range 500 {
+					rl.Allow(context.Background(), fmt.Sprintf("10.%d.%d.1", g, i%100))
+				}
+			}()
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				rl.evictIdle()
+			}
+		}()
+		wg.Wait()
+
+		tracked := 0
+		for _, shard := range rl.limiters {
+			tracked += len(shard.limiters)
+		}
+		assert.Equal(t, tracked, rl.Len())
+		assert.LessOrEqual(t, tracked, 64)
+	})
+}
@@ -0,0 +1,311 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"myapp/pkg/config"
+	"myapp/pkg/problem"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// RateLimitKeyIP counts requests per client IP
+	RateLimitKeyIP = "ip"
+	// RateLimitKeyUserID counts requests per authenticated user
+	RateLimitKeyUserID = "user_id"
+	// RateLimitKeyAPIKey counts requests per X-API-Key header, falling back to the client IP
+	RateLimitKeyAPIKey = "api_key"
+	// RateLimitKeyGlobal counts all requests together, e.g. to protect an expensive endpoint
+	RateLimitKeyGlobal = "global"
+
+	// DefaultRateLimitPolicy names the policy built from rate_limit.requests_per_second and rate_limit.burst
+	DefaultRateLimitPolicy = "default"
+
+	// APIKeyHeader carries the API key used by api_key policies
+	APIKeyHeader = "X-API-Key"
+
+	// rateLimitPolicyKey marks a request as counted, holding the policy name
+	rateLimitPolicyKey = "rate_limit_policy"
+)
+
+// RateLimitPolicy limits the requests matching a route, counted separately per key
+type RateLimitPolicy struct {
+	Name   string
+	method string
+	path   string
+	prefix bool
+	key    string
+	store  LimiterStore
+}
+
+// matches reports whether the policy applies to a request for the route template fullPath
+func (p *RateLimitPolicy) matches(method, fullPath string) bool {
+	if p.method != "" && p.method != method {
+		return false
+	}
+	if p.prefix {
+		return fullPath == p.path || strings.HasPrefix(fullPath, p.path+"/")
+	}
+	return fullPath == p.path
+}
+
+// keyFor returns the bucket of the request; false while the user of a user_id policy is not known
+func (p *RateLimitPolicy) keyFor(c *gin.Context) (string, bool) {
+	switch p.key {
+	case RateLimitKeyUserID:
+		userID, exists := c.Get("user_id")
+		if !exists {
+			return "", false
+		}
+		return fmt.Sprintf("user:%v", userID), true
+	case RateLimitKeyAPIKey:
+		if apiKey := c.GetHeader(APIKeyHeader); apiKey != "" {
+			// Never keep API keys in the store
+			sum := sha256.Sum256([]byte(apiKey))
+			return "key:" + hex.EncodeToString(sum[:16]), true
+		}
+		return "ip:" + c.ClientIP(), true
+	case RateLimitKeyGlobal:
+		return "global", true
+	default:
+		return "ip:" + c.ClientIP(), true
+	}
+}
+
+// RateLimitPolicies applies the first configured policy whose route matches a
+// request, or the default policy when none does
+type RateLimitPolicies struct {
+	policies []*RateLimitPolicy
+	fallback *RateLimitPolicy
+	// conn is the connection shared by the stores, closed by Close
+	conn   io.Closer
+	logger *zap.Logger
+}
+
+// NewRateLimitPolicies builds the default policy and rate_limit.policies on the configured store
+func NewRateLimitPolicies(cfg config.RateLimitConfig, logger *zap.Logger) (_ *RateLimitPolicies, err error) {
+	if err := validateRate(DefaultRateLimitPolicy, cfg.RequestsPerSecond, cfg.Burst); err != nil {
+		return nil, err
+	}
+	newStore, conn, err := newStoreFactory(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &RateLimitPolicies{
+		fallback: &RateLimitPolicy{
+			Name:  DefaultRateLimitPolicy,
+			key:   RateLimitKeyIP,
+			store: newStore(DefaultRateLimitPolicy, cfg.RequestsPerSecond, cfg.Burst),
+		},
+		conn:   conn,
+		logger: logger,
+	}
+	// Release the stores created so far when a policy is invalid
+	defer func() {
+		if err != nil {
+			p.Close()
+		}
+	}()
+
+	seen := map[string]bool{DefaultRateLimitPolicy: true}
+	for i, pc := range cfg.Policies {
+		if pc.Name == "" {
+			return nil, fmt.Errorf("rate_limit.policies[%d]: name is required", i)
+		}
+		if seen[pc.Name] {
+			return nil, fmt.Errorf("rate_limit.policies[%d]: duplicate policy name %q", i, pc.Name)
+		}
+		seen[pc.Name] = true
+
+		policy, err := parseRoute(pc.Route)
+		if err != nil {
+			return nil, fmt.Errorf("rate_limit.policies[%d] (%s): %w", i, pc.Name, err)
+		}
+		switch pc.Key {
+		case RateLimitKeyIP, RateLimitKeyUserID, RateLimitKeyAPIKey, RateLimitKeyGlobal:
+			policy.key = pc.Key
+		case "":
+			policy.key = RateLimitKeyIP
+		default:
+			return nil, fmt.Errorf("rate_limit.policies[%d] (%s): unsupported key %q", i, pc.Name, pc.Key)
+		}
+		if err := validateRate(pc.Name, pc.RequestsPerSecond, pc.Burst); err != nil {
+			return nil, fmt.Errorf("rate_limit.policies[%d]: %w", i, err)
+		}
+		policy.Name = pc.Name
+		policy.store = newStore(pc.Name, pc.RequestsPerSecond, pc.Burst)
+		p.policies = append(p.policies, policy)
+	}
+
+	return p, nil
+}
+
+// parseRoute parses "[METHOD ]PATH" where PATH is a route template such as
+// /v1/users/:id; a trailing /* also matches every route below it
+func parseRoute(route string) (*RateLimitPolicy, error) {
+	fields := strings.Fields(route)
+	policy := &RateLimitPolicy{}
+	switch len(fields) {
+	case 1:
+		policy.path = fields[0]
+	case 2:
+		policy.method = strings.ToUpper(fields[0])
+		policy.path = fields[1]
+	default:
+		return nil, fmt.Errorf("route must be \"[METHOD] /path\", got %q", route)
+	}
+	if !strings.HasPrefix(policy.path, "/") {
+		return nil, fmt.Errorf("route path must start with /, got %q", policy.path)
+	}
+	if rest, ok := strings.CutSuffix(policy.path, "/*"); ok {
+		policy.path, policy.prefix = rest, true
+	}
+	return policy, nil
+}
+
+func validateRate(name string, requestsPerSecond float64, burst int) error {
+	if requestsPerSecond <= 0 {
+		return fmt.Errorf("rate limit policy %q: requests_per_second must be positive", name)
+	}
+	if burst < 1 {
+		return fmt.Errorf("rate limit policy %q: burst must be at least 1", name)
+	}
+	return nil
+}
+
+// Close stops the background work of the policies' stores and closes the
+// connection they share
+func (p *RateLimitPolicies) Close() {
+	for _, policy := range p.policies {
+		closeStore(policy.store)
+	}
+	closeStore(p.fallback.store)
+	if p.conn != nil {
+		if err := p.conn.Close(); err != nil {
+			p.logger.Warn("Failed to close rate limit store connection", zap.Error(err))
+		}
+	}
+}
+
+func closeStore(store LimiterStore) {
+	if closer, ok := store.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+// match returns the policy for the request
+func (p *RateLimitPolicies) match(c *gin.Context) *RateLimitPolicy {
+	for _, policy := range p.policies {
+		if policy.matches(c.Request.Method, c.FullPath()) {
+			return policy
+		}
+	}
+	return p.fallback
+}
+
+// Middleware enforces the policies. Install it on every route; on routes behind
+// authentication install it after the authentication middleware, with
+// DeferredMiddleware before it. Each request is counted once, by the first
+// installation that knows its key. A request whose user is still unknown is
+// counted per client IP, so routes without authentication cannot bypass
+// user_id policies.
+// Requests are let through when the store fails so that an outage of a shared
+// store does not take the API down with it.
+func (p *RateLimitPolicies) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, counted := c.Get(rateLimitPolicyKey); counted {
+			c.Next()
+			return
+		}
+
+		policy := p.match(c)
+		key, ok := policy.keyFor(c)
+		if !ok {
+			key = "ip:" + c.ClientIP()
+		}
+		if !p.allow(c, policy, key) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// DeferredMiddleware enforces the policies on routes behind authentication.
+// Install it before the authentication middleware and Middleware after it:
+// requests are counted right away unless a user_id policy applies, which is
+// left to Middleware until the user is known. If the authentication
+// middleware rejects the request, it is counted per client IP afterwards.
+func (p *RateLimitPolicies) DeferredMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		policy := p.match(c)
+		if key, ok := policy.keyFor(c); ok {
+			if p.allow(c, policy, key) {
+				c.Next()
+			}
+			return
+		}
+
+		c.Next()
+		if _, counted := c.Get(rateLimitPolicyKey); !counted && c.IsAborted() {
+			// The response is written already, so the request is only counted
+			p.count(c, policy, "ip:"+c.ClientIP())
+		}
+	}
+}
+
+// allow counts the request and rejects it when the policy is exhausted
+func (p *RateLimitPolicies) allow(c *gin.Context, policy *RateLimitPolicy, key string) bool {
+	decision, ok := p.count(c, policy, key)
+	if !ok {
+		return true
+	}
+
+	setRateLimitHeaders(c.Writer.Header(), decision)
+	if !decision.Allowed {
+		rateLimitRejectionsTotal.WithLabelValues(policy.Name).Inc()
+		problem.Render(c, problem.TooManyRequests(problem.CodeRateLimitExceeded, "rate limit exceeded"))
+		return false
+	}
+	return true
+}
+
+// count marks the request as counted and takes a token from the policy's
+// bucket for key; false when the store is unavailable
+func (p *RateLimitPolicies) count(c *gin.Context, policy *RateLimitPolicy, key string) (Decision, bool) {
+	c.Set(rateLimitPolicyKey, policy.Name)
+
+	decision, err := policy.store.Allow(c.Request.Context(), key)
+	if err != nil {
+		p.logger.Warn("Rate limit store unavailable, allowing request",
+			zap.String("policy", policy.Name),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Error(err),
+		)
+		return Decision{}, false
+	}
+	return decision, true
+}
+
+// setRateLimitHeaders writes the RateLimit-* fields, plus Retry-After on rejection
+func setRateLimitHeaders(h http.Header, d Decision) {
+	h.Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(d.Reset)))
+	if !d.Allowed {
+		h.Set("Retry-After", strconv.Itoa(max(ceilSeconds(d.RetryAfter), 1)))
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,301 @@
+package middleware
+
+import (
+	"context"
+	"myapp/pkg/config"
+	"myapp/pkg/problem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newPolicyRouter(t *testing.T, cfg config.RateLimitConfig) *gin.Engine {
+	t.Helper()
+	policies, err := NewRateLimitPolicies(cfg, zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(policies.Close)
+	rateLimit := policies.Middleware()
+
+	// Mirrors SetupRoutes: public routes, and protected routes counted around authentication
+	router := gin.New()
+	public := router.Group("/", rateLimit)
+	ok := func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"message": "success"}) }
+	public.GET("/info", ok)
+	public.GET("/health", ok)
+	public.POST("/v1/login", ok)
+	public.GET("/v1/reports/daily", ok)
+
+	protected := router.Group("/v1")
+	protected.Use(policies.DeferredMiddleware(), func(c *gin.Context) {
+		if c.GetHeader("X-Test-Reject") != "" {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		if id := c.GetHeader("X-Test-User"); id != "" {
+			c.Set("user_id", id)
+		}
+		c.Next()
+	}, rateLimit)
+	protected.GET("/users/:id", ok)
+	return router
+}
+
+func doRequest(router *gin.Engine, method, path string, headers map[string]string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimitPolicies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	base := config.RateLimitConfig{RequestsPerSecond: 100, Burst: 100}
+
+	t.Run("should apply the first matching policy instead of the default", func(t *testing.T) {
+		cfg := base
+		cfg.Policies = []config.RateLimitPolicyConfig{
+			{Name: "login", Route: "POST /v1/login", Key: "ip", RequestsPerSecond: 1, Burst: 2},
+			{Name: "v1", Route: "/v1/*", Key: "ip", RequestsPerSecond: 1, Burst: 50},
+		}
+		router := newPolicyRouter(t, cfg)
+
+		assert.Equal(t, http.StatusOK, doRequest(router, "POST", "/v1/login", nil).Code)
+		assert.Equal(t, http.StatusOK, doRequest(router, "POST", "/v1/login", nil).Code)
+		w := doRequest(router, "POST", "/v1/login", nil)
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Body.String(), `"code":"rate_limit_exceeded"`)
+
+		// Other routes are counted by their own policies
+		assert.Equal(t, "50", doRequest(router, "GET", "/v1/reports/daily", nil).Header().Get("RateLimit-Limit"))
+		assert.Equal(t, "100", doRequest(router, "GET", "/health", nil).Header().Get("RateLimit-Limit"))
+	})
+
+	t.Run("should match the method of a route", func(t *testing.T) {
+		cfg := base
+		cfg.Policies = []config.RateLimitPolicyConfig{
+			{Name: "info", Route: "POST /info", Key: "global", RequestsPerSecond: 1, Burst: 1},
+		}
+		router := newPolicyRouter(t, cfg)
+
+		assert.Equal(t, "100", doRequest(router, "GET", "/info", nil).Header().Get("RateLimit-Limit"))
+	})
+
+	t.Run("should set RateLimit headers and Retry-After on rejection", func(t *testing.T) {
+		cfg := base
+		cfg.Policies = []config.RateLimitPolicyConfig{
+			{Name: "info", Route: "GET /info", Key: "global", RequestsPerSecond: 0.5, Burst: 2},
+		}
+		router := newPolicyRouter(t, cfg)
+
+		w := doRequest(router, "GET", "/info", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "2", w.Header().Get("RateLimit-Reset"))
+		assert.Empty(t, w.Header().Get("Retry-After"))
+
+		doRequest(router, "GET", "/info", nil)
+		w = doRequest(router, "GET", "/info", nil)
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "2", w.Header().Get("Retry-After"))
+	})
+
+	t.Run("should share a global bucket between clients", func(t *testing.T) {
+		cfg := base
+		cfg.Policies = []config.RateLimitPolicyConfig{
+			{Name: "info", Route: "GET /info", Key: "global", RequestsPerSecond: 1, Burst: 1},
+		}
+		router := newPolicyRouter(t, cfg)
+
+		assert.Equal(t, http.StatusOK, doRequest(router, "GET", "/info", map[string]string{"X-Forwarded-For": "10.0.0.1"}).Code)
+		assert.Equal(t, http.StatusTooManyRequests, doRequest(router, "GET", "/info", map[string]string{"X-Forwarded-For": "10.0.0.2"}).Code)
+	})
+
+	t.Run("should count user_id policies per authenticated user", func(t *testing.T) {
+		cfg := base
+		cfg.Policies = []config.RateLimitPolicyConfig{
+			{Name: "users", Route: "GET /v1/users/:id", Key: "user_id", RequestsPerSecond: 1, Burst: 1},
+		}
+		router := newPolicyRouter(t, cfg)
+
+		alice := map[string]string{"X-Test-User": "1"}
+		bob := map[string]string{"X-Test-User": "2"}
+		assert.Equal(t, http.StatusOK, doRequest(router, "GET", "/v1/users/1", alice).Code)
+		assert.Equal(t, http.StatusTooManyRequests, doRequest(router, "GET", "/v1/users/1", alice).Code)
+		assert.Equal(t, http.StatusOK, doRequest(router, "GET", "/v1/users/2", bob).Code)
+	})
+
+	t.Run("should count user_id policies per client IP when there is no user", func(t *testing.T) {
+		cfg := base
+		cfg.Policies = []config.RateLimitPolicyConfig{
+			{Name: "per-user", Route: "GET /*", Key: "user_id", RequestsPerSecond: 1, Burst: 1},
+		}
+		router := newPolicyRouter(t, cfg)
+
+		// /info has no authentication; without X-Test-User nobody is authenticated on /v1/users/:id
+		assert.Equal(t, http.StatusOK, doRequest(router, "GET", "/info", nil).Code)
+		assert.Equal(t, http.StatusTooManyRequests, doRequest(router, "GET", "/info", nil).Code)
+		assert.Equal(t, http.StatusTooManyRequests, doRequest(router, "GET", "/v1/users/1", nil).Code)
+		assert.Equal(t, http.StatusOK, doRequest(router, "GET", "/v1/users/1", map[string]string{"X-Test-User": "1"}).Code)
+	})
+
+	t.Run("should count requests rejected by authentication per client IP", func(t *testing.T) {
+		cfg := base
+		cfg.Policies = []config.RateLimitPolicyConfig{
+			{Name: "users", Route: "GET /v1/users/:id", Key: "user_id", RequestsPerSecond: 1, Burst: 1},
+		}
+		router := newPolicyRouter(t, cfg)
+		rejected := map[string]string{"X-Test-Reject": "1"}
+
+		assert.Equal(t, http.StatusUnauthorized, doRequest(router, "GET", "/v1/users/1", rejected).Code)
+		assert.Equal(t, http.StatusTooManyRequests, doRequest(router, "GET", "/v1/users/1", nil).Code)
+		assert.Equal(t, http.StatusOK, doRequest(router, "GET", "/v1/users/1", map[string]string{"X-Test-User": "1"}).Code)
+	})
+
+	t.Run("should reject requests before authentication for other keys", func(t *testing.T) {
+		cfg := base
+		cfg.Policies = []config.RateLimitPolicyConfig{
+			{Name: "users", Route: "GET /v1/users/:id", Key: "ip", RequestsPerSecond: 1, Burst: 1},
+		}
+		router := newPolicyRouter(t, cfg)
+		rejected := map[string]string{"X-Test-Reject": "1"}
+
+		assert.Equal(t, http.StatusUnauthorized, doRequest(router, "GET", "/v1/users/1", rejected).Code)
+		assert.Equal(t, http.StatusTooManyRequests, doRequest(router, "GET", "/v1/users/1", rejected).Code)
+	})
+
+	t.Run("should count a request only once", func(t *testing.T) {
+		cfg := base
+		cfg.Burst = 2
+		router := newPolicyRouter(t, cfg)
+
+		// The default policy is counted before authentication, not again after it
+		assert.Equal(t, "1", doRequest(router, "GET", "/v1/users/1", map[string]string{"X-Test-User": "1"}).Header().Get("RateLimit-Remaining"))
+	})
+
+	t.Run("should count api_key policies per key and fall back to the client IP", func(t *testing.T) {
+		cfg := base
+		cfg.Policies = []config.RateLimitPolicyConfig{
+			{Name: "reports", Route: "/v1/reports/*", Key: "api_key", RequestsPerSecond: 1, Burst: 1},
+		}
+		router := newPolicyRouter(t, cfg)
+
+		assert.Equal(t, http.StatusOK, doRequest(router, "GET", "/v1/reports/daily", map[string]string{APIKeyHeader: "key-a"}).Code)
+		assert.Equal(t, http.StatusTooManyRequests, doRequest(router, "GET", "/v1/reports/daily", map[string]string{APIKeyHeader: "key-a"}).Code)
+		assert.Equal(t, http.StatusOK, doRequest(router, "GET", "/v1/reports/daily", map[string]string{APIKeyHeader: "key-b"}).Code)
+
+		assert.Equal(t, http.StatusOK, doRequest(router, "GET", "/v1/reports/daily", nil).Code)
+		assert.Equal(t, http.StatusTooManyRequests, doRequest(router, "GET", "/v1/reports/daily", nil).Code)
+	})
+
+	t.Run("should count rejections per policy", func(t *testing.T) {
+		cfg := base
+		cfg.Policies = []config.RateLimitPolicyConfig{
+			{Name: "metric-test", Route: "GET /info", Key: "global", RequestsPerSecond: 1, Burst: 1},
+		}
+		router := newPolicyRouter(t, cfg)
+		before := testutil.ToFloat64(rateLimitRejectionsTotal.WithLabelValues("metric-test"))
+
+		for range 3 {
+			doRequest(router, "GET", "/info", nil)
+		}
+
+		assert.Equal(t, before+2, testutil.ToFloat64(rateLimitRejectionsTotal.WithLabelValues("metric-test")))
+	})
+
+	t.Run("should keep each policy under its own redis prefix", func(t *testing.T) {
+		srv := miniredis.RunT(t)
+		cfg := base
+		cfg.Store = LimiterStoreRedis
+		cfg.Redis = config.RedisConfig{Addr: srv.Addr(), KeyPrefix: "app:", Timeout: 100}
+		cfg.Policies = []config.RateLimitPolicyConfig{
+			{Name: "info", Route: "GET /info", Key: "global", RequestsPerSecond: 1, Burst: 1},
+		}
+		router := newPolicyRouter(t, cfg)
+
+		assert.Equal(t, http.StatusOK, doRequest(router, "GET", "/info", nil).Code)
+		assert.Equal(t, http.StatusTooManyRequests, doRequest(router, "GET", "/info", nil).Code)
+		assert.Equal(t, http.StatusOK, doRequest(router, "GET", "/health", nil).Code)
+
+		assert.True(t, srv.Exists("app:info:global"))
+		assert.True(t, srv.Exists("app:default:ip:"), "httptest requests have no client IP")
+	})
+}
+
+func TestNewRateLimitPolicies(t *testing.T) {
+	valid := config.RateLimitPolicyConfig{Name: "login", Route: "POST /v1/login", Key: "ip", RequestsPerSecond: 1, Burst: 5}
+
+	t.Run("should accept valid policies", func(t *testing.T) {
+		policy := valid
+		policy.Key = ""
+		p, err := NewRateLimitPolicies(config.RateLimitConfig{
+			RequestsPerSecond: 10,
+			Burst:             20,
+			Policies:          []config.RateLimitPolicyConfig{policy},
+		}, zap.NewNop())
+		require.NoError(t, err)
+		require.Len(t, p.policies, 1)
+		assert.Equal(t, RateLimitKeyIP, p.policies[0].key)
+		assert.Equal(t, "POST", p.policies[0].method)
+	})
+
+	tests := []struct {
+		name   string
+		mutate func(*config.RateLimitConfig)
+		want   string
+	}{
+		{"unknown store", func(c *config.RateLimitConfig) { c.Store = "memcached" }, "unsupported rate limit store"},
+		{"redis without address", func(c *config.RateLimitConfig) { c.Store = LimiterStoreRedis }, "rate_limit.redis.addr"},
+		{"non-positive default rate", func(c *config.RateLimitConfig) { c.RequestsPerSecond = 0 }, "requests_per_second must be positive"},
+		{"missing name", func(c *config.RateLimitConfig) { c.Policies[0].Name = "" }, "name is required"},
+		{"reserved name", func(c *config.RateLimitConfig) { c.Policies[0].Name = DefaultRateLimitPolicy }, "duplicate policy name"},
+		{"relative route", func(c *config.RateLimitConfig) { c.Policies[0].Route = "POST v1/login" }, "must start with /"},
+		{"malformed route", func(c *config.RateLimitConfig) { c.Policies[0].Route = "POST /v1/login extra" }, "route must be"},
+		{"unknown key", func(c *config.RateLimitConfig) { c.Policies[0].Key = "session" }, "unsupported key"},
+		{"zero burst", func(c *config.RateLimitConfig) { c.Policies[0].Burst = 0 }, "burst must be at least 1"},
+	}
+	for _, tt := range tests {
+		t.Run("should reject "+tt.name, func(t *testing.T) {
+			cfg := config.RateLimitConfig{
+				RequestsPerSecond: 10,
+				Burst:             20,
+				Policies:          []config.RateLimitPolicyConfig{valid},
+			}
+			tt.mutate(&cfg)
+			_, err := NewRateLimitPolicies(cfg, zap.NewNop())
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
+
+func TestRateLimiterDecision(t *testing.T) {
+	t.Run("should report remaining tokens and refill times", func(t *testing.T) {
+		rl := NewRateLimiter(1, 2)
+
+		d, err := rl.Allow(context.Background(), "192.168.1.1")
+		require.NoError(t, err)
+		assert.True(t, d.Allowed)
+		assert.Equal(t, 2, d.Limit)
+		assert.Equal(t, 1, d.Remaining)
+		assert.InDelta(t, 1.0, d.Reset.Seconds(), 0.05)
+
+		rl.Allow(context.Background(), "192.168.1.1")
+		d, _ = rl.Allow(context.Background(), "192.168.1.1")
+		assert.False(t, d.Allowed)
+		assert.Equal(t, 0, d.Remaining)
+		assert.InDelta(t, 1.0, d.RetryAfter.Seconds(), 0.05)
+	})
+}
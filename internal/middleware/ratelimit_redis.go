@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// gcraScript implements the generic cell rate algorithm, a token bucket that
+// stores one timestamp per key: the theoretical arrival time (TAT) at which
+// the bucket is full again. The server clock is used so that replicas with
+// skewed clocks agree. Times are in microseconds.
+//
+// KEYS[1] holds the TAT, ARGV[1] is the emission interval 1/rate and ARGV[2]
+// the burst tolerance interval*burst. It returns {allowed, remaining, reset, retry after}.
+var gcraScript = redis.NewScript(`
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+local interval = tonumber(ARGV[1])
+local tolerance = tonumber(ARGV[2])
+
+local tat = tonumber(redis.call('GET', KEYS[1])) or now
+if tat < now then
+	tat = now
+end
+
+local allow_at = tat + interval - tolerance
+if now < allow_at then
+	return {0, 0, tat - now, allow_at - now}
+end
+
+local new_tat = tat + interval
+redis.call('SET', KEYS[1], string.format('%d', new_tat), 'PX', math.ceil((new_tat - now) / 1000))
+return {1, math.floor((now - (new_tat - tolerance)) / interval), new_tat - now, 0}
+`)
+
+// RedisLimiterStore keeps a token bucket per key in a Redis-protocol server so
+// that all replicas share one limit. Like the memory store it admits burst
+// requests at once and refills at requestsPerSecond.
+type RedisLimiterStore struct {
+	client    *redis.Client
+	prefix    string
+	limit     int
+	interval  int64
+	tolerance int64
+}
+
+// NewRedisLimiterStore creates a store; keys are written as prefix+key
+func NewRedisLimiterStore(client *redis.Client, prefix string, requestsPerSecond float64, burst int) *RedisLimiterStore {
+	interval := max(int64(float64(time.Second/time.Microsecond)/requestsPerSecond), 1)
+	return &RedisLimiterStore{
+		client:    client,
+		prefix:    prefix,
+		limit:     burst,
+		interval:  interval,
+		tolerance: interval * int64(burst),
+	}
+}
+
+// Allow implements LimiterStore. The script runs atomically, so concurrent
+// requests of all replicas are counted exactly.
+func (s *RedisLimiterStore) Allow(ctx context.Context, key string) (Decision, error) {
+	reply, err := gcraScript.Run(ctx, s.client, []string{s.prefix + key}, s.interval, s.tolerance).Int64Slice()
+	if err != nil {
+		return Decision{}, err
+	}
+
+	return Decision{
+		Allowed:    reply[0] == 1,
+		Limit:      s.limit,
+		Remaining:  int(max(reply[1], 0)),
+		Reset:      time.Duration(reply[2]) * time.Microsecond,
+		RetryAfter: time.Duration(reply[3]) * time.Microsecond,
+	}, nil
+}
@@ -0,0 +1,205 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// redisTestServer is an in-memory Redis server with a controllable clock
+type redisTestServer struct {
+	*miniredis.Miniredis
+	now time.Time
+}
+
+// advance moves the clock forward by d, expiring keys as it goes
+func (s *redisTestServer) advance(d time.Duration) {
+	s.now = s.now.Add(d)
+	s.SetTime(s.now)
+	s.FastForward(d)
+}
+
+func newRedisTestStore(t *testing.T, requestsPerSecond float64, burst int) (*RedisLimiterStore, *redisTestServer) {
+	t.Helper()
+	srv := &redisTestServer{Miniredis: miniredis.RunT(t), now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	srv.SetTime(srv.now)
+	client := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewRedisLimiterStore(client, "test:", requestsPerSecond, burst), srv
+}
+
+func TestRedisLimiterStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should allow burst requests and reject the rest", func(t *testing.T) {
+		store, _ := newRedisTestStore(t, 1, 3)
+
+		for range 3 {
+			d, err := store.Allow(ctx, "192.168.1.1")
+			require.NoError(t, err)
+			assert.True(t, d.Allowed)
+		}
+		d, err := store.Allow(ctx, "192.168.1.1")
+		require.NoError(t, err)
+		assert.False(t, d.Allowed)
+	})
+
+	t.Run("should track keys independently", func(t *testing.T) {
+		store, srv := newRedisTestStore(t, 1, 1)
+
+		d, _ := store.Allow(ctx, "192.168.1.1")
+		assert.True(t, d.Allowed)
+		d, _ = store.Allow(ctx, "192.168.1.2")
+		assert.True(t, d.Allowed)
+		assert.Len(t, srv.Keys(), 2)
+	})
+
+	t.Run("should expire keys once the bucket is full again", func(t *testing.T) {
+		store, srv := newRedisTestStore(t, 50, 100)
+
+		_, err := store.Allow(ctx, "10.0.0.1")
+		require.NoError(t, err)
+		assert.Equal(t, 20*time.Millisecond, srv.TTL("test:10.0.0.1"))
+	})
+
+	t.Run("should report remaining requests and the time until the bucket is full", func(t *testing.T) {
+		store, _ := newRedisTestStore(t, 1, 3)
+
+		d, err := store.Allow(ctx, "192.168.1.1")
+		require.NoError(t, err)
+		assert.Equal(t, Decision{Allowed: true, Limit: 3, Remaining: 2, Reset: time.Second}, d)
+
+		store.Allow(ctx, "192.168.1.1")
+		store.Allow(ctx, "192.168.1.1")
+		d, err = store.Allow(ctx, "192.168.1.1")
+		require.NoError(t, err)
+		assert.Equal(t, Decision{Allowed: false, Limit: 3, Remaining: 0, Reset: 3 * time.Second, RetryAfter: time.Second}, d)
+	})
+
+	t.Run("should refill at the configured rate without bursting at window edges", func(t *testing.T) {
+		store, srv := newRedisTestStore(t, 1, 2)
+
+		for range 2 {
+			d, _ := store.Allow(ctx, "192.168.1.1")
+			assert.True(t, d.Allowed)
+		}
+
+		srv.advance(1500 * time.Millisecond)
+		d, err := store.Allow(ctx, "192.168.1.1")
+		require.NoError(t, err)
+		assert.True(t, d.Allowed)
+		d, err = store.Allow(ctx, "192.168.1.1")
+		require.NoError(t, err)
+		assert.False(t, d.Allowed)
+		assert.Equal(t, 500*time.Millisecond, d.RetryAfter)
+
+		srv.advance(500 * time.Millisecond)
+		d, err = store.Allow(ctx, "192.168.1.1")
+		require.NoError(t, err)
+		assert.True(t, d.Allowed)
+	})
+
+	t.Run("should share the limit between replicas", func(t *testing.T) {
+		srv := miniredis.RunT(t)
+		replica := func() *RedisLimiterStore {
+			client := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+			t.Cleanup(func() { client.Close() })
+			return NewRedisLimiterStore(client, "test:", 1, 2)
+		}
+		a, b := replica(), replica()
+
+		d, _ := a.Allow(ctx, "192.168.1.1")
+		assert.True(t, d.Allowed)
+		d, _ = b.Allow(ctx, "192.168.1.1")
+		assert.True(t, d.Allowed)
+		d, _ = a.Allow(ctx, "192.168.1.1")
+		assert.False(t, d.Allowed)
+	})
+
+	t.Run("should count concurrent requests exactly", func(t *testing.T) {
+		store, _ := newRedisTestStore(t, 1, 10)
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		admitted := 0
+		for range 50 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				d, err := store.Allow(ctx, "192.168.1.1")
+				assert.NoError(t, err)
+				if d.Allowed {
+					mu.Lock()
+					admitted++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, 10, admitted)
+	})
+
+	t.Run("should return an error when the server is unreachable", func(t *testing.T) {
+		store, srv := newRedisTestStore(t, 1, 1)
+		srv.Close()
+
+		_, err := store.Allow(ctx, "192.168.1.1")
+		assert.Error(t, err)
+	})
+}
+
+type failingStore struct{}
+
+func (failingStore) Allow(context.Context, string) (Decision, error) {
+	return Decision{}, errors.New("connection refused")
+}
+
+func TestRateLimitMiddlewareWithStore(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newRouter := func(store LimiterStore) *gin.Engine {
+		router := gin.New()
+		router.Use(RateLimitMiddlewareWithStore(store, zap.NewNop()))
+		router.GET("/test", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"message": "success"})
+		})
+		return router
+	}
+
+	t.Run("should reject requests over the limit of a redis store", func(t *testing.T) {
+		store, _ := newRedisTestStore(t, 1, 2)
+		router := newRouter(store)
+
+		codes := make([]int, 3)
+		for i := range codes {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/test", nil)
+			router.ServeHTTP(w, req)
+			codes[i] = w.Code
+		}
+
+		assert.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}, codes)
+	})
+
+	t.Run("should let requests through when the store fails", func(t *testing.T) {
+		router := newRouter(failingStore{})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/test", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"myapp/pkg/config"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// swaggerPathPrefix is served with the swagger content security policy
+const swaggerPathPrefix = "/swagger/"
+
+// SecurityHeadersMiddleware sets HSTS, X-Content-Type-Options, X-Frame-Options,
+// Referrer-Policy and Content-Security-Policy on every response. Swagger UI
+// needs inline scripts and styles, so /swagger gets its own, relaxed policy.
+// Empty settings leave the corresponding header out.
+func SecurityHeadersMiddleware(cfg config.SecurityHeadersConfig) gin.HandlerFunc {
+	if !cfg.Enabled {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	static := map[string]string{
+		"X-Content-Type-Options": "nosniff",
+		"X-Frame-Options":        strings.ToUpper(cfg.FrameOptions),
+		"Referrer-Policy":        cfg.ReferrerPolicy,
+	}
+	if cfg.HSTSMaxAge > 0 {
+		hsts := "max-age=" + strconv.Itoa(cfg.HSTSMaxAge)
+		if cfg.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if cfg.HSTSPreload {
+			hsts += "; preload"
+		}
+		static["Strict-Transport-Security"] = hsts
+	}
+
+	return func(c *gin.Context) {
+		h := c.Writer.Header()
+		for name, value := range static {
+			if value != "" {
+				h.Set(name, value)
+			}
+		}
+
+		csp := cfg.ContentSecurityPolicy
+		if strings.HasPrefix(c.Request.URL.Path, swaggerPathPrefix) {
+			csp = cfg.SwaggerContentSecurityPolicy
+		}
+		if csp != "" {
+			h.Set("Content-Security-Policy", csp)
+		}
+
+		c.Next()
+	}
+}
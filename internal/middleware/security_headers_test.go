@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"myapp/pkg/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testAPIPolicy     = "default-src 'none'; frame-ancestors 'none'"
+	testSwaggerPolicy = "default-src 'self'; script-src 'self' 'unsafe-inline'"
+)
+
+func securityHeadersTestConfig() config.SecurityHeadersConfig {
+	return config.SecurityHeadersConfig{
+		Enabled:                      true,
+		FrameOptions:                 "DENY",
+		ReferrerPolicy:               "no-referrer",
+		ContentSecurityPolicy:        testAPIPolicy,
+		SwaggerContentSecurityPolicy: testSwaggerPolicy,
+	}
+}
+
+func securityHeadersRequest(cfg config.SecurityHeadersConfig, path string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(SecurityHeadersMiddleware(cfg))
+	router.GET("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+	})
+	router.GET("/swagger/*any", func(c *gin.Context) {
+		c.String(http.StatusOK, "<html></html>")
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, path, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestSecurityHeadersMiddleware(t *testing.T) {
+	t.Run("should set security headers on API responses", func(t *testing.T) {
+		w := securityHeadersRequest(securityHeadersTestConfig(), "/test")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+		assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
+		assert.Equal(t, "no-referrer", w.Header().Get("Referrer-Policy"))
+		assert.Equal(t, testAPIPolicy, w.Header().Get("Content-Security-Policy"))
+		assert.Empty(t, w.Header().Get("Strict-Transport-Security"))
+	})
+
+	t.Run("should apply the swagger policy only under /swagger", func(t *testing.T) {
+		w := securityHeadersRequest(securityHeadersTestConfig(), "/swagger/index.html")
+		assert.Equal(t, testSwaggerPolicy, w.Header().Get("Content-Security-Policy"))
+		assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
+
+		w = securityHeadersRequest(securityHeadersTestConfig(), "/swaggerish")
+		assert.Equal(t, testAPIPolicy, w.Header().Get("Content-Security-Policy"))
+	})
+
+	t.Run("should set headers on unmatched routes", func(t *testing.T) {
+		w := securityHeadersRequest(securityHeadersTestConfig(), "/missing")
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+		assert.Equal(t, testAPIPolicy, w.Header().Get("Content-Security-Policy"))
+	})
+
+	t.Run("should build HSTS from max age and flags", func(t *testing.T) {
+		cfg := securityHeadersTestConfig()
+		cfg.HSTSMaxAge = 31536000
+		assert.Equal(t, "max-age=31536000", securityHeadersRequest(cfg, "/test").Header().Get("Strict-Transport-Security"))
+
+		cfg.HSTSIncludeSubdomains = true
+		cfg.HSTSPreload = true
+		assert.Equal(t, "max-age=31536000; includeSubDomains; preload",
+			securityHeadersRequest(cfg, "/test").Header().Get("Strict-Transport-Security"))
+	})
+
+	t.Run("should leave out headers with empty values", func(t *testing.T) {
+		cfg := securityHeadersTestConfig()
+		cfg.FrameOptions = ""
+		cfg.ContentSecurityPolicy = ""
+
+		w := securityHeadersRequest(cfg, "/test")
+
+		assert.Empty(t, w.Header().Values("X-Frame-Options"))
+		assert.Empty(t, w.Header().Values("Content-Security-Policy"))
+		assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+	})
+
+	t.Run("should set nothing when disabled", func(t *testing.T) {
+		cfg := securityHeadersTestConfig()
+		cfg.Enabled = false
+		cfg.HSTSMaxAge = 3600
+
+		w := securityHeadersRequest(cfg, "/test")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("X-Content-Type-Options"))
+		assert.Empty(t, w.Header().Get("Strict-Transport-Security"))
+		assert.Empty(t, w.Header().Get("Content-Security-Policy"))
+	})
+}
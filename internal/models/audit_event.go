@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// AuditEvent is an append-only record of a security-relevant action: who did
+// what to which resource, from where and with which effect.
+type AuditEvent struct {
+	ID         uint      `gorm:"primaryKey" json:"id" example:"1"`
+	OccurredAt time.Time `gorm:"not null;index" json:"occurred_at" example:"2024-01-01T00:00:00Z"`
+	// ActorID is nil for anonymous actions such as failed logins
+	ActorID    *uint  `gorm:"index" json:"actor_id" example:"1"`
+	Action     string `gorm:"type:varchar(50);not null;index" json:"action" example:"user.updated"`
+	TargetType string `gorm:"type:varchar(50);index:idx_audit_events_target" json:"target_type,omitempty" example:"user"`
+	TargetID   string `gorm:"type:varchar(255);index:idx_audit_events_target" json:"target_id,omitempty" example:"2"`
+	// Changes is a JSON object mapping changed fields to their before and after values
+	Changes string `gorm:"type:text" json:"-"`
+	// Metadata is a JSON object with further details, such as the reason of a failure
+	Metadata  string `gorm:"type:text" json:"-"`
+	RequestID string `gorm:"type:varchar(64)" json:"request_id,omitempty" example:"0b7c5d8e-3d1f-4a7e-9f0a-5c2b1e4d6f70"`
+	ClientIP  string `gorm:"type:varchar(45)" json:"client_ip,omitempty" example:"203.0.113.7"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// EmailVerificationToken represents a single-use token that confirms a user
+// owns their email address. Only the SHA-256 hash of the token is stored.
+type EmailVerificationToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index"`
+	Email     string    `gorm:"type:varchar(100);not null"`
+	TokenHash string    `gorm:"type:varchar(64);not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// IsExpired reports whether the token is past its expiry time
+func (t *EmailVerificationToken) IsExpired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
+
+// IsUsed reports whether the token has already been redeemed or invalidated
+func (t *EmailVerificationToken) IsUsed() bool {
+	return t.UsedAt != nil
+}
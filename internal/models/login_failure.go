@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+const (
+	// LoginFailureScopeAccount tracks failed logins per email address
+	LoginFailureScopeAccount = "account"
+	// LoginFailureScopeIP tracks failed logins per client IP
+	LoginFailureScopeIP = "ip"
+)
+
+// LoginFailure counts recent failed login attempts for an account or a
+// client IP and records until when further attempts are refused.
+type LoginFailure struct {
+	Scope        string     `gorm:"type:varchar(10);primaryKey" json:"scope" example:"account"`
+	Identifier   string     `gorm:"type:varchar(255);primaryKey" json:"identifier" example:"john@example.com"`
+	Failures     int        `gorm:"not null;default:0" json:"failures" example:"5"`
+	LastFailedAt time.Time  `gorm:"not null" json:"last_failed_at" example:"2024-01-01T00:00:00Z"`
+	LockedUntil  *time.Time `json:"locked_until,omitempty" example:"2024-01-01T00:00:30Z"`
+}
+
+// IsLocked reports whether attempts are refused at the given time
+func (f *LoginFailure) IsLocked(now time.Time) bool {
+	return f.LockedUntil != nil && now.Before(*f.LockedUntil)
+}
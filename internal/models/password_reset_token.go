@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// PasswordResetToken represents a single-use token that allows a user to set
+// a new password. Only the SHA-256 hash of the token is stored.
+type PasswordResetToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index"`
+	TokenHash string    `gorm:"type:varchar(64);not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// IsExpired reports whether the token is past its expiry time
+func (t *PasswordResetToken) IsExpired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
+
+// IsUsed reports whether the token has already been redeemed or invalidated
+func (t *PasswordResetToken) IsUsed() bool {
+	return t.UsedAt != nil
+}
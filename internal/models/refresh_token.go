@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// RefreshToken represents a persisted, opaque refresh token.
+// Only the SHA-256 hash of the token is stored. Tokens issued by rotating
+// one another share a FamilyID so the whole chain can be revoked on reuse.
+type RefreshToken struct {
+	ID           uint       `gorm:"primaryKey"`
+	UserID       uint       `gorm:"not null;index"`
+	FamilyID     string     `gorm:"type:varchar(36);not null;index"`
+	TokenHash    string     `gorm:"type:varchar(64);not null;uniqueIndex"`
+	ExpiresAt    time.Time  `gorm:"not null"`
+	RevokedAt    *time.Time `gorm:"index"`
+	ReplacedByID *uint
+	CreatedAt    time.Time
+}
+
+// IsExpired reports whether the token is past its expiry time
+func (t *RefreshToken) IsExpired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
+
+// IsRevoked reports whether the token has been rotated or revoked
+func (t *RefreshToken) IsRevoked() bool {
+	return t.RevokedAt != nil
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// RevokedToken records a single access token, identified by its jti claim,
+// that must be rejected until it would have expired anyway.
+type RevokedToken struct {
+	JTI       string    `gorm:"column:jti;type:varchar(36);primaryKey"`
+	UserID    uint      `gorm:"not null;index"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	RevokedAt time.Time `gorm:"not null"`
+}
+
+// UserTokenRevocation records that every token of a user issued before
+// RevokedBefore must be rejected.
+type UserTokenRevocation struct {
+	UserID        uint      `gorm:"primaryKey;autoIncrement:false"`
+	RevokedBefore time.Time `gorm:"not null"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// Permission is a named action that can be granted to roles, e.g. users:delete
+type Permission struct {
+	ID          uint   `gorm:"primaryKey" json:"-"`
+	Name        string `gorm:"type:varchar(100);uniqueIndex;not null" json:"name" example:"users:delete"`
+	Description string `gorm:"type:varchar(255);not null;default:''" json:"description" example:"Delete any user"`
+}
+
+// Role groups permissions. A user has the permissions of the role named in
+// User.Role plus those of every role bound to them through a RoleBinding.
+type Role struct {
+	ID          uint   `gorm:"primaryKey"`
+	Name        string `gorm:"type:varchar(50);uniqueIndex;not null"`
+	Description string `gorm:"type:varchar(255);not null;default:''"`
+	// Builtin roles are created on startup and cannot be deleted
+	Builtin     bool         `gorm:"not null;default:false"`
+	Permissions []Permission `gorm:"many2many:role_permissions"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// PermissionNames returns the names of the role's permissions
+func (r *Role) PermissionNames() []string {
+	names := make([]string, len(r.Permissions))
+	for i, p := range r.Permissions {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// RoleBinding grants a role to a user in addition to their primary role
+type RoleBinding struct {
+	UserID    uint `gorm:"primaryKey;autoIncrement:false"`
+	RoleID    uint `gorm:"primaryKey;autoIncrement:false;index"`
+	CreatedAt time.Time
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// UserTOTP holds the TOTP second factor of a user. The factor is only
+// enforced at login once ConfirmedAt is set.
+type UserTOTP struct {
+	UserID uint   `gorm:"primaryKey;autoIncrement:false"`
+	Secret string `gorm:"type:varchar(64);not null"`
+	// LastUsedStep is the time step of the last accepted code, so a code cannot be replayed
+	LastUsedStep int64 `gorm:"not null;default:0"`
+	ConfirmedAt  *time.Time
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// IsConfirmed reports whether enrollment was completed with a valid code
+func (t *UserTOTP) IsConfirmed() bool {
+	return t.ConfirmedAt != nil
+}
+
+// MFARecoveryCode is a single-use code that replaces a TOTP code when the
+// authenticator is lost. Only the SHA-256 hash of the code is stored.
+type MFARecoveryCode struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"not null;index"`
+	CodeHash  string `gorm:"type:varchar(64);not null;uniqueIndex"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
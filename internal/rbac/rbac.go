@@ -0,0 +1,83 @@
+// Package rbac defines the permissions checked by the API and the built-in
+// roles that are created on startup.
+package rbac
+
+import (
+	"context"
+	"errors"
+	"myapp/internal/models"
+	"myapp/internal/repository"
+)
+
+const (
+	// RoleAdmin is the built-in role that always holds every permission
+	RoleAdmin = "admin"
+	// RoleUser is the built-in default role of new users
+	RoleUser = "user"
+)
+
+// Permissions checked by the API
+const (
+	PermUsersList      = "users:list"
+	PermUsersRead      = "users:read"
+	PermUsersUpdate    = "users:update"
+	PermUsersDelete    = "users:delete"
+	PermTokensRevoke   = "tokens:revoke"
+	PermLockoutsManage = "lockouts:manage"
+	PermRolesRead      = "roles:read"
+	PermRolesWrite     = "roles:write"
+	PermAuditRead      = "audit:read"
+)
+
+// Permissions lists every permission checked by the API
+var Permissions = []models.Permission{
+	{Name: PermUsersList, Description: "List and search all users"},
+	{Name: PermUsersRead, Description: "View any user"},
+	{Name: PermUsersUpdate, Description: "Update any user"},
+	{Name: PermUsersDelete, Description: "Delete any user"},
+	{Name: PermTokensRevoke, Description: "Revoke all tokens of any user"},
+	{Name: PermLockoutsManage, Description: "View and clear login lockouts"},
+	{Name: PermRolesRead, Description: "View roles, permissions and role bindings"},
+	{Name: PermRolesWrite, Description: "Create, update and delete roles and bind them to users"},
+	{Name: PermAuditRead, Description: "View the audit log"},
+}
+
+// PermissionNames returns the names of all permissions
+func PermissionNames() []string {
+	names := make([]string, len(Permissions))
+	for i, p := range Permissions {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// Seed creates missing permissions and built-in roles and grants every
+// permission to the admin role. It is safe to run on every startup.
+func Seed(ctx context.Context, repo repository.RoleRepository) error {
+	if err := repo.EnsurePermissions(ctx, Permissions); err != nil {
+		return err
+	}
+
+	if _, err := repo.FindRoleByName(ctx, RoleUser); errors.Is(err, repository.ErrRoleNotFound) {
+		role := &models.Role{Name: RoleUser, Description: "Default role, can manage only their own account", Builtin: true}
+		if err := repo.CreateRole(ctx, role, nil); err != nil && !errors.Is(err, repository.ErrRoleExists) {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	admin, err := repo.FindRoleByName(ctx, RoleAdmin)
+	if errors.Is(err, repository.ErrRoleNotFound) {
+		role := &models.Role{Name: RoleAdmin, Description: "Full access to all users and settings", Builtin: true}
+		err = repo.CreateRole(ctx, role, PermissionNames())
+		if errors.Is(err, repository.ErrRoleExists) {
+			return nil
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	return repo.UpdateRole(ctx, admin, PermissionNames())
+}
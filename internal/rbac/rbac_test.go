@@ -0,0 +1,157 @@
+package rbac
+
+import (
+	"context"
+	"myapp/internal/models"
+	"myapp/internal/repository"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupRepo creates a seeded role repository on an in-memory database
+func setupRepo(t *testing.T) (repository.RoleRepository, *gorm.DB) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	require.NoError(t, db.AutoMigrate(&models.User{}, &models.Permission{}, &models.Role{}, &models.RoleBinding{}))
+
+	repo := repository.NewPostgresRoleRepository(db)
+	require.NoError(t, Seed(context.Background(), repo))
+	return repo, db
+}
+
+func TestSeed(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should create permissions and built-in roles", func(t *testing.T) {
+		repo, _ := setupRepo(t)
+
+		permissions, err := repo.ListPermissions(ctx)
+		require.NoError(t, err)
+		assert.Len(t, permissions, len(Permissions))
+
+		admin, err := repo.FindRoleByName(ctx, RoleAdmin)
+		require.NoError(t, err)
+		assert.True(t, admin.Builtin)
+		assert.ElementsMatch(t, PermissionNames(), admin.PermissionNames())
+
+		user, err := repo.FindRoleByName(ctx, RoleUser)
+		require.NoError(t, err)
+		assert.True(t, user.Builtin)
+		assert.Empty(t, user.Permissions)
+	})
+
+	t.Run("should be idempotent and restore admin permissions", func(t *testing.T) {
+		repo, _ := setupRepo(t)
+
+		admin, err := repo.FindRoleByName(ctx, RoleAdmin)
+		require.NoError(t, err)
+		require.NoError(t, repo.UpdateRole(ctx, admin, []string{PermUsersList}))
+
+		require.NoError(t, Seed(ctx, repo))
+
+		roles, err := repo.ListRoles(ctx)
+		require.NoError(t, err)
+		assert.Len(t, roles, 2)
+		admin, err = repo.FindRoleByName(ctx, RoleAdmin)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, PermissionNames(), admin.PermissionNames())
+	})
+}
+
+func TestPermissionsForUser(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should combine primary and bound roles", func(t *testing.T) {
+		repo, db := setupRepo(t)
+		user := &models.User{Name: "Sam", Email: "sam@example.com", PasswordHash: "hash", Role: RoleUser}
+		require.NoError(t, db.Create(user).Error)
+
+		support := &models.Role{Name: "support"}
+		require.NoError(t, repo.CreateRole(ctx, support, []string{PermUsersRead, PermUsersList}))
+		auditor := &models.Role{Name: "auditor"}
+		require.NoError(t, repo.CreateRole(ctx, auditor, []string{PermUsersList, PermLockoutsManage}))
+
+		permissions, err := repo.PermissionsForUser(ctx, user.ID, RoleUser)
+		require.NoError(t, err)
+		assert.NotNil(t, permissions)
+		assert.Empty(t, permissions)
+
+		require.NoError(t, repo.BindRole(ctx, user.ID, support.ID))
+		require.NoError(t, repo.BindRole(ctx, user.ID, auditor.ID))
+		require.NoError(t, repo.BindRole(ctx, user.ID, auditor.ID))
+
+		permissions, err = repo.PermissionsForUser(ctx, user.ID, RoleUser)
+		require.NoError(t, err)
+		assert.Equal(t, []string{PermLockoutsManage, PermUsersList, PermUsersRead}, permissions)
+
+		// The primary role counts even without a binding
+		permissions, err = repo.PermissionsForUser(ctx, user.ID, "support")
+		require.NoError(t, err)
+		assert.Equal(t, []string{PermLockoutsManage, PermUsersList, PermUsersRead}, permissions)
+
+		require.NoError(t, repo.UnbindRole(ctx, user.ID, auditor.ID))
+		permissions, err = repo.PermissionsForUser(ctx, user.ID, RoleUser)
+		require.NoError(t, err)
+		assert.Equal(t, []string{PermUsersList, PermUsersRead}, permissions)
+	})
+}
+
+func TestRoleRepository(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should reject duplicate names and unknown permissions", func(t *testing.T) {
+		repo, _ := setupRepo(t)
+
+		err := repo.CreateRole(ctx, &models.Role{Name: RoleAdmin}, nil)
+		assert.ErrorIs(t, err, repository.ErrRoleExists)
+
+		err = repo.CreateRole(ctx, &models.Role{Name: "support"}, []string{"users:fly"})
+		assert.ErrorIs(t, err, repository.ErrUnknownPermission)
+		_, err = repo.FindRoleByName(ctx, "support")
+		assert.ErrorIs(t, err, repository.ErrRoleNotFound)
+	})
+
+	t.Run("should replace permissions on update", func(t *testing.T) {
+		repo, _ := setupRepo(t)
+		role := &models.Role{Name: "support"}
+		require.NoError(t, repo.CreateRole(ctx, role, []string{PermUsersRead}))
+
+		role.Description = "Helpdesk"
+		require.NoError(t, repo.UpdateRole(ctx, role, []string{PermUsersList, PermUsersUpdate}))
+		stored, err := repo.FindRoleByID(ctx, role.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Helpdesk", stored.Description)
+		assert.Equal(t, []string{PermUsersList, PermUsersUpdate}, stored.PermissionNames())
+
+		require.NoError(t, repo.UpdateRole(ctx, role, nil))
+		stored, err = repo.FindRoleByID(ctx, role.ID)
+		require.NoError(t, err)
+		assert.Empty(t, stored.Permissions)
+	})
+
+	t.Run("should refuse to delete a primary role in use", func(t *testing.T) {
+		repo, db := setupRepo(t)
+		role := &models.Role{Name: "support"}
+		require.NoError(t, repo.CreateRole(ctx, role, []string{PermUsersRead}))
+		user := &models.User{Name: "Sam", Email: "sam@example.com", PasswordHash: "hash", Role: "support"}
+		require.NoError(t, db.Create(user).Error)
+
+		assert.ErrorIs(t, repo.DeleteRole(ctx, role.ID), repository.ErrRoleInUse)
+
+		require.NoError(t, db.Model(user).Update("role", RoleUser).Error)
+		require.NoError(t, repo.BindRole(ctx, user.ID, role.ID))
+		require.NoError(t, repo.DeleteRole(ctx, role.ID))
+
+		roles, err := repo.ListUserRoles(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Empty(t, roles)
+		assert.ErrorIs(t, repo.DeleteRole(ctx, role.ID), repository.ErrRoleNotFound)
+	})
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"myapp/internal/models"
+	"time"
+)
+
+const (
+	// DefaultAuditPageSize is used when no limit is requested
+	DefaultAuditPageSize = 50
+	// MaxAuditPageSize caps the number of audit events returned per page
+	MaxAuditPageSize = 200
+)
+
+// AuditQueryOptions filters and paginates audit events. Empty fields do not filter.
+type AuditQueryOptions struct {
+	Limit  int
+	Offset int
+
+	ActorID    *uint
+	Action     string
+	TargetType string
+	TargetID   string
+	Since      *time.Time
+	Until      *time.Time
+}
+
+// AuditPage is a single page of audit events, most recent first
+type AuditPage struct {
+	Events []models.AuditEvent
+	Total  int64
+}
+
+// AuditRepository defines the interface for the append-only audit log
+type AuditRepository interface {
+	Create(ctx context.Context, event *models.AuditEvent) error
+	List(ctx context.Context, opts AuditQueryOptions) (*AuditPage, error)
+}
+
+// normalize applies defaults and limits to the options
+func (o *AuditQueryOptions) normalize() {
+	if o.Limit <= 0 {
+		o.Limit = DefaultAuditPageSize
+	}
+	if o.Limit > MaxAuditPageSize {
+		o.Limit = MaxAuditPageSize
+	}
+	if o.Offset < 0 {
+		o.Offset = 0
+	}
+}
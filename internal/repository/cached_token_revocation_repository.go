@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRevocationCacheTTL is how long a negative lookup is trusted before
+	// the backing store is consulted again. Revocations made by other replicas
+	// become visible after at most this long.
+	DefaultRevocationCacheTTL = 30 * time.Second
+
+	// revocationCacheSweepSize is the number of cached entries above which
+	// expired entries are swept on write
+	revocationCacheSweepSize = 10000
+)
+
+type cutoffEntry struct {
+	revokedBefore time.Time
+	fetchedAt     time.Time
+}
+
+// CachedTokenRevocationRepository wraps a TokenRevocationRepository with an
+// in-memory cache. Revoked tokens are cached until they expire, because a
+// revocation is never undone; lookups that found nothing are cached for ttl.
+type CachedTokenRevocationRepository struct {
+	inner   TokenRevocationRepository
+	ttl     time.Duration
+	mu      sync.RWMutex
+	revoked map[string]time.Time // jti -> token expiry
+	active  map[string]time.Time // jti -> time the negative lookup was made
+	cutoffs map[uint]cutoffEntry
+}
+
+// NewCachedTokenRevocationRepository creates a caching token revocation repository
+func NewCachedTokenRevocationRepository(inner TokenRevocationRepository, ttl time.Duration) *CachedTokenRevocationRepository {
+	if ttl <= 0 {
+		ttl = DefaultRevocationCacheTTL
+	}
+	return &CachedTokenRevocationRepository{
+		inner:   inner,
+		ttl:     ttl,
+		revoked: make(map[string]time.Time),
+		active:  make(map[string]time.Time),
+		cutoffs: make(map[uint]cutoffEntry),
+	}
+}
+
+// RevokeToken revokes a token in the backing store and caches the revocation
+func (r *CachedTokenRevocationRepository) RevokeToken(ctx context.Context, jti string, userID uint, expiresAt time.Time) error {
+	if err := r.inner.RevokeToken(ctx, jti, userID, expiresAt); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sweepLocked(time.Now())
+	r.revoked[jti] = expiresAt
+	delete(r.active, jti)
+	return nil
+}
+
+// IsTokenRevoked checks the cache before falling back to the backing store
+func (r *CachedTokenRevocationRepository) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	now := time.Now()
+
+	r.mu.RLock()
+	_, revoked := r.revoked[jti]
+	checkedAt, checked := r.active[jti]
+	r.mu.RUnlock()
+
+	if revoked {
+		return true, nil
+	}
+	if checked && now.Sub(checkedAt) < r.ttl {
+		return false, nil
+	}
+
+	revoked, err := r.inner.IsTokenRevoked(ctx, jti)
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sweepLocked(now)
+	if revoked {
+		// The token expiry is unknown here, so the entry only survives a sweep for ttl
+		r.revoked[jti] = now.Add(r.ttl)
+	} else {
+		r.active[jti] = now
+	}
+	return revoked, nil
+}
+
+// RevokeAllForUser revokes all tokens of a user and caches the new cutoff
+func (r *CachedTokenRevocationRepository) RevokeAllForUser(ctx context.Context, userID uint, before time.Time) error {
+	if err := r.inner.RevokeAllForUser(ctx, userID, before); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cutoffs[userID] = cutoffEntry{revokedBefore: before, fetchedAt: time.Now()}
+	return nil
+}
+
+// DeleteExpired removes expired revoked tokens from the backing store; the
+// cache drops them on its own once they expire
+func (r *CachedTokenRevocationRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	return r.inner.DeleteExpired(ctx, now)
+}
+
+// RevokedBefore returns the cached cutoff of a user, refreshing it after ttl
+func (r *CachedTokenRevocationRepository) RevokedBefore(ctx context.Context, userID uint) (time.Time, error) {
+	now := time.Now()
+
+	r.mu.RLock()
+	entry, ok := r.cutoffs[userID]
+	r.mu.RUnlock()
+
+	if ok && now.Sub(entry.fetchedAt) < r.ttl {
+		return entry.revokedBefore, nil
+	}
+
+	before, err := r.inner.RevokedBefore(ctx, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sweepLocked(now)
+	r.cutoffs[userID] = cutoffEntry{revokedBefore: before, fetchedAt: now}
+	return before, nil
+}
+
+// sweepLocked drops expired entries once the cache grows large.
+// The caller must hold the write lock.
+func (r *CachedTokenRevocationRepository) sweepLocked(now time.Time) {
+	if len(r.revoked)+len(r.active)+len(r.cutoffs) < revocationCacheSweepSize {
+		return
+	}
+	r.sweep(now)
+}
+
+// sweep drops all expired entries. The caller must hold the write lock.
+func (r *CachedTokenRevocationRepository) sweep(now time.Time) {
+	for jti, expiresAt := range r.revoked {
+		if now.After(expiresAt) {
+			delete(r.revoked, jti)
+		}
+	}
+	for jti, checkedAt := range r.active {
+		if now.Sub(checkedAt) >= r.ttl {
+			delete(r.active, jti)
+		}
+	}
+	for userID, entry := range r.cutoffs {
+		if now.Sub(entry.fetchedAt) >= r.ttl {
+			delete(r.cutoffs, userID)
+		}
+	}
+}
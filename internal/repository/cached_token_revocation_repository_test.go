@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeTokenRevocationRepository is an in-memory TokenRevocationRepository that counts lookups
+type fakeTokenRevocationRepository struct {
+	revoked      map[string]bool
+	cutoffs      map[uint]time.Time
+	revokedCalls int
+	cutoffCalls  int
+	err          error
+}
+
+func newFakeTokenRevocationRepository() *fakeTokenRevocationRepository {
+	return &fakeTokenRevocationRepository{
+		revoked: make(map[string]bool),
+		cutoffs: make(map[uint]time.Time),
+	}
+}
+
+func (f *fakeTokenRevocationRepository) RevokeToken(ctx context.Context, jti string, userID uint, expiresAt time.Time) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.revoked[jti] = true
+	return nil
+}
+
+func (f *fakeTokenRevocationRepository) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	f.revokedCalls++
+	return f.revoked[jti], f.err
+}
+
+func (f *fakeTokenRevocationRepository) RevokeAllForUser(ctx context.Context, userID uint, before time.Time) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.cutoffs[userID] = before
+	return nil
+}
+
+func (f *fakeTokenRevocationRepository) RevokedBefore(ctx context.Context, userID uint) (time.Time, error) {
+	f.cutoffCalls++
+	return f.cutoffs[userID], f.err
+}
+
+func (f *fakeTokenRevocationRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	return 0, f.err
+}
+
+func TestCachedTokenRevocationRepository(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should cache revocations made through the cache", func(t *testing.T) {
+		inner := newFakeTokenRevocationRepository()
+		cache := NewCachedTokenRevocationRepository(inner, time.Minute)
+
+		assert.NoError(t, cache.RevokeToken(ctx, "jti-1", 1, time.Now().Add(time.Hour)))
+
+		revoked, err := cache.IsTokenRevoked(ctx, "jti-1")
+		assert.NoError(t, err)
+		assert.True(t, revoked)
+		assert.Equal(t, 0, inner.revokedCalls)
+	})
+
+	t.Run("should cache negative lookups within ttl", func(t *testing.T) {
+		inner := newFakeTokenRevocationRepository()
+		cache := NewCachedTokenRevocationRepository(inner, time.Minute)
+
+		for range 3 {
+			revoked, err := cache.IsTokenRevoked(ctx, "jti-1")
+			assert.NoError(t, err)
+			assert.False(t, revoked)
+		}
+		assert.Equal(t, 1, inner.revokedCalls)
+	})
+
+	t.Run("should see revocations from other replicas after ttl", func(t *testing.T) {
+		inner := newFakeTokenRevocationRepository()
+		cache := NewCachedTokenRevocationRepository(inner, 10*time.Millisecond)
+
+		revoked, _ := cache.IsTokenRevoked(ctx, "jti-1")
+		assert.False(t, revoked)
+
+		// Simulate a revocation written by another replica
+		inner.revoked["jti-1"] = true
+		time.Sleep(20 * time.Millisecond)
+
+		revoked, err := cache.IsTokenRevoked(ctx, "jti-1")
+		assert.NoError(t, err)
+		assert.True(t, revoked)
+	})
+
+	t.Run("should cache user cutoffs", func(t *testing.T) {
+		inner := newFakeTokenRevocationRepository()
+		cache := NewCachedTokenRevocationRepository(inner, time.Minute)
+		cutoff := time.Now()
+
+		assert.NoError(t, cache.RevokeAllForUser(ctx, 7, cutoff))
+
+		before, err := cache.RevokedBefore(ctx, 7)
+		assert.NoError(t, err)
+		assert.True(t, cutoff.Equal(before))
+		assert.Equal(t, 0, inner.cutoffCalls)
+	})
+
+	t.Run("should not cache errors", func(t *testing.T) {
+		inner := newFakeTokenRevocationRepository()
+		inner.err = errors.New("database unavailable")
+		cache := NewCachedTokenRevocationRepository(inner, time.Minute)
+
+		_, err := cache.IsTokenRevoked(ctx, "jti-1")
+		assert.Error(t, err)
+
+		inner.err = nil
+		_, err = cache.IsTokenRevoked(ctx, "jti-1")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, inner.revokedCalls)
+	})
+}
+
+func TestIsAccessTokenRevoked(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	t.Run("should report individually revoked token", func(t *testing.T) {
+		inner := newFakeTokenRevocationRepository()
+		inner.revoked["jti-1"] = true
+
+		revoked, err := IsAccessTokenRevoked(ctx, inner, "jti-1", 1, now)
+		assert.NoError(t, err)
+		assert.True(t, revoked)
+	})
+
+	t.Run("should report tokens issued before the user cutoff", func(t *testing.T) {
+		inner := newFakeTokenRevocationRepository()
+		inner.cutoffs[1] = now
+
+		revoked, err := IsAccessTokenRevoked(ctx, inner, "jti-1", 1, now.Add(-time.Hour))
+		assert.NoError(t, err)
+		assert.True(t, revoked)
+
+		revoked, err = IsAccessTokenRevoked(ctx, inner, "", 1, now.Add(-time.Hour))
+		assert.NoError(t, err)
+		assert.True(t, revoked)
+	})
+
+	t.Run("should accept tokens issued after the user cutoff", func(t *testing.T) {
+		inner := newFakeTokenRevocationRepository()
+		inner.cutoffs[1] = now.Add(-time.Hour)
+
+		revoked, err := IsAccessTokenRevoked(ctx, inner, "jti-1", 1, now)
+		assert.NoError(t, err)
+		assert.False(t, revoked)
+	})
+
+	t.Run("should accept tokens issued right after the user cutoff", func(t *testing.T) {
+		inner := newFakeTokenRevocationRepository()
+		cutoff := time.Date(2024, 1, 1, 12, 0, 0, int(500*time.Millisecond+300*time.Microsecond), time.UTC)
+		inner.cutoffs[1] = cutoff
+
+		// iat is truncated to whole milliseconds
+		revoked, err := IsAccessTokenRevoked(ctx, inner, "jti-1", 1, cutoff.Truncate(time.Millisecond))
+		assert.NoError(t, err)
+		assert.False(t, revoked)
+
+		revoked, err = IsAccessTokenRevoked(ctx, inner, "jti-1", 1, cutoff.Truncate(time.Millisecond).Add(-time.Millisecond))
+		assert.NoError(t, err)
+		assert.True(t, revoked)
+	})
+
+	t.Run("should accept tokens of other users", func(t *testing.T) {
+		inner := newFakeTokenRevocationRepository()
+		inner.cutoffs[1] = now
+
+		revoked, err := IsAccessTokenRevoked(ctx, inner, "jti-2", 2, now.Add(-time.Hour))
+		assert.NoError(t, err)
+		assert.False(t, revoked)
+	})
+}
@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"myapp/internal/models"
+)
+
+var (
+	// ErrEmailVerificationTokenNotFound is returned when an email verification token is not found
+	ErrEmailVerificationTokenNotFound = errors.New("email verification token not found")
+	// ErrEmailVerificationTokenUsed is returned when an email verification token was already redeemed
+	ErrEmailVerificationTokenUsed = errors.New("email verification token already used")
+)
+
+// EmailVerificationTokenRepository defines the interface for email verification token persistence
+type EmailVerificationTokenRepository interface {
+	Create(ctx context.Context, token *models.EmailVerificationToken) error
+	FindByHash(ctx context.Context, tokenHash string) (*models.EmailVerificationToken, error)
+	// MarkUsed redeems the token. It returns ErrEmailVerificationTokenUsed if
+	// the token was already used, so only one concurrent redemption can win.
+	MarkUsed(ctx context.Context, id uint) error
+	// InvalidateAllForUser marks every outstanding token of the user as used
+	InvalidateAllForUser(ctx context.Context, userID uint) error
+}
@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"fmt"
+	"myapp/pkg/database"
+
+	"gorm.io/gorm"
+)
+
+// NewUserRepository creates the UserRepository implementation for a database driver
+func NewUserRepository(driver string, db *gorm.DB) (UserRepository, error) {
+	switch driver {
+	case database.DriverPostgres, "":
+		return NewPostgresUserRepository(db), nil
+	case database.DriverSQLite, database.DriverMemory:
+		// The memory driver's database is SQLite too; other tables and user
+		// counts must see the same users
+		return NewSQLiteUserRepository(db), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
+	}
+}
@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewUserRepository(t *testing.T) {
+	testCases := []struct {
+		driver   string
+		expected any
+	}{
+		{"postgres", &PostgresUserRepository{}},
+		{"", &PostgresUserRepository{}},
+		{"sqlite", &SQLiteUserRepository{}},
+		{"memory", &SQLiteUserRepository{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run("should create repository for driver "+tc.driver, func(t *testing.T) {
+			repo, err := NewUserRepository(tc.driver, nil)
+			assert.NoError(t, err)
+			assert.IsType(t, tc.expected, repo)
+		})
+	}
+
+	t.Run("should reject unknown driver", func(t *testing.T) {
+		_, err := NewUserRepository("oracle", nil)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,245 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"myapp/internal/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// gormUserRepository implements UserRepository on top of GORM.
+// Dialect-specific repositories embed it.
+type gormUserRepository struct {
+	db *gorm.DB
+	// translateError maps dialect errors of writes to repository errors
+	translateError func(error) error
+}
+
+// FindAll retrieves all users
+func (r *gormUserRepository) FindAll(ctx context.Context) ([]models.User, error) {
+	var users []models.User
+	if err := r.db.WithContext(ctx).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// FindPage retrieves a filtered, sorted page of users using offset or keyset pagination
+func (r *gormUserRepository) FindPage(ctx context.Context, opts UserQueryOptions) (*UserPage, error) {
+	if err := opts.normalize(); err != nil {
+		return nil, err
+	}
+	cursor, err := opts.decodeCursor()
+	if err != nil {
+		return nil, err
+	}
+
+	query := r.db.WithContext(ctx).Model(&models.User{})
+	if opts.Role != "" {
+		query = query.Where("role = ?", opts.Role)
+	}
+	if opts.EmailContains != "" {
+		query = query.Where(`LOWER(email) LIKE ? ESCAPE '\'`, "%"+escapeLike(strings.ToLower(opts.EmailContains))+"%")
+	}
+	if opts.CreatedAfter != nil {
+		query = query.Where("created_at >= ?", *opts.CreatedAfter)
+	}
+	if opts.CreatedBefore != nil {
+		query = query.Where("created_at < ?", *opts.CreatedBefore)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	direction, comparator := "ASC", ">"
+	if opts.SortDesc {
+		direction, comparator = "DESC", "<"
+	}
+
+	// The sort field is validated against UserSortFields, so it is safe to interpolate
+	if cursor != nil {
+		value, err := cursorValue(opts.SortBy, cursor.Value)
+		if err != nil {
+			return nil, err
+		}
+		if opts.SortBy == "id" {
+			query = query.Where("id "+comparator+" ?", cursor.ID)
+		} else {
+			query = query.Where(
+				"("+opts.SortBy+" "+comparator+" ?) OR ("+opts.SortBy+" = ? AND id "+comparator+" ?)",
+				value, value, cursor.ID,
+			)
+		}
+	} else if opts.Offset > 0 {
+		query = query.Offset(opts.Offset)
+	}
+
+	query = query.Order(opts.SortBy + " " + direction)
+	if opts.SortBy != "id" {
+		query = query.Order("id " + direction)
+	}
+
+	// Fetch one extra row to learn whether another page exists
+	var users []models.User
+	if err := query.Limit(opts.Limit + 1).Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	page := &UserPage{Users: users, Total: total}
+	if len(users) > opts.Limit {
+		page.Users = users[:opts.Limit]
+		page.NextCursor = encodeUserCursor(opts, page.Users[opts.Limit-1])
+	}
+	return page, nil
+}
+
+// cursorValue converts a cursor value back to the type of its column
+func cursorValue(field, value string) (any, error) {
+	if field != "created_at" {
+		return value, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	// GORM stores local timestamps and SQLite compares them as text
+	return t.Local(), nil
+}
+
+// FindByID retrieves a user by ID
+func (r *gormUserRepository) FindByID(ctx context.Context, id uint) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).First(&user, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// FindByEmail retrieves a user by email address
+func (r *gormUserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Create creates a new user
+func (r *gormUserRepository) Create(ctx context.Context, user *models.User) error {
+	user.Version = 1
+	return r.translate(r.db.WithContext(ctx).Create(user).Error)
+}
+
+// Update writes every field of user if its version is unchanged since it was read
+func (r *gormUserRepository) Update(ctx context.Context, user *models.User) error {
+	expected := user.Version
+	user.Version = expected + 1
+
+	// Select("*") also writes zero values such as a cleared EmailVerifiedAt
+	result := r.db.WithContext(ctx).Model(user).Where("version = ?", expected).Select("*").Updates(user)
+	if result.Error != nil {
+		user.Version = expected
+		return r.translate(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		user.Version = expected
+		var count int64
+		if err := r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", user.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrUserNotFound
+		}
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// UpdateKeepingRole updates user unless that leaves no user with role. The
+// holders of role are locked until the update commits so that concurrent calls
+// see each other's changes; SQLite serializes write transactions instead.
+func (r *gormUserRepository) UpdateKeepingRole(ctx context.Context, user *models.User, role string) error {
+	if user.Role == role {
+		return r.Update(ctx, user)
+	}
+
+	expected := user.Version
+	err := r.keepingRole(ctx, user.ID, role, func(tx *gormUserRepository) error {
+		return tx.Update(ctx, user)
+	})
+	if err != nil {
+		user.Version = expected
+	}
+	return err
+}
+
+// keepingRole runs write in a transaction unless user id is the last holder
+// of role. The holders of role are locked until the transaction commits.
+func (r *gormUserRepository) keepingRole(ctx context.Context, id uint, role string, write func(tx *gormUserRepository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var holders []uint
+		if err := tx.Model(&models.User{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("role = ?", role).Pluck("id", &holders).Error; err != nil {
+			return err
+		}
+		if len(holders) == 1 && holders[0] == id {
+			return ErrLastRoleHolder
+		}
+		return write(&gormUserRepository{db: tx, translateError: r.translateError})
+	})
+}
+
+// translate applies translateError to a failed write
+func (r *gormUserRepository) translate(err error) error {
+	if err == nil || r.translateError == nil {
+		return err
+	}
+	return r.translateError(err)
+}
+
+// SetEmailVerifiedAt updates only the email verification timestamp.
+// Update cannot clear it because GORM skips nil fields.
+func (r *gormUserRepository) SetEmailVerifiedAt(ctx context.Context, id uint, verifiedAt *time.Time) error {
+	result := r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", id).Updates(map[string]any{
+		"email_verified_at": verifiedAt,
+		"version":           gorm.Expr("version + 1"),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// Delete deletes a user by ID
+func (r *gormUserRepository) Delete(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Delete(&models.User{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// DeleteKeepingRole deletes a user unless it is the last one with role
+func (r *gormUserRepository) DeleteKeepingRole(ctx context.Context, id uint, role string) error {
+	return r.keepingRole(ctx, id, role, func(tx *gormUserRepository) error {
+		return tx.Delete(ctx, id)
+	})
+}
@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"myapp/internal/models"
+	"time"
+)
+
+var (
+	// ErrLoginFailureNotFound is returned when no failures are tracked for a scope and identifier
+	ErrLoginFailureNotFound = errors.New("login failure not found")
+)
+
+// LoginFailureRepository defines the interface for failed login tracking
+type LoginFailureRepository interface {
+	Find(ctx context.Context, scope, identifier string) (*models.LoginFailure, error)
+	// Update loads the record for scope and identifier, or a new zero record,
+	// passes it to fn and stores the result in a single transaction
+	Update(ctx context.Context, scope, identifier string, fn func(failure *models.LoginFailure)) (*models.LoginFailure, error)
+	Delete(ctx context.Context, scope, identifier string) error
+	// List returns records of the given scope, or all scopes if empty, that
+	// failed at or after failedSince or are locked past lockedAfter, most recent first
+	List(ctx context.Context, scope string, failedSince, lockedAfter time.Time) ([]models.LoginFailure, error)
+	// Count returns the number of records of the given scope
+	Count(ctx context.Context, scope string) (int64, error)
+	// DeleteStale removes records that last failed before failedBefore and are
+	// not locked past lockedAfter, and returns how many were removed
+	DeleteStale(ctx context.Context, failedBefore, lockedAfter time.Time) (int64, error)
+}
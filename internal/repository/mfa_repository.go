@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"myapp/internal/models"
+)
+
+var (
+	// ErrTOTPNotFound is returned when a user has no TOTP factor
+	ErrTOTPNotFound = errors.New("totp not found")
+	// ErrTOTPCodeReused is returned when a TOTP code of an already used time step is presented again
+	ErrTOTPCodeReused = errors.New("totp code already used")
+	// ErrRecoveryCodeInvalid is returned when a recovery code does not exist or was already used
+	ErrRecoveryCodeInvalid = errors.New("invalid recovery code")
+)
+
+// MFARepository defines the interface for second factor persistence
+type MFARepository interface {
+	FindTOTP(ctx context.Context, userID uint) (*models.UserTOTP, error)
+	// SaveTOTP creates the TOTP factor of a user or replaces an existing one
+	SaveTOTP(ctx context.Context, totp *models.UserTOTP) error
+	// ConfirmTOTP enables the factor, records step as used and replaces the
+	// recovery codes of the user with codeHashes in a single transaction
+	ConfirmTOTP(ctx context.Context, userID uint, step int64, codeHashes []string) error
+	// DeleteTOTP removes the TOTP factor and all recovery codes of a user
+	DeleteTOTP(ctx context.Context, userID uint) error
+	// UseTOTPStep records step as used. It returns ErrTOTPCodeReused unless
+	// step is later than the last used one, so a code is accepted only once.
+	UseTOTPStep(ctx context.Context, userID uint, step int64) error
+	// UseRecoveryCode redeems an unused recovery code of the user
+	UseRecoveryCode(ctx context.Context, userID uint, codeHash string) error
+	// CountRecoveryCodes returns the number of unused recovery codes of the user
+	CountRecoveryCodes(ctx context.Context, userID uint) (int64, error)
+}
@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"myapp/internal/models"
+)
+
+// ErrPasswordResetTokenNotFound is returned when a password reset token is not found
+var ErrPasswordResetTokenNotFound = errors.New("password reset token not found")
+
+// PasswordResetTokenRepository defines the interface for password reset token persistence
+type PasswordResetTokenRepository interface {
+	Create(ctx context.Context, token *models.PasswordResetToken) error
+	FindByHash(ctx context.Context, tokenHash string) (*models.PasswordResetToken, error)
+	// InvalidateAllForUser marks every outstanding token of the user as used,
+	// which also redeems the token of a completed reset
+	InvalidateAllForUser(ctx context.Context, userID uint) error
+}
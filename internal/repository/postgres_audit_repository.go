@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"myapp/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// PostgresAuditRepository implements AuditRepository for PostgreSQL
+type PostgresAuditRepository struct {
+	db *gorm.DB
+}
+
+// NewPostgresAuditRepository creates a new PostgreSQL audit repository
+func NewPostgresAuditRepository(db *gorm.DB) AuditRepository {
+	return &PostgresAuditRepository{db: db}
+}
+
+// Create appends an event to the audit log
+func (r *PostgresAuditRepository) Create(ctx context.Context, event *models.AuditEvent) error {
+	return r.db.WithContext(ctx).Create(event).Error
+}
+
+// List returns a page of events matching the options, most recent first
+func (r *PostgresAuditRepository) List(ctx context.Context, opts AuditQueryOptions) (*AuditPage, error) {
+	opts.normalize()
+
+	query := r.db.WithContext(ctx).Model(&models.AuditEvent{})
+	if opts.ActorID != nil {
+		query = query.Where("actor_id = ?", *opts.ActorID)
+	}
+	if opts.Action != "" {
+		query = query.Where("action = ?", opts.Action)
+	}
+	if opts.TargetType != "" {
+		query = query.Where("target_type = ?", opts.TargetType)
+	}
+	if opts.TargetID != "" {
+		query = query.Where("target_id = ?", opts.TargetID)
+	}
+	if opts.Since != nil {
+		query = query.Where("occurred_at >= ?", *opts.Since)
+	}
+	if opts.Until != nil {
+		query = query.Where("occurred_at < ?", *opts.Until)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	events := []models.AuditEvent{}
+	if err := query.Order("id DESC").Limit(opts.Limit).Offset(opts.Offset).Find(&events).Error; err != nil {
+		return nil, err
+	}
+
+	return &AuditPage{Events: events, Total: total}, nil
+}
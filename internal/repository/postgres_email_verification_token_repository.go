@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"myapp/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PostgresEmailVerificationTokenRepository implements EmailVerificationTokenRepository for PostgreSQL
+type PostgresEmailVerificationTokenRepository struct {
+	db *gorm.DB
+}
+
+// NewPostgresEmailVerificationTokenRepository creates a new PostgreSQL email verification token repository
+func NewPostgresEmailVerificationTokenRepository(db *gorm.DB) EmailVerificationTokenRepository {
+	return &PostgresEmailVerificationTokenRepository{db: db}
+}
+
+// Create persists a new email verification token
+func (r *PostgresEmailVerificationTokenRepository) Create(ctx context.Context, token *models.EmailVerificationToken) error {
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+// FindByHash retrieves an email verification token by the hash of its value
+func (r *PostgresEmailVerificationTokenRepository) FindByHash(ctx context.Context, tokenHash string) (*models.EmailVerificationToken, error) {
+	var token models.EmailVerificationToken
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrEmailVerificationTokenNotFound
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkUsed sets used_at only if the token is still unused
+func (r *PostgresEmailVerificationTokenRepository) MarkUsed(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Model(&models.EmailVerificationToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrEmailVerificationTokenUsed
+	}
+	return nil
+}
+
+// InvalidateAllForUser marks every unused token of the given user as used
+func (r *PostgresEmailVerificationTokenRepository) InvalidateAllForUser(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Model(&models.EmailVerificationToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now()).Error
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"myapp/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PostgresLoginFailureRepository implements LoginFailureRepository for PostgreSQL
+type PostgresLoginFailureRepository struct {
+	db *gorm.DB
+}
+
+// NewPostgresLoginFailureRepository creates a new PostgreSQL login failure repository
+func NewPostgresLoginFailureRepository(db *gorm.DB) LoginFailureRepository {
+	return &PostgresLoginFailureRepository{db: db}
+}
+
+// Find retrieves the failures tracked for a scope and identifier
+func (r *PostgresLoginFailureRepository) Find(ctx context.Context, scope, identifier string) (*models.LoginFailure, error) {
+	// Find with a limit instead of First: a missing record is the common case and not worth logging
+	var failure models.LoginFailure
+	result := r.db.WithContext(ctx).Where("scope = ? AND identifier = ?", scope, identifier).Limit(1).Find(&failure)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrLoginFailureNotFound
+	}
+	return &failure, nil
+}
+
+// Update reads, modifies and upserts a record while holding a row lock where supported
+func (r *PostgresLoginFailureRepository) Update(ctx context.Context, scope, identifier string, fn func(failure *models.LoginFailure)) (*models.LoginFailure, error) {
+	var failure models.LoginFailure
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("scope = ? AND identifier = ?", scope, identifier)
+		if tx.Dialector.Name() == "postgres" {
+			query = query.Clauses(clause.Locking{Strength: "UPDATE"})
+		}
+		if err := query.Limit(1).Find(&failure).Error; err != nil {
+			return err
+		}
+		failure.Scope = scope
+		failure.Identifier = identifier
+
+		fn(&failure)
+
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "scope"}, {Name: "identifier"}},
+			DoUpdates: clause.AssignmentColumns([]string{"failures", "last_failed_at", "locked_until"}),
+		}).Create(&failure).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &failure, nil
+}
+
+// Delete removes the record for a scope and identifier
+func (r *PostgresLoginFailureRepository) Delete(ctx context.Context, scope, identifier string) error {
+	result := r.db.WithContext(ctx).Where("scope = ? AND identifier = ?", scope, identifier).Delete(&models.LoginFailure{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrLoginFailureNotFound
+	}
+	return nil
+}
+
+// List retrieves recent or still locked records, most recent failure first
+func (r *PostgresLoginFailureRepository) List(ctx context.Context, scope string, failedSince, lockedAfter time.Time) ([]models.LoginFailure, error) {
+	query := r.db.WithContext(ctx).Where("(last_failed_at >= ? OR locked_until > ?)", failedSince, lockedAfter)
+	if scope != "" {
+		query = query.Where("scope = ?", scope)
+	}
+
+	var failures []models.LoginFailure
+	if err := query.Order("last_failed_at DESC").Find(&failures).Error; err != nil {
+		return nil, err
+	}
+	return failures, nil
+}
+
+// Count counts the records of a scope
+func (r *PostgresLoginFailureRepository) Count(ctx context.Context, scope string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.LoginFailure{}).Where("scope = ?", scope).Count(&count).Error
+	return count, err
+}
+
+// DeleteStale removes records whose failures have aged out and whose lockout has ended
+func (r *PostgresLoginFailureRepository) DeleteStale(ctx context.Context, failedBefore, lockedAfter time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("last_failed_at < ? AND (locked_until IS NULL OR locked_until <= ?)", failedBefore, lockedAfter).
+		Delete(&models.LoginFailure{})
+	return result.RowsAffected, result.Error
+}
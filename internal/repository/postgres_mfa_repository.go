@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"myapp/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PostgresMFARepository implements MFARepository for PostgreSQL
+type PostgresMFARepository struct {
+	db *gorm.DB
+}
+
+// NewPostgresMFARepository creates a new PostgreSQL MFA repository
+func NewPostgresMFARepository(db *gorm.DB) MFARepository {
+	return &PostgresMFARepository{db: db}
+}
+
+// FindTOTP retrieves the TOTP factor of a user
+func (r *PostgresMFARepository) FindTOTP(ctx context.Context, userID uint) (*models.UserTOTP, error) {
+	// Find with a limit instead of First: most users have no factor, which is not worth logging
+	var totp models.UserTOTP
+	result := r.db.WithContext(ctx).Where("user_id = ?", userID).Limit(1).Find(&totp)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrTOTPNotFound
+	}
+	return &totp, nil
+}
+
+// SaveTOTP upserts the TOTP factor of a user
+func (r *PostgresMFARepository) SaveTOTP(ctx context.Context, totp *models.UserTOTP) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"secret", "last_used_step", "confirmed_at", "updated_at"}),
+	}).Create(totp).Error
+}
+
+// ConfirmTOTP marks the factor confirmed and stores a fresh set of recovery codes
+func (r *PostgresMFARepository) ConfirmTOTP(ctx context.Context, userID uint, step int64, codeHashes []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.UserTOTP{}).
+			Where("user_id = ? AND last_used_step < ?", userID, step).
+			Updates(map[string]any{"confirmed_at": time.Now(), "last_used_step": step})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTOTPCodeReused
+		}
+
+		if err := tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		codes := make([]models.MFARecoveryCode, len(codeHashes))
+		for i, hash := range codeHashes {
+			codes[i] = models.MFARecoveryCode{UserID: userID, CodeHash: hash}
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+// DeleteTOTP removes the factor and recovery codes of a user
+func (r *PostgresMFARepository) DeleteTOTP(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		result := tx.Where("user_id = ?", userID).Delete(&models.UserTOTP{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTOTPNotFound
+		}
+		return nil
+	})
+}
+
+// UseTOTPStep advances last_used_step only if step is newer
+func (r *PostgresMFARepository) UseTOTPStep(ctx context.Context, userID uint, step int64) error {
+	result := r.db.WithContext(ctx).Model(&models.UserTOTP{}).
+		Where("user_id = ? AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTOTPCodeReused
+	}
+	return nil
+}
+
+// UseRecoveryCode sets used_at only if the code belongs to the user and is still unused
+func (r *PostgresMFARepository) UseRecoveryCode(ctx context.Context, userID uint, codeHash string) error {
+	result := r.db.WithContext(ctx).Model(&models.MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRecoveryCodeInvalid
+	}
+	return nil
+}
+
+// CountRecoveryCodes counts the unused recovery codes of a user
+func (r *PostgresMFARepository) CountRecoveryCodes(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.MFARecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
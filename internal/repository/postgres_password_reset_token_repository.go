@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"myapp/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PostgresPasswordResetTokenRepository implements PasswordResetTokenRepository for PostgreSQL
+type PostgresPasswordResetTokenRepository struct {
+	db *gorm.DB
+}
+
+// NewPostgresPasswordResetTokenRepository creates a new PostgreSQL password reset token repository
+func NewPostgresPasswordResetTokenRepository(db *gorm.DB) PasswordResetTokenRepository {
+	return &PostgresPasswordResetTokenRepository{db: db}
+}
+
+// Create persists a new password reset token
+func (r *PostgresPasswordResetTokenRepository) Create(ctx context.Context, token *models.PasswordResetToken) error {
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+// FindByHash retrieves a password reset token by the hash of its value
+func (r *PostgresPasswordResetTokenRepository) FindByHash(ctx context.Context, tokenHash string) (*models.PasswordResetToken, error) {
+	var token models.PasswordResetToken
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPasswordResetTokenNotFound
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// InvalidateAllForUser marks every unused token of the given user as used
+func (r *PostgresPasswordResetTokenRepository) InvalidateAllForUser(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now()).Error
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"myapp/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PostgresRefreshTokenRepository implements RefreshTokenRepository for PostgreSQL
+type PostgresRefreshTokenRepository struct {
+	db *gorm.DB
+}
+
+// NewPostgresRefreshTokenRepository creates a new PostgreSQL refresh token repository
+func NewPostgresRefreshTokenRepository(db *gorm.DB) RefreshTokenRepository {
+	return &PostgresRefreshTokenRepository{db: db}
+}
+
+// Create persists a new refresh token
+func (r *PostgresRefreshTokenRepository) Create(ctx context.Context, token *models.RefreshToken) error {
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+// FindByHash retrieves a refresh token by the hash of its value
+func (r *PostgresRefreshTokenRepository) FindByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRefreshTokenNotFound
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Rotate revokes current and stores next in a single transaction.
+// The conditional update guarantees only one concurrent rotation can win.
+func (r *PostgresRefreshTokenRepository) Rotate(ctx context.Context, current, next *models.RefreshToken) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(next).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", current.ID).
+			Updates(map[string]any{"revoked_at": now, "replaced_by_id": next.ID})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRefreshTokenReused
+		}
+
+		current.RevokedAt = &now
+		current.ReplacedByID = &next.ID
+		return nil
+	})
+}
+
+// RevokeFamily revokes every active token sharing the given family ID
+func (r *PostgresRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	return r.db.WithContext(ctx).Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeAllForUser revokes every active token belonging to the given user
+func (r *PostgresRefreshTokenRepository) RevokeAllForUser(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
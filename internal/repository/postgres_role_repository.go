@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"myapp/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PostgresRoleRepository implements RoleRepository for PostgreSQL
+type PostgresRoleRepository struct {
+	db *gorm.DB
+}
+
+// NewPostgresRoleRepository creates a new PostgreSQL role repository
+func NewPostgresRoleRepository(db *gorm.DB) RoleRepository {
+	return &PostgresRoleRepository{db: db}
+}
+
+// ListPermissions retrieves all permissions ordered by name
+func (r *PostgresRoleRepository) ListPermissions(ctx context.Context) ([]models.Permission, error) {
+	var permissions []models.Permission
+	err := r.db.WithContext(ctx).Order("name").Find(&permissions).Error
+	return permissions, err
+}
+
+// EnsurePermissions inserts missing permissions and keeps existing ones unchanged
+func (r *PostgresRoleRepository) EnsurePermissions(ctx context.Context, permissions []models.Permission) error {
+	if len(permissions) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoNothing: true,
+	}).Create(&permissions).Error
+}
+
+// ListRoles retrieves all roles with their permissions
+func (r *PostgresRoleRepository) ListRoles(ctx context.Context) ([]models.Role, error) {
+	var roles []models.Role
+	err := r.db.WithContext(ctx).Preload("Permissions", withPermissionOrder).Order("name").Find(&roles).Error
+	return roles, err
+}
+
+// FindRoleByID retrieves a role and its permissions by ID
+func (r *PostgresRoleRepository) FindRoleByID(ctx context.Context, id uint) (*models.Role, error) {
+	return r.findRole(ctx, "id = ?", id)
+}
+
+// FindRoleByName retrieves a role and its permissions by name
+func (r *PostgresRoleRepository) FindRoleByName(ctx context.Context, name string) (*models.Role, error) {
+	return r.findRole(ctx, "name = ?", name)
+}
+
+// CreateRole inserts a role and its permissions in a single transaction
+func (r *PostgresRoleRepository) CreateRole(ctx context.Context, role *models.Role, permissionNames []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Role{}).Where("name = ?", role.Name).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrRoleExists
+		}
+
+		permissions, err := findPermissions(tx, permissionNames)
+		if err != nil {
+			return err
+		}
+
+		role.Permissions = nil
+		if err := tx.Create(role).Error; err != nil {
+			return err
+		}
+		return replacePermissions(tx, role, permissions)
+	})
+}
+
+// UpdateRole saves the description and replaces the permissions of a role
+func (r *PostgresRoleRepository) UpdateRole(ctx context.Context, role *models.Role, permissionNames []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		permissions, err := findPermissions(tx, permissionNames)
+		if err != nil {
+			return err
+		}
+
+		result := tx.Model(role).Update("description", role.Description)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRoleNotFound
+		}
+		return replacePermissions(tx, role, permissions)
+	})
+}
+
+// DeleteRole removes a role unless users still have it as their primary role
+func (r *PostgresRoleRepository) DeleteRole(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var role models.Role
+		if err := tx.First(&role, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRoleNotFound
+			}
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.User{}).Where("role = ?", role.Name).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrRoleInUse
+		}
+
+		if err := tx.Where("role_id = ?", id).Delete(&models.RoleBinding{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&role).Association("Permissions").Clear(); err != nil {
+			return err
+		}
+		return tx.Delete(&role).Error
+	})
+}
+
+// ListUserRoles retrieves the roles bound to a user
+func (r *PostgresRoleRepository) ListUserRoles(ctx context.Context, userID uint) ([]models.Role, error) {
+	var roles []models.Role
+	err := r.db.WithContext(ctx).Preload("Permissions", withPermissionOrder).
+		Where("id IN (?)", r.db.Model(&models.RoleBinding{}).Select("role_id").Where("user_id = ?", userID)).
+		Order("name").Find(&roles).Error
+	return roles, err
+}
+
+// BindRole grants a role to a user; binding an already bound role is a no-op
+func (r *PostgresRoleRepository) BindRole(ctx context.Context, userID, roleID uint) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.RoleBinding{UserID: userID, RoleID: roleID}).Error
+}
+
+// UnbindRole removes a role binding of a user
+func (r *PostgresRoleRepository) UnbindRole(ctx context.Context, userID, roleID uint) error {
+	result := r.db.WithContext(ctx).Where("user_id = ? AND role_id = ?", userID, roleID).Delete(&models.RoleBinding{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRoleNotFound
+	}
+	return nil
+}
+
+// PermissionsForUser collects the permissions of the primary and bound roles of a user
+func (r *PostgresRoleRepository) PermissionsForUser(ctx context.Context, userID uint, role string) ([]string, error) {
+	names := []string{}
+	err := r.db.WithContext(ctx).Model(&models.Permission{}).
+		Distinct("permissions.name").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Joins("JOIN roles ON roles.id = role_permissions.role_id").
+		Where("roles.name = ? OR roles.id IN (?)", role,
+			r.db.Model(&models.RoleBinding{}).Select("role_id").Where("user_id = ?", userID)).
+		Order("permissions.name").
+		Pluck("permissions.name", &names).Error
+	return names, err
+}
+
+// findRole retrieves a single role with its permissions
+func (r *PostgresRoleRepository) findRole(ctx context.Context, query string, arg any) (*models.Role, error) {
+	var role models.Role
+	if err := r.db.WithContext(ctx).Preload("Permissions", withPermissionOrder).Where(query, arg).First(&role).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRoleNotFound
+		}
+		return nil, err
+	}
+	return &role, nil
+}
+
+// findPermissions loads permissions by name and fails if any of them does not exist
+func findPermissions(tx *gorm.DB, names []string) ([]models.Permission, error) {
+	permissions := []models.Permission{}
+	if len(names) == 0 {
+		return permissions, nil
+	}
+	if err := tx.Where("name IN ?", names).Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]bool, len(permissions))
+	for _, p := range permissions {
+		found[p.Name] = true
+	}
+	for _, name := range names {
+		if !found[name] {
+			return nil, ErrUnknownPermission
+		}
+	}
+	return permissions, nil
+}
+
+// replacePermissions sets the permissions of a role to exactly the given ones
+func replacePermissions(tx *gorm.DB, role *models.Role, permissions []models.Permission) error {
+	if err := tx.Model(role).Association("Permissions").Replace(permissions); err != nil {
+		return err
+	}
+	role.Permissions = permissions
+	return nil
+}
+
+// withPermissionOrder sorts preloaded permissions by name
+func withPermissionOrder(db *gorm.DB) *gorm.DB {
+	return db.Order("permissions.name")
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"myapp/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PostgresTokenRevocationRepository implements TokenRevocationRepository for PostgreSQL
+type PostgresTokenRevocationRepository struct {
+	db *gorm.DB
+}
+
+// NewPostgresTokenRevocationRepository creates a new PostgreSQL token revocation repository
+func NewPostgresTokenRevocationRepository(db *gorm.DB) TokenRevocationRepository {
+	return &PostgresTokenRevocationRepository{db: db}
+}
+
+// RevokeToken adds a token to the revocation list
+func (r *PostgresTokenRevocationRepository) RevokeToken(ctx context.Context, jti string, userID uint, expiresAt time.Time) error {
+	revoked := models.RevokedToken{
+		JTI:       jti,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+		RevokedAt: time.Now(),
+	}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&revoked).Error
+}
+
+// IsTokenRevoked checks whether a token is on the revocation list
+func (r *PostgresTokenRevocationRepository) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// RevokeAllForUser stores or moves forward the revocation cutoff of a user
+func (r *PostgresTokenRevocationRepository) RevokeAllForUser(ctx context.Context, userID uint, before time.Time) error {
+	revocation := models.UserTokenRevocation{
+		UserID:        userID,
+		RevokedBefore: before,
+	}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"revoked_before"}),
+	}).Create(&revocation).Error
+}
+
+// RevokedBefore returns the revocation cutoff of a user
+func (r *PostgresTokenRevocationRepository) RevokedBefore(ctx context.Context, userID uint) (time.Time, error) {
+	var revocation models.UserTokenRevocation
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&revocation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+	return revocation.RevokedBefore, nil
+}
+
+// DeleteExpired removes revoked tokens that expired before now; they fail validation anyway
+func (r *PostgresTokenRevocationRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("expires_at < ?", now).Delete(&models.RevokedToken{})
+	return result.RowsAffected, result.Error
+}
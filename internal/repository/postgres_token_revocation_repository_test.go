@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"context"
+	"myapp/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestPostgresTokenRevocationRepositoryDeleteExpired(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should remove only tokens that have expired", func(t *testing.T) {
+		db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+		require.NoError(t, err)
+		sqlDB, err := db.DB()
+		require.NoError(t, err)
+		sqlDB.SetMaxOpenConns(1)
+		require.NoError(t, db.AutoMigrate(&models.RevokedToken{}))
+		repo := NewPostgresTokenRevocationRepository(db)
+
+		now := time.Now()
+		require.NoError(t, repo.RevokeToken(ctx, "expired", 1, now.Add(-time.Minute)))
+		require.NoError(t, repo.RevokeToken(ctx, "active", 1, now.Add(time.Minute)))
+
+		removed, err := repo.DeleteExpired(ctx, now)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), removed)
+
+		revoked, err := repo.IsTokenRevoked(ctx, "expired")
+		require.NoError(t, err)
+		assert.False(t, revoked)
+		revoked, err = repo.IsTokenRevoked(ctx, "active")
+		require.NoError(t, err)
+		assert.True(t, revoked)
+	})
+}
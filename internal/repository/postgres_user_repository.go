@@ -1,74 +1,38 @@
 package repository
 
 import (
-	"context"
 	"errors"
-	"myapp/internal/models"
+	"fmt"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
 )
 
+// pgUniqueViolation is the SQLSTATE of a unique constraint violation
+const pgUniqueViolation = "23505"
+
 // PostgresUserRepository implements UserRepository for PostgreSQL
 type PostgresUserRepository struct {
-	db *gorm.DB
+	gormUserRepository
 }
 
 // NewPostgresUserRepository creates a new PostgreSQL user repository
 func NewPostgresUserRepository(db *gorm.DB) UserRepository {
-	return &PostgresUserRepository{db: db}
-}
-
-var (
-	// ErrUserNotFound is returned when a user is not found
-	ErrUserNotFound = errors.New("user not found")
-)
-
-// FindAll retrieves all users
-func (r *PostgresUserRepository) FindAll(ctx context.Context) ([]models.User, error) {
-	var users []models.User
-	if err := r.db.WithContext(ctx).Find(&users).Error; err != nil {
-		return nil, err
-	}
-	return users, nil
-}
-
-// FindByID retrieves a user by ID
-func (r *PostgresUserRepository) FindByID(ctx context.Context, id uint) (*models.User, error) {
-	var user models.User
-	if err := r.db.WithContext(ctx).First(&user, id).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrUserNotFound
-		}
-		return nil, err
-	}
-	return &user, nil
-}
-
-// Create creates a new user
-func (r *PostgresUserRepository) Create(ctx context.Context, user *models.User) error {
-	return r.db.WithContext(ctx).Create(user).Error
-}
-
-// Update updates an existing user
-func (r *PostgresUserRepository) Update(ctx context.Context, user *models.User) error {
-	result := r.db.WithContext(ctx).Model(user).Updates(user)
-	if result.Error != nil {
-		return result.Error
-	}
-	if result.RowsAffected == 0 {
-		return ErrUserNotFound
-	}
-	return nil
+	return &PostgresUserRepository{gormUserRepository{db: db, translateError: translatePostgresError}}
 }
 
-// Delete deletes a user by ID
-func (r *PostgresUserRepository) Delete(ctx context.Context, id uint) error {
-	result := r.db.WithContext(ctx).Delete(&models.User{}, id)
-	if result.Error != nil {
-		return result.Error
+// translatePostgresError maps unique violations to ErrEmailTaken or ErrConflict.
+// The email constraint is users_email_key when created by the migrations and
+// idx_users_email when created by AutoMigrate.
+func translatePostgresError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != pgUniqueViolation {
+		return err
 	}
-	if result.RowsAffected == 0 {
-		return ErrUserNotFound
+	switch pgErr.ConstraintName {
+	case "users_email_key", "idx_users_email":
+		return ErrEmailTaken
+	default:
+		return fmt.Errorf("%w: %s", ErrConflict, pgErr.ConstraintName)
 	}
-	return nil
 }
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"myapp/internal/models"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// newMockPostgresUserRepository returns a repository whose SQL is answered by mock
+func newMockPostgresUserRepository(t *testing.T) (UserRepository, sqlmock.Sqlmock) {
+	t.Helper()
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { mockDB.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: mockDB}), &gorm.Config{})
+	require.NoError(t, err)
+	return NewPostgresUserRepository(db), mock
+}
+
+func TestPostgresUserRepositoryErrors(t *testing.T) {
+	ctx := context.Background()
+	uniqueViolation := func(constraint string) error {
+		return &pgconn.PgError{Code: pgUniqueViolation, TableName: "users", ConstraintName: constraint}
+	}
+
+	t.Run("should map a duplicate email on create to ErrEmailTaken", func(t *testing.T) {
+		repo, mock := newMockPostgresUserRepository(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`INSERT INTO "users"`).WillReturnError(uniqueViolation("users_email_key"))
+		mock.ExpectRollback()
+
+		err := repo.Create(ctx, &models.User{Name: "Alice", Email: "alice@example.com", PasswordHash: "hash"})
+
+		assert.ErrorIs(t, err, ErrEmailTaken)
+		assert.ErrorIs(t, err, ErrConflict)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should map a duplicate email on update to ErrEmailTaken", func(t *testing.T) {
+		repo, mock := newMockPostgresUserRepository(t)
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "users"`).WillReturnError(uniqueViolation("idx_users_email"))
+		mock.ExpectRollback()
+
+		err := repo.Update(ctx, &models.User{ID: 2, Email: "alice@example.com"})
+
+		assert.ErrorIs(t, err, ErrEmailTaken)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should map other unique violations to ErrConflict", func(t *testing.T) {
+		repo, mock := newMockPostgresUserRepository(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`INSERT INTO "users"`).WillReturnError(uniqueViolation("users_pkey"))
+		mock.ExpectRollback()
+
+		err := repo.Create(ctx, &models.User{ID: 1, Name: "Alice", Email: "alice@example.com"})
+
+		assert.ErrorIs(t, err, ErrConflict)
+		assert.NotErrorIs(t, err, ErrEmailTaken)
+		assert.Contains(t, err.Error(), "users_pkey")
+	})
+
+	t.Run("should pass other errors through", func(t *testing.T) {
+		repo, mock := newMockPostgresUserRepository(t)
+		dbErr := &pgconn.PgError{Code: "23502", ColumnName: "name"} // not_null_violation
+		mock.ExpectBegin()
+		mock.ExpectQuery(`INSERT INTO "users"`).WillReturnError(dbErr)
+		mock.ExpectRollback()
+
+		err := repo.Create(ctx, &models.User{Email: "alice@example.com"})
+
+		assert.False(t, errors.Is(err, ErrConflict))
+		var pgErr *pgconn.PgError
+		assert.ErrorAs(t, err, &pgErr)
+	})
+}
+
+func TestPostgresUserRepositoryUpdateKeepingRole(t *testing.T) {
+	t.Run("should lock the holders of the role before removing it", func(t *testing.T) {
+		repo, mock := newMockPostgresUserRepository(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT "id" FROM "users" WHERE role = \$1 .*FOR UPDATE`).
+			WithArgs("admin").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectRollback()
+
+		user := &models.User{ID: 1, Name: "Admin", Email: "admin@example.com", Role: "user", Version: 4}
+		err := repo.UpdateKeepingRole(context.Background(), user, "admin")
+
+		assert.ErrorIs(t, err, ErrLastRoleHolder)
+		assert.Equal(t, uint(4), user.Version)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"myapp/internal/models"
+)
+
+var (
+	// ErrRefreshTokenNotFound is returned when a refresh token is not found
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+	// ErrRefreshTokenReused is returned when an already rotated refresh token is presented again
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+// RefreshTokenRepository defines the interface for refresh token persistence
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *models.RefreshToken) error
+	FindByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	// Rotate atomically revokes current and persists next as its replacement.
+	// It returns ErrRefreshTokenReused if current was already revoked.
+	Rotate(ctx context.Context, current, next *models.RefreshToken) error
+	RevokeFamily(ctx context.Context, familyID string) error
+	RevokeAllForUser(ctx context.Context, userID uint) error
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"myapp/internal/models"
+)
+
+var (
+	// ErrRoleNotFound is returned when a role is not found
+	ErrRoleNotFound = errors.New("role not found")
+	// ErrRoleExists is returned when creating a role with a name that is already taken
+	ErrRoleExists = errors.New("role already exists")
+	// ErrRoleInUse is returned when deleting a role that is still the primary role of a user
+	ErrRoleInUse = errors.New("role is assigned to users")
+	// ErrUnknownPermission is returned when granting a permission that does not exist
+	ErrUnknownPermission = errors.New("unknown permission")
+)
+
+// RoleRepository defines the interface for roles, permissions and role bindings
+type RoleRepository interface {
+	ListPermissions(ctx context.Context) ([]models.Permission, error)
+	// EnsurePermissions creates the given permissions if they do not exist yet
+	EnsurePermissions(ctx context.Context, permissions []models.Permission) error
+
+	// ListRoles returns every role with its permissions, ordered by name
+	ListRoles(ctx context.Context) ([]models.Role, error)
+	FindRoleByID(ctx context.Context, id uint) (*models.Role, error)
+	FindRoleByName(ctx context.Context, name string) (*models.Role, error)
+	// CreateRole creates a role granting permissionNames. It returns
+	// ErrRoleExists or ErrUnknownPermission without creating anything.
+	CreateRole(ctx context.Context, role *models.Role, permissionNames []string) error
+	// UpdateRole stores the description of role and replaces its permissions with permissionNames
+	UpdateRole(ctx context.Context, role *models.Role, permissionNames []string) error
+	// DeleteRole removes a role and its bindings. It returns ErrRoleInUse if
+	// a user still has it as their primary role.
+	DeleteRole(ctx context.Context, id uint) error
+
+	// ListUserRoles returns the roles bound to a user, not including the primary role
+	ListUserRoles(ctx context.Context, userID uint) ([]models.Role, error)
+	BindRole(ctx context.Context, userID, roleID uint) error
+	UnbindRole(ctx context.Context, userID, roleID uint) error
+
+	// PermissionsForUser returns the sorted, distinct permission names granted
+	// by the primary role and every role bound to the user
+	PermissionsForUser(ctx context.Context, userID uint, role string) ([]string, error)
+}
@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// SQLiteUserRepository implements UserRepository for SQLite.
+// It is intended for local development and hermetic tests.
+type SQLiteUserRepository struct {
+	gormUserRepository
+}
+
+// NewSQLiteUserRepository creates a new SQLite user repository
+func NewSQLiteUserRepository(db *gorm.DB) UserRepository {
+	return &SQLiteUserRepository{gormUserRepository{db: db, translateError: translateSQLiteError}}
+}
+
+// translateSQLiteError maps unique violations to ErrEmailTaken or ErrConflict.
+// SQLite only names the columns, as in "UNIQUE constraint failed: users.email".
+// The error text is matched instead of sqlite3.Error, which only exists in cgo
+// builds.
+func translateSQLiteError(err error) error {
+	message := err.Error()
+	if !strings.Contains(message, "UNIQUE constraint failed: ") {
+		return err
+	}
+	if strings.HasSuffix(message, "users.email") {
+		return ErrEmailTaken
+	}
+	return fmt.Errorf("%w: %s", ErrConflict, message)
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// TokenRevocationRepository defines the interface for the access token revocation list
+type TokenRevocationRepository interface {
+	// RevokeToken rejects the token with the given jti until expiresAt
+	RevokeToken(ctx context.Context, jti string, userID uint, expiresAt time.Time) error
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+	// RevokeAllForUser rejects every token of the user issued before the given time
+	RevokeAllForUser(ctx context.Context, userID uint, before time.Time) error
+	// RevokedBefore returns the user's revocation cutoff, or the zero time if there is none
+	RevokedBefore(ctx context.Context, userID uint) (time.Time, error)
+	// DeleteExpired removes revoked tokens that expired before now and returns how many were removed
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+// IsAccessTokenRevoked reports whether a token with the given claims has been
+// revoked either individually or through a revocation of all of the user's tokens.
+func IsAccessTokenRevoked(ctx context.Context, repo TokenRevocationRepository, jti string, userID uint, issuedAt time.Time) (bool, error) {
+	if jti != "" {
+		revoked, err := repo.IsTokenRevoked(ctx, jti)
+		if err != nil || revoked {
+			return revoked, err
+		}
+	}
+
+	cutoff, err := repo.RevokedBefore(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	if cutoff.IsZero() {
+		return false, nil
+	}
+
+	// iat has millisecond precision, so a token issued within the cutoff
+	// millisecond stays valid; a login right after a password change must not
+	// receive a token that is already revoked
+	return issuedAt.Before(cutoff.Truncate(time.Millisecond)), nil
+}
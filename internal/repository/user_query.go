@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"myapp/internal/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultUserPageSize is used when no limit is requested
+	DefaultUserPageSize = 20
+	// MaxUserPageSize caps the number of users returned per page
+	MaxUserPageSize = 100
+)
+
+var (
+	// ErrInvalidCursor is returned when a pagination cursor cannot be decoded or
+	// was issued for a different sort order
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrInvalidSortField is returned when sorting by a field that is not supported
+	ErrInvalidSortField = errors.New("invalid sort field")
+)
+
+// UserSortFields lists the fields users can be sorted by
+var UserSortFields = []string{"id", "name", "email", "created_at"}
+
+// UserQueryOptions controls filtering, sorting and pagination of users.
+// Cursor and Offset are alternatives; a cursor takes precedence.
+type UserQueryOptions struct {
+	Limit  int
+	Offset int
+	Cursor string
+
+	Role          string
+	EmailContains string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+
+	SortBy   string
+	SortDesc bool
+}
+
+// UserPage is a single page of users
+type UserPage struct {
+	Users      []models.User
+	Total      int64
+	NextCursor string
+}
+
+// userCursor is the decoded form of an opaque pagination cursor. It records the
+// sort key and ID of the last user on a page so the next page starts after it.
+type userCursor struct {
+	SortBy string `json:"s"`
+	Desc   bool   `json:"d,omitempty"`
+	Value  string `json:"v"`
+	ID     uint   `json:"id"`
+}
+
+// normalize applies defaults and validates the options
+func (o *UserQueryOptions) normalize() error {
+	if o.Limit <= 0 {
+		o.Limit = DefaultUserPageSize
+	}
+	if o.Limit > MaxUserPageSize {
+		o.Limit = MaxUserPageSize
+	}
+	if o.Offset < 0 {
+		o.Offset = 0
+	}
+	if o.SortBy == "" {
+		o.SortBy = "id"
+	}
+	if !isUserSortField(o.SortBy) {
+		return ErrInvalidSortField
+	}
+	return nil
+}
+
+// decodeCursor parses the cursor and checks it matches the requested sort order
+func (o *UserQueryOptions) decodeCursor() (*userCursor, error) {
+	if o.Cursor == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(o.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor userCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.SortBy != o.SortBy || cursor.Desc != o.SortDesc {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// encodeUserCursor creates the cursor pointing after user
+func encodeUserCursor(opts UserQueryOptions, user models.User) string {
+	cursor := userCursor{
+		SortBy: opts.SortBy,
+		Desc:   opts.SortDesc,
+		Value:  userSortValue(user, opts.SortBy),
+		ID:     user.ID,
+	}
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// userSortValue returns the string form of the sort field of a user
+func userSortValue(user models.User, field string) string {
+	switch field {
+	case "name":
+		return user.Name
+	case "email":
+		return user.Email
+	case "created_at":
+		return user.CreatedAt.UTC().Format(time.RFC3339Nano)
+	default:
+		return strconv.FormatUint(uint64(user.ID), 10)
+	}
+}
+
+// isUserSortField reports whether field can be used for sorting
+func isUserSortField(field string) bool {
+	for _, f := range UserSortFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// escapeLike escapes LIKE wildcards so the input matches literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -2,7 +2,26 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"myapp/internal/models"
+	"time"
+)
+
+var (
+	// ErrUserNotFound is returned when a user is not found
+	ErrUserNotFound = errors.New("user not found")
+	// ErrConflict is returned when a write violates a unique constraint
+	ErrConflict = errors.New("conflicting user")
+	// ErrEmailTaken is returned when another user already has the email address.
+	// It wraps ErrConflict.
+	ErrEmailTaken = fmt.Errorf("email already registered: %w", ErrConflict)
+	// ErrVersionConflict is returned by Update when the user was changed since
+	// it was read. It wraps ErrConflict.
+	ErrVersionConflict = fmt.Errorf("user was modified concurrently: %w", ErrConflict)
+	// ErrLastRoleHolder is returned by UpdateKeepingRole when the update would
+	// take a role away from the only user that has it
+	ErrLastRoleHolder = errors.New("user is the last one with the role")
 )
 
 //go:generate mockgen -source=user_repository.go -destination=user_repository_mock.go -package=repository
@@ -10,8 +29,24 @@ import (
 // UserRepository defines the interface for user data operations
 type UserRepository interface {
 	FindAll(ctx context.Context) ([]models.User, error)
+	// FindPage returns one filtered and sorted page of users plus the total match count
+	FindPage(ctx context.Context, opts UserQueryOptions) (*UserPage, error)
 	FindByID(ctx context.Context, id uint) (*models.User, error)
+	FindByEmail(ctx context.Context, email string) (*models.User, error)
+	// Create stores a new user at version 1
 	Create(ctx context.Context, user *models.User) error
+	// Update stores every field of user if it still has user.Version, then
+	// increments the version. Otherwise it returns ErrVersionConflict.
 	Update(ctx context.Context, user *models.User) error
+	// UpdateKeepingRole works like Update but returns ErrLastRoleHolder if no
+	// user would have role afterwards. Concurrent calls cannot both remove the
+	// last two holders.
+	UpdateKeepingRole(ctx context.Context, user *models.User, role string) error
+	// SetEmailVerifiedAt sets or, with nil, clears the email verification timestamp
+	// and increments the version
+	SetEmailVerifiedAt(ctx context.Context, id uint, verifiedAt *time.Time) error
 	Delete(ctx context.Context, id uint) error
+	// DeleteKeepingRole works like Delete but returns ErrLastRoleHolder if the
+	// user is the last one with role
+	DeleteKeepingRole(ctx context.Context, id uint, role string) error
 }
@@ -0,0 +1,485 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"myapp/internal/models"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// runUserRepositoryConformance verifies the behavior every UserRepository backend must provide.
+// newRepo must return an empty repository for each call.
+func runUserRepositoryConformance(t *testing.T, newRepo func(t *testing.T) UserRepository) {
+	ctx := context.Background()
+
+	newUser := func(name, email string) *models.User {
+		return &models.User{Name: name, Email: email, PasswordHash: "hash", Role: "user"}
+	}
+
+	t.Run("Create assigns ID and timestamps", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser("Alice", "alice@example.com")
+
+		require.NoError(t, repo.Create(ctx, user))
+		assert.NotZero(t, user.ID)
+		assert.False(t, user.CreatedAt.IsZero())
+	})
+
+	t.Run("Create assigns distinct IDs", func(t *testing.T) {
+		repo := newRepo(t)
+		alice := newUser("Alice", "alice@example.com")
+		bob := newUser("Bob", "bob@example.com")
+
+		require.NoError(t, repo.Create(ctx, alice))
+		require.NoError(t, repo.Create(ctx, bob))
+		assert.NotEqual(t, alice.ID, bob.ID)
+	})
+
+	t.Run("Create rejects duplicate email", func(t *testing.T) {
+		repo := newRepo(t)
+
+		require.NoError(t, repo.Create(ctx, newUser("Alice", "alice@example.com")))
+		err := repo.Create(ctx, newUser("Other", "alice@example.com"))
+		assert.ErrorIs(t, err, ErrEmailTaken)
+		assert.ErrorIs(t, err, ErrConflict)
+	})
+
+	t.Run("FindByID returns created user", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser("Alice", "alice@example.com")
+		require.NoError(t, repo.Create(ctx, user))
+
+		found, err := repo.FindByID(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Alice", found.Name)
+		assert.Equal(t, "alice@example.com", found.Email)
+		assert.Equal(t, "hash", found.PasswordHash)
+		assert.Equal(t, "user", found.Role)
+	})
+
+	t.Run("FindByID returns ErrUserNotFound for unknown ID", func(t *testing.T) {
+		repo := newRepo(t)
+
+		_, err := repo.FindByID(ctx, 9999)
+		assert.True(t, errors.Is(err, ErrUserNotFound))
+	})
+
+	t.Run("FindByEmail returns matching user", func(t *testing.T) {
+		repo := newRepo(t)
+		require.NoError(t, repo.Create(ctx, newUser("Alice", "alice@example.com")))
+		require.NoError(t, repo.Create(ctx, newUser("Bob", "bob@example.com")))
+
+		found, err := repo.FindByEmail(ctx, "bob@example.com")
+		require.NoError(t, err)
+		assert.Equal(t, "Bob", found.Name)
+	})
+
+	t.Run("FindByEmail returns ErrUserNotFound for unknown email", func(t *testing.T) {
+		repo := newRepo(t)
+
+		_, err := repo.FindByEmail(ctx, "nobody@example.com")
+		assert.True(t, errors.Is(err, ErrUserNotFound))
+	})
+
+	t.Run("FindAll returns every user", func(t *testing.T) {
+		repo := newRepo(t)
+
+		users, err := repo.FindAll(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, users)
+
+		require.NoError(t, repo.Create(ctx, newUser("Alice", "alice@example.com")))
+		require.NoError(t, repo.Create(ctx, newUser("Bob", "bob@example.com")))
+
+		users, err = repo.FindAll(ctx)
+		require.NoError(t, err)
+		assert.Len(t, users, 2)
+	})
+
+	t.Run("Update persists changes", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser("Alice", "alice@example.com")
+		require.NoError(t, repo.Create(ctx, user))
+
+		user.Name = "Alice Smith"
+		user.Email = "alice.smith@example.com"
+		require.NoError(t, repo.Update(ctx, user))
+
+		found, err := repo.FindByID(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Alice Smith", found.Name)
+		assert.Equal(t, "alice.smith@example.com", found.Email)
+	})
+
+	t.Run("Update returns ErrUserNotFound for unknown user", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser("Ghost", "ghost@example.com")
+		user.ID = 9999
+
+		assert.True(t, errors.Is(repo.Update(ctx, user), ErrUserNotFound))
+	})
+
+	t.Run("Update rejects email of another user", func(t *testing.T) {
+		repo := newRepo(t)
+		require.NoError(t, repo.Create(ctx, newUser("Alice", "alice@example.com")))
+		bob := newUser("Bob", "bob@example.com")
+		require.NoError(t, repo.Create(ctx, bob))
+
+		bob.Email = "alice@example.com"
+		assert.ErrorIs(t, repo.Update(ctx, bob), ErrEmailTaken)
+	})
+
+	t.Run("Update increments the version", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser("Alice", "alice@example.com")
+		require.NoError(t, repo.Create(ctx, user))
+		assert.Equal(t, uint(1), user.Version)
+
+		user.Name = "Alice Smith"
+		require.NoError(t, repo.Update(ctx, user))
+		assert.Equal(t, uint(2), user.Version)
+
+		found, err := repo.FindByID(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, uint(2), found.Version)
+	})
+
+	t.Run("Update rejects a stale version", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser("Alice", "alice@example.com")
+		require.NoError(t, repo.Create(ctx, user))
+
+		first, err := repo.FindByID(ctx, user.ID)
+		require.NoError(t, err)
+		second, err := repo.FindByID(ctx, user.ID)
+		require.NoError(t, err)
+
+		first.Name = "First"
+		require.NoError(t, repo.Update(ctx, first))
+		second.Name = "Second"
+		err = repo.Update(ctx, second)
+		assert.ErrorIs(t, err, ErrVersionConflict)
+		assert.ErrorIs(t, err, ErrConflict)
+		assert.Equal(t, uint(1), second.Version)
+
+		found, err := repo.FindByID(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "First", found.Name)
+	})
+
+	t.Run("Update clears fields set to zero values", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser("Alice", "alice@example.com")
+		require.NoError(t, repo.Create(ctx, user))
+		verifiedAt := time.Now()
+		require.NoError(t, repo.SetEmailVerifiedAt(ctx, user.ID, &verifiedAt))
+
+		found, err := repo.FindByID(ctx, user.ID)
+		require.NoError(t, err)
+		found.EmailVerifiedAt = nil
+		require.NoError(t, repo.Update(ctx, found))
+
+		found, err = repo.FindByID(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Nil(t, found.EmailVerifiedAt)
+	})
+
+	t.Run("UpdateKeepingRole refuses to remove the last holder of the role", func(t *testing.T) {
+		repo := newRepo(t)
+		admin := newUser("Admin", "admin@example.com")
+		admin.Role = "admin"
+		require.NoError(t, repo.Create(ctx, admin))
+
+		admin.Role = "user"
+		assert.ErrorIs(t, repo.UpdateKeepingRole(ctx, admin, "admin"), ErrLastRoleHolder)
+		assert.Equal(t, uint(1), admin.Version)
+
+		found, err := repo.FindByID(ctx, admin.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "admin", found.Role)
+	})
+
+	t.Run("UpdateKeepingRole removes the role while another user has it", func(t *testing.T) {
+		repo := newRepo(t)
+		first := newUser("First", "first@example.com")
+		first.Role = "admin"
+		require.NoError(t, repo.Create(ctx, first))
+		second := newUser("Second", "second@example.com")
+		second.Role = "admin"
+		require.NoError(t, repo.Create(ctx, second))
+
+		first.Role = "user"
+		require.NoError(t, repo.UpdateKeepingRole(ctx, first, "admin"))
+		assert.Equal(t, uint(2), first.Version)
+
+		second.Role = "user"
+		assert.ErrorIs(t, repo.UpdateKeepingRole(ctx, second, "admin"), ErrLastRoleHolder)
+	})
+
+	t.Run("UpdateKeepingRole updates other users like Update", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser("Alice", "alice@example.com")
+		require.NoError(t, repo.Create(ctx, user))
+
+		user.Role = "admin"
+		require.NoError(t, repo.UpdateKeepingRole(ctx, user, "admin"))
+		user.Name = "Alice Smith"
+		require.NoError(t, repo.UpdateKeepingRole(ctx, user, "admin"))
+
+		found, err := repo.FindByID(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "admin", found.Role)
+		assert.Equal(t, "Alice Smith", found.Name)
+		assert.Equal(t, uint(3), found.Version)
+
+		stale := *found
+		stale.Version = 1
+		stale.Role = "user"
+		assert.ErrorIs(t, repo.UpdateKeepingRole(ctx, &stale, "user"), ErrVersionConflict)
+	})
+
+	t.Run("DeleteKeepingRole refuses to delete the last holder of the role", func(t *testing.T) {
+		repo := newRepo(t)
+		first := newUser("First", "first@example.com")
+		first.Role = "admin"
+		require.NoError(t, repo.Create(ctx, first))
+		second := newUser("Second", "second@example.com")
+		second.Role = "admin"
+		require.NoError(t, repo.Create(ctx, second))
+
+		require.NoError(t, repo.DeleteKeepingRole(ctx, first.ID, "admin"))
+		assert.ErrorIs(t, repo.DeleteKeepingRole(ctx, second.ID, "admin"), ErrLastRoleHolder)
+
+		_, err := repo.FindByID(ctx, second.ID)
+		assert.NoError(t, err)
+	})
+
+	t.Run("DeleteKeepingRole deletes other users like Delete", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser("Alice", "alice@example.com")
+		require.NoError(t, repo.Create(ctx, user))
+
+		require.NoError(t, repo.DeleteKeepingRole(ctx, user.ID, "admin"))
+		_, err := repo.FindByID(ctx, user.ID)
+		assert.ErrorIs(t, err, ErrUserNotFound)
+		assert.ErrorIs(t, repo.DeleteKeepingRole(ctx, user.ID, "admin"), ErrUserNotFound)
+	})
+
+	t.Run("SetEmailVerifiedAt sets and clears the timestamp", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser("Alice", "alice@example.com")
+		require.NoError(t, repo.Create(ctx, user))
+
+		found, err := repo.FindByID(ctx, user.ID)
+		require.NoError(t, err)
+		assert.False(t, found.IsEmailVerified())
+
+		verifiedAt := time.Now()
+		require.NoError(t, repo.SetEmailVerifiedAt(ctx, user.ID, &verifiedAt))
+		found, err = repo.FindByID(ctx, user.ID)
+		require.NoError(t, err)
+		require.True(t, found.IsEmailVerified())
+		assert.WithinDuration(t, verifiedAt, *found.EmailVerifiedAt, time.Second)
+
+		require.NoError(t, repo.SetEmailVerifiedAt(ctx, user.ID, nil))
+		found, err = repo.FindByID(ctx, user.ID)
+		require.NoError(t, err)
+		assert.False(t, found.IsEmailVerified())
+		assert.Equal(t, uint(3), found.Version, "every change increments the version")
+	})
+
+	t.Run("SetEmailVerifiedAt returns ErrUserNotFound for unknown user", func(t *testing.T) {
+		repo := newRepo(t)
+		now := time.Now()
+
+		assert.True(t, errors.Is(repo.SetEmailVerifiedAt(ctx, 9999, &now), ErrUserNotFound))
+	})
+
+	t.Run("Delete removes user", func(t *testing.T) {
+		repo := newRepo(t)
+		user := newUser("Alice", "alice@example.com")
+		require.NoError(t, repo.Create(ctx, user))
+
+		require.NoError(t, repo.Delete(ctx, user.ID))
+
+		_, err := repo.FindByID(ctx, user.ID)
+		assert.True(t, errors.Is(err, ErrUserNotFound))
+		_, err = repo.FindByEmail(ctx, "alice@example.com")
+		assert.True(t, errors.Is(err, ErrUserNotFound))
+
+		users, err := repo.FindAll(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, users)
+	})
+
+	t.Run("Delete returns ErrUserNotFound for unknown user", func(t *testing.T) {
+		repo := newRepo(t)
+
+		assert.True(t, errors.Is(repo.Delete(ctx, 9999), ErrUserNotFound))
+	})
+
+	seed := func(t *testing.T, repo UserRepository) {
+		for _, u := range []struct{ name, email, role string }{
+			{"Carol", "carol@example.com", "user"},
+			{"Alice", "alice@example.com", "admin"},
+			{"Bob", "bob@corp.example", "user"},
+			{"Alice", "alice2@corp.example", "user"},
+			{"Dave", "dave_x@example.com", "admin"},
+		} {
+			user := newUser(u.name, u.email)
+			user.Role = u.role
+			require.NoError(t, repo.Create(ctx, user))
+		}
+	}
+
+	emails := func(users []models.User) []string {
+		result := make([]string, len(users))
+		for i, u := range users {
+			result[i] = u.Email
+		}
+		return result
+	}
+
+	t.Run("FindPage paginates by offset with total", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo)
+
+		page, err := repo.FindPage(ctx, UserQueryOptions{Limit: 2, Offset: 2})
+		require.NoError(t, err)
+		assert.Equal(t, int64(5), page.Total)
+		assert.Equal(t, []string{"bob@corp.example", "alice2@corp.example"}, emails(page.Users))
+		assert.NotEmpty(t, page.NextCursor)
+
+		page, err = repo.FindPage(ctx, UserQueryOptions{Limit: 2, Offset: 4})
+		require.NoError(t, err)
+		assert.Len(t, page.Users, 1)
+		assert.Empty(t, page.NextCursor)
+	})
+
+	t.Run("FindPage filters by role and email substring", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo)
+
+		page, err := repo.FindPage(ctx, UserQueryOptions{Role: "admin"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), page.Total)
+		assert.Equal(t, []string{"alice@example.com", "dave_x@example.com"}, emails(page.Users))
+
+		page, err = repo.FindPage(ctx, UserQueryOptions{EmailContains: "CORP", Role: "user"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"bob@corp.example", "alice2@corp.example"}, emails(page.Users))
+
+		// Wildcards in the filter match literally
+		page, err = repo.FindPage(ctx, UserQueryOptions{EmailContains: "_x"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"dave_x@example.com"}, emails(page.Users))
+	})
+
+	t.Run("FindPage filters by created_at range", func(t *testing.T) {
+		repo := newRepo(t)
+		require.NoError(t, repo.Create(ctx, newUser("Old", "old@example.com")))
+		time.Sleep(20 * time.Millisecond)
+		from := time.Now()
+		time.Sleep(20 * time.Millisecond)
+		require.NoError(t, repo.Create(ctx, newUser("New", "new@example.com")))
+
+		page, err := repo.FindPage(ctx, UserQueryOptions{CreatedAfter: &from})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"new@example.com"}, emails(page.Users))
+
+		page, err = repo.FindPage(ctx, UserQueryOptions{CreatedBefore: &from})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"old@example.com"}, emails(page.Users))
+	})
+
+	t.Run("FindPage sorts by field with ID as tie breaker", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo)
+
+		page, err := repo.FindPage(ctx, UserQueryOptions{SortBy: "name", SortDesc: true})
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"dave_x@example.com", "carol@example.com", "bob@corp.example",
+			"alice2@corp.example", "alice@example.com",
+		}, emails(page.Users))
+	})
+
+	t.Run("FindPage walks all pages by cursor", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo)
+
+		for _, sortBy := range UserSortFields {
+			var seen []string
+			opts := UserQueryOptions{Limit: 2, SortBy: sortBy}
+			for {
+				page, err := repo.FindPage(ctx, opts)
+				require.NoError(t, err)
+				assert.Equal(t, int64(5), page.Total)
+				seen = append(seen, emails(page.Users)...)
+				if page.NextCursor == "" {
+					break
+				}
+				opts.Cursor = page.NextCursor
+			}
+
+			all, err := repo.FindPage(ctx, UserQueryOptions{SortBy: sortBy})
+			require.NoError(t, err)
+			assert.Equal(t, emails(all.Users), seen, "sort by %s", sortBy)
+		}
+	})
+
+	t.Run("FindPage rejects invalid options", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo)
+
+		_, err := repo.FindPage(ctx, UserQueryOptions{SortBy: "password_hash"})
+		assert.ErrorIs(t, err, ErrInvalidSortField)
+
+		_, err = repo.FindPage(ctx, UserQueryOptions{Cursor: "not-a-cursor"})
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+
+		page, err := repo.FindPage(ctx, UserQueryOptions{Limit: 1, SortBy: "name"})
+		require.NoError(t, err)
+		_, err = repo.FindPage(ctx, UserQueryOptions{Cursor: page.NextCursor, SortBy: "email"})
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+	})
+}
+
+func TestSQLiteUserRepositoryConformance(t *testing.T) {
+	runUserRepositoryConformance(t, func(t *testing.T) UserRepository {
+		db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+		require.NoError(t, err)
+		// A single connection keeps every query on the same in-memory database
+		sqlDB, err := db.DB()
+		require.NoError(t, err)
+		sqlDB.SetMaxOpenConns(1)
+		require.NoError(t, db.AutoMigrate(&models.User{}))
+		return NewSQLiteUserRepository(db)
+	})
+}
+
+// TestPostgresUserRepositoryConformance runs against a real database when
+// TEST_DATABASE_URL is set, e.g. the one started by scripts/db-start.sh.
+func TestPostgresUserRepositoryConformance(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set, skipping PostgreSQL conformance tests")
+	}
+
+	db, err := gorm.Open(postgres.Open(url), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.User{}))
+
+	runUserRepositoryConformance(t, func(t *testing.T) UserRepository {
+		require.NoError(t, db.Exec("TRUNCATE TABLE users RESTART IDENTITY CASCADE").Error)
+		return NewPostgresUserRepository(db)
+	})
+}
@@ -13,6 +13,7 @@ import (
 	context "context"
 	models "myapp/internal/models"
 	reflect "reflect"
+	time "time"
 
 	gomock "go.uber.org/mock/gomock"
 )
@@ -69,6 +70,20 @@ func (mr *MockUserRepositoryMockRecorder) Delete(ctx, id any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockUserRepository)(nil).Delete), ctx, id)
 }
 
+// DeleteKeepingRole mocks base method.
+func (m *MockUserRepository) DeleteKeepingRole(ctx context.Context, id uint, role string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteKeepingRole", ctx, id, role)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteKeepingRole indicates an expected call of DeleteKeepingRole.
+func (mr *MockUserRepositoryMockRecorder) DeleteKeepingRole(ctx, id, role any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteKeepingRole", reflect.TypeOf((*MockUserRepository)(nil).DeleteKeepingRole), ctx, id, role)
+}
+
 // FindAll mocks base method.
 func (m *MockUserRepository) FindAll(ctx context.Context) ([]models.User, error) {
 	m.ctrl.T.Helper()
@@ -84,6 +99,21 @@ func (mr *MockUserRepositoryMockRecorder) FindAll(ctx any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAll", reflect.TypeOf((*MockUserRepository)(nil).FindAll), ctx)
 }
 
+// FindByEmail mocks base method.
+func (m *MockUserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByEmail", ctx, email)
+	ret0, _ := ret[0].(*models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByEmail indicates an expected call of FindByEmail.
+func (mr *MockUserRepositoryMockRecorder) FindByEmail(ctx, email any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByEmail", reflect.TypeOf((*MockUserRepository)(nil).FindByEmail), ctx, email)
+}
+
 // FindByID mocks base method.
 func (m *MockUserRepository) FindByID(ctx context.Context, id uint) (*models.User, error) {
 	m.ctrl.T.Helper()
@@ -99,6 +129,35 @@ func (mr *MockUserRepositoryMockRecorder) FindByID(ctx, id any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByID", reflect.TypeOf((*MockUserRepository)(nil).FindByID), ctx, id)
 }
 
+// FindPage mocks base method.
+func (m *MockUserRepository) FindPage(ctx context.Context, opts UserQueryOptions) (*UserPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindPage", ctx, opts)
+	ret0, _ := ret[0].(*UserPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindPage indicates an expected call of FindPage.
+func (mr *MockUserRepositoryMockRecorder) FindPage(ctx, opts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindPage", reflect.TypeOf((*MockUserRepository)(nil).FindPage), ctx, opts)
+}
+
+// SetEmailVerifiedAt mocks base method.
+func (m *MockUserRepository) SetEmailVerifiedAt(ctx context.Context, id uint, verifiedAt *time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetEmailVerifiedAt", ctx, id, verifiedAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetEmailVerifiedAt indicates an expected call of SetEmailVerifiedAt.
+func (mr *MockUserRepositoryMockRecorder) SetEmailVerifiedAt(ctx, id, verifiedAt any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetEmailVerifiedAt", reflect.TypeOf((*MockUserRepository)(nil).SetEmailVerifiedAt), ctx, id, verifiedAt)
+}
+
 // Update mocks base method.
 func (m *MockUserRepository) Update(ctx context.Context, user *models.User) error {
 	m.ctrl.T.Helper()
@@ -112,3 +171,17 @@ func (mr *MockUserRepositoryMockRecorder) Update(ctx, user any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockUserRepository)(nil).Update), ctx, user)
 }
+
+// UpdateKeepingRole mocks base method.
+func (m *MockUserRepository) UpdateKeepingRole(ctx context.Context, user *models.User, role string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateKeepingRole", ctx, user, role)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateKeepingRole indicates an expected call of UpdateKeepingRole.
+func (mr *MockUserRepositoryMockRecorder) UpdateKeepingRole(ctx, user, role any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateKeepingRole", reflect.TypeOf((*MockUserRepository)(nil).UpdateKeepingRole), ctx, user, role)
+}
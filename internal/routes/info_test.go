@@ -32,7 +32,8 @@ func setupTestRouterWithDB(t *testing.T) (*gin.Engine, *gorm.DB) {
 	// Setup router
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
-	SetupRoutes(router, db, logger, "test-secret")
+	_, closeRoutes := SetupRoutes(router, db, logger, "test-secret")
+	t.Cleanup(closeRoutes)
 
 	return router, db
 }
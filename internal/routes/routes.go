@@ -1,16 +1,22 @@
 package routes
 
 import (
+	"myapp/internal/audit"
 	"myapp/internal/handlers"
+	"myapp/internal/janitor"
+	"myapp/internal/lockout"
 	"myapp/internal/middleware"
+	"myapp/internal/rbac"
 	"myapp/internal/repository"
 	"myapp/pkg/config"
+	"myapp/pkg/database"
 	"myapp/pkg/health"
 	"myapp/pkg/info"
+	"myapp/pkg/jwks"
+	"myapp/pkg/notification"
 	"runtime"
 	"time"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	swaggerFiles "github.com/swaggo/files"
@@ -19,32 +25,97 @@ import (
 	"gorm.io/gorm"
 )
 
-// SetupRoutes configures all application routes
-func SetupRoutes(router *gin.Engine, db *gorm.DB, logger *zap.Logger, jwtSecret string) {
+// SetupRoutes configures all application routes. It returns the readiness
+// check to mark DOWN when the server starts shutting down, and a function that
+// releases the routes' resources once the server has stopped.
+func SetupRoutes(router *gin.Engine, db *gorm.DB, logger *zap.Logger, jwtSecret string) (*health.ShutdownHealthCheckProvider, func()) {
 	// Load configuration
 	cfg := config.Load()
 
 	// Configure connection pool settings
-	sqlDB, err := db.DB()
-	if err != nil {
+	if err := database.ConfigurePool(db, cfg.Database); err != nil {
 		logger.Fatal("Failed to get database connection", zap.Error(err))
 	}
 
-	// Apply settings from Viper configuration
-	sqlDB.SetMaxOpenConns(cfg.Database.MaxOpenConns)
-	sqlDB.SetMaxIdleConns(cfg.Database.MaxIdleConns)
-	sqlDB.SetConnMaxLifetime(time.Duration(cfg.Database.ConnMaxLifetime) * time.Minute)
+	// Create repository for the configured storage backend
+	userRepo, err := repository.NewUserRepository(database.Driver(cfg.Database), db)
+	if err != nil {
+		logger.Fatal("Failed to create user repository", zap.Error(err))
+	}
+
+	// Token revocation list shared by the auth middleware and the logout handler
+	revocations := repository.NewCachedTokenRevocationRepository(
+		repository.NewPostgresTokenRevocationRepository(db),
+		time.Duration(cfg.JWT.RevocationCacheTTL)*time.Second,
+	)
+
+	// Remove rows that are no longer needed, once at startup and then hourly
+	cleanup := janitor.New(janitor.DefaultInterval, logger).
+		Add("revoked_tokens", revocations.DeleteExpired)
+
+	// Signing keys: HS256 with jwtSecret unless asymmetric keys are configured
+	jwtConfig := cfg.JWT
+	jwtConfig.Secret = jwtSecret
+	keys, err := jwks.LoadKeySet(jwtConfig)
+	if err != nil {
+		logger.Fatal("Failed to load JWT signing keys", zap.Error(err))
+	}
+	// Roles and permissions; tokens without a permissions claim are resolved on each request
+	roleRepo := repository.NewPostgresRoleRepository(db)
+	jwtAuth := middleware.JWTAuthMiddlewareWithPermissions(keys, revocations, roleRepo)
+
+	// Notifier delivers verification and password reset links to users
+	notifier, err := notification.New(cfg.Notification, logger)
+	if err != nil {
+		logger.Fatal("Failed to create notifier", zap.Error(err))
+	}
 
-	// Create repository
-	userRepo := repository.NewPostgresUserRepository(db)
+	// Append-only audit log of logins and account changes
+	auditor := audit.NewRecorder(repository.NewPostgresAuditRepository(db), logger)
 
 	// Create handlers
-	userHandler := handlers.NewUserHandler(userRepo)
-	authHandler := handlers.NewAuthHandler(db, jwtSecret, logger)
+	verificationHandler := handlers.NewEmailVerificationHandler(db, notifier, logger).WithVerificationToken(
+		time.Duration(cfg.EmailVerification.TokenTTL)*time.Minute,
+		cfg.EmailVerification.URL,
+	).WithUserRepository(userRepo)
+	userHandler := handlers.NewUserHandler(userRepo).WithEmailVerifier(verificationHandler).WithRoles(roleRepo).WithAuditor(auditor).
+		WithSessionRevocation(revocations, repository.NewPostgresRefreshTokenRepository(db)).WithLogger(logger)
+	roleHandler := handlers.NewRoleHandler(db, logger).WithUserRepository(userRepo).WithAuditor(auditor)
+	// Failed login tracking shared by Login and the admin lockout endpoints
+	lockoutService := lockout.NewService(repository.NewPostgresLoginFailureRepository(db), lockout.Policy{
+		AccountThreshold:   cfg.Lockout.AccountThreshold,
+		IPThreshold:        cfg.Lockout.IPThreshold,
+		BaseDelay:          time.Duration(cfg.Lockout.BaseDelay) * time.Second,
+		MaxDelay:           time.Duration(cfg.Lockout.MaxDelay) * time.Second,
+		Window:             time.Duration(cfg.Lockout.Window) * time.Second,
+		MaxTrackedAccounts: cfg.Lockout.MaxTrackedAccounts,
+	})
+	cleanup.Add("login_failures", lockoutService.Prune)
+	lockoutHandler := handlers.NewLockoutHandler(lockoutService, logger)
+
+	authHandler := handlers.NewAuthHandler(db, jwtSecret, logger).WithTokenTTLs(
+		time.Duration(cfg.JWT.AccessTokenTTL)*time.Minute,
+		time.Duration(cfg.JWT.RefreshTokenTTL)*time.Minute,
+	).WithRevocations(revocations).WithKeySet(keys).WithUserRepository(userRepo).
+		WithEmailVerificationRequired(cfg.EmailVerification.Required).
+		WithMFAChallengeTTL(time.Duration(cfg.MFA.ChallengeTTL) * time.Second).
+		WithPermissions(roleRepo).WithAuditor(auditor)
+	if cfg.Lockout.Enabled {
+		authHandler.WithLockout(lockoutService)
+	}
+	mfaHandler := handlers.NewMFAHandler(db, logger).WithIssuer(cfg.MFA.Issuer).WithUserRepository(userRepo)
+	auditHandler := handlers.NewAuditHandler(db, logger)
+	jwksHandler := handlers.NewJWKSHandler(keys)
+	passwordHandler := handlers.NewPasswordHandler(db, notifier, logger).WithResetToken(
+		time.Duration(cfg.PasswordReset.TokenTTL)*time.Minute,
+		cfg.PasswordReset.URL,
+	).WithRevocations(revocations).WithUserRepository(userRepo).WithAuditor(auditor)
 
 	// Setup health check providers
 	healthRegistry := health.NewRegistry()
 	healthRegistry.Register(health.NewDatabaseHealthCheckProvider(db, health.ScopeStartup, health.ScopeReady))
+	shutdown := health.NewShutdownHealthCheckProvider()
+	healthRegistry.Register(shutdown)
 	healthHandler := handlers.NewHealthHandler(healthRegistry)
 
 	// Setup info providers
@@ -56,14 +127,20 @@ func SetupRoutes(router *gin.Engine, db *gorm.DB, logger *zap.Logger, jwtSecret
 	// Register user count metric collector
 	middleware.RegisterUserCountCollector(db)
 
-	// CORS middleware - allow all origins in development, configure for production
-	router.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"*"}, // Configure this for production
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "traceparent", "tracestate"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-	}))
+	// Client IP resolution: forwarded headers are only believed from trusted proxies
+	if err := middleware.ConfigureTrustedProxies(router, cfg.Server.TrustedProxies, cfg.Server.ForwardedHeader); err != nil {
+		logger.Fatal("Failed to configure trusted proxies", zap.Error(err))
+	}
+
+	// CORS middleware - allowed origins per stage
+	corsMiddleware, err := middleware.CORSMiddleware(cfg.CORS)
+	if err != nil {
+		logger.Fatal("Invalid CORS configuration", zap.Error(err))
+	}
+	router.Use(corsMiddleware)
+
+	// Security headers, with a relaxed content security policy for swagger UI
+	router.Use(middleware.SecurityHeadersMiddleware(cfg.SecurityHeaders))
 
 	// OpenTelemetry tracing middleware (conditionally enabled)
 	router.Use(middleware.OtelMiddleware("myapp", cfg.Observability.Otel))
@@ -71,8 +148,16 @@ func SetupRoutes(router *gin.Engine, db *gorm.DB, logger *zap.Logger, jwtSecret
 	// Logging middleware (with W3C trace context support)
 	router.Use(middleware.LoggingMiddleware(logger))
 
-	// Rate limiting middleware - configurable via YAML/environment variables
-	router.Use(middleware.RateLimitMiddleware(cfg.RateLimit.RequestsPerSecond, cfg.RateLimit.Burst))
+	// Rate limiting middleware - policies per route, counted per IP, user, API key or globally.
+	// Public routes use rateLimit; protected routes use deferredRateLimit before
+	// authentication and rateLimit after it, so user_id policies count the user.
+	rateLimits, err := middleware.NewRateLimitPolicies(cfg.RateLimit, logger)
+	if err != nil {
+		logger.Fatal("Failed to create rate limit policies", zap.Error(err))
+	}
+	rateLimit := rateLimits.Middleware()
+	deferredRateLimit := rateLimits.DeferredMiddleware()
+	router.NoRoute(rateLimit)
 
 	// Prometheus metrics middleware
 	router.Use(middleware.PrometheusMiddleware())
@@ -80,61 +165,102 @@ func SetupRoutes(router *gin.Engine, db *gorm.DB, logger *zap.Logger, jwtSecret
 	// Recovery middleware
 	router.Use(gin.Recovery())
 
+	// Routes without authentication
+	public := router.Group("/", rateLimit)
+
 	// Metrics endpoint
-	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	public.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
 	// Swagger documentation endpoint
-	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	public.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	// Health check endpoints
-	router.GET("/health", healthHandler.HealthCheck)
-	router.GET("/health/startup", healthHandler.StartupProbe)
-	router.GET("/health/liveness", healthHandler.LivenessProbe)
-	router.GET("/health/readiness", healthHandler.ReadinessProbe)
+	public.GET("/health", healthHandler.HealthCheck)
+	public.GET("/health/startup", healthHandler.StartupProbe)
+	public.GET("/health/liveness", healthHandler.LivenessProbe)
+	public.GET("/health/readiness", healthHandler.ReadinessProbe)
 
 	// Info endpoint
-	router.GET("/info", infoHandler.GetInfo)
+	public.GET("/info", infoHandler.GetInfo)
+
+	// Public keys for verifying access tokens
+	public.GET("/.well-known/jwks.json", jwksHandler.GetJWKS)
 
 	// API v1 routes
 	v1 := router.Group("/v1")
 	{
 		// Public routes
-		v1.POST("/login", authHandler.Login)
-		v1.POST("/users", userHandler.CreateUser) // Public signup
+		v1Public := v1.Group("/", rateLimit)
+		v1Public.POST("/login", authHandler.Login)
+		v1Public.POST("/login/mfa", authHandler.LoginMFA)
+		v1Public.POST("/token/refresh", authHandler.Refresh)
+		v1Public.POST("/password/forgot", passwordHandler.ForgotPassword)
+		v1Public.POST("/password/reset", passwordHandler.ResetPassword)
+		v1Public.GET("/verify-email", verificationHandler.VerifyEmail)
+		v1Public.POST("/verify-email", verificationHandler.VerifyEmail)
+		v1Public.POST("/verify-email/resend", verificationHandler.ResendVerification)
+		v1Public.POST("/users", userHandler.CreateUser) // Public signup
 
 		// Protected routes
 		protected := v1.Group("/")
-		protected.Use(middleware.JWTAuthMiddleware(jwtSecret))
+		protected.Use(deferredRateLimit, jwtAuth, rateLimit) // user_id policies are counted once the user is known
 		{
-			// Admin-only routes
-			admin := protected.Group("/")
-			admin.Use(middleware.RequireRole("admin"))
-			{
-				admin.GET("/users", userHandler.GetUsers)
-				admin.DELETE("/users/:id", userHandler.DeleteUser)
-			}
-
-			// Owner or admin routes
+			protected.POST("/logout", authHandler.Logout)
+
+			// Second factor of the authenticated user
+			protected.GET("/mfa", mfaHandler.GetMFAStatus)
+			protected.POST("/mfa/totp/enroll", mfaHandler.EnrollTOTP)
+			protected.POST("/mfa/totp/confirm", mfaHandler.ConfirmTOTP)
+			protected.DELETE("/mfa/totp", mfaHandler.DisableTOTP)
+
+			// Administrative routes, each guarded by a permission or, for role changes, the admin role
+			protected.GET("/users", middleware.RequirePermission(rbac.PermUsersList), userHandler.GetUsers)
+			protected.DELETE("/users/:id", middleware.RequirePermission(rbac.PermUsersDelete), userHandler.DeleteUser)
+			protected.POST("/users/:id/revoke-tokens", middleware.RequirePermission(rbac.PermTokensRevoke), authHandler.RevokeUserTokens)
+			protected.PUT("/users/:id/role", middleware.RequireRole(rbac.RoleAdmin), userHandler.UpdateUserRole)
+			protected.GET("/lockouts", middleware.RequirePermission(rbac.PermLockoutsManage), lockoutHandler.ListLockouts)
+			protected.DELETE("/lockouts/:scope/:identifier", middleware.RequirePermission(rbac.PermLockoutsManage), lockoutHandler.ClearLockout)
+			protected.GET("/audit", middleware.RequirePermission(rbac.PermAuditRead), auditHandler.ListAuditEvents)
+
+			// Role management
+			canReadRoles := middleware.RequirePermission(rbac.PermRolesRead)
+			canWriteRoles := middleware.RequirePermission(rbac.PermRolesWrite)
+			protected.GET("/permissions", canReadRoles, roleHandler.ListPermissions)
+			protected.GET("/roles", canReadRoles, roleHandler.ListRoles)
+			protected.GET("/roles/:id", canReadRoles, roleHandler.GetRole)
+			protected.POST("/roles", canWriteRoles, roleHandler.CreateRole)
+			protected.PUT("/roles/:id", canWriteRoles, roleHandler.UpdateRole)
+			protected.DELETE("/roles/:id", canWriteRoles, roleHandler.DeleteRole)
+			protected.GET("/users/:id/roles", canReadRoles, roleHandler.ListUserRoles)
+			protected.PUT("/users/:id/roles/:role_id", canWriteRoles, roleHandler.BindRole)
+			protected.DELETE("/users/:id/roles/:role_id", canWriteRoles, roleHandler.UnbindRole)
+
+			// Owner or permitted routes
 			protected.GET("/users/:id", userHandler.GetUserByID)
 			protected.PUT("/users/:id", userHandler.UpdateUser)
+			protected.PATCH("/users/:id", userHandler.PatchUser)
+			protected.PUT("/users/:id/password", passwordHandler.ChangePassword)
 		}
 	}
 
 	// Legacy routes (backward compatibility) - redirect to v1
-	router.POST("/login", authHandler.Login)
-	router.POST("/users", userHandler.CreateUser)
+	public.POST("/login", authHandler.Login)
+	public.POST("/users", userHandler.CreateUser)
 
 	protected := router.Group("/")
-	protected.Use(middleware.JWTAuthMiddleware(jwtSecret))
+	protected.Use(deferredRateLimit, jwtAuth, rateLimit)
 	{
-		admin := protected.Group("/")
-		admin.Use(middleware.RequireRole("admin"))
-		{
-			admin.GET("/users", userHandler.GetUsers)
-			admin.DELETE("/users/:id", userHandler.DeleteUser)
-		}
+		protected.GET("/users", middleware.RequirePermission(rbac.PermUsersList), userHandler.GetUsers)
+		protected.DELETE("/users/:id", middleware.RequirePermission(rbac.PermUsersDelete), userHandler.DeleteUser)
 
 		protected.GET("/users/:id", userHandler.GetUserByID)
 		protected.PUT("/users/:id", userHandler.UpdateUser)
 	}
+
+	cleanup.Start()
+
+	return shutdown, func() {
+		cleanup.Close()
+		rateLimits.Close()
+	}
 }
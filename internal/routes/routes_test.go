@@ -206,3 +206,20 @@ func TestHealthReadinessEndpoint(t *testing.T) {
 		assert.Contains(t, w.Body.String(), "database")
 	})
 }
+
+func TestClientIPResolution(t *testing.T) {
+	router := setupTestRouter()
+	router.GET("/test/client-ip", func(c *gin.Context) {
+		c.String(http.StatusOK, c.ClientIP())
+	})
+
+	t.Run("should not believe X-Forwarded-For without trusted proxies", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/test/client-ip", nil)
+		req.RemoteAddr = "203.0.113.7:1234"
+		req.Header.Set("X-Forwarded-For", "1.2.3.4")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, "203.0.113.7", w.Body.String())
+	})
+}
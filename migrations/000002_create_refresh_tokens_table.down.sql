@@ -0,0 +1,5 @@
+-- Drop refresh_tokens table
+DROP INDEX IF EXISTS idx_refresh_tokens_revoked_at;
+DROP INDEX IF EXISTS idx_refresh_tokens_family_id;
+DROP INDEX IF EXISTS idx_refresh_tokens_user_id;
+DROP TABLE IF EXISTS refresh_tokens;
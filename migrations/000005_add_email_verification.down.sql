@@ -0,0 +1,6 @@
+-- Drop email_verification_tokens table
+DROP INDEX IF EXISTS idx_email_verification_tokens_user_id;
+DROP TABLE IF EXISTS email_verification_tokens;
+
+-- Remove email verification timestamp from users
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified_at;
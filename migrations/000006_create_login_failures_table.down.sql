@@ -0,0 +1,3 @@
+-- Drop login_failures table
+DROP INDEX IF EXISTS idx_login_failures_last_failed_at;
+DROP TABLE IF EXISTS login_failures;
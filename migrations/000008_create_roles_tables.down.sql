@@ -0,0 +1,9 @@
+-- Narrow users.role again; fails while a user has a role name longer than 20 characters
+ALTER TABLE users ALTER COLUMN role TYPE VARCHAR(20);
+
+-- Drop roles and permissions tables
+DROP INDEX IF EXISTS idx_role_bindings_role_id;
+DROP TABLE IF EXISTS role_bindings;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS roles;
+DROP TABLE IF EXISTS permissions;
@@ -0,0 +1,2 @@
+-- Remove the optimistic locking version from users
+ALTER TABLE users DROP COLUMN IF EXISTS version;
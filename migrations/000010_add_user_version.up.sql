@@ -0,0 +1,2 @@
+-- Add an optimistic locking version to users, incremented on every write
+ALTER TABLE users ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,10 @@
+// Package migrations embeds the SQL migration files into the binary so the
+// server can migrate the database regardless of its working directory.
+package migrations
+
+import "embed"
+
+// FS holds the *.up.sql and *.down.sql files of this directory
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"fmt"
+	"myapp/pkg/config"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+const (
+	// DriverPostgres stores all data in PostgreSQL
+	DriverPostgres = "postgres"
+	// DriverSQLite stores all data in a SQLite database, database.url is the DSN
+	DriverSQLite = "sqlite"
+	// DriverMemory stores all data in an in-memory SQLite database that is lost on restart
+	DriverMemory = "memory"
+
+	// memoryDSN names a shared in-memory SQLite database so every pooled connection sees the same data
+	memoryDSN = "file:myapp?mode=memory&cache=shared"
+)
+
+// Driver returns the configured driver, defaulting to PostgreSQL
+func Driver(cfg config.DatabaseConfig) string {
+	if cfg.Driver == "" {
+		return DriverPostgres
+	}
+	return cfg.Driver
+}
+
+// Open connects to the database selected by cfg.Driver
+func Open(cfg config.DatabaseConfig) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch Driver(cfg) {
+	case DriverPostgres:
+		dialector = postgres.Open(cfg.URL)
+	case DriverSQLite:
+		dialector = sqlite.Open(cfg.URL)
+	case DriverMemory:
+		dialector = sqlite.Open(memoryDSN)
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", cfg.Driver)
+	}
+
+	return gorm.Open(dialector, &gorm.Config{})
+}
+
+// ConfigurePool applies the connection pool settings of cfg. SQLite databases
+// get a single connection that is never closed instead: SQLite destroys an
+// in-memory database with its last connection, and one connection also keeps
+// concurrent writers from failing with SQLITE_BUSY.
+func ConfigurePool(db *gorm.DB, cfg config.DatabaseConfig) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	switch Driver(cfg) {
+	case DriverSQLite, DriverMemory:
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetMaxIdleConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+		sqlDB.SetConnMaxIdleTime(0)
+	default:
+		sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+		sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+		sqlDB.SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetime) * time.Minute)
+	}
+	return nil
+}
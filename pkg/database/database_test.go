@@ -0,0 +1,71 @@
+package database
+
+import (
+	"myapp/pkg/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDriver(t *testing.T) {
+	t.Run("should default to postgres", func(t *testing.T) {
+		assert.Equal(t, DriverPostgres, Driver(config.DatabaseConfig{}))
+	})
+
+	t.Run("should return configured driver", func(t *testing.T) {
+		assert.Equal(t, DriverSQLite, Driver(config.DatabaseConfig{Driver: "sqlite"}))
+	})
+}
+
+func TestOpen(t *testing.T) {
+	t.Run("should open sqlite database", func(t *testing.T) {
+		db, err := Open(config.DatabaseConfig{Driver: DriverSQLite, URL: ":memory:"})
+		assert.NoError(t, err)
+		assert.NotNil(t, db)
+	})
+
+	t.Run("should open in-memory database for memory driver", func(t *testing.T) {
+		db, err := Open(config.DatabaseConfig{Driver: DriverMemory})
+		assert.NoError(t, err)
+		assert.NotNil(t, db)
+	})
+
+	t.Run("should reject unknown driver", func(t *testing.T) {
+		_, err := Open(config.DatabaseConfig{Driver: "oracle"})
+		assert.Error(t, err)
+	})
+}
+
+func TestConfigurePool(t *testing.T) {
+	t.Run("should keep a single connection to the memory database", func(t *testing.T) {
+		cfg := config.DatabaseConfig{Driver: DriverMemory, MaxOpenConns: 25, MaxIdleConns: 0, ConnMaxLifetime: 30}
+		db, err := Open(cfg)
+		require.NoError(t, err)
+		require.NoError(t, ConfigurePool(db, cfg))
+		sqlDB, err := db.DB()
+		require.NoError(t, err)
+		t.Cleanup(func() { sqlDB.Close() })
+
+		require.NoError(t, db.Exec("CREATE TABLE pool_test (id INTEGER)").Error)
+		require.NoError(t, db.Exec("INSERT INTO pool_test VALUES (1)").Error)
+
+		// The idle connection, and with it the database, survives between queries
+		var count int64
+		require.NoError(t, db.Raw("SELECT COUNT(*) FROM pool_test").Scan(&count).Error)
+		assert.Equal(t, int64(1), count)
+		assert.Equal(t, 1, sqlDB.Stats().MaxOpenConnections)
+		assert.Equal(t, 1, sqlDB.Stats().OpenConnections)
+	})
+
+	t.Run("should apply the configured settings to postgres", func(t *testing.T) {
+		cfg := config.DatabaseConfig{Driver: DriverPostgres, MaxOpenConns: 25, MaxIdleConns: 10, ConnMaxLifetime: 30}
+		db, err := Open(config.DatabaseConfig{Driver: DriverSQLite, URL: ":memory:"})
+		require.NoError(t, err)
+
+		require.NoError(t, ConfigurePool(db, cfg))
+		sqlDB, err := db.DB()
+		require.NoError(t, err)
+		assert.Equal(t, 25, sqlDB.Stats().MaxOpenConnections)
+	})
+}
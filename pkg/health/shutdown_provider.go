@@ -0,0 +1,41 @@
+package health
+
+import "sync/atomic"
+
+// ShutdownHealthCheckProvider reports DOWN once the application starts shutting down.
+// Registered in the readiness scope it tells load balancers to stop routing new
+// requests to the instance while in-flight requests are still being served.
+type ShutdownHealthCheckProvider struct {
+	shuttingDown atomic.Bool
+}
+
+// NewShutdownHealthCheckProvider creates a provider that reports UP until Shutdown is called
+func NewShutdownHealthCheckProvider() *ShutdownHealthCheckProvider {
+	return &ShutdownHealthCheckProvider{}
+}
+
+// Shutdown makes every following check report DOWN
+func (p *ShutdownHealthCheckProvider) Shutdown() {
+	p.shuttingDown.Store(true)
+}
+
+// Name returns the name of this health check.
+func (p *ShutdownHealthCheckProvider) Name() string {
+	return "shutdown"
+}
+
+// Check reports DOWN while the application is shutting down.
+func (p *ShutdownHealthCheckProvider) Check() (*CheckResult, error) {
+	if p.shuttingDown.Load() {
+		return &CheckResult{
+			Status:  StatusDown,
+			Details: map[string]any{"reason": "shutting down"},
+		}, nil
+	}
+	return &CheckResult{Status: StatusUp}, nil
+}
+
+// Scopes returns the scopes for this health check.
+func (p *ShutdownHealthCheckProvider) Scopes() []Scope {
+	return []Scope{ScopeReady}
+}
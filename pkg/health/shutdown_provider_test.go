@@ -0,0 +1,40 @@
+package health
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShutdownHealthCheckProvider(t *testing.T) {
+	t.Run("should report UP until shutdown", func(t *testing.T) {
+		provider := NewShutdownHealthCheckProvider()
+
+		assert.Equal(t, "shutdown", provider.Name())
+		assert.Equal(t, []Scope{ScopeReady}, provider.Scopes())
+
+		result, err := provider.Check()
+		require.NoError(t, err)
+		assert.Equal(t, StatusUp, result.Status)
+
+		provider.Shutdown()
+		result, err = provider.Check()
+		require.NoError(t, err)
+		assert.Equal(t, StatusDown, result.Status)
+		assert.Equal(t, "shutting down", result.Details["reason"])
+	})
+
+	t.Run("should only fail readiness", func(t *testing.T) {
+		registry := NewRegistry()
+		provider := NewShutdownHealthCheckProvider()
+		registry.Register(provider)
+		provider.Shutdown()
+
+		ready, live := ScopeReady, ScopeLive
+		status, _ := registry.BuildResponse(&ready)
+		assert.Equal(t, 503, status)
+		status, _ = registry.BuildResponse(&live)
+		assert.Equal(t, 200, status)
+	})
+}
@@ -0,0 +1,313 @@
+// Package jsonpatch applies JSON Merge Patch (RFC 7396) and JSON Patch
+// (RFC 6902) documents to JSON values decoded with encoding/json.
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MergePatchContentType is the media type of RFC 7396 merge patches
+	MergePatchContentType = "application/merge-patch+json"
+	// JSONPatchContentType is the media type of RFC 6902 JSON patches
+	JSONPatchContentType = "application/json-patch+json"
+)
+
+var (
+	// ErrInvalidPatch is returned for patches that are malformed or cannot be applied
+	ErrInvalidPatch = errors.New("invalid patch")
+	// ErrTestFailed is returned when a "test" operation does not match the document
+	ErrTestFailed = errors.New("test operation failed")
+)
+
+// unescapeToken decodes ~1 before ~0 as RFC 6901 requires, so "~01" becomes "~1"
+var unescapeToken = strings.NewReplacer("~1", "/", "~0", "~")
+
+// Operation is a single JSON Patch operation
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// MergePatch applies the merge patch to target and returns the result. Objects
+// are merged recursively, null removes a member and any other value replaces
+// the target. target may be modified.
+func MergePatch(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = map[string]any{}
+	}
+	for name, value := range p {
+		if value == nil {
+			delete(t, name)
+			continue
+		}
+		t[name] = MergePatch(t[name], value)
+	}
+	return t
+}
+
+// DecodePatch parses a JSON Patch document
+func DecodePatch(data []byte) ([]Operation, error) {
+	var ops []Operation
+	if err := json.Unmarshal(data, &ops); err != nil {
+		return nil, fmt.Errorf("%w: must be an array of operations", ErrInvalidPatch)
+	}
+	return ops, nil
+}
+
+// Apply applies the operations to doc in order and returns the result. It
+// stops at the first operation that fails; doc may be modified by then.
+func Apply(doc any, ops []Operation) (any, error) {
+	for i, op := range ops {
+		var err error
+		doc, err = applyOperation(doc, op)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return doc, nil
+}
+
+func applyOperation(doc any, op Operation) (any, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		value, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		switch op.Op {
+		case "add":
+			return update(doc, path, value, addTo)
+		case "replace":
+			return update(doc, path, value, replaceIn)
+		}
+		current, err := get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(current, value) {
+			return nil, ErrTestFailed
+		}
+		return doc, nil
+	case "remove":
+		if len(path) == 0 {
+			return nil, fmt.Errorf("%w: cannot remove the whole document", ErrInvalidPatch)
+		}
+		return update(doc, path, nil, removeFrom)
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		value, err := get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "copy" {
+			return update(doc, path, deepCopy(value), addTo)
+		}
+		if isProperPrefix(from, path) {
+			return nil, fmt.Errorf("%w: cannot move a value into one of its children", ErrInvalidPatch)
+		}
+		if len(from) == 0 {
+			return update(doc, path, value, addTo)
+		}
+		if doc, err = update(doc, from, nil, removeFrom); err != nil {
+			return nil, err
+		}
+		return update(doc, path, value, addTo)
+	default:
+		return nil, fmt.Errorf("%w: unknown operation %q", ErrInvalidPatch, op.Op)
+	}
+}
+
+// value decodes the operation's value, which must be present but may be null
+func (op Operation) value() (any, error) {
+	if op.Value == nil {
+		return nil, fmt.Errorf("%w: value is required", ErrInvalidPatch)
+	}
+	var v any
+	if err := json.Unmarshal(op.Value, &v); err != nil {
+		return nil, fmt.Errorf("%w: value is not valid JSON", ErrInvalidPatch)
+	}
+	return v, nil
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into its unescaped reference tokens
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%w: path %q must be empty or start with /", ErrInvalidPatch, pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = unescapeToken.Replace(token)
+	}
+	return tokens, nil
+}
+
+func isProperPrefix(prefix, path []string) bool {
+	if len(prefix) >= len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// get returns the value at path
+func get(doc any, path []string) (any, error) {
+	for _, token := range path {
+		switch container := doc.(type) {
+		case map[string]any:
+			value, ok := container[token]
+			if !ok {
+				return nil, fmt.Errorf("%w: member %q does not exist", ErrInvalidPatch, token)
+			}
+			doc = value
+		case []any:
+			i, err := arrayIndex(token, len(container)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = container[i]
+		default:
+			return nil, fmt.Errorf("%w: %q is not inside an object or array", ErrInvalidPatch, token)
+		}
+	}
+	return doc, nil
+}
+
+// containerFunc changes the member key of an object or array and returns the
+// container, which is a new slice when an array grows or shrinks
+type containerFunc func(container any, key string, value any) (any, error)
+
+// update applies fn to the parent of path and stores the result back into
+// the document, which is replaced as a whole when path is empty
+func update(doc any, path []string, value any, fn containerFunc) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	if len(path) == 1 {
+		return fn(doc, path[0], value)
+	}
+	child, err := get(doc, path[:1])
+	if err != nil {
+		return nil, err
+	}
+	if child, err = update(child, path[1:], value, fn); err != nil {
+		return nil, err
+	}
+	switch container := doc.(type) {
+	case map[string]any:
+		container[path[0]] = child
+	case []any:
+		i, _ := arrayIndex(path[0], len(container)-1)
+		container[i] = child
+	}
+	return doc, nil
+}
+
+func addTo(container any, key string, value any) (any, error) {
+	switch c := container.(type) {
+	case map[string]any:
+		c[key] = value
+		return c, nil
+	case []any:
+		if key == "-" {
+			return append(c, value), nil
+		}
+		i, err := arrayIndex(key, len(c))
+		if err != nil {
+			return nil, err
+		}
+		c = append(c, nil)
+		copy(c[i+1:], c[i:])
+		c[i] = value
+		return c, nil
+	default:
+		return nil, fmt.Errorf("%w: %q is not inside an object or array", ErrInvalidPatch, key)
+	}
+}
+
+func replaceIn(container any, key string, value any) (any, error) {
+	if _, err := get(container, []string{key}); err != nil {
+		return nil, err
+	}
+	switch c := container.(type) {
+	case map[string]any:
+		c[key] = value
+	case []any:
+		i, _ := arrayIndex(key, len(c)-1)
+		c[i] = value
+	}
+	return container, nil
+}
+
+func removeFrom(container any, key string, _ any) (any, error) {
+	if _, err := get(container, []string{key}); err != nil {
+		return nil, err
+	}
+	switch c := container.(type) {
+	case map[string]any:
+		delete(c, key)
+	case []any:
+		i, _ := arrayIndex(key, len(c)-1)
+		return append(c[:i], c[i+1:]...), nil
+	}
+	return container, nil
+}
+
+// arrayIndex parses an array index token, which must not exceed maxIndex
+func arrayIndex(token string, maxIndex int) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') || strings.TrimLeft(token, "0123456789") != "" {
+		return 0, fmt.Errorf("%w: %q is not an array index", ErrInvalidPatch, token)
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i > maxIndex {
+		return 0, fmt.Errorf("%w: array index %s is out of bounds", ErrInvalidPatch, token)
+	}
+	return i, nil
+}
+
+// deepCopy copies the objects and arrays of v so that copies can be changed independently
+func deepCopy(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		c := make(map[string]any, len(v))
+		for name, value := range v {
+			c[name] = deepCopy(value)
+		}
+		return c
+	case []any:
+		c := make([]any, len(v))
+		for i, value := range v {
+			c[i] = deepCopy(value)
+		}
+		return c
+	default:
+		return v
+	}
+}
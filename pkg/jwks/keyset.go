@@ -0,0 +1,316 @@
+package jwks
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"myapp/pkg/config"
+	"os"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// minRSAKeyBits is the smallest RSA modulus accepted for RS256
+	minRSAKeyBits = 2048
+)
+
+var (
+	// ErrUnknownKey is returned when a token references a kid that is not in the key set
+	ErrUnknownKey = errors.New("unknown signing key")
+	// ErrNoSigningKey is returned when the key set has no key able to sign tokens
+	ErrNoSigningKey = errors.New("no active signing key")
+)
+
+// Key is a single JWT signing or verification key
+type Key struct {
+	ID         string
+	Method     jwt.SigningMethod
+	signingKey any
+	verifyKey  any
+}
+
+// CanSign reports whether the key holds private material
+func (k *Key) CanSign() bool {
+	return k.signingKey != nil
+}
+
+// KeySet signs tokens with its active key and verifies tokens against every
+// key it knows, selected by the kid header.
+type KeySet struct {
+	active *Key
+	keys   map[string]*Key
+	hmac   *Key
+}
+
+// NewHMACKeySet creates a key set that signs and verifies with a shared secret using HS256
+func NewHMACKeySet(secret string) *KeySet {
+	key := &Key{
+		Method:     jwt.SigningMethodHS256,
+		signingKey: []byte(secret),
+		verifyKey:  []byte(secret),
+	}
+	return &KeySet{
+		active: key,
+		keys:   map[string]*Key{},
+		hmac:   key,
+	}
+}
+
+// LoadKeySet builds a key set from configuration. Without an active key ID
+// it falls back to HS256 with the shared secret.
+func LoadKeySet(cfg config.JWTConfig) (*KeySet, error) {
+	if cfg.ActiveKeyID == "" {
+		return NewHMACKeySet(cfg.Secret), nil
+	}
+
+	ks := &KeySet{keys: make(map[string]*Key)}
+	for _, kc := range cfg.SigningKeys {
+		key, err := loadKey(kc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load signing key %q: %w", kc.ID, err)
+		}
+		if _, exists := ks.keys[key.ID]; exists {
+			return nil, fmt.Errorf("duplicate signing key id %q", key.ID)
+		}
+		ks.keys[key.ID] = key
+	}
+
+	active, ok := ks.keys[cfg.ActiveKeyID]
+	if !ok {
+		return nil, fmt.Errorf("active signing key %q is not configured", cfg.ActiveKeyID)
+	}
+	if !active.CanSign() {
+		return nil, fmt.Errorf("active signing key %q has no private key", cfg.ActiveKeyID)
+	}
+	ks.active = active
+
+	if cfg.AllowHMAC && cfg.Secret != "" {
+		ks.hmac = &Key{
+			Method:    jwt.SigningMethodHS256,
+			verifyKey: []byte(cfg.Secret),
+		}
+	}
+
+	return ks, nil
+}
+
+// ActiveKeyID returns the kid used for new tokens, empty for HS256
+func (ks *KeySet) ActiveKeyID() string {
+	if ks.active == nil {
+		return ""
+	}
+	return ks.active.ID
+}
+
+// Sign signs claims with the active key and sets the kid header
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	if ks.active == nil || !ks.active.CanSign() {
+		return "", ErrNoSigningKey
+	}
+
+	token := jwt.NewWithClaims(ks.active.Method, claims)
+	if ks.active.ID != "" {
+		token.Header["kid"] = ks.active.ID
+	}
+	return token.SignedString(ks.active.signingKey)
+}
+
+// Keyfunc resolves the verification key for a token. It is meant to be
+// passed to jwt.Parse together with ValidMethods.
+func (ks *KeySet) Keyfunc(token *jwt.Token) (any, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		if ks.hmac == nil {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return ks.hmac.verifyKey, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	// Never let the token choose an algorithm other than the key's own
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, jwt.ErrSignatureInvalid
+	}
+	return key.verifyKey, nil
+}
+
+// ValidMethods returns the algorithms accepted by this key set
+func (ks *KeySet) ValidMethods() []string {
+	seen := make(map[string]bool)
+	var methods []string
+	if ks.hmac != nil {
+		seen[ks.hmac.Method.Alg()] = true
+		methods = append(methods, ks.hmac.Method.Alg())
+	}
+	for _, key := range ks.keys {
+		if !seen[key.Method.Alg()] {
+			seen[key.Method.Alg()] = true
+			methods = append(methods, key.Method.Alg())
+		}
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// Parse parses and verifies a token string against the key set
+func (ks *KeySet) Parse(tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, ks.Keyfunc, jwt.WithValidMethods(ks.ValidMethods()))
+}
+
+// JSONWebKey is the public part of a key in RFC 7517 format
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JSONWebKeySet is a set of public keys in RFC 7517 format
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JWKS returns the public keys of all asymmetric keys, active key first.
+// Shared HMAC secrets are never published.
+func (ks *KeySet) JWKS() JSONWebKeySet {
+	ids := make([]string, 0, len(ks.keys))
+	for id := range ks.keys {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if ids[i] == ks.ActiveKeyID() {
+			return true
+		}
+		if ids[j] == ks.ActiveKeyID() {
+			return false
+		}
+		return ids[i] < ids[j]
+	})
+
+	set := JSONWebKeySet{Keys: make([]JSONWebKey, 0, len(ids))}
+	for _, id := range ids {
+		key := ks.keys[id]
+		jwk := JSONWebKey{Kid: key.ID, Use: "sig", Alg: key.Method.Alg()}
+		switch pub := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// loadKey reads and parses a configured key
+func loadKey(kc config.SigningKeyConfig) (*Key, error) {
+	if kc.ID == "" {
+		return nil, errors.New("key id is required")
+	}
+
+	privatePEM, err := readPEM(kc.PrivateKey, kc.PrivateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	publicPEM, err := readPEM(kc.PublicKey, kc.PublicKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{ID: kc.ID}
+	switch {
+	case privatePEM != nil:
+		signer, err := parsePrivateKey(privatePEM)
+		if err != nil {
+			return nil, err
+		}
+		key.signingKey = signer
+		key.verifyKey = signer.Public()
+	case publicPEM != nil:
+		pub, err := parsePublicKey(publicPEM)
+		if err != nil {
+			return nil, err
+		}
+		key.verifyKey = pub
+	default:
+		return nil, errors.New("either a private or a public key is required")
+	}
+
+	switch pub := key.verifyKey.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA key must be at least %d bits", minRSAKeyBits)
+		}
+		key.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key.verifyKey)
+	}
+
+	return key, nil
+}
+
+// readPEM returns inline PEM if set, otherwise the content of file, or nil if neither is set
+func readPEM(inline, file string) ([]byte, error) {
+	if inline != "" {
+		return []byte(inline), nil
+	}
+	if file == "" {
+		return nil, nil
+	}
+	return os.ReadFile(file)
+}
+
+// parsePrivateKey parses a PKCS#8 or PKCS#1 PEM encoded private key
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid PEM data")
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		}
+		return signer, nil
+	}
+
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
+
+// parsePublicKey parses a PKIX or PKCS#1 PEM encoded public key
+func parsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid PEM data")
+	}
+
+	if key, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	return x509.ParsePKCS1PublicKey(block.Bytes)
+}
@@ -0,0 +1,285 @@
+package jwks
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"myapp/pkg/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func generateRSAKeyPEM(t *testing.T, bits int) (privatePEM, publicPEM string) {
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	require.NoError(t, err)
+	return encodeKeyPair(t, key, &key.PublicKey)
+}
+
+func generateEd25519KeyPEM(t *testing.T) (privatePEM, publicPEM string) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return encodeKeyPair(t, priv, pub)
+}
+
+func encodeKeyPair(t *testing.T, private, public any) (string, string) {
+	privDER, err := x509.MarshalPKCS8PrivateKey(private)
+	require.NoError(t, err)
+	pubDER, err := x509.MarshalPKIXPublicKey(public)
+	require.NoError(t, err)
+
+	privatePEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER})
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})
+	return string(privatePEM), string(publicPEM)
+}
+
+func testClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"user_id": 1,
+		"role":    "user",
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func TestHMACKeySet(t *testing.T) {
+	t.Run("should sign and verify with the shared secret", func(t *testing.T) {
+		ks := NewHMACKeySet("test-secret")
+
+		token, err := ks.Sign(testClaims())
+		require.NoError(t, err)
+
+		parsed, err := ks.Parse(token)
+		assert.NoError(t, err)
+		assert.True(t, parsed.Valid)
+		assert.Nil(t, parsed.Header["kid"])
+	})
+
+	t.Run("should reject token signed with another secret", func(t *testing.T) {
+		token, _ := NewHMACKeySet("other-secret").Sign(testClaims())
+
+		_, err := NewHMACKeySet("test-secret").Parse(token)
+		assert.Error(t, err)
+	})
+
+	t.Run("should publish no keys", func(t *testing.T) {
+		assert.Empty(t, NewHMACKeySet("test-secret").JWKS().Keys)
+	})
+}
+
+func TestLoadKeySet(t *testing.T) {
+	rsaPrivate, rsaPublic := generateRSAKeyPEM(t, 2048)
+	edPrivate, edPublic := generateEd25519KeyPEM(t)
+
+	t.Run("should fall back to HMAC without an active key", func(t *testing.T) {
+		ks, err := LoadKeySet(config.JWTConfig{Secret: "test-secret"})
+		require.NoError(t, err)
+
+		token, err := ks.Sign(testClaims())
+		require.NoError(t, err)
+		_, err = NewHMACKeySet("test-secret").Parse(token)
+		assert.NoError(t, err)
+	})
+
+	t.Run("should sign with RS256 and set kid", func(t *testing.T) {
+		ks, err := LoadKeySet(config.JWTConfig{
+			ActiveKeyID: "rsa-1",
+			SigningKeys: []config.SigningKeyConfig{{ID: "rsa-1", PrivateKey: rsaPrivate}},
+		})
+		require.NoError(t, err)
+
+		token, err := ks.Sign(testClaims())
+		require.NoError(t, err)
+
+		parsed, err := ks.Parse(token)
+		require.NoError(t, err)
+		assert.Equal(t, "RS256", parsed.Method.Alg())
+		assert.Equal(t, "rsa-1", parsed.Header["kid"])
+	})
+
+	t.Run("should sign with EdDSA", func(t *testing.T) {
+		ks, err := LoadKeySet(config.JWTConfig{
+			ActiveKeyID: "ed-1",
+			SigningKeys: []config.SigningKeyConfig{{ID: "ed-1", PrivateKey: edPrivate}},
+		})
+		require.NoError(t, err)
+
+		token, err := ks.Sign(testClaims())
+		require.NoError(t, err)
+
+		parsed, err := ks.Parse(token)
+		require.NoError(t, err)
+		assert.Equal(t, "EdDSA", parsed.Method.Alg())
+	})
+
+	t.Run("should load keys from files", func(t *testing.T) {
+		dir := t.TempDir()
+		privateFile := filepath.Join(dir, "private.pem")
+		require.NoError(t, os.WriteFile(privateFile, []byte(edPrivate), 0o600))
+
+		ks, err := LoadKeySet(config.JWTConfig{
+			ActiveKeyID: "ed-1",
+			SigningKeys: []config.SigningKeyConfig{{ID: "ed-1", PrivateKeyFile: privateFile}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "ed-1", ks.ActiveKeyID())
+	})
+
+	t.Run("should verify tokens of retired keys after rotation", func(t *testing.T) {
+		before, err := LoadKeySet(config.JWTConfig{
+			ActiveKeyID: "rsa-1",
+			SigningKeys: []config.SigningKeyConfig{{ID: "rsa-1", PrivateKey: rsaPrivate}},
+		})
+		require.NoError(t, err)
+		oldToken, _ := before.Sign(testClaims())
+
+		after, err := LoadKeySet(config.JWTConfig{
+			ActiveKeyID: "ed-1",
+			SigningKeys: []config.SigningKeyConfig{
+				{ID: "ed-1", PrivateKey: edPrivate},
+				{ID: "rsa-1", PublicKey: rsaPublic},
+			},
+		})
+		require.NoError(t, err)
+
+		_, err = after.Parse(oldToken)
+		assert.NoError(t, err)
+
+		newToken, _ := after.Sign(testClaims())
+		parsed, err := after.Parse(newToken)
+		require.NoError(t, err)
+		assert.Equal(t, "ed-1", parsed.Header["kid"])
+	})
+
+	t.Run("should reject token with unknown kid", func(t *testing.T) {
+		signer, _ := LoadKeySet(config.JWTConfig{
+			ActiveKeyID: "rsa-1",
+			SigningKeys: []config.SigningKeyConfig{{ID: "rsa-1", PrivateKey: rsaPrivate}},
+		})
+		token, _ := signer.Sign(testClaims())
+
+		otherPrivate, _ := generateRSAKeyPEM(t, 2048)
+		verifier, _ := LoadKeySet(config.JWTConfig{
+			ActiveKeyID: "rsa-2",
+			SigningKeys: []config.SigningKeyConfig{{ID: "rsa-2", PrivateKey: otherPrivate}},
+		})
+		_, err := verifier.Parse(token)
+		assert.ErrorIs(t, err, ErrUnknownKey)
+	})
+
+	t.Run("should reject HMAC tokens unless allowed", func(t *testing.T) {
+		hmacToken, _ := NewHMACKeySet("test-secret").Sign(testClaims())
+		cfg := config.JWTConfig{
+			Secret:      "test-secret",
+			ActiveKeyID: "rsa-1",
+			SigningKeys: []config.SigningKeyConfig{{ID: "rsa-1", PrivateKey: rsaPrivate}},
+		}
+
+		strict, err := LoadKeySet(cfg)
+		require.NoError(t, err)
+		_, err = strict.Parse(hmacToken)
+		assert.Error(t, err)
+
+		cfg.AllowHMAC = true
+		lenient, err := LoadKeySet(cfg)
+		require.NoError(t, err)
+		_, err = lenient.Parse(hmacToken)
+		assert.NoError(t, err)
+	})
+
+	t.Run("should reject HS256 token forged with the public key", func(t *testing.T) {
+		ks, err := LoadKeySet(config.JWTConfig{
+			ActiveKeyID: "rsa-1",
+			SigningKeys: []config.SigningKeyConfig{{ID: "rsa-1", PrivateKey: rsaPrivate}},
+		})
+		require.NoError(t, err)
+
+		forged := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+		forged.Header["kid"] = "rsa-1"
+		forgedString, _ := forged.SignedString([]byte(rsaPublic))
+
+		_, err = ks.Parse(forgedString)
+		assert.Error(t, err)
+	})
+
+	t.Run("should publish public keys with active key first", func(t *testing.T) {
+		ks, err := LoadKeySet(config.JWTConfig{
+			Secret:      "test-secret",
+			AllowHMAC:   true,
+			ActiveKeyID: "rsa-1",
+			SigningKeys: []config.SigningKeyConfig{
+				{ID: "ed-0", PublicKey: edPublic},
+				{ID: "rsa-1", PrivateKey: rsaPrivate},
+			},
+		})
+		require.NoError(t, err)
+
+		set := ks.JWKS()
+		require.Len(t, set.Keys, 2)
+
+		assert.Equal(t, "rsa-1", set.Keys[0].Kid)
+		assert.Equal(t, "RSA", set.Keys[0].Kty)
+		assert.Equal(t, "RS256", set.Keys[0].Alg)
+		assert.Equal(t, "AQAB", set.Keys[0].E)
+		assert.NotEmpty(t, set.Keys[0].N)
+
+		assert.Equal(t, "ed-0", set.Keys[1].Kid)
+		assert.Equal(t, "OKP", set.Keys[1].Kty)
+		assert.Equal(t, "Ed25519", set.Keys[1].Crv)
+		assert.NotEmpty(t, set.Keys[1].X)
+	})
+
+	t.Run("should reject invalid configurations", func(t *testing.T) {
+		smallPrivate, _ := generateRSAKeyPEM(t, 1024)
+
+		testCases := []struct {
+			name string
+			cfg  config.JWTConfig
+		}{
+			{"missing active key", config.JWTConfig{
+				ActiveKeyID: "missing",
+				SigningKeys: []config.SigningKeyConfig{{ID: "rsa-1", PrivateKey: rsaPrivate}},
+			}},
+			{"active key without private part", config.JWTConfig{
+				ActiveKeyID: "rsa-1",
+				SigningKeys: []config.SigningKeyConfig{{ID: "rsa-1", PublicKey: rsaPublic}},
+			}},
+			{"duplicate key id", config.JWTConfig{
+				ActiveKeyID: "rsa-1",
+				SigningKeys: []config.SigningKeyConfig{
+					{ID: "rsa-1", PrivateKey: rsaPrivate},
+					{ID: "rsa-1", PublicKey: rsaPublic},
+				},
+			}},
+			{"key without id", config.JWTConfig{
+				ActiveKeyID: "rsa-1",
+				SigningKeys: []config.SigningKeyConfig{{PrivateKey: rsaPrivate}},
+			}},
+			{"key without material", config.JWTConfig{
+				ActiveKeyID: "rsa-1",
+				SigningKeys: []config.SigningKeyConfig{{ID: "rsa-1"}},
+			}},
+			{"invalid PEM", config.JWTConfig{
+				ActiveKeyID: "rsa-1",
+				SigningKeys: []config.SigningKeyConfig{{ID: "rsa-1", PrivateKey: "not a key"}},
+			}},
+			{"RSA key too small", config.JWTConfig{
+				ActiveKeyID: "rsa-1",
+				SigningKeys: []config.SigningKeyConfig{{ID: "rsa-1", PrivateKey: smallPrivate}},
+			}},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				_, err := LoadKeySet(tc.cfg)
+				assert.Error(t, err)
+			})
+		}
+	})
+}
@@ -1,81 +1,198 @@
 package migration
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"myapp/migrations"
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
 	"go.uber.org/zap"
 )
 
-// RunMigrations runs database migrations
-func RunMigrations(databaseURL string, logger *zap.Logger) error {
-	m, err := migrate.New(
-		"file://migrations",
-		databaseURL,
-	)
+// Status describes a migration and whether it has been applied
+type Status struct {
+	Version uint   `json:"version"`
+	Name    string `json:"name"`
+	Applied bool   `json:"applied"`
+	// Dirty marks the current version when it failed halfway and needs to be forced
+	Dirty bool `json:"dirty,omitempty"`
+}
+
+// Migrator applies the SQL migrations from a source to a database
+type Migrator struct {
+	m      *migrate.Migrate
+	source source.Driver
+	logger *zap.Logger
+}
+
+// Open creates a migrator for the database at databaseURL. It uses the
+// migrations embedded in the binary, or those in dir if dir is not empty.
+func Open(dir, databaseURL string, logger *zap.Logger) (*Migrator, error) {
+	if dir == "" {
+		return NewFromFS(migrations.FS, databaseURL, logger)
+	}
+	return New("file://"+dir, databaseURL, logger)
+}
+
+// New creates a migrator for the migrations at sourceURL and the database at databaseURL
+func New(sourceURL, databaseURL string, logger *zap.Logger) (*Migrator, error) {
+	src, err := source.Open(sourceURL)
 	if err != nil {
-		return fmt.Errorf("failed to create migrate instance: %w", err)
+		return nil, fmt.Errorf("failed to open migration source: %w", err)
 	}
-	defer m.Close()
+	return newWithSource(src, databaseURL, logger)
+}
+
+// NewFromFS creates a migrator for the migrations at the root of fsys
+func NewFromFS(fsys fs.FS, databaseURL string, logger *zap.Logger) (*Migrator, error) {
+	src, err := iofs.New(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open migration source: %w", err)
+	}
+	return newWithSource(src, databaseURL, logger)
+}
 
-	// Run migrations
-	if err := m.Up(); err != nil {
-		if err == migrate.ErrNoChange {
-			logger.Info("No new migrations to apply")
+// newWithSource creates a migrator that takes ownership of src
+func newWithSource(src source.Driver, databaseURL string, logger *zap.Logger) (*Migrator, error) {
+	m, err := migrate.NewWithSourceInstance("migrations", src, databaseURL)
+	if err != nil {
+		src.Close()
+		return nil, fmt.Errorf("failed to create migrate instance: %w", err)
+	}
+	return &Migrator{m: m, source: src, logger: logger}, nil
+}
+
+// Close releases the source and the database connection
+func (m *Migrator) Close() error {
+	sourceErr, databaseErr := m.m.Close()
+	return errors.Join(sourceErr, databaseErr)
+}
+
+// Up applies all pending migrations
+func (m *Migrator) Up() error {
+	if err := m.m.Up(); err != nil {
+		if errors.Is(err, migrate.ErrNoChange) {
+			m.logger.Info("No new migrations to apply")
 			return nil
 		}
 		return fmt.Errorf("failed to run migrations: %w", err)
 	}
-
-	logger.Info("Migrations applied successfully")
+	m.logger.Info("Migrations applied successfully")
 	return nil
 }
 
-// RollbackMigration rolls back the last migration
-func RollbackMigration(databaseURL string, logger *zap.Logger) error {
-	m, err := migrate.New(
-		"file://migrations",
-		databaseURL,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to create migrate instance: %w", err)
+// Down rolls back the last n migrations
+func (m *Migrator) Down(n int) error {
+	if n <= 0 {
+		return fmt.Errorf("number of migrations to roll back must be positive, got %d", n)
 	}
-	defer m.Close()
+	if err := m.m.Steps(-n); err != nil {
+		if errors.Is(err, migrate.ErrNoChange) {
+			m.logger.Info("No migrations to rollback")
+			return nil
+		}
+		return fmt.Errorf("failed to rollback migrations: %w", err)
+	}
+	m.logger.Info("Migrations rolled back successfully", zap.Int("steps", n))
+	return nil
+}
 
-	// Rollback one step
-	if err := m.Steps(-1); err != nil {
-		if err == migrate.ErrNoChange {
-			logger.Info("No migrations to rollback")
+// Goto migrates up or down to the given version
+func (m *Migrator) Goto(version uint) error {
+	if err := m.m.Migrate(version); err != nil {
+		if errors.Is(err, migrate.ErrNoChange) {
+			m.logger.Info("Already at target version", zap.Uint("version", version))
 			return nil
 		}
-		return fmt.Errorf("failed to rollback migration: %w", err)
+		return fmt.Errorf("failed to migrate to version %d: %w", version, err)
 	}
+	m.logger.Info("Migrated to version successfully", zap.Uint("version", version))
+	return nil
+}
 
-	logger.Info("Migration rolled back successfully")
+// Version returns the current version and whether it is dirty. Version is 0 if
+// no migration has been applied yet.
+func (m *Migrator) Version() (uint, bool, error) {
+	version, dirty, err := m.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read migration version: %w", err)
+	}
+	return version, dirty, nil
+}
+
+// Force sets the version without running migrations, clearing the dirty flag.
+// A version of -1 marks the database as having no migrations applied.
+func (m *Migrator) Force(version int) error {
+	if err := m.m.Force(version); err != nil {
+		return fmt.Errorf("failed to force version %d: %w", version, err)
+	}
+	m.logger.Warn("Migration version forced", zap.Int("version", version))
 	return nil
 }
 
-// MigrateToVersion migrates to a specific version
-func MigrateToVersion(databaseURL string, version uint, logger *zap.Logger) error {
-	m, err := migrate.New(
-		"file://migrations",
-		databaseURL,
-	)
+// Status lists every available migration and whether it has been applied
+func (m *Migrator) Status() ([]Status, error) {
+	current, dirty, err := m.m.Version()
+	applied := err == nil
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return nil, fmt.Errorf("failed to read migration version: %w", err)
+	}
+
+	var statuses []Status
+	version, err := m.source.First()
+	for err == nil {
+		status := Status{
+			Version: version,
+			Applied: applied && version <= current,
+			Dirty:   dirty && version == current,
+		}
+		if r, identifier, readErr := m.source.ReadUp(version); readErr == nil {
+			r.Close()
+			status.Name = identifier
+		}
+		statuses = append(statuses, status)
+		version, err = m.source.Next(version)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+	return statuses, nil
+}
+
+// RunMigrations runs the embedded database migrations
+func RunMigrations(databaseURL string, logger *zap.Logger) error {
+	m, err := Open("", databaseURL, logger)
 	if err != nil {
-		return fmt.Errorf("failed to create migrate instance: %w", err)
+		return err
 	}
 	defer m.Close()
+	return m.Up()
+}
 
-	if err := m.Migrate(version); err != nil {
-		if err == migrate.ErrNoChange {
-			logger.Info("Already at target version", zap.Uint("version", version))
-			return nil
-		}
-		return fmt.Errorf("failed to migrate to version %d: %w", version, err)
+// RollbackMigration rolls back the last migration
+func RollbackMigration(databaseURL string, logger *zap.Logger) error {
+	m, err := Open("", databaseURL, logger)
+	if err != nil {
+		return err
 	}
+	defer m.Close()
+	return m.Down(1)
+}
 
-	logger.Info("Migrated to version successfully", zap.Uint("version", version))
-	return nil
+// MigrateToVersion migrates to a specific version
+func MigrateToVersion(databaseURL string, version uint, logger *zap.Logger) error {
+	m, err := Open("", databaseURL, logger)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	return m.Goto(version)
 }
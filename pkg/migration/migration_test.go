@@ -0,0 +1,126 @@
+package migration
+
+import (
+	"io/fs"
+	"myapp/migrations"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/golang-migrate/migrate/v4/database/stub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// newTestMigrator creates a migrator for three migration files and an in-memory stub database
+func newTestMigrator(t *testing.T) *Migrator {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"000001_create_users.up.sql", "000001_create_users.down.sql",
+		"000002_add_roles.up.sql", "000002_add_roles.down.sql",
+		"000005_add_audit.up.sql", "000005_add_audit.down.sql",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("SELECT 1;"), 0o600))
+	}
+
+	m, err := New("file://"+dir, "stub://", zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+func TestMigrator(t *testing.T) {
+	t.Run("should report version 0 before the first migration", func(t *testing.T) {
+		m := newTestMigrator(t)
+
+		version, dirty, err := m.Version()
+		require.NoError(t, err)
+		assert.Zero(t, version)
+		assert.False(t, dirty)
+
+		statuses, err := m.Status()
+		require.NoError(t, err)
+		assert.Equal(t, []Status{
+			{Version: 1, Name: "create_users"},
+			{Version: 2, Name: "add_roles"},
+			{Version: 5, Name: "add_audit"},
+		}, statuses)
+	})
+
+	t.Run("should apply, roll back and go to versions", func(t *testing.T) {
+		m := newTestMigrator(t)
+
+		require.NoError(t, m.Up())
+		require.NoError(t, m.Up(), "no change is not an error")
+		version, _, err := m.Version()
+		require.NoError(t, err)
+		assert.Equal(t, uint(5), version)
+
+		require.NoError(t, m.Down(2))
+		version, _, _ = m.Version()
+		assert.Equal(t, uint(1), version)
+
+		require.NoError(t, m.Goto(2))
+		statuses, err := m.Status()
+		require.NoError(t, err)
+		assert.True(t, statuses[0].Applied)
+		assert.True(t, statuses[1].Applied)
+		assert.False(t, statuses[2].Applied)
+
+		assert.Error(t, m.Down(0))
+		assert.Error(t, m.Goto(3), "version 3 does not exist")
+	})
+
+	t.Run("should force a version", func(t *testing.T) {
+		m := newTestMigrator(t)
+
+		require.NoError(t, m.Force(2))
+		version, dirty, err := m.Version()
+		require.NoError(t, err)
+		assert.Equal(t, uint(2), version)
+		assert.False(t, dirty)
+
+		require.NoError(t, m.Force(-1))
+		version, _, err = m.Version()
+		require.NoError(t, err)
+		assert.Zero(t, version)
+	})
+}
+
+func TestOpen(t *testing.T) {
+	t.Run("should use the embedded migrations by default", func(t *testing.T) {
+		m, err := Open("", "stub://", zap.NewNop())
+		require.NoError(t, err)
+		defer m.Close()
+
+		upFiles, err := fs.Glob(migrations.FS, "*.up.sql")
+		require.NoError(t, err)
+		statuses, err := m.Status()
+		require.NoError(t, err)
+		require.Len(t, statuses, len(upFiles))
+		assert.Equal(t, Status{Version: 1, Name: "create_users_table"}, statuses[0])
+		for i, status := range statuses {
+			assert.True(t, strings.HasSuffix(upFiles[i], "_"+status.Name+".up.sql"), upFiles[i])
+		}
+	})
+
+	t.Run("should load migrations from a directory when given", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "000042_local_change.up.sql"), []byte("SELECT 1;"), 0o600))
+
+		m, err := Open(dir, "stub://", zap.NewNop())
+		require.NoError(t, err)
+		defer m.Close()
+
+		statuses, err := m.Status()
+		require.NoError(t, err)
+		assert.Equal(t, []Status{{Version: 42, Name: "local_change"}}, statuses)
+	})
+
+	t.Run("should fail for a missing directory", func(t *testing.T) {
+		_, err := Open(filepath.Join(t.TempDir(), "missing"), "stub://", zap.NewNop())
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,28 @@
+package notification
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// LogNotifier writes messages to the application log.
+// Message bodies may contain secrets such as reset links; use it for local development only.
+type LogNotifier struct {
+	logger *zap.Logger
+}
+
+// NewLogNotifier creates a notifier that logs every message
+func NewLogNotifier(logger *zap.Logger) *LogNotifier {
+	return &LogNotifier{logger: logger}
+}
+
+// Send logs the message
+func (n *LogNotifier) Send(ctx context.Context, msg Message) error {
+	n.logger.Info("notification",
+		zap.String("to", msg.To),
+		zap.String("subject", msg.Subject),
+		zap.String("body", msg.Body),
+	)
+	return nil
+}
@@ -0,0 +1,43 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"myapp/pkg/config"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// DriverLog writes notifications to the application log
+	DriverLog = "log"
+	// DriverFile appends notifications to a local file
+	DriverFile = "file"
+)
+
+// Message is a notification addressed to a single recipient
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Notifier delivers messages to users, e.g. by email
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New creates the Notifier for the configured driver, defaulting to the log driver
+func New(cfg config.NotificationConfig, logger *zap.Logger) (Notifier, error) {
+	switch cfg.Driver {
+	case DriverLog, "":
+		return NewLogNotifier(logger), nil
+	case DriverFile:
+		if cfg.FilePath == "" {
+			return nil, fmt.Errorf("notification.file_path is required for the %q driver", DriverFile)
+		}
+		return NewFileNotifier(cfg.FilePath), nil
+	default:
+		return nil, fmt.Errorf("unsupported notification driver %q", cfg.Driver)
+	}
+}
@@ -0,0 +1,67 @@
+package notification
+
+import (
+	"context"
+	"myapp/pkg/config"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("should default to log notifier", func(t *testing.T) {
+		n, err := New(config.NotificationConfig{}, zap.NewNop())
+		require.NoError(t, err)
+		assert.IsType(t, &LogNotifier{}, n)
+	})
+
+	t.Run("should create file notifier", func(t *testing.T) {
+		n, err := New(config.NotificationConfig{Driver: DriverFile, FilePath: "out.log"}, zap.NewNop())
+		require.NoError(t, err)
+		assert.IsType(t, &FileNotifier{}, n)
+	})
+
+	t.Run("should require a file path for the file driver", func(t *testing.T) {
+		_, err := New(config.NotificationConfig{Driver: DriverFile}, zap.NewNop())
+		assert.Error(t, err)
+	})
+
+	t.Run("should reject unknown driver", func(t *testing.T) {
+		_, err := New(config.NotificationConfig{Driver: "smtp"}, zap.NewNop())
+		assert.Error(t, err)
+	})
+}
+
+func TestLogNotifier(t *testing.T) {
+	t.Run("should log the message", func(t *testing.T) {
+		core, logs := observer.New(zap.InfoLevel)
+		n := NewLogNotifier(zap.New(core))
+
+		require.NoError(t, n.Send(context.Background(), Message{To: "alice@example.com", Subject: "Hello", Body: "Hi"}))
+
+		require.Equal(t, 1, logs.Len())
+		fields := logs.All()[0].ContextMap()
+		assert.Equal(t, "alice@example.com", fields["to"])
+		assert.Equal(t, "Hello", fields["subject"])
+	})
+}
+
+func TestFileNotifier(t *testing.T) {
+	t.Run("should append messages to the file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "mail", "outbox.log")
+		n := NewFileNotifier(path)
+
+		require.NoError(t, n.Send(context.Background(), Message{To: "alice@example.com", Subject: "First", Body: "one"}))
+		require.NoError(t, n.Send(context.Background(), Message{To: "bob@example.com", Subject: "Second", Body: "two"}))
+
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Contains(t, string(content), "To: alice@example.com\nSubject: First\n\none")
+		assert.Contains(t, string(content), "To: bob@example.com\nSubject: Second\n\ntwo")
+	})
+}
@@ -0,0 +1,168 @@
+// Package problem renders API errors as RFC 9457 problem details
+// (application/problem+json) with a stable, machine-readable code.
+package problem
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContentType is the media type of problem details responses
+const ContentType = "application/problem+json"
+
+// Stable error codes. Clients may rely on them; the detail text may change.
+const (
+	CodeInvalidRequest           = "invalid_request"
+	CodeValidationFailed         = "validation_failed"
+	CodeInvalidUserID            = "invalid_user_id"
+	CodeAuthorizationRequired    = "authorization_required"
+	CodeInvalidToken             = "invalid_token"
+	CodeTokenRevoked             = "token_revoked"
+	CodeTokenNotRevocable        = "token_not_revocable"
+	CodeInvalidCredentials       = "invalid_credentials"
+	CodeIncorrectPassword        = "incorrect_password"
+	CodeInvalidResetToken        = "invalid_reset_token"
+	CodeInvalidVerificationToken = "invalid_verification_token"
+	CodeEmailNotVerified         = "email_not_verified"
+	CodeInvalidMFAToken          = "invalid_mfa_token"
+	CodeInvalidCode              = "invalid_code"
+	CodeInvalidRefreshToken      = "invalid_refresh_token"
+	CodeRefreshTokenExpired      = "refresh_token_expired"
+	CodeMFAAlreadyEnabled        = "mfa_already_enabled"
+	CodeMFANotEnabled            = "mfa_not_enabled"
+	CodeNoPendingEnrollment      = "no_pending_enrollment"
+	CodeLoginLocked              = "login_locked"
+	CodeInvalidLockoutScope      = "invalid_lockout_scope"
+	CodeLockoutNotFound          = "lockout_not_found"
+	CodeForbidden                = "forbidden"
+	CodeSelfDemotion             = "self_demotion"
+	CodeUserNotFound             = "user_not_found"
+	CodeEmailTaken               = "email_taken"
+	CodeConflict                 = "conflict"
+	CodeLastAdmin                = "last_admin"
+	CodePreconditionFailed       = "precondition_failed"
+	CodeUnknownRole              = "unknown_role"
+	CodeInvalidRoleID            = "invalid_role_id"
+	CodeRoleNotFound             = "role_not_found"
+	CodeRoleExists               = "role_exists"
+	CodeRoleNotBound             = "role_not_bound"
+	CodeRoleInUse                = "role_in_use"
+	CodeBuiltinRole              = "builtin_role"
+	CodeUnknownPermission        = "unknown_permission"
+	CodeInvalidPatch             = "invalid_patch"
+	CodePatchTestFailed          = "patch_test_failed"
+	CodeUnsupportedMediaType     = "unsupported_media_type"
+	CodeRateLimitExceeded        = "rate_limit_exceeded"
+	CodeInternal                 = "internal_error"
+	CodeUnavailable              = "service_unavailable"
+)
+
+// Problem is the RFC 9457 problem details body, extended with the error code,
+// the request ID and per-field validation errors
+type Problem struct {
+	Type      string       `json:"type" example:"about:blank"`
+	Title     string       `json:"title" example:"Bad Request"`
+	Status    int          `json:"status" example:"400"`
+	Detail    string       `json:"detail,omitempty" example:"request validation failed"`
+	Instance  string       `json:"instance,omitempty" example:"/v1/users"`
+	Code      string       `json:"code" example:"validation_failed"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describes why a single request field was rejected
+type FieldError struct {
+	Field  string `json:"field" example:"email"`
+	Code   string `json:"code" example:"email"`
+	Detail string `json:"detail" example:"must be a valid email address"`
+}
+
+// Error is an API error with the HTTP status and code it is rendered with
+type Error struct {
+	Status int
+	Code   string
+	Detail string
+	Fields []FieldError
+}
+
+// New creates an error rendered with status and code
+func New(status int, code, detail string) *Error {
+	return &Error{Status: status, Code: code, Detail: detail}
+}
+
+// BadRequest creates a 400 error
+func BadRequest(code, detail string) *Error {
+	return New(http.StatusBadRequest, code, detail)
+}
+
+// Unauthorized creates a 401 error
+func Unauthorized(code, detail string) *Error {
+	return New(http.StatusUnauthorized, code, detail)
+}
+
+// Forbidden creates a 403 error
+func Forbidden(code, detail string) *Error {
+	return New(http.StatusForbidden, code, detail)
+}
+
+// NotFound creates a 404 error
+func NotFound(code, detail string) *Error {
+	return New(http.StatusNotFound, code, detail)
+}
+
+// Conflict creates a 409 error
+func Conflict(code, detail string) *Error {
+	return New(http.StatusConflict, code, detail)
+}
+
+// PreconditionFailed creates a 412 error for failed conditional requests
+func PreconditionFailed(code, detail string) *Error {
+	return New(http.StatusPreconditionFailed, code, detail)
+}
+
+// UnsupportedMediaType creates a 415 error for request bodies of an unsupported Content-Type
+func UnsupportedMediaType(code, detail string) *Error {
+	return New(http.StatusUnsupportedMediaType, code, detail)
+}
+
+// TooManyRequests creates a 429 error; set Retry-After separately
+func TooManyRequests(code, detail string) *Error {
+	return New(http.StatusTooManyRequests, code, detail)
+}
+
+// Internal creates a 500 error. The detail must not contain the cause.
+func Internal(detail string) *Error {
+	return New(http.StatusInternalServerError, CodeInternal, detail)
+}
+
+// Unavailable creates a 503 error for failing dependencies
+func Unavailable(detail string) *Error {
+	return New(http.StatusServiceUnavailable, CodeUnavailable, detail)
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Detail
+}
+
+// Render aborts the request with err as problem details. Errors other than
+// *Error are rendered as a generic 500 so that their text never leaks.
+func Render(c *gin.Context, err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		e = Internal("internal server error")
+	}
+
+	c.Header("Content-Type", ContentType)
+	c.AbortWithStatusJSON(e.Status, Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(e.Status),
+		Status:    e.Status,
+		Detail:    e.Detail,
+		Instance:  c.Request.URL.Path,
+		Code:      e.Code,
+		RequestID: c.GetString("request_id"),
+		Errors:    e.Fields,
+	})
+}
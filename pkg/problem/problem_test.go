@@ -0,0 +1,158 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type signupRequest struct {
+	Name     string `json:"name" binding:"required"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,min=6"`
+	Age      int    `json:"age" binding:"omitempty,max=150"`
+}
+
+type listQuery struct {
+	Limit int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	Order string `form:"order" binding:"omitempty,oneof=asc desc"`
+}
+
+// render runs handler behind a middleware that sets a request ID like LoggingMiddleware
+func render(t *testing.T, target, body string, handler gin.HandlerFunc) (*httptest.ResponseRecorder, Problem) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("request_id", "req-1")
+		c.Next()
+	})
+	router.Any("/test", handler)
+
+	req, _ := http.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var p Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p), w.Body.String())
+	return w, p
+}
+
+func TestRender(t *testing.T) {
+	t.Run("should render problem details with code and request ID", func(t *testing.T) {
+		w, p := render(t, "/test", "", func(c *gin.Context) {
+			Render(c, NotFound(CodeUserNotFound, "user not found"))
+		})
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+		assert.Equal(t, Problem{
+			Type:      "about:blank",
+			Title:     "Not Found",
+			Status:    http.StatusNotFound,
+			Detail:    "user not found",
+			Instance:  "/test",
+			Code:      CodeUserNotFound,
+			RequestID: "req-1",
+		}, p)
+	})
+
+	t.Run("should hide the text of unknown errors", func(t *testing.T) {
+		w, p := render(t, "/test", "", func(c *gin.Context) {
+			Render(c, errors.New("pq: connection refused"))
+		})
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, CodeInternal, p.Code)
+		assert.NotContains(t, w.Body.String(), "connection refused")
+	})
+
+	t.Run("should abort the handler chain", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			Render(c, Unauthorized(CodeInvalidToken, "invalid token"))
+		})
+		called := false
+		router.GET("/test", func(c *gin.Context) { called = true })
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/test", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.False(t, called)
+	})
+}
+
+func TestFromBinding(t *testing.T) {
+	bindJSON := func(c *gin.Context) {
+		var req signupRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			Render(c, FromBinding(err))
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+
+	t.Run("should report every invalid field by its JSON name", func(t *testing.T) {
+		w, p := render(t, "/test", `{"email":"nope","password":"123"}`, bindJSON)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, CodeValidationFailed, p.Code)
+		assert.Equal(t, []FieldError{
+			{Field: "name", Code: "required", Detail: "is required"},
+			{Field: "email", Code: "email", Detail: "must be a valid email address"},
+			{Field: "password", Code: "min", Detail: "must be at least 6 characters"},
+		}, p.Errors)
+		assert.NotContains(t, w.Body.String(), "signupRequest")
+	})
+
+	t.Run("should report fields of the wrong type", func(t *testing.T) {
+		_, p := render(t, "/test", `{"name":"a","email":"a@example.com","password":"secret1","age":"old"}`, bindJSON)
+
+		assert.Equal(t, CodeValidationFailed, p.Code)
+		assert.Equal(t, []FieldError{{Field: "age", Code: "type", Detail: "must be of type int"}}, p.Errors)
+	})
+
+	t.Run("should summarize malformed and missing bodies", func(t *testing.T) {
+		_, p := render(t, "/test", `{"name":`, bindJSON)
+		assert.Equal(t, CodeInvalidRequest, p.Code)
+		assert.Equal(t, "request body is not valid JSON", p.Detail)
+		assert.Empty(t, p.Errors)
+
+		_, p = render(t, "/test", "", bindJSON)
+		assert.Equal(t, CodeInvalidRequest, p.Code)
+		assert.Equal(t, "request body is required", p.Detail)
+	})
+
+	t.Run("should report query parameters by their form name", func(t *testing.T) {
+		_, p := render(t, "/test?limit=500&order=up", "", func(c *gin.Context) {
+			var q listQuery
+			Render(c, FromBinding(c.ShouldBindQuery(&q)))
+		})
+
+		assert.Equal(t, []FieldError{
+			{Field: "limit", Code: "max", Detail: "must be at most 100"},
+			{Field: "order", Code: "oneof", Detail: "must be one of: asc, desc"},
+		}, p.Errors)
+	})
+
+	t.Run("should not leak parser errors", func(t *testing.T) {
+		w, p := render(t, "/test?limit=abc", "", func(c *gin.Context) {
+			var q listQuery
+			Render(c, FromBinding(c.ShouldBindQuery(&q)))
+		})
+
+		assert.Equal(t, CodeInvalidRequest, p.Code)
+		assert.NotContains(t, w.Body.String(), "strconv")
+	})
+}
@@ -0,0 +1,101 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+func init() {
+	// Report fields by the name clients send instead of the Go field name
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(fieldName)
+	}
+}
+
+// fieldName returns the JSON name of a field, or its form name for query parameters
+func fieldName(f reflect.StructField) string {
+	for _, tag := range []string{"json", "form", "uri"} {
+		name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return f.Name
+}
+
+// FromBinding converts an error of c.ShouldBind* into a 400 error. Validation
+// failures are reported per field; other errors are summarized so that parser
+// messages do not leak to clients.
+func FromBinding(err error) *Error {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		fields := make([]FieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			fields = append(fields, FieldError{Field: fe.Field(), Code: fe.Tag(), Detail: validationDetail(fe)})
+		}
+		return &Error{
+			Status: http.StatusBadRequest,
+			Code:   CodeValidationFailed,
+			Detail: "request validation failed",
+			Fields: fields,
+		}
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return &Error{
+			Status: http.StatusBadRequest,
+			Code:   CodeValidationFailed,
+			Detail: "request validation failed",
+			Fields: []FieldError{{
+				Field:  typeErr.Field,
+				Code:   "type",
+				Detail: "must be of type " + typeErr.Type.Kind().String(),
+			}},
+		}
+	}
+
+	if errors.Is(err, io.EOF) {
+		return BadRequest(CodeInvalidRequest, "request body is required")
+	}
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return BadRequest(CodeInvalidRequest, "request body is not valid JSON")
+	}
+	return BadRequest(CodeInvalidRequest, "request could not be parsed")
+}
+
+// validationDetail describes a failed validation rule in plain words
+func validationDetail(fe validator.FieldError) string {
+	unit := ""
+	if fe.Kind() == reflect.String {
+		unit = " characters"
+	}
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "min":
+		return fmt.Sprintf("must be at least %s%s", fe.Param(), unit)
+	case "max":
+		return fmt.Sprintf("must be at most %s%s", fe.Param(), unit)
+	case "len":
+		return fmt.Sprintf("must be exactly %s%s", fe.Param(), unit)
+	case "oneof":
+		return "must be one of: " + strings.Join(strings.Fields(fe.Param()), ", ")
+	default:
+		return "is invalid"
+	}
+}
@@ -0,0 +1,114 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the SHA1 test key from RFC 6238 appendix B
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	t.Run("should match RFC 6238 test vectors", func(t *testing.T) {
+		testCases := []struct {
+			unix int64
+			code string
+		}{
+			{59, "287082"},
+			{1111111109, "081804"},
+			{1111111111, "050471"},
+			{1234567890, "005924"},
+			{2000000000, "279037"},
+			{20000000000, "353130"},
+		}
+
+		for _, tc := range testCases {
+			code, err := Code(rfcSecret, time.Unix(tc.unix, 0))
+			require.NoError(t, err)
+			assert.Equal(t, tc.code, code, "time %d", tc.unix)
+		}
+	})
+
+	t.Run("should reject invalid secret", func(t *testing.T) {
+		_, err := Code("not base32!", time.Now())
+		assert.Error(t, err)
+	})
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+
+	t.Run("should accept current code and return its step", func(t *testing.T) {
+		step, ok := Validate(rfcSecret, "050471", now, 1)
+		assert.True(t, ok)
+		assert.Equal(t, Step(now), step)
+	})
+
+	t.Run("should accept codes within the skew", func(t *testing.T) {
+		previous, _ := Code(rfcSecret, now.Add(-Period))
+		next, _ := Code(rfcSecret, now.Add(Period))
+
+		_, ok := Validate(rfcSecret, previous, now, 1)
+		assert.True(t, ok)
+		_, ok = Validate(rfcSecret, next, now, 1)
+		assert.True(t, ok)
+	})
+
+	t.Run("should reject codes outside the skew", func(t *testing.T) {
+		old, _ := Code(rfcSecret, now.Add(-2*Period))
+
+		_, ok := Validate(rfcSecret, old, now, 1)
+		assert.False(t, ok)
+	})
+
+	t.Run("should reject malformed codes", func(t *testing.T) {
+		for _, code := range []string{"", "12345", "1234567", "abcdef"} {
+			_, ok := Validate(rfcSecret, code, now, 1)
+			assert.False(t, ok, code)
+		}
+	})
+
+	t.Run("should accept lower case secret with spaces", func(t *testing.T) {
+		secret := strings.ToLower(rfcSecret[:4] + " " + rfcSecret[4:])
+		_, ok := Validate(secret, "050471", now, 0)
+		assert.True(t, ok)
+	})
+}
+
+func TestGenerateSecret(t *testing.T) {
+	t.Run("should generate distinct 160-bit secrets", func(t *testing.T) {
+		a, err := GenerateSecret()
+		require.NoError(t, err)
+		b, err := GenerateSecret()
+		require.NoError(t, err)
+
+		assert.NotEqual(t, a, b)
+		assert.Len(t, a, 32)
+
+		code, err := Code(a, time.Now())
+		require.NoError(t, err)
+		assert.Len(t, code, Digits)
+	})
+}
+
+func TestURI(t *testing.T) {
+	t.Run("should build otpauth URI", func(t *testing.T) {
+		uri := URI("My App", "alice@example.com", "JBSWY3DPEHPK3PXP")
+
+		u, err := url.Parse(uri)
+		require.NoError(t, err)
+		assert.Equal(t, "otpauth", u.Scheme)
+		assert.Equal(t, "totp", u.Host)
+		assert.Equal(t, "/My App:alice@example.com", u.Path)
+		assert.Equal(t, "JBSWY3DPEHPK3PXP", u.Query().Get("secret"))
+		assert.Equal(t, "My App", u.Query().Get("issuer"))
+		assert.Equal(t, "6", u.Query().Get("digits"))
+		assert.Equal(t, "30", u.Query().Get("period"))
+	})
+}
@@ -1,9 +1,11 @@
 package utils
 
 import (
+	"math"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -11,6 +13,12 @@ const (
 	// BcryptCost is the cost factor for bcrypt hashing
 	// 12 provides a good balance between security and performance
 	BcryptCost = 12
+
+	// TokenUseAccess marks tokens that grant access to the API
+	TokenUseAccess = "access"
+	// TokenUseMFAChallenge marks tokens that only prove the password step of
+	// a login and must be exchanged together with a second factor
+	TokenUseMFAChallenge = "mfa_challenge"
 )
 
 // HashPassword creates a bcrypt hash of the password
@@ -27,12 +35,75 @@ func CheckPasswordHash(password, hash string) bool {
 
 // GenerateJWT creates a JWT token for a user
 func GenerateJWT(userID uint, role, secret string) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id": userID,
-		"role":    role,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
-	}
+	return GenerateJWTWithTTL(userID, role, secret, time.Hour*24)
+}
+
+// TokenSigner signs a set of JWT claims
+type TokenSigner interface {
+	Sign(claims jwt.Claims) (string, error)
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+// GenerateJWTWithTTL creates a JWT token for a user that expires after ttl.
+// Every token carries a unique jti claim so it can be revoked individually.
+func GenerateJWTWithTTL(userID uint, role, secret string, ttl time.Duration) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, newClaims(userID, role, ttl))
 	return token.SignedString([]byte(secret))
 }
+
+// SignJWT creates a JWT token for a user that expires after ttl using signer
+func SignJWT(userID uint, role string, signer TokenSigner, ttl time.Duration) (string, error) {
+	return signer.Sign(newClaims(userID, role, ttl))
+}
+
+// SignAccessToken creates a JWT token like SignJWT that also carries the
+// permissions of the user, so services can authorize without a lookup
+func SignAccessToken(userID uint, role string, permissions []string, signer TokenSigner, ttl time.Duration) (string, error) {
+	claims := newClaims(userID, role, ttl)
+	if permissions != nil {
+		claims["permissions"] = permissions
+	}
+	return signer.Sign(claims)
+}
+
+// SignMFAChallenge creates a token that identifies a user who passed the
+// password check but still has to present a second factor
+func SignMFAChallenge(userID uint, signer TokenSigner, ttl time.Duration) (string, error) {
+	now := time.Now()
+	return signer.Sign(jwt.MapClaims{
+		"jti":       uuid.New().String(),
+		"user_id":   userID,
+		"token_use": TokenUseMFAChallenge,
+		"iat":       issuedAtClaim(now),
+		"exp":       now.Add(ttl).Unix(),
+	})
+}
+
+// newClaims builds the standard claims of an access token
+func newClaims(userID uint, role string, ttl time.Duration) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"jti":       uuid.New().String(),
+		"user_id":   userID,
+		"role":      role,
+		"token_use": TokenUseAccess,
+		"iat":       issuedAtClaim(now),
+		"exp":       now.Add(ttl).Unix(),
+	}
+}
+
+// issuedAtClaim encodes now as a NumericDate with millisecond precision, so a
+// token issued right after a revocation of all of the user's tokens can be
+// told apart from one issued right before it
+func issuedAtClaim(now time.Time) float64 {
+	return float64(now.UnixMilli()) / 1e3
+}
+
+// IssuedAt returns the iat claim with its fractional seconds, or the zero time
+// if the claim is missing. Unlike MapClaims.GetIssuedAt it keeps sub-second precision.
+func IssuedAt(claims jwt.MapClaims) time.Time {
+	iat, ok := claims["iat"].(float64)
+	if !ok {
+		return time.Time{}
+	}
+	return time.UnixMilli(int64(math.Round(iat * 1e3)))
+}
@@ -2,7 +2,9 @@ package utils
 
 import (
 	"testing"
+	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -47,3 +49,51 @@ func TestGenerateJWT(t *testing.T) {
 		assert.NotEmpty(t, token)
 	})
 }
+
+func TestGenerateJWTWithTTL(t *testing.T) {
+	t.Run("should embed expiry based on ttl", func(t *testing.T) {
+		token, err := GenerateJWTWithTTL(1, "user", "test-secret", 5*time.Minute)
+		assert.NoError(t, err)
+
+		parsed, err := jwt.Parse(token, func(token *jwt.Token) (any, error) {
+			return []byte("test-secret"), nil
+		})
+		assert.NoError(t, err)
+
+		exp, err := parsed.Claims.GetExpirationTime()
+		assert.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(5*time.Minute), exp.Time, 5*time.Second)
+	})
+}
+
+func TestGenerateJWTClaims(t *testing.T) {
+	t.Run("should include unique jti and iat claims", func(t *testing.T) {
+		first, err := GenerateJWT(1, "user", "test-secret")
+		assert.NoError(t, err)
+		second, err := GenerateJWT(1, "user", "test-secret")
+		assert.NoError(t, err)
+
+		parse := func(token string) jwt.MapClaims {
+			claims := jwt.MapClaims{}
+			_, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (any, error) {
+				return []byte("test-secret"), nil
+			})
+			assert.NoError(t, err)
+			return claims
+		}
+
+		firstClaims := parse(first)
+		secondClaims := parse(second)
+		assert.NotEmpty(t, firstClaims["jti"])
+		assert.NotEqual(t, firstClaims["jti"], secondClaims["jti"])
+		assert.NotNil(t, firstClaims["iat"])
+	})
+
+	t.Run("should keep milliseconds of the iat claim", func(t *testing.T) {
+		now := time.Date(2024, 1, 1, 12, 0, 0, int(250*time.Millisecond+400*time.Microsecond), time.UTC)
+		claims := jwt.MapClaims{"iat": issuedAtClaim(now)}
+
+		assert.True(t, now.Truncate(time.Millisecond).Equal(IssuedAt(claims)))
+		assert.True(t, IssuedAt(jwt.MapClaims{}).IsZero())
+	})
+}
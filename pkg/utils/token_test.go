@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateOpaqueToken(t *testing.T) {
+	t.Run("should generate unique tokens", func(t *testing.T) {
+		first, err := GenerateOpaqueToken()
+		assert.NoError(t, err)
+		assert.NotEmpty(t, first)
+
+		second, err := GenerateOpaqueToken()
+		assert.NoError(t, err)
+		assert.NotEqual(t, first, second)
+	})
+}
+
+func TestHashToken(t *testing.T) {
+	t.Run("should hash deterministically", func(t *testing.T) {
+		assert.Equal(t, HashToken("token"), HashToken("token"))
+		assert.Len(t, HashToken("token"), 64)
+	})
+
+	t.Run("should not return the token itself", func(t *testing.T) {
+		assert.NotEqual(t, "token", HashToken("token"))
+		assert.NotEqual(t, HashToken("token"), HashToken("other"))
+	})
+}
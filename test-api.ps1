@@ -2,6 +2,8 @@
 # This script demonstrates all API endpoints
 
 $BaseUrl = "http://localhost:8080"
+# Gives a registered user the admin role; signups cannot choose their role
+$PromoteAdmin = if ($env:PROMOTE_ADMIN) { $env:PROMOTE_ADMIN } else { "go run ./cmd/server promote-admin" }
 
 Write-Host "================================"
 Write-Host "User Management API Test Script"
@@ -105,7 +107,6 @@ $userBody = @{
     name = "John Doe"
     email = "john@example.com"
     password = "password123"
-    role = "user"
 } | ConvertTo-Json
 
 try {
@@ -126,7 +127,6 @@ $adminBody = @{
     name = "Admin User"
     email = "admin@example.com"
     password = "admin123"
-    role = "admin"
 } | ConvertTo-Json
 
 try {
@@ -139,6 +139,11 @@ try {
     Write-Host "Error: $($_.Exception.Message)" -ForegroundColor Red
     $script:ErrorCount++
 }
+Invoke-Expression "$PromoteAdmin admin@example.com"
+if ($LASTEXITCODE -ne 0) {
+    Write-Host "Error: failed to promote admin@example.com" -ForegroundColor Red
+    $script:ErrorCount++
+}
 Write-Host ""
 
 # Login as admin
@@ -4,6 +4,8 @@
 # This script demonstrates all API endpoints
 
 BASE_URL="http://localhost:8080"
+# Gives a registered user the admin role; signups cannot choose their role
+PROMOTE_ADMIN="${PROMOTE_ADMIN:-go run ./cmd/server promote-admin}"
 
 echo "================================"
 echo "User Management API Test Script"
@@ -88,8 +90,7 @@ USER_RESPONSE=$(curl -s -X POST "$BASE_URL/v1/users" \
   -d '{
     "name": "John Doe",
     "email": "john@example.com",
-    "password": "password123",
-    "role": "user"
+    "password": "password123"
   }')
 if check_response "$USER_RESPONSE" "create user"; then
     echo "$USER_RESPONSE" | jq .
@@ -104,13 +105,16 @@ ADMIN_RESPONSE=$(curl -s -X POST "$BASE_URL/v1/users" \
   -d '{
     "name": "Admin User",
     "email": "admin@example.com",
-    "password": "admin123",
-    "role": "admin"
+    "password": "admin123"
   }')
 if check_response "$ADMIN_RESPONSE" "create admin"; then
     echo "$ADMIN_RESPONSE" | jq .
     ADMIN_ID=$(echo "$ADMIN_RESPONSE" | jq -r '.id')
 fi
+if ! $PROMOTE_ADMIN admin@example.com; then
+    echo -e "${RED}ERROR: failed to promote admin@example.com${NC}"
+    ((ERROR_COUNT++))
+fi
 echo ""
 
 # Login as admin